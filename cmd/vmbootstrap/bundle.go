@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bundle"
+	pkgconfig "github.com/infrakit-io/vmware-vm-bootstrap/pkg/config"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	talosprofile "github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile/talos"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+	"github.com/spf13/cobra"
+)
+
+var offlineMode bool
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Offline artifact bundles for sites without internet access (export, import)",
+	Long: "Move everything a set of VMs downloads to an air-gapped site in one tar file:\n" +
+		"Ubuntu/Talos ISOs, Talos OVAs, checksum files, signing keys, release catalogs,\n" +
+		"Talos schematics and optionally an apt mirror. After `bundle import` downloads\n" +
+		"resolve from the bundle; set --offline (iso.offline) to never fall back to the network.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var bundleExportCmd = &cobra.Command{
+	Use:           "export VM_CONFIG...",
+	Short:         "Download the artifacts of VM configs into a bundle",
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		aptMirror, _ := cmd.Flags().GetString("apt-mirror")
+		schematics, _ := cmd.Flags().GetString("schematics")
+		workDir, _ := cmd.Flags().GetString("work-dir")
+		return exportBundle(cmd.Context(), output, args, aptMirror, schematics, workDir)
+	},
+}
+
+var bundleImportCmd = &cobra.Command{
+	Use:   "import BUNDLE",
+	Short: "Import a bundle into the cache (and the content library)",
+	Long: "Verify a bundle against its manifest, keep its files in <cache>/bundle and seed\n" +
+		"the ISO cache from it. With --content-library, Talos OVAs are imported into the\n" +
+		"content library of the vCenter config (content_library, default talos-images).",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		toLibrary, _ := cmd.Flags().GetBool("content-library")
+		noSeed, _ := cmd.Flags().GetBool("no-seed")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		return importBundle(cmd.Context(), args[0], !noSeed, toLibrary, timeout)
+	},
+}
+
+func exportBundle(ctx context.Context, output string, vmConfigs []string, aptMirror, schematics, workDir string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if output == "" {
+		return &userError{msg: "no output file", hint: "vmbootstrap bundle export -o site.tar configs/vm.*.sops.yaml"}
+	}
+	if workDir == "" {
+		tmp, err := os.MkdirTemp("", "vmbootstrap-bundle-")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		workDir = tmp
+	}
+
+	c := bundle.NewCollector(ctx, workDir)
+	if err := c.SetCacheDir(cacheDir); err != nil {
+		return err
+	}
+	for _, path := range vmConfigs {
+		cfg, err := loadBundleVMConfig(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %s: %s %s\n", filepath.Base(path), cfg.EffectiveProfile(), cfg.EffectiveOSVersion())
+		if err := c.AddVM(cfg); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := c.AddCatalogs(); err != nil {
+		return err
+	}
+	if schematics == "" {
+		if _, err := os.Stat(talosSchematicsConfigFile); err == nil {
+			schematics = talosSchematicsConfigFile
+		}
+	}
+	if schematics != "" {
+		if err := c.AddFile(bundle.KindSchematics, "talos", schematics); err != nil {
+			return &userError{msg: err.Error(), hint: "--schematics configs/talos.schematics.sops.yaml"}
+		}
+	}
+	if aptMirror != "" {
+		if err := c.AddDir(bundle.KindAptMirror, "apt/"+filepath.Base(filepath.Clean(aptMirror)), aptMirror); err != nil {
+			return fmt.Errorf("add apt mirror: %w", err)
+		}
+	}
+
+	f, err := os.Create(output + ".tmp")
+	if err != nil {
+		return err
+	}
+	m, err := bundle.Write(f, c.Files())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(output + ".tmp")
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if err := os.Rename(output+".tmp", output); err != nil {
+		return err
+	}
+	var total int64
+	for _, a := range m.Artifacts {
+		total += a.Size
+	}
+	fmt.Printf("\033[32m✓ %s: %d files, %s\033[0m\n", output, len(m.Artifacts), formatSize(total))
+	return nil
+}
+
+// loadBundleVMConfig reads a VM config without the vCenter config, which
+// bundle export does not need.
+func loadBundleVMConfig(path string) (*bootstrap.VMConfig, error) {
+	data, err := sopsDecrypt(path)
+	if err != nil {
+		return nil, err
+	}
+	vmFile, err := pkgconfig.ParseVMFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse VM config:\n%w", err)
+	}
+	if err := vmFile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid VM config %s:\n%w", path, err)
+	}
+	return pkgconfig.ToVMConfig(vmFile, &pkgconfig.VCenterFile{})
+}
+
+func importBundle(ctx context.Context, path string, seed, toLibrary bool, timeout time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return &userError{msg: err.Error(), hint: "vmbootstrap bundle import site.tar"}
+	}
+	defer func() { _ = f.Close() }()
+
+	offlineDir := iso.NewCache(cacheDir).OfflineDir()
+	fmt.Printf("Importing %s into %s...\n", path, offlineDir)
+	m, err := bundle.Import(f, offlineDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  \033[32m✓ %d files verified (bundle created %s)\033[0m\n", len(m.Artifacts), m.Created.Format(time.RFC3339))
+
+	var failed int
+	report := func(name string, err error) {
+		if err != nil {
+			failed++
+			fmt.Printf("  \033[31m✗ %s: %v\033[0m\n", name, err)
+			return
+		}
+		fmt.Printf("  \033[32m✓ %s\033[0m\n", name)
+	}
+	var ovas []bundle.Artifact
+	aptMirror := false
+	for _, a := range m.Artifacts {
+		switch a.Kind {
+		case bundle.KindUbuntuISO:
+			if seed {
+				mgr, err := newISOManager(ctx)
+				if err == nil {
+					_, err = mgr.DownloadUbuntu(a.Version)
+				}
+				report("Ubuntu "+a.Version+" ISO cached", err)
+			}
+		case bundle.KindTalosISO:
+			if seed {
+				_, err := talosprofile.DownloadISO(ctx, a.Version, a.SchematicID, talosprofile.ISOOptions{CacheDir: cacheDir, Offline: offlineMode})
+				report("Talos "+a.Version+" ISO cached", err)
+			}
+		case bundle.KindTalosOVA:
+			ovas = append(ovas, a)
+		case bundle.KindCatalog:
+			if a.Path == "catalogs/"+bundle.OverrideCatalogName {
+				report("Release catalog merged", mergeBundledCatalog(filepath.Join(offlineDir, filepath.FromSlash(a.Path))))
+			}
+		case bundle.KindSchematics:
+			report("Talos schematics", installSchematics(filepath.Join(offlineDir, filepath.FromSlash(a.Path))))
+		case bundle.KindAptMirror:
+			aptMirror = true
+		}
+	}
+	if aptMirror {
+		fmt.Printf("  apt mirror: %s (serve it over HTTP for the VMs)\n", filepath.Join(offlineDir, "apt"))
+	}
+	if toLibrary && len(ovas) > 0 {
+		report("Talos OVAs in content library", importBundleOVAs(ctx, ovas, offlineDir, timeout))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d import steps failed", failed)
+	}
+	if !offlineMode {
+		fmt.Println("  Set iso.offline (or pass --offline) to never fall back to downloading.")
+	}
+	return nil
+}
+
+// mergeBundledCatalog merges the bundled release catalog override into the
+// local one, so the site sees the releases the bundle was exported with.
+func mergeBundledCatalog(src string) error {
+	bundled, err := configs.LoadCatalogOverride(src)
+	if err != nil {
+		return err
+	}
+	local, err := configs.LoadCatalogOverride("")
+	if err != nil {
+		return err
+	}
+	local.Merge(bundled)
+	return local.Save("")
+}
+
+// installSchematics copies the bundled schematics file to the schematics
+// config path unless one exists there already.
+func installSchematics(src string) error {
+	if _, err := os.Stat(talosSchematicsConfigFile); err == nil {
+		fmt.Printf("  %s exists; bundled schematics kept at %s\n", talosSchematicsConfigFile, src)
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(talosSchematicsConfigFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(talosSchematicsConfigFile, data, 0600)
+}
+
+// importBundleOVAs imports the bundled OVAs into the content library of the
+// vCenter config, as the items Talos node creation looks for.
+func importBundleOVAs(ctx context.Context, ovas []bundle.Artifact, offlineDir string, timeout time.Duration) error {
+	vcCfg, err := loadVCenterConfig(vcenterConfigFile)
+	if err != nil {
+		return err
+	}
+	clientCfg, err := vcenterClientConfig(vcCfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	vc, err := vcenter.NewClient(ctx, clientCfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = vc.Disconnect() }()
+
+	libraryID := strings.TrimSpace(vcCfg.VCenter.ContentLibraryID)
+	if libraryID == "" {
+		name := strings.TrimSpace(vcCfg.VCenter.ContentLibrary)
+		if name == "" {
+			name = "talos-images"
+		}
+		lib, err := vc.EnsureLibrary(name, vcCfg.VCenter.Datacenter, vcCfg.VCenter.ISODatastore)
+		if err != nil {
+			return fmt.Errorf("ensure content library %q: %w", name, err)
+		}
+		libraryID = lib.ID
+	}
+	for _, a := range ovas {
+		src := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(offlineDir, filepath.FromSlash(a.Path)))}).String()
+		if _, err := vc.EnsureLibraryItemFromURL(libraryID, a.LibraryItem, src); err != nil {
+			return fmt.Errorf("%s: %w", a.LibraryItem, err)
+		}
+		fmt.Printf("    %s\n", a.LibraryItem)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	"github.com/spf13/cobra"
+)
+
+var cacheDir string
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Local ISO cache utilities (list, verify, prune)",
+	Long: "Manage the local artifact cache: downloaded Ubuntu ISOs, -autoinstall remasters,\n" +
+		"Talos ISOs (talos/), NoCloud seeds and their sidecar files.\n" +
+		"The cache is shared by all checkouts; set --cache-dir or iso.cache_dir to move it.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listCache(cmd.OutOrStdout())
+	},
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List cached artifacts, least recently used first",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listCache(cmd.OutOrStdout())
+	},
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Re-hash cached downloads and check remasters against their source ISO",
+	Long: "Re-hash downloaded ISOs against the SHA256 recorded when they were verified and\n" +
+		"check that -autoinstall remasters still match their source ISO. With --remove,\n" +
+		"corrupt and stale entries are deleted (they are re-created on the next run).",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remove, _ := cmd.Flags().GetBool("remove")
+		return verifyCache(cmd.Context(), remove)
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached artifacts by age, size or release",
+	Long: "Remove cached artifacts matching any of the given criteria:\n" +
+		"  --older-than  not used for this long (e.g. 30d, 12h)\n" +
+		"  --max-size    evict least recently used artifacts until the cache fits (GB)\n" +
+		"  --unused      releases no longer in the release catalogs, stale remasters,\n" +
+		"                NoCloud seeds and interrupted downloads\n" +
+		"Artifacts in use by another vmbootstrap process are skipped.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		maxSizeGB, _ := cmd.Flags().GetFloat64("max-size")
+		unused, _ := cmd.Flags().GetBool("unused")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		opts := iso.PruneOptions{
+			MaxSize: int64(maxSizeGB * (1 << 30)),
+			Unused:  unused,
+			DryRun:  dryRun,
+		}
+		if olderThan != "" {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return &userError{msg: err.Error(), hint: "vmbootstrap cache prune --older-than 30d"}
+			}
+			opts.OlderThan = age
+		}
+		if opts.OlderThan <= 0 && opts.MaxSize <= 0 && !opts.Unused {
+			return &userError{
+				msg:  "nothing to prune: give --older-than, --max-size or --unused",
+				hint: "vmbootstrap cache prune --unused --dry-run",
+			}
+		}
+		return pruneCache(opts)
+	},
+}
+
+func listCache(w io.Writer) error {
+	c := iso.NewCache(cacheDir)
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Cache: %s\n", c.Dir)
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "  (empty)")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKIND\tVERSION\tSIZE\tLAST USED")
+	var total int64
+	for _, e := range entries {
+		version := e.Version
+		if version != "" && !e.Current {
+			version += " (not in catalog)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Name, e.Kind, dashIfEmpty(version), formatSize(e.Size), formatAge(e.LastUsed))
+		total += e.Size
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	limit := "unlimited"
+	if gb := configs.Defaults.ISO.CacheMaxSizeGB; gb > 0 {
+		limit = fmt.Sprintf("%d GB", gb)
+	}
+	fmt.Fprintf(w, "Total: %s in %d artifacts (limit: %s)\n", formatSize(total), len(entries), limit)
+	return nil
+}
+
+func verifyCache(ctx context.Context, remove bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c := iso.NewCache(cacheDir)
+	results, err := c.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	var bad []iso.CacheEntry
+	for _, r := range results {
+		switch r.Status {
+		case iso.VerifyOK:
+			fmt.Printf("  \033[32m✓ %s\033[0m\n", r.Entry.Name)
+		case iso.VerifyUnverified:
+			fmt.Printf("  - %s: %s\n", r.Entry.Name, r.Detail)
+		default:
+			bad = append(bad, r.Entry)
+			fmt.Printf("  \033[31m✗ %s: %s (%s)\033[0m\n", r.Entry.Name, r.Status, r.Detail)
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	if !remove {
+		return &userError{
+			msg:  fmt.Sprintf("%d of %d cache entries are corrupt or stale", len(bad), len(results)),
+			hint: "vmbootstrap cache verify --remove",
+		}
+	}
+	removed, err := c.Remove(bad...)
+	fmt.Printf("Removed %d of %d corrupt or stale entries\n", len(removed), len(bad))
+	return err
+}
+
+func pruneCache(opts iso.PruneOptions) error {
+	c := iso.NewCache(cacheDir)
+	removed, err := c.Prune(opts)
+	var freed int64
+	for _, e := range removed {
+		freed += e.Size
+		if opts.DryRun {
+			fmt.Printf("  → would remove %s (%s, %s)\n", e.Name, e.Kind, formatSize(e.Size))
+		} else {
+			fmt.Printf("  \033[32m✓ removed %s (%s, %s)\033[0m\n", e.Name, e.Kind, formatSize(e.Size))
+		}
+	}
+	verb := "Freed"
+	if opts.DryRun {
+		verb = "Would free"
+	}
+	fmt.Printf("%s %s in %d artifacts\n", verb, formatSize(freed), len(removed))
+	return err
+}
+
+// parseAge parses a duration that may also be given in days ("30d").
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d or 12h)", s)
+	}
+	return d, nil
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func formatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/catalog"
+	"github.com/spf13/cobra"
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Ubuntu and Talos release catalogs (show, update)",
+	Long: "The built-in release catalogs are merged with a user-level override catalog\n" +
+		"(catalog.override_file, default <user config dir>/vmbootstrap/catalog.yaml),\n" +
+		"which `catalog update` refreshes from the release endpoints.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showCatalog(cmd.OutOrStdout())
+	},
+}
+
+var catalogShowCmd = &cobra.Command{
+	Use:           "show",
+	Short:         "Show the merged release catalogs",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showCatalog(cmd.OutOrStdout())
+	},
+}
+
+var catalogUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Fetch the current Ubuntu point releases and Talos releases",
+	Long: "Look up the newest point release of each Ubuntu series in the GPG-signed\n" +
+		"SHA256SUMS of catalog.ubuntu_url and the stable releases at catalog.talos_url,\n" +
+		"and merge them into the override catalog.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := catalog.UpdateOptions{}
+		opts.UbuntuURL, _ = cmd.Flags().GetString("ubuntu-url")
+		opts.TalosURL, _ = cmd.Flags().GetString("talos-url")
+		opts.TalosLimit, _ = cmd.Flags().GetInt("talos-limit")
+		opts.UbuntuVersions, _ = cmd.Flags().GetStringSlice("ubuntu")
+		opts.Timeout, _ = cmd.Flags().GetDuration("timeout")
+		opts.KeyDir = filepath.Join(cacheDir, "keys")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return updateCatalog(cmd.Context(), opts, dryRun)
+	},
+}
+
+func updateCatalog(ctx context.Context, opts catalog.UpdateOptions, dryRun bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	override, err := configs.LoadCatalogOverride("")
+	if err != nil {
+		return &userError{msg: err.Error(), hint: "fix or delete " + configs.CatalogOverridePath()}
+	}
+
+	fmt.Println("Fetching release catalogs...")
+	fetched, err := catalog.Update(ctx, opts)
+	if err != nil {
+		return err
+	}
+	known := configs.MergedUbuntuReleases()
+	for _, v := range sortedKeys(fetched.Ubuntu) {
+		r := fetched.Ubuntu[v]
+		state := "unchanged"
+		if old, ok := known[v]; !ok || old.URL != r.URL {
+			state = "new"
+		}
+		fmt.Printf("  \033[32m✓ Ubuntu %s: %s (%s)\033[0m\n", v, path.Base(r.URL), state)
+	}
+	if len(fetched.Talos) > 0 {
+		fmt.Printf("  \033[32m✓ Talos: %s\033[0m\n", strings.Join(fetched.Talos, ", "))
+	}
+
+	override.Merge(fetched)
+	if dryRun {
+		fmt.Printf("Dry run: %s not written\n", configs.CatalogOverridePath())
+		return nil
+	}
+	if err := override.Save(""); err != nil {
+		return err
+	}
+	fmt.Printf("\033[32m✓ Release catalog written to %s\033[0m\n", configs.CatalogOverridePath())
+	return nil
+}
+
+func showCatalog(w io.Writer) error {
+	override, err := configs.LoadCatalogOverride("")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Override: %s", configs.CatalogOverridePath())
+	if override.UpdatedAt.IsZero() {
+		fmt.Fprintln(w, " (none)")
+	} else {
+		fmt.Fprintf(w, " (updated %s)\n", override.UpdatedAt.Local().Format(time.RFC3339))
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UBUNTU\tSOURCE\tISO")
+	releases := configs.MergedUbuntuReleases()
+	for _, v := range sortedKeys(releases) {
+		source := "built-in"
+		if r, ok := override.Ubuntu[v]; ok && r.URL == releases[v].URL {
+			source = "override"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", v, source, releases[v].URL)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	builtin := map[string]bool{}
+	for _, v := range configs.SortTalosVersions(configs.TalosReleases.Versions) {
+		builtin[v] = true
+	}
+	var talos []string
+	for _, v := range configs.MergedTalosVersions() {
+		if !builtin[v] {
+			v += " (override)"
+		}
+		talos = append(talos, v)
+	}
+	fmt.Fprintf(w, "\nTalos: %s\n", strings.Join(talos, ", "))
+	return nil
+}
+
+func sortedKeys(m map[string]configs.UbuntuRelease) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
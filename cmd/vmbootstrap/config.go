@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"math"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
@@ -20,6 +19,7 @@ import (
 
 	wizard "github.com/infrakit-io/cli-wizard-core"
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	pkgconfig "github.com/infrakit-io/vmware-vm-bootstrap/pkg/config"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
 	"github.com/chzyer/readline"
 	"golang.org/x/term"
@@ -29,62 +29,10 @@ import (
 var sel = wizard.NewSelector()
 
 // VMWizardOutput is the YAML structure for vm.*.sops.yaml files.
-type VMWizardOutput struct {
-	VM struct {
-		Name              string `yaml:"name"`
-		Profile           string `yaml:"profile,omitempty"`
-		CPUs              int    `yaml:"cpus"`
-		MemoryMB          int    `yaml:"memory_mb"`
-		DiskSizeGB        int    `yaml:"disk_size_gb"`
-		DataDiskSizeGB    int    `yaml:"data_disk_size_gb,omitempty"`
-		DataDiskMountPath string `yaml:"data_disk_mount_path,omitempty"`
-		SwapSizeGB        *int   `yaml:"swap_size_gb,omitempty"`
-		Username          string `yaml:"username"`
-		SSHKeyPath        string `yaml:"ssh_key_path,omitempty"`
-		SSHKey            string `yaml:"ssh_key,omitempty"`
-		Password          string `yaml:"password,omitempty"`
-		AllowPasswordSSH  bool   `yaml:"allow_password_ssh,omitempty"`
-		SSHPort           int    `yaml:"ssh_port,omitempty"`
-		IPAddress         string `yaml:"ip_address"`
-		Netmask           string `yaml:"netmask"`
-		Gateway           string `yaml:"gateway"`
-		DNS               string `yaml:"dns"`
-		DNS2              string `yaml:"dns2,omitempty"`
-		Datastore         string `yaml:"datastore,omitempty"`
-		NetworkName       string `yaml:"network_name,omitempty"`
-		NetworkInterface  string `yaml:"network_interface,omitempty"`
-		Folder            string `yaml:"folder,omitempty"`
-		ResourcePool      string `yaml:"resource_pool,omitempty"`
-		TimeoutMinutes    int    `yaml:"timeout_minutes"`
-		Profiles          struct {
-			Ubuntu struct {
-				Version string `yaml:"version,omitempty"`
-			} `yaml:"ubuntu,omitempty"`
-			Talos struct {
-				Version     string `yaml:"version,omitempty"`
-				SchematicID string `yaml:"schematic_id,omitempty"`
-			} `yaml:"talos,omitempty"`
-		} `yaml:"profiles,omitempty"`
-	} `yaml:"vm"`
-}
+type VMWizardOutput = pkgconfig.VMFile
 
 // vcenterFileConfig is the YAML structure for vcenter.sops.yaml.
-type vcenterFileConfig struct {
-	VCenter struct {
-		Host             string `yaml:"host"`
-		Username         string `yaml:"username"`
-		Password         string `yaml:"password"`
-		Datacenter       string `yaml:"datacenter"`
-		ContentLibrary   string `yaml:"content_library,omitempty"`
-		ContentLibraryID string `yaml:"content_library_id,omitempty"`
-		ISODatastore     string `yaml:"iso_datastore"`
-		Folder           string `yaml:"folder"`        // default VM folder for new VMs
-		ResourcePool     string `yaml:"resource_pool"` // default resource pool for new VMs
-		Network          string `yaml:"network"`       // default network for new VMs
-		Port             int    `yaml:"port"`
-		Insecure         bool   `yaml:"insecure"`
-	} `yaml:"vcenter"`
-}
+type vcenterFileConfig = pkgconfig.VCenterFile
 
 // datastoreCandidate holds a scored datastore for recommendations.
 type datastoreCandidate struct {
@@ -101,43 +49,30 @@ func listVCenterContentLibraries(vc *vcenterFileConfig, timeout time.Duration) (
 		return nil, fmt.Errorf("vcenter config is nil")
 	}
 	v := vc.VCenter
-	if strings.TrimSpace(v.Host) == "" || strings.TrimSpace(v.Username) == "" || strings.TrimSpace(v.Password) == "" {
+	if strings.TrimSpace(v.Host) == "" || strings.TrimSpace(v.Username) == "" || (strings.TrimSpace(v.Password) == "" && strings.TrimSpace(v.PasswordFrom) == "") {
 		return nil, fmt.Errorf("missing vcenter host/username/password")
 	}
-	if _, err := exec.LookPath("govc"); err != nil {
-		return nil, fmt.Errorf("govc not found")
-	}
-	url := strings.TrimSpace(v.Host)
-	if !strings.Contains(url, "://") {
-		port := v.Port
-		if port == 0 {
-			port = 443
-		}
-		url = fmt.Sprintf("https://%s:%d/sdk", strings.TrimSpace(v.Host), port)
+	clientCfg, err := vcenterClientConfig(vc)
+	if err != nil {
+		return nil, err
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "govc", "library.ls")
-	cmd.Env = append(os.Environ(),
-		"GOVC_URL="+url,
-		"GOVC_USERNAME="+v.Username,
-		"GOVC_PASSWORD="+v.Password,
-		fmt.Sprintf("GOVC_INSECURE=%t", v.Insecure),
-	)
-	out, err := cmd.Output()
+
+	vclient, err := vcenter.NewClient(ctx, clientCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = vclient.Disconnect() }()
+
+	found, err := vclient.ListLibraries()
 	if err != nil {
 		return nil, err
 	}
-	lines := strings.Split(string(out), "\n")
 	seen := map[string]struct{}{}
-	libs := make([]string, 0, len(lines))
-	for _, line := range lines {
-		s := strings.TrimSpace(line)
-		if s == "" {
-			continue
-		}
-		parts := strings.Split(s, "/")
-		name := strings.TrimSpace(parts[len(parts)-1])
+	libs := make([]string, 0, len(found))
+	for _, lib := range found {
+		name := strings.TrimSpace(lib.Name)
 		if name == "" {
 			continue
 		}
@@ -285,6 +220,16 @@ func runVMSpecsStep(vm *VMWizardOutput, opts vmSpecsStepOptions) {
 	}
 	swap := readInt("Swap size (GB, 0 = no swap)", defaultSwap, 0, 64)
 	vm.VM.SwapSizeGB = &swap
+
+	if readYesNo("Enable Secure Boot + vTPM?", vm.VM.SecureBoot || vm.VM.VTPM) {
+		vm.VM.SecureBoot = true
+		vm.VM.VTPM = true
+		vm.VM.Firmware = "efi"
+		vm.VM.EncryptionPolicy = readLine("Encryption storage policy ID (empty = none)", vm.VM.EncryptionPolicy)
+	} else {
+		vm.VM.SecureBoot = false
+		vm.VM.VTPM = false
+	}
 	fmt.Println()
 }
 
@@ -341,6 +286,7 @@ func runVMSummaryAndSave(targetPath string, vm VMWizardOutput, opts vmSummarySav
 		return nil
 	}
 
+	vm.APIVersion = pkgconfig.APIVersion
 	if err := saveAndEncrypt(targetPath, vm, opts.DraftPath); err != nil {
 		if strings.TrimSpace(opts.SaveErrorPrefix) != "" {
 			return fmt.Errorf("%s: %w", opts.SaveErrorPrefix, err)
@@ -478,6 +424,7 @@ func editVCenterConfig(path string) error {
 	if pw := readPassword("Password (blank = keep current)"); pw != "" {
 		v.Password = pw
 	}
+	confirmVCenterTrust(&cfg)
 	v.Datacenter = readLine("Datacenter", v.Datacenter)
 	v.ContentLibrary, v.ContentLibraryID = selectContentLibraryInteractive(&cfg, v.ContentLibrary, v.ContentLibraryID)
 
@@ -493,6 +440,7 @@ func editVCenterConfig(path string) error {
 		return nil
 	}
 
+	cfg.APIVersion = pkgconfig.APIVersion
 	if err := saveAndEncrypt(path, cfg, ""); err != nil {
 		return err
 	}
@@ -531,7 +479,9 @@ func createVCenterConfigWithSeed(path string, seed vcenterFileConfig, draftPath
 
 	v.Host = readLine("Host", v.Host)
 	v.Username = readLine("Username", v.Username)
-	if v.Password != "" {
+	if v.PasswordFrom != "" {
+		fmt.Printf("  Password: from %s\n", v.PasswordFrom)
+	} else if v.Password != "" {
 		if readYesNo("Use saved password?", true) {
 			// keep existing
 		} else {
@@ -540,10 +490,11 @@ func createVCenterConfigWithSeed(path string, seed vcenterFileConfig, draftPath
 	} else {
 		v.Password = readPassword("Password")
 	}
-	v.Datacenter = readLine("Datacenter", v.Datacenter)
-	v.ContentLibrary, v.ContentLibraryID = selectContentLibraryInteractive(&cfg, v.ContentLibrary, v.ContentLibraryID)
 	v.Port = readInt("Port", intOrDefault(v.Port, configs.Defaults.VCenter.Port), 1, 65535)
 	v.Insecure = readYesNo("Skip TLS verification? (not recommended)", v.Insecure)
+	confirmVCenterTrust(&cfg)
+	v.Datacenter = readLine("Datacenter", v.Datacenter)
+	v.ContentLibrary, v.ContentLibraryID = selectContentLibraryInteractive(&cfg, v.ContentLibrary, v.ContentLibraryID)
 
 	// Try to connect and fetch resource pickers.
 	fmt.Print("  Connecting to vCenter... ")
@@ -568,6 +519,7 @@ func createVCenterConfigWithSeed(path string, seed vcenterFileConfig, draftPath
 		return nil
 	}
 
+	cfg.APIVersion = pkgconfig.APIVersion
 	if err := saveAndEncrypt(path, cfg, draftPath); err != nil {
 		return err
 	}
@@ -1131,7 +1083,7 @@ func selectISODatastore(datastores []vcenter.DatastoreInfo, defaultDS string) st
 }
 
 func buildUbuntuOptions() []string {
-	releases := configs.UbuntuReleases.Releases
+	releases := configs.MergedUbuntuReleases()
 	var vers []string
 	for ver := range releases {
 		vers = append(vers, ver)
@@ -1173,33 +1125,18 @@ func selectUbuntuVersion(target *string) {
 }
 
 func selectTalosVersion(current string) string {
+	versions := configs.MergedTalosVersions()
 	defaultVersion := strings.TrimSpace(current)
 	if defaultVersion == "" {
 		defaultVersion = strings.TrimSpace(configs.Defaults.Talos.DefaultVersion)
 	}
-	if defaultVersion == "" && len(configs.TalosReleases.Versions) > 0 {
-		defaultVersion = strings.TrimSpace(configs.TalosReleases.Versions[0])
+	if defaultVersion == "" && len(versions) > 0 {
+		defaultVersion = versions[0]
 	}
 	if defaultVersion != "" && !strings.HasPrefix(defaultVersion, "v") {
 		defaultVersion = "v" + defaultVersion
 	}
 
-	var versions []string
-	seen := map[string]struct{}{}
-	for _, v := range configs.TalosReleases.Versions {
-		v = strings.TrimSpace(v)
-		if v == "" {
-			continue
-		}
-		if !strings.HasPrefix(v, "v") {
-			v = "v" + v
-		}
-		if _, ok := seen[v]; ok {
-			continue
-		}
-		seen[v] = struct{}{}
-		versions = append(versions, v)
-	}
 	if len(versions) == 0 {
 		return readLine("Talos version (e.g. v1.12.0)", defaultVersion)
 	}
@@ -1246,6 +1183,17 @@ func printSummary(out VMWizardOutput) {
 	if v.SwapSizeGB != nil {
 		fmt.Printf("  %-20s %d GB\n", "Swap:", *v.SwapSizeGB)
 	}
+	if v.CoresPerSocket > 0 || v.MemoryReservationMB > 0 || v.CPUReservationMHz > 0 || v.LatencySensitivity != "" {
+		fmt.Printf("  %-20s cores/socket=%d cpu_res=%dMHz mem_res=%dMB latency=%s\n", "Tuning:",
+			v.CoresPerSocket, v.CPUReservationMHz, v.MemoryReservationMB, strOrDefault(v.LatencySensitivity, "normal"))
+	}
+	if v.SecureBoot || v.VTPM || v.EncryptionPolicy != "" {
+		fmt.Printf("  %-20s secure_boot=%t vtpm=%t", "Security:", v.SecureBoot, v.VTPM)
+		if v.EncryptionPolicy != "" {
+			fmt.Printf(" policy=%s", v.EncryptionPolicy)
+		}
+		fmt.Println()
+	}
 	fmt.Printf("  %-20s %s\n", "OS profile:", strOrDefault(v.Profile, "ubuntu"))
 	switch v.Profile {
 	case "talos":
@@ -1289,15 +1237,7 @@ func printSummary(out VMWizardOutput) {
 // ─── vCenter config loading ───────────────────────────────────────────────────
 
 func loadVCenterConfig(path string) (*vcenterFileConfig, error) {
-	data, err := sopsDecrypt(path)
-	if err != nil {
-		return nil, err
-	}
-	var cfg vcenterFileConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
-	}
-	return &cfg, nil
+	return pkgconfig.LoadVCenterFile(path)
 }
 
 // ─── File path input with Tab completion ─────────────────────────────────────
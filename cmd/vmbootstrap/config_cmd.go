@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+	pkgconfig "github.com/infrakit-io/vmware-vm-bootstrap/pkg/config"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:           "config",
+	Short:         "VM and vCenter config file utilities (render, migrate, schema)",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var configRenderCmd = &cobra.Command{
+	Use:   "render <vm-config>",
+	Short: "Print the effective VM config with extends and classes merged",
+	Long: "Print the effective VM config: base files named in extends and the size class in\n" +
+		"vm.class are deep-merged beneath the file, as run and smoke see it.\n" +
+		"With --user-data, print the Ubuntu autoinstall user-data instead, with vm.cloud_init\n" +
+		"merged, as a dry run of what the installer will receive.\n" +
+		"Passwords are redacted unless --show-secrets is given.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+		userData, _ := cmd.Flags().GetBool("user-data")
+		data, err := sopsDecrypt(args[0])
+		if err != nil {
+			return err
+		}
+		vmFile, err := pkgconfig.ParseVMFile(args[0], data)
+		if err != nil {
+			return err
+		}
+		if userData {
+			return renderUserData(cmd, args[0], vmFile, showSecrets)
+		}
+		if !showSecrets && vmFile.VM.Password != "" {
+			vmFile.VM.Password = "<redacted>"
+		}
+		out, err := vmFile.Render()
+		if err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(out)
+		return err
+	},
+}
+
+// renderUserData prints the autoinstall user-data generated for vmFile.
+func renderUserData(cmd *cobra.Command, path string, vmFile *pkgconfig.VMFile, showSecrets bool) error {
+	if err := vmFile.Validate(); err != nil {
+		return fmt.Errorf("invalid VM config %s:\n%w", path, err)
+	}
+	cfg, err := pkgconfig.ToVMConfig(vmFile, &pkgconfig.VCenterFile{})
+	if err != nil {
+		return err
+	}
+	if !showSecrets && (cfg.Password != "" || cfg.PasswordHash != "" || cfg.PasswordProvider != nil) {
+		cfg.Password, cfg.PasswordHash = "", "<redacted>"
+	}
+	if showSecrets && cfg.PasswordProvider != nil {
+		// RenderUserData does not resolve vm.password_from; do it here so the
+		// output shows the password the installer will actually get.
+		if cfg.Password, err = credentials.Resolve(cmd.Context(), cfg.Password, cfg.PasswordProvider); err != nil {
+			return fmt.Errorf("resolve vm.password_from: %w", err)
+		}
+	}
+	out, err := bootstrap.RenderUserData(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(cmd.OutOrStdout(), out)
+	return err
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [file...]",
+	Short: "Upgrade config files to the current apiVersion in place",
+	Long: "Upgrade VM and vCenter config files to the current apiVersion (" + pkgconfig.APIVersion + ").\n" +
+		"Without arguments, the vCenter config and all configs/vm.*.sops.yaml files are migrated.\n" +
+		"SOPS-encrypted files are decrypted and re-encrypted in-process; plain files stay plain.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return migrateConfigFiles(args, dryRun)
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:           "schema vm|vcenter",
+	Short:         "Print the JSON Schema of VM or vCenter config files",
+	Args:          cobra.ExactArgs(1),
+	ValidArgs:     []string{pkgconfig.KindVM, pkgconfig.KindVCenter},
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := pkgconfig.JSONSchema(args[0])
+		if err != nil {
+			return &userError{msg: err.Error(), hint: "vmbootstrap config schema vm > vm.schema.json"}
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(schema))
+		return err
+	},
+}
+
+// migrateConfigFiles upgrades paths (or all known config files) to the current apiVersion.
+func migrateConfigFiles(paths []string, dryRun bool) error {
+	if len(paths) == 0 {
+		paths = append(paths, vcenterConfigFile)
+		vmFiles, _ := filepath.Glob(resolveConfigPath("configs/vm.*.sops.yaml"))
+		paths = append(paths, vmFiles...)
+	}
+
+	var failed int
+	for _, path := range paths {
+		changed, err := migrateConfigFile(path, dryRun)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("  \033[31m✗ %s: %v\033[0m\n", path, err)
+		case changed && dryRun:
+			fmt.Printf("  → %s: would migrate to %s\n", path, pkgconfig.APIVersion)
+		case changed:
+			fmt.Printf("  \033[32m✓ %s: migrated to %s\033[0m\n", path, pkgconfig.APIVersion)
+		default:
+			fmt.Printf("  ✓ %s: up to date\n", path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d config files could not be migrated", failed, len(paths))
+	}
+	return nil
+}
+
+func migrateConfigFile(path string, dryRun bool) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		return false, err
+	}
+	if !dryRun {
+		changed, err := pkgconfig.MigrateFile(path)
+		return changed, sopsWriteError(err)
+	}
+	plain, err := sopsDecrypt(path)
+	if err != nil {
+		return false, err
+	}
+	_, changed, err := pkgconfig.Migrate(plain)
+	return changed, err
+}
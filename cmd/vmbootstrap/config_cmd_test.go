@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	pkgconfig "github.com/infrakit-io/vmware-vm-bootstrap/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+const renderTestVMYAML = `vm:
+  name: web-01
+  cpus: 2
+  memory_mb: 4096
+  disk_size_gb: 40
+  username: sysadmin
+  password_from: env:RENDER_TEST_PASSWORD
+  ssh_key: ssh-ed25519 AAAA test
+  ip_address: 192.168.1.10
+  netmask: 255.255.255.0
+  gateway: 192.168.1.1
+  dns: 8.8.8.8
+  datastore: SSD01
+  network_name: LAN
+  profiles:
+    ubuntu:
+      version: "24.04"
+`
+
+func TestRenderUserData_ResolvesPasswordFromWithShowSecrets(t *testing.T) {
+	t.Setenv("RENDER_TEST_PASSWORD", "s3cret")
+
+	render := func(showSecrets bool) string {
+		t.Helper()
+		vmFile, err := pkgconfig.ParseVMFile("vm.web-01.yaml", []byte(renderTestVMYAML))
+		if err != nil {
+			t.Fatalf("ParseVMFile: %v", err)
+		}
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		cmd.SetContext(context.Background())
+		if err := renderUserData(cmd, "vm.web-01.yaml", vmFile, showSecrets); err != nil {
+			t.Fatalf("renderUserData: %v", err)
+		}
+		return out.String()
+	}
+
+	if got := render(false); !strings.Contains(got, "<redacted>") {
+		t.Errorf("user-data without --show-secrets is not redacted:\n%s", got)
+	}
+	if got := render(true); strings.Contains(got, `passwd: "*"`) || strings.Contains(got, "<redacted>") {
+		t.Errorf("user-data with --show-secrets lacks the password_from password:\n%s", got)
+	}
+}
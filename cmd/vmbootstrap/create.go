@@ -9,55 +9,47 @@ import (
 	"time"
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+	pkgconfig "github.com/infrakit-io/vmware-vm-bootstrap/pkg/config"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
-	"gopkg.in/yaml.v3"
 )
 
-// vmFileConfig is the YAML structure for vm.*.sops.yaml (runtime fields).
-type vmFileConfig struct {
-	VM struct {
-		Name              string `yaml:"name"`
-		Profile           string `yaml:"profile,omitempty"`
-		CPUs              int    `yaml:"cpus"`
-		MemoryMB          int    `yaml:"memory_mb"`
-		DiskSizeGB        int    `yaml:"disk_size_gb"`
-		DataDiskSizeGB    int    `yaml:"data_disk_size_gb"`
-		DataDiskMountPath string `yaml:"data_disk_mount_path"`
-		SwapSizeGB        int    `yaml:"swap_size_gb"`
-		Username          string `yaml:"username"`
-		SSHKeyPath        string `yaml:"ssh_key_path"`
-		SSHKey            string `yaml:"ssh_key"`
-		Password          string `yaml:"password"`
-		SSHPort           int    `yaml:"ssh_port"`
-		AllowPasswordSSH  bool   `yaml:"allow_password_ssh"`
-		IPAddress         string `yaml:"ip_address"`
-		Netmask           string `yaml:"netmask"`
-		Gateway           string `yaml:"gateway"`
-		DNS               string `yaml:"dns"`
-		DNS2              string `yaml:"dns2"`
-		Datastore         string `yaml:"datastore"`
-		NetworkName       string `yaml:"network_name"`
-		NetworkInterface  string `yaml:"network_interface"`
-		Folder            string `yaml:"folder"`
-		ResourcePool      string `yaml:"resource_pool"`
-		TimeoutMinutes    int    `yaml:"timeout_minutes"`
-		Profiles          struct {
-			Ubuntu struct {
-				Version string `yaml:"version,omitempty"`
-			} `yaml:"ubuntu,omitempty"`
-			Talos struct {
-				Version     string `yaml:"version,omitempty"`
-				SchematicID string `yaml:"schematic_id,omitempty"`
-			} `yaml:"talos,omitempty"`
-		} `yaml:"profiles,omitempty"`
-	} `yaml:"vm"`
+// loadVMRunConfig loads the vCenter config and the VM config at vmConfigPath,
+// validates both and converts them into a bootstrap.VMConfig.
+func loadVMRunConfig(vmConfigPath string) (*pkgconfig.VMFile, *bootstrap.VMConfig, error) {
+	vcCfg, err := loadVCenterConfig(vcenterConfigFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load vCenter config: %w", err)
+	}
+	if err := vcCfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid vCenter config:\n%w", err)
+	}
+
+	vmOut, err := sopsDecrypt(vmConfigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	vmFile, err := pkgconfig.ParseVMFile(vmConfigPath, vmOut)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse VM config:\n%w", err)
+	}
+	if err := vmFile.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid VM config:\n%w", err)
+	}
+
+	cfg, err := pkgconfig.ToVMConfig(vmFile, vcCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	applyCLISettings(cfg)
+	return vmFile, cfg, nil
 }
 
-func buildDNS(primary, secondary string) []string {
-	if secondary != "" {
-		return []string{primary, secondary}
+// firstSSHKey returns the configured SSH public key ("" when none, e.g. for talos).
+func firstSSHKey(cfg *bootstrap.VMConfig) string {
+	if len(cfg.SSHPublicKeys) == 0 {
+		return ""
 	}
-	return []string{primary}
+	return cfg.SSHPublicKeys[0]
 }
 
 // bootstrapVM decrypts vmConfigPath, merges with vcenter config, and runs bootstrap.
@@ -66,89 +58,19 @@ func bootstrapVM(vmConfigPath string, resultPath string) error {
 	fmt.Println(strings.Repeat("─", 50))
 	fmt.Println()
 
-	// Load vCenter config
-	vcCfg, err := loadVCenterConfig(vcenterConfigFile)
-	if err != nil {
-		return fmt.Errorf("failed to load vCenter config: %w", err)
-	}
-
-	// Decrypt VM config
-	vmOut, err := sopsDecrypt(vmConfigPath)
+	vmFile, cfg, err := loadVMRunConfig(vmConfigPath)
 	if err != nil {
 		return err
 	}
-
-	var vmFile vmFileConfig
-	if err := yaml.Unmarshal(vmOut, &vmFile); err != nil {
-		return fmt.Errorf("failed to parse VM config: %w", err)
-	}
-
 	v := vmFile.VM
-	profile := strings.TrimSpace(v.Profile)
-	if profile == "" {
-		profile = "ubuntu"
-	}
+	profile := cfg.Profile
 	resultPath = resolveBootstrapResultPath(resultPath, v.Name)
 
-	printConfigWarnings(profile, v.DataDiskSizeGB, v.DataDiskMountPath, v.SwapSizeGB, v.SSHKeyPath, v.SSHKey, v.Password, v.SSHPort)
-
-	// Load SSH key (required only for ubuntu profile).
-	var sshKey string
-	if profile == "ubuntu" {
-		if v.SSHKeyPath != "" {
-			data, err := os.ReadFile(v.SSHKeyPath)
-			if err != nil {
-				return fmt.Errorf("failed to read SSH key %s: %w", v.SSHKeyPath, err)
-			}
-			sshKey = strings.TrimSpace(string(data))
-		} else if v.SSHKey != "" {
-			sshKey = v.SSHKey
-		} else {
-			return fmt.Errorf("either vm.ssh_key or vm.ssh_key_path is required for ubuntu profile")
-		}
-	}
-
-	cfg := &bootstrap.VMConfig{
-		VCenterHost:     vcCfg.VCenter.Host,
-		VCenterUsername: vcCfg.VCenter.Username,
-		VCenterPassword: vcCfg.VCenter.Password,
-		VCenterPort:     vcCfg.VCenter.Port,
-		VCenterInsecure: vcCfg.VCenter.Insecure,
-
-		Name:       v.Name,
-		CPUs:       v.CPUs,
-		MemoryMB:   v.MemoryMB,
-		DiskSizeGB: v.DiskSizeGB,
-		Profile:    profile,
-
-		NetworkName:      v.NetworkName,
-		NetworkInterface: v.NetworkInterface,
-		IPAddress:        v.IPAddress,
-		Netmask:          v.Netmask,
-		Gateway:          v.Gateway,
-		DNS:              buildDNS(v.DNS, v.DNS2),
-
-		Datacenter:       vcCfg.VCenter.Datacenter,
-		Folder:           v.Folder,
-		ResourcePool:     v.ResourcePool,
-		Datastore:        v.Datastore,
-		ISODatastore:     vcCfg.VCenter.ISODatastore,
-		ContentLibrary:   vcCfg.VCenter.ContentLibrary,
-		ContentLibraryID: vcCfg.VCenter.ContentLibraryID,
-	}
-	if profile == "ubuntu" {
-		cfg.Username = v.Username
-		cfg.SSHPublicKeys = []string{sshKey}
-		cfg.Password = v.Password
-		cfg.AllowPasswordSSH = v.AllowPasswordSSH
-	}
-	cfg.Profiles.Ubuntu.Version = v.Profiles.Ubuntu.Version
-	cfg.Profiles.Talos.Version = v.Profiles.Talos.Version
-	cfg.Profiles.Talos.SchematicID = v.Profiles.Talos.SchematicID
+	printConfigWarnings(profile, v.DataDiskSizeGB, v.DataDiskMountPath, v.EffectiveSwapSizeGB(), v.SSHKeyPath, v.SSHKey, strOrDefault(v.Password, v.PasswordFrom), v.SSHPort)
 
 	// If VM already exists, warn and offer options.
 	if exists, err := vmExists(cfg); err == nil && exists {
-		keyPath, cleanupKey, keyErr := prepareSSHKeyPath(v.SSHKeyPath, sshKey)
+		keyPath, cleanupKey, keyErr := prepareSSHKeyPath(v.SSHKeyPath, firstSSHKey(cfg))
 		if keyErr == nil {
 			if cleanupKey != nil {
 				defer cleanupKey()
@@ -186,27 +108,12 @@ func bootstrapVM(vmConfigPath string, resultPath string) error {
 		}
 	}
 
-	if v.DataDiskSizeGB > 0 {
-		size := v.DataDiskSizeGB
-		cfg.DataDiskSizeGB = &size
-		cfg.DataDiskMountPath = v.DataDiskMountPath
-	}
-
-	if v.SwapSizeGB > 0 {
-		size := v.SwapSizeGB
-		cfg.SwapSizeGB = &size
-	}
-
-	timeoutMinutes := v.TimeoutMinutes
-	if timeoutMinutes == 0 {
-		timeoutMinutes = 45
-	}
-
 	fmt.Printf("  VM name:    %s\n", cfg.Name)
 	fmt.Printf("  vCenter:    %s\n", cfg.VCenterHost)
 	fmt.Printf("  IP:         %s\n", cfg.IPAddress)
 	fmt.Printf("  Datastore:  %s\n", cfg.Datastore)
 	fmt.Printf("  Network:    %s\n", cfg.NetworkName)
+	fmt.Printf("  Timeout:    %s\n", cfg.Options.Timeout)
 	fmt.Println()
 	fmt.Println("\033[33m⚠ This will create a real VM in vCenter. Press Ctrl+C to abort.\033[0m")
 	for i := 5; i > 0; i-- {
@@ -217,8 +124,14 @@ func bootstrapVM(vmConfigPath string, resultPath string) error {
 	fmt.Println()
 
 	logger := getLogger()
+	stopSeed, err := startSeedServer(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer stopSeed()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutMinutes)*time.Minute)
+	// The overall deadline comes from vm.timeout_minutes via cfg.Options.Timeout.
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Take over Ctrl+C from the global handler so we can offer VM cleanup on interrupt.
@@ -313,13 +226,7 @@ func offerVMCleanup(cfg *bootstrap.VMConfig) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	vclient, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     cfg.VCenterHost,
-		Username: cfg.VCenterUsername,
-		Password: cfg.VCenterPassword,
-		Port:     cfg.VCenterPort,
-		Insecure: cfg.VCenterInsecure,
-	})
+	vclient, err := vcenter.NewClient(ctx, cfg.VCenterConfig())
 	if err != nil {
 		fmt.Printf("\033[31m✗ %v\033[0m\n  Delete manually in vCenter: %s\n", err, cfg.Name)
 		return
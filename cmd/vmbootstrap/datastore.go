@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+	"github.com/spf13/cobra"
+	"github.com/vmware/govmomi/object"
+)
+
+var datastoreCmd = &cobra.Command{
+	Use:           "datastore",
+	Short:         "ISO datastore utilities (list, gc)",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var datastoreListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List uploaded ISOs with size and the VMs mounting them",
+	Long: "List the ISOs vmbootstrap uploaded to the ISO datastore (" + strings.Join(iso.DatastoreDirs, ", ") + ")\n" +
+		"with their size, upload time and the VMs whose CD-ROMs mount them.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("datastore")
+		return withISODatastore(name, func(mgr *iso.Manager, ds *object.Datastore) error {
+			artifacts, err := mgr.ListDatastoreArtifacts(ds)
+			if err != nil {
+				return err
+			}
+			printDatastoreArtifacts(ds.Name(), artifacts)
+			return nil
+		})
+	},
+}
+
+var datastoreGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete uploaded ISOs that no VM mounts",
+	Long: "Delete ISOs under " + strings.Join(iso.DatastoreDirs, ", ") + " that no VM's CD-ROM mounts and that\n" +
+		"were uploaded longer ago than --older-than (e.g. NoCloud seeds left by crashed runs).\n" +
+		"Shared Ubuntu/Talos ISOs are uploaded again by the next bootstrap that needs them;\n" +
+		"use --kind nocloud to only collect seeds.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("datastore")
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		kinds, _ := cmd.Flags().GetStringSlice("kind")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		age, err := parseAge(olderThan)
+		if err != nil {
+			return &userError{msg: err.Error(), hint: "vmbootstrap datastore gc --older-than 7d"}
+		}
+		for _, k := range kinds {
+			switch k {
+			case iso.KindUbuntu, iso.KindAutoinstall, iso.KindTalos, iso.KindNoCloud:
+			default:
+				return &userError{
+					msg:  fmt.Sprintf("unknown kind %q", k),
+					hint: "--kind " + strings.Join([]string{iso.KindUbuntu, iso.KindAutoinstall, iso.KindTalos, iso.KindNoCloud}, "|"),
+				}
+			}
+		}
+		opts := iso.DatastoreGCOptions{OlderThan: age, Kinds: kinds, DryRun: dryRun}
+		return withISODatastore(name, func(mgr *iso.Manager, ds *object.Datastore) error {
+			return runDatastoreGC(mgr, ds, opts)
+		})
+	},
+}
+
+// withISODatastore connects to vCenter and runs fn against the ISO datastore
+// (the vCenter config's iso_datastore unless name is given).
+func withISODatastore(name string, fn func(*iso.Manager, *object.Datastore) error) error {
+	vcCfg, err := loadVCenterConfig(vcenterConfigFile)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = vcCfg.VCenter.ISODatastore
+	}
+	if name == "" {
+		return &userError{
+			msg:  "no ISO datastore configured",
+			hint: "set vcenter.iso_datastore in " + vcenterConfigFile + " or pass --datastore",
+		}
+	}
+	clientCfg, err := vcenterClientConfig(vcCfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	vclient, err := vcenter.NewClient(ctx, clientCfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = vclient.Disconnect() }()
+
+	ds, err := vclient.FindDatastore(vcCfg.VCenter.Datacenter, name)
+	if err != nil {
+		return err
+	}
+	mgr, err := newISOManager(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(mgr, ds)
+}
+
+func printDatastoreArtifacts(dsName string, artifacts []iso.DatastoreArtifact) {
+	fmt.Printf("Datastore: %s\n", dsName)
+	if len(artifacts) == 0 {
+		fmt.Println("  (no vmbootstrap ISOs)")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tKIND\tSIZE\tUPLOADED\tMOUNTED BY")
+	var total, orphaned int64
+	for _, a := range artifacts {
+		mountedBy := "-"
+		if !a.Orphan() {
+			mountedBy = strings.Join(a.MountedBy, ", ")
+		} else {
+			orphaned += a.Size
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Path, a.Kind, formatSize(a.Size), formatAge(a.Modified), mountedBy)
+		total += a.Size
+	}
+	_ = tw.Flush()
+	fmt.Printf("Total: %s in %d ISOs, %s not mounted by any VM\n", formatSize(total), len(artifacts), formatSize(orphaned))
+}
+
+func runDatastoreGC(mgr *iso.Manager, ds *object.Datastore, opts iso.DatastoreGCOptions) error {
+	deleted, err := mgr.DatastoreGC(ds, opts)
+	var freed int64
+	for _, a := range deleted {
+		freed += a.Size
+		if opts.DryRun {
+			fmt.Printf("  → would delete [%s] %s (%s, %s, uploaded %s)\n", ds.Name(), a.Path, a.Kind, formatSize(a.Size), formatAge(a.Modified))
+		} else {
+			fmt.Printf("  \033[32m✓ deleted [%s] %s (%s, %s)\033[0m\n", ds.Name(), a.Path, a.Kind, formatSize(a.Size))
+		}
+	}
+	verb := "Freed"
+	if opts.DryRun {
+		verb = "Would free"
+	}
+	fmt.Printf("%s %s in %d orphaned ISOs\n", verb, formatSize(freed), len(deleted))
+	return err
+}
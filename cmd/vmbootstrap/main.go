@@ -2,17 +2,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 
 	wizard "github.com/infrakit-io/cli-wizard-core"
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
 	"github.com/spf13/cobra"
 )
 
 var vcenterConfigFile string
 var debugLogs bool
 var bootstrapResultPath string
+var sessionCacheDir string
 
 // mainSigCh receives SIGINT for the default (non-bootstrap) handler.
 // bootstrapVM temporarily stops delivery to this channel so it can handle
@@ -41,9 +47,6 @@ var runCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := checkRequirements(); err != nil {
-			return err
-		}
 		return runBootstrapSelector()
 	},
 }
@@ -54,9 +57,6 @@ var smokeCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := checkRequirements(); err != nil {
-			return err
-		}
 		vmPath, _ := cmd.Flags().GetString("config")
 		if vmPath == "" {
 			selectedPath, selectedLabel, err := selectVMConfig("\033[1mvmbootstrap\033[0m — Smoke Test", "Select VM config to smoke test:")
@@ -97,21 +97,107 @@ var talosConfigCmd = &cobra.Command{
 	},
 }
 
+// applyCLISettings passes the global flags (session cache, ISO cache, offline
+// mode) to cfg instead of changing process-wide defaults.
+func applyCLISettings(cfg *bootstrap.VMConfig) {
+	cfg.VCenterSessionCacheDir = sessionCacheDir
+	cfg.Options.CacheDir = cacheDir
+	cfg.Options.Offline = offlineMode
+}
+
+// vcenterClientConfig returns the connection settings of vc with the session
+// cache of --session-cache-dir.
+func vcenterClientConfig(vc *vcenterFileConfig) (*vcenter.Config, error) {
+	clientCfg, err := vc.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientCfg.SessionCacheDir = sessionCacheDir
+	return clientCfg, nil
+}
+
+// newISOManager returns an ISO manager on the --cache-dir cache.
+func newISOManager(ctx context.Context) (*iso.Manager, error) {
+	mgr := iso.NewManager(ctx)
+	if err := mgr.SetCacheDir(cacheDir); err != nil {
+		return nil, err
+	}
+	mgr.SetOffline(offlineMode)
+	return mgr, nil
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&vcenterConfigFile, "vcenter-config", resolveConfigPath("configs/vcenter.sops.yaml"),
-		"Path to vCenter config file (SOPS encrypted)")
+		"Path to vCenter config file (SOPS encrypted or plain YAML)")
 	rootCmd.PersistentFlags().BoolVar(&debugLogs, "debug", false, "Enable debug logging to tmp/vmbootstrap-debug.log")
+	rootCmd.PersistentFlags().StringVar(&sessionCacheDir, "session-cache-dir", configs.Defaults.VCenter.SessionCacheDir,
+		"Directory for encrypted vCenter session reuse between runs (default: vcenter.session_cache_dir; empty disables)")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", iso.DefaultCacheDir(),
+		"Local ISO cache shared by all checkouts (downloads, remasters, Talos images)")
+	rootCmd.PersistentFlags().StringVar(&seedListen, "seed-listen", configs.Defaults.ISO.SeedListen,
+		"Serve Ubuntu cloud-init seeds over HTTP on this address (e.g. :8600) instead of per-VM NoCloud ISOs")
+	rootCmd.PersistentFlags().StringVar(&seedURL, "seed-url", configs.Defaults.ISO.SeedURL,
+		"URL VMs reach the seed server at (default: http://<address used to reach vCenter>:<port>/)")
+	rootCmd.PersistentFlags().BoolVar(&offlineMode, "offline", configs.Defaults.ISO.Offline,
+		"Never download: resolve ISOs, OVAs and checksums from imported bundles only")
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(smokeCmd)
 	rootCmd.AddCommand(talosCmd)
 	talosCmd.AddCommand(talosConfigCmd)
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configRenderCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheVerifyCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(datastoreCmd)
+	datastoreCmd.AddCommand(datastoreListCmd)
+	datastoreCmd.AddCommand(datastoreGCCmd)
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleExportCmd)
+	bundleCmd.AddCommand(bundleImportCmd)
+	rootCmd.AddCommand(catalogCmd)
+	catalogCmd.AddCommand(catalogShowCmd)
+	catalogCmd.AddCommand(catalogUpdateCmd)
 
 	runCmd.Flags().StringVar(&bootstrapResultPath, "bootstrap-result", "",
 		"Write bootstrap result to YAML/JSON file (optional)")
 
-	smokeCmd.Flags().String("config", "", "Path to VM config file (SOPS encrypted)")
+	smokeCmd.Flags().String("config", "", "Path to VM config file (SOPS encrypted or plain YAML)")
 	smokeCmd.Flags().Bool("cleanup", false, "Delete VM after smoke test")
 
+	configRenderCmd.Flags().Bool("show-secrets", false, "Print passwords instead of <redacted>")
+	configRenderCmd.Flags().Bool("user-data", false, "Print the generated autoinstall user-data (dry run)")
+	configMigrateCmd.Flags().Bool("dry-run", false, "Only report which files would be migrated")
+
+	cacheVerifyCmd.Flags().Bool("remove", false, "Delete corrupt and stale entries")
+	cachePruneCmd.Flags().String("older-than", "", "Remove artifacts not used for this long (e.g. 30d, 12h)")
+	cachePruneCmd.Flags().Float64("max-size", 0, "Evict least recently used artifacts until the cache is at most this many GB")
+	cachePruneCmd.Flags().Bool("unused", false, "Remove releases not in the catalogs, stale remasters, NoCloud seeds and partial downloads")
+	cachePruneCmd.Flags().Bool("dry-run", false, "Only report what would be removed")
+
+	datastoreCmd.PersistentFlags().String("datastore", "", "ISO datastore (default: iso_datastore of the vCenter config)")
+	datastoreGCCmd.Flags().String("older-than", "24h", "Only delete ISOs uploaded longer ago (protects running bootstraps)")
+	datastoreGCCmd.Flags().StringSlice("kind", nil, "Only delete these kinds: ubuntu, ubuntu-autoinstall, talos, nocloud")
+	datastoreGCCmd.Flags().Bool("dry-run", false, "Only report what would be deleted")
+
+	bundleExportCmd.Flags().StringP("output", "o", "", "Bundle file to write (tar)")
+	bundleExportCmd.Flags().String("apt-mirror", "", "Directory of an apt mirror to include (optional)")
+	bundleExportCmd.Flags().String("schematics", "", "Talos schematics file to include (default: "+talosSchematicsConfigFile+" if present)")
+	bundleExportCmd.Flags().String("work-dir", "", "Keep staged downloads here for the next export (default: temporary)")
+	bundleImportCmd.Flags().Bool("content-library", false, "Import Talos OVAs into the content library of the vCenter config")
+	bundleImportCmd.Flags().Bool("no-seed", false, "Only extract; leave the ISO cache as is")
+	bundleImportCmd.Flags().Duration("timeout", configs.Defaults.Timeouts.Download(), "Max time to import the OVAs into the content library")
+
+	catalogUpdateCmd.Flags().String("ubuntu-url", "", "Ubuntu releases base URL (default: catalog.ubuntu_url)")
+	catalogUpdateCmd.Flags().String("talos-url", "", "Talos releases API URL (default: catalog.talos_url)")
+	catalogUpdateCmd.Flags().Int("talos-limit", 0, "Newest stable Talos releases to keep (default: catalog.talos_limit)")
+	catalogUpdateCmd.Flags().StringSlice("ubuntu", nil, "Only look up these Ubuntu series (e.g. 24.04)")
+	catalogUpdateCmd.Flags().Bool("dry-run", false, "Only report what would be written")
+	catalogUpdateCmd.Flags().Duration("timeout", configs.Defaults.Timeouts.Download(), "Max time per catalog request")
+
 }
 
 func main() {
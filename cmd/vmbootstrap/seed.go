@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/seed"
+)
+
+var (
+	seedListen string
+	seedURL    string
+)
+
+// startSeedServer starts the nocloud-net seed server for an Ubuntu bootstrap
+// when --seed-listen (iso.seed_listen) is set and attaches it to cfg.
+// The returned stop function is never nil.
+func startSeedServer(cfg *bootstrap.VMConfig, logger *slog.Logger) (func(), error) {
+	listen := seedListen
+	if listen == "" || cfg.EffectiveProfile() != "ubuntu" {
+		return func() {}, nil
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, &userError{msg: fmt.Sprintf("invalid seed listen address %q", listen), hint: "--seed-listen :8600"}
+	}
+	// The server builds the URL from the port it actually listens on, so
+	// ":0" works; only the host has to be guessed here.
+	var advertise string
+	if seedURL == "" && (host == "" || net.ParseIP(host).IsUnspecified()) {
+		if advertise, err = outboundIP(vcenterHostname(cfg.VCenterHost)); err != nil {
+			return nil, &userError{
+				msg:  fmt.Sprintf("cannot determine the address VMs reach the seed server at: %v", err),
+				hint: "--seed-url http://<this host>:" + port + "/",
+			}
+		}
+	}
+
+	srv := seed.NewServer(seed.Config{Listen: listen, URL: seedURL, AdvertiseHost: advertise, Logger: logger})
+	if err := srv.Start(); err != nil {
+		return nil, &userError{msg: err.Error(), hint: "choose a free port with --seed-listen"}
+	}
+	cfg.SeedServer = srv
+	fmt.Printf("  Seed:       %s (nocloud-net)\n", srv.URL())
+	return func() { _ = srv.Close() }, nil
+}
+
+// vcenterHostname returns the host name of a vCenter given as a host,
+// host:port or URL ("https://vc.example.com/sdk").
+func vcenterHostname(vcenter string) string {
+	if strings.Contains(vcenter, "://") {
+		if u, err := url.Parse(vcenter); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	if host, _, err := net.SplitHostPort(vcenter); err == nil {
+		return host
+	}
+	return strings.Trim(vcenter, "[]")
+}
+
+// outboundIP returns the local address this host uses to reach host, a
+// reasonable guess for an address VMs in the same vCenter can reach.
+// No packets are sent.
+func outboundIP(host string) (string, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "443"))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return "", fmt.Errorf("unexpected local address %s", conn.LocalAddr())
+	}
+	return addr.IP.String(), nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestVCenterHostname(t *testing.T) {
+	for in, want := range map[string]string{
+		"vc.example.com":                 "vc.example.com",
+		"vc.example.com:443":             "vc.example.com",
+		"https://vc.example.com/sdk":     "vc.example.com",
+		"https://vc.example.com:8443/":   "vc.example.com",
+		"10.0.0.10":                      "10.0.0.10",
+		"https://[2001:db8::10]:443/sdk": "2001:db8::10",
+	} {
+		if got := vcenterHostname(in); got != want {
+			t.Errorf("vcenterHostname(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -11,7 +11,6 @@ import (
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
-	"gopkg.in/yaml.v3"
 )
 
 // smokeVM bootstraps a VM and runs a minimal validation, then optionally cleans up.
@@ -20,41 +19,26 @@ func smokeVM(vmConfigPath string, cleanup bool) error {
 	fmt.Println(strings.Repeat("─", 50))
 	fmt.Println()
 
-	// Load vCenter config
-	vcCfg, err := loadVCenterConfig(vcenterConfigFile)
-	if err != nil {
-		return fmt.Errorf("failed to load vCenter config: %w", err)
-	}
-
-	// Decrypt VM config
-	vmOut, err := sopsDecrypt(vmConfigPath)
+	vmFile, cfg, err := loadVMRunConfig(vmConfigPath)
 	if err != nil {
 		return err
 	}
-
-	var vmFile vmFileConfig
-	if err := yaml.Unmarshal(vmOut, &vmFile); err != nil {
-		return fmt.Errorf("failed to parse VM config: %w", err)
-	}
-
 	v := vmFile.VM
-	profile := strings.TrimSpace(v.Profile)
-	if profile == "" {
-		profile = "ubuntu"
-	}
-	if profile != "ubuntu" {
+	if cfg.Profile != "ubuntu" {
 		return &userError{
-			msg:  fmt.Sprintf("smoke is supported only for ubuntu profile (got %q)", profile),
+			msg:  fmt.Sprintf("smoke is supported only for ubuntu profile (got %q)", cfg.Profile),
 			hint: "Use 'make vm-deploy' with ubuntu profile for smoke tests.",
 		}
 	}
+	cfg.SkipSSHVerify = true
+	cfg.SkipCleanupOnError = true
 
-	// Load SSH key
-	sshKey, err := loadSSHKey(v.SSHKeyPath, v.SSHKey)
-	if err != nil {
-		return err
-	}
-	sshKeyPath, cleanupKey, err := prepareSSHKeyPath(v.SSHKeyPath, sshKey)
+	// The overall deadline, post-install checks included, comes from
+	// vm.timeout_minutes via cfg.Options.Timeout.
+	ctx, cancel := contextWithOptionalTimeout(cfg.Options.Timeout)
+	defer cancel()
+
+	sshKeyPath, cleanupKey, err := prepareSSHKeyPath(v.SSHKeyPath, firstSSHKey(cfg))
 	if err != nil {
 		return err
 	}
@@ -62,56 +46,6 @@ func smokeVM(vmConfigPath string, cleanup bool) error {
 		defer cleanupKey()
 	}
 
-	cfg := &bootstrap.VMConfig{
-		VCenterHost:     vcCfg.VCenter.Host,
-		VCenterUsername: vcCfg.VCenter.Username,
-		VCenterPassword: vcCfg.VCenter.Password,
-		VCenterPort:     vcCfg.VCenter.Port,
-		VCenterInsecure: vcCfg.VCenter.Insecure,
-
-		Name:               v.Name,
-		Profile:            v.Profile,
-		CPUs:               v.CPUs,
-		MemoryMB:           v.MemoryMB,
-		DiskSizeGB:         v.DiskSizeGB,
-		Username:           v.Username,
-		SSHPublicKeys:      []string{sshKey},
-		Password:           v.Password,
-		AllowPasswordSSH:   v.AllowPasswordSSH,
-		SkipSSHVerify:      true,
-		SkipCleanupOnError: true,
-
-		NetworkName: v.NetworkName,
-		IPAddress:   v.IPAddress,
-		Netmask:     v.Netmask,
-		Gateway:     v.Gateway,
-		DNS:         buildDNS(v.DNS, v.DNS2),
-
-		Datacenter:       vcCfg.VCenter.Datacenter,
-		Folder:           v.Folder,
-		ResourcePool:     v.ResourcePool,
-		Datastore:        v.Datastore,
-		ISODatastore:     vcCfg.VCenter.ISODatastore,
-		ContentLibrary:   vcCfg.VCenter.ContentLibrary,
-		ContentLibraryID: vcCfg.VCenter.ContentLibraryID,
-	}
-	if cfg.Profile == "" {
-		cfg.Profile = "ubuntu"
-	}
-	cfg.Profiles.Ubuntu.Version = v.Profiles.Ubuntu.Version
-	cfg.Profiles.Talos.Version = v.Profiles.Talos.Version
-	cfg.Profiles.Talos.SchematicID = v.Profiles.Talos.SchematicID
-
-	if v.DataDiskSizeGB > 0 {
-		size := v.DataDiskSizeGB
-		cfg.DataDiskSizeGB = &size
-		cfg.DataDiskMountPath = v.DataDiskMountPath
-	}
-	if v.SwapSizeGB > 0 {
-		size := v.SwapSizeGB
-		cfg.SwapSizeGB = &size
-	}
-
 	// If VM already exists, ask whether to reuse or recreate.
 	if exists, err := vmExists(cfg); err == nil && exists {
 		fmt.Printf("\n\033[33m⚠ VM already exists: %s\033[0m\n", cfg.Name)
@@ -127,7 +61,7 @@ func smokeVM(vmConfigPath string, cleanup bool) error {
 		fmt.Println()
 		switch choice {
 		case "Reuse existing VM":
-			return runSmokeChecksOnly(cfg, v.DataDiskMountPath, v.SwapSizeGB, sshKeyPath, v.SSHPort, cleanup, 0)
+			return runSmokeChecksOnly(ctx, cfg, v.DataDiskMountPath, v.EffectiveSwapSizeGB(), sshKeyPath, v.SSHPort, cleanup, 0)
 		case "Create new VM (delete existing)":
 			if !readYesNoDanger("Delete existing VM before creating new?") {
 				fmt.Println("  Cancelled.")
@@ -144,8 +78,11 @@ func smokeVM(vmConfigPath string, cleanup bool) error {
 
 	// Bootstrap
 	logger := getLogger()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(45)*time.Minute)
-	defer cancel()
+	stopSeed, err := startSeedServer(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer stopSeed()
 
 	// Handle Ctrl+C locally for cleanup
 	signal.Stop(mainSigCh)
@@ -203,21 +140,16 @@ func smokeVM(vmConfigPath string, cleanup bool) error {
 	fmt.Printf("  IP:        %s\n", vm.IPAddress)
 	fmt.Printf("  SSH ready: %v\n", vm.SSHReady)
 
-	return runSmokeChecksOnly(cfg, v.DataDiskMountPath, v.SwapSizeGB, sshKeyPath, v.SSHPort, cleanup, 60)
+	return runSmokeChecksOnly(ctx, cfg, v.DataDiskMountPath, v.EffectiveSwapSizeGB(), sshKeyPath, v.SSHPort, cleanup, 60)
 }
 
-func loadSSHKey(path, raw string) (string, error) {
-	if path != "" {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return "", fmt.Errorf("failed to read SSH key %s: %w", path, err)
-		}
-		return strings.TrimSpace(string(data)), nil
-	}
-	if raw == "" {
-		return "", fmt.Errorf("either vm.ssh_key or vm.ssh_key_path is required")
+// contextWithOptionalTimeout returns a context with deadline d from now, or
+// without a deadline when d <= 0.
+func contextWithOptionalTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(context.Background())
 	}
-	return raw, nil
+	return context.WithTimeout(context.Background(), d)
 }
 
 func prepareSSHKeyPath(path, raw string) (string, func(), error) {
@@ -250,13 +182,7 @@ func cleanupVM(cfg *bootstrap.VMConfig, name string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	vclient, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     cfg.VCenterHost,
-		Username: cfg.VCenterUsername,
-		Password: cfg.VCenterPassword,
-		Port:     cfg.VCenterPort,
-		Insecure: cfg.VCenterInsecure,
-	})
+	vclient, err := vcenter.NewClient(ctx, cfg.VCenterConfig())
 	if err != nil {
 		fmt.Printf("\033[31m✗ %v\033[0m\n", err)
 		return fmt.Errorf("connect: %w", err)
@@ -293,13 +219,7 @@ func cleanupVM(cfg *bootstrap.VMConfig, name string) error {
 func vmExists(cfg *bootstrap.VMConfig) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	vclient, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     cfg.VCenterHost,
-		Username: cfg.VCenterUsername,
-		Password: cfg.VCenterPassword,
-		Port:     cfg.VCenterPort,
-		Insecure: cfg.VCenterInsecure,
-	})
+	vclient, err := vcenter.NewClient(ctx, cfg.VCenterConfig())
 	if err != nil {
 		return false, err
 	}
@@ -311,14 +231,17 @@ func vmExists(cfg *bootstrap.VMConfig) (bool, error) {
 	return vmObj != nil, nil
 }
 
-func runSmokeChecksOnly(cfg *bootstrap.VMConfig, dataMount string, swapSizeGB int, keyPath string, sshPort int, cleanup bool, settleSeconds int) error {
+func runSmokeChecksOnly(ctx context.Context, cfg *bootstrap.VMConfig, dataMount string, swapSizeGB int, keyPath string, sshPort int, cleanup bool, settleSeconds int) error {
 	if settleSeconds > 0 {
 		fmt.Printf("  Waiting %ds for services to settle...\n", settleSeconds)
-		time.Sleep(time.Duration(settleSeconds) * time.Second)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(settleSeconds) * time.Second):
+		}
 	}
 
 	fmt.Println("  Running SSH checks...")
-	if err := smokeSSHChecks(cfg.Username, cfg.IPAddress, keyPath, sshPort, dataMount, swapSizeGB); err != nil {
+	if err := smokeSSHChecks(ctx, cfg.Username, cfg.IPAddress, keyPath, sshPort, dataMount, swapSizeGB); err != nil {
 		fmt.Printf("\033[31m✗ Smoke checks failed: %v\033[0m\n", err)
 	} else {
 		fmt.Println("\033[32m✓ Smoke checks passed\033[0m")
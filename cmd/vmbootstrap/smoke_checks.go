@@ -9,7 +9,7 @@ import (
 	"time"
 )
 
-func smokeSSHChecks(user, ip, keyPath string, sshPort int, dataMount string, swapSizeGB int) error {
+func smokeSSHChecks(ctx context.Context, user, ip, keyPath string, sshPort int, dataMount string, swapSizeGB int) error {
 	if ip == "" {
 		return fmt.Errorf("missing VM IP")
 	}
@@ -17,14 +17,14 @@ func smokeSSHChecks(user, ip, keyPath string, sshPort int, dataMount string, swa
 		sshPort = 22
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 7*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 7*time.Minute)
 	defer cancel()
 
 	// Basic connectivity + hostname (retry up to ~6 minutes)
 	var lastErr error
 	attempt := 0
 	deadline := time.Now().Add(6 * time.Minute)
-	for time.Now().Before(deadline) {
+	for time.Now().Before(deadline) && ctx.Err() == nil {
 		attempt++
 		if _, err := sshExecAuto(ctx, user, ip, sshPort, keyPath, "hostname"); err == nil {
 			lastErr = nil
@@ -32,7 +32,10 @@ func smokeSSHChecks(user, ip, keyPath string, sshPort int, dataMount string, swa
 		} else {
 			lastErr = err
 			fmt.Printf("  SSH not ready (attempt %d), retrying in 15s...\n", attempt)
-			time.Sleep(15 * time.Second)
+			select {
+			case <-ctx.Done():
+			case <-time.After(15 * time.Second):
+			}
 		}
 	}
 	if lastErr != nil {
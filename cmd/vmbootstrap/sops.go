@@ -1,74 +1,56 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+
+	pkgconfig "github.com/infrakit-io/vmware-vm-bootstrap/pkg/config"
 )
 
-// sopsDecrypt decrypts a SOPS-encrypted file and returns the plaintext content.
+// sopsDecrypt returns the plaintext content of a config file.
+// SOPS-encrypted files are decrypted in-process; plain YAML is returned unchanged.
 func sopsDecrypt(path string) ([]byte, error) {
-	out, err := exec.Command("sops", "-d", path).Output()
+	out, err := pkgconfig.ReadFile(path)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("sops -d %s: %s", filepath.Base(path), string(exitErr.Stderr))
+		if !errors.Is(err, os.ErrNotExist) && !ageKeyAvailable() {
+			return nil, &userError{
+				msg:  err.Error(),
+				hint: "Set SOPS_AGE_KEY_FILE (or SOPS_AGE_KEY) or create " + defaultAgeKeyFile(),
+			}
 		}
-		return nil, fmt.Errorf("sops -d %s: %w", filepath.Base(path), err)
+		return nil, err
 	}
 	return out, nil
 }
 
-// sopsEncrypt writes plaintext YAML to path and encrypts it in-place with SOPS.
+// sopsEncrypt encrypts plaintext YAML with SOPS and writes it to path.
 // The file must match a creation_rule in .sops.yaml.
 func sopsEncrypt(path string, plaintext []byte) error {
-	cmd := exec.Command("sops",
-		"-e",
-		"--input-type", "yaml",
-		"--output-type", "yaml",
-		"--filename-override", path,
-		"/dev/stdin",
-	)
-	cmd.Stdin = bytes.NewReader(plaintext)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		msg := strings.TrimSpace(string(out))
-		if strings.Contains(msg, "no matching creation rules found") {
-			return &userError{
-				msg:  "sops config has no matching creation rules",
-				hint: "Edit .sops.yaml (see .sops.yaml.example) so configs/*.sops.yaml matches",
-			}
-		}
-		return fmt.Errorf("sops -e (stdin): %s", msg)
-	}
-
-	if err := os.WriteFile(path, out, 0600); err != nil {
-		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
-	}
-	return nil
+	return sopsWriteError(pkgconfig.EncryptFile(path, plaintext))
 }
 
-// checkRequirements verifies SOPS is available and the AGE key is accessible.
-func checkRequirements() error {
-	// Check sops binary
-	if _, err := exec.LookPath("sops"); err != nil {
+// sopsWriteError turns pkg/config encryption errors into actionable CLI errors.
+func sopsWriteError(err error) error {
+	switch {
+	case errors.Is(err, pkgconfig.ErrNoCreationRule):
 		return &userError{
-			msg:  "'sops' not found in PATH",
-			hint: "make install-requirements",
+			msg:  "sops config has no matching creation rules",
+			hint: "Edit .sops.yaml (see .sops.yaml.example) so configs/*.sops.yaml matches",
 		}
+	case errors.Is(err, pkgconfig.ErrNoSOPSConfig):
+		return sopsConfigMissingError()
 	}
+	return err
+}
 
-	// Check .sops.yaml
+// checkRequirements verifies the SOPS setup needed to write encrypted config files.
+// Read-only commands do not need it: decryption only needs the age key, and plain
+// YAML files need nothing at all.
+func checkRequirements() error {
 	if _, err := os.Stat(".sops.yaml"); os.IsNotExist(err) {
-		if _, exErr := os.Stat(".sops.yaml.example"); exErr == nil {
-			return &userError{
-				msg:  "'.sops.yaml' not found — run from project root or create it",
-				hint: "cp .sops.yaml.example .sops.yaml",
-			}
-		}
-		return fmt.Errorf("'.sops.yaml' not found in current directory — run from project root")
+		return sopsConfigMissingError()
 	}
 
 	// Check .sopsrc (optional but recommended)
@@ -80,15 +62,36 @@ func checkRequirements() error {
 			}
 		}
 	}
+	return nil
+}
 
-	// Check AGE key (standard location)
-	ageKeyFile := os.ExpandEnv("$HOME/.config/sops/age/keys.txt")
-	if envKey := os.Getenv("SOPS_AGE_KEY_FILE"); envKey != "" {
-		ageKeyFile = envKey
+func sopsConfigMissingError() error {
+	if _, exErr := os.Stat(".sops.yaml.example"); exErr == nil {
+		return &userError{
+			msg:  "'.sops.yaml' not found — run from project root or create it",
+			hint: "cp .sops.yaml.example .sops.yaml",
+		}
 	}
-	if _, err := os.Stat(ageKeyFile); os.IsNotExist(err) {
-		return fmt.Errorf("AGE key not found at %s — set SOPS_AGE_KEY_FILE or create the key", ageKeyFile)
+	return fmt.Errorf("'.sops.yaml' not found in current directory — run from project root")
+}
+
+// defaultAgeKeyFile returns the age key location sops uses when SOPS_AGE_KEY_FILE is unset.
+func defaultAgeKeyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return os.ExpandEnv("$HOME/.config/sops/age/keys.txt")
 	}
+	return filepath.Join(dir, "sops", "age", "keys.txt")
+}
 
-	return nil
+func ageKeyAvailable() bool {
+	if os.Getenv("SOPS_AGE_KEY") != "" || os.Getenv("SOPS_AGE_KEY_CMD") != "" {
+		return true
+	}
+	keyFile := os.Getenv("SOPS_AGE_KEY_FILE")
+	if keyFile == "" {
+		keyFile = defaultAgeKeyFile()
+	}
+	_, err := os.Stat(keyFile)
+	return err == nil
 }
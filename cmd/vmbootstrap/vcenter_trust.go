@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+)
+
+// confirmVCenterTrust offers trust-on-first-use for a vCenter certificate that is
+// neither trusted by the configured roots nor already pinned. On confirmation the
+// SHA-256 thumbprint is stored in vc so it is saved with the rest of vcenter.sops.yaml.
+func confirmVCenterTrust(vc *vcenterFileConfig) {
+	v := &vc.VCenter
+	if v.Insecure || strings.TrimSpace(v.Host) == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var cert *vcenter.CertificateInfo
+	clientCfg, err := vc.ClientConfig()
+	if err == nil {
+		cert, err = vcenter.ProbeCertificate(ctx, clientCfg)
+	}
+	if err != nil {
+		fmt.Printf("  \033[33m⚠ Could not check vCenter certificate: %v\033[0m\n", err)
+		return
+	}
+	if cert.Pinned || (cert.Trusted && v.Thumbprint == "") {
+		return
+	}
+
+	fmt.Println()
+	if v.Thumbprint != "" {
+		fmt.Println("  \033[31m⚠ vCenter certificate does not match the pinned thumbprint!\033[0m")
+		fmt.Printf("  Pinned:   %s\n", v.Thumbprint)
+	} else {
+		fmt.Println("  \033[33m⚠ vCenter certificate is not trusted by this system.\033[0m")
+	}
+	fmt.Printf("  Subject:  %s\n", cert.Subject)
+	fmt.Printf("  Issuer:   %s\n", cert.Issuer)
+	fmt.Printf("  Expires:  %s\n", cert.NotAfter.Format("2006-01-02"))
+	fmt.Printf("  SHA-256:  %s\n", cert.SHA256)
+	fmt.Println()
+
+	if readYesNo("Trust this certificate and pin its thumbprint?", false) {
+		v.Thumbprint = cert.SHA256
+	}
+}
@@ -34,6 +34,10 @@ type VCenterCatalog struct {
 	Pools      []vcenter.ResourcePoolInfo
 }
 
+// vcenterInventoryCacheTTL keeps vCenter listings warm across wizard steps,
+// which each open their own connection.
+const vcenterInventoryCacheTTL = 2 * time.Minute
+
 type VCenterResources struct {
 	Datastores []vcenter.DatastoreInfo
 	Networks   []vcenter.NetworkInfo
@@ -53,13 +57,12 @@ func fetchVCenterResources(vcCfg *vcenterFileConfig, timeout time.Duration) (*VC
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	vclient, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     vcCfg.VCenter.Host,
-		Username: vcCfg.VCenter.Username,
-		Password: vcCfg.VCenter.Password,
-		Port:     vcCfg.VCenter.Port,
-		Insecure: vcCfg.VCenter.Insecure,
-	})
+	clientCfg, err := vcenterClientConfig(vcCfg)
+	if err != nil {
+		return nil, err
+	}
+	clientCfg.CacheTTL = vcenterInventoryCacheTTL
+	vclient, err := vcenter.NewClient(ctx, clientCfg)
 	if err != nil {
 		return nil, err
 	}
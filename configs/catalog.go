@@ -0,0 +1,180 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogOverride is the user-level release catalog written by
+// `vmbootstrap catalog update` (and `bundle import`). It is merged over the
+// built-in catalogs, so new point releases need no new build. Signing keys
+// and the keyserver always come from the built-in catalog.
+type CatalogOverride struct {
+	UpdatedAt time.Time                `yaml:"updated_at"`
+	Ubuntu    map[string]UbuntuRelease `yaml:"ubuntu,omitempty"` // version → release, replaces the built-in entry
+	Talos     []string                 `yaml:"talos,omitempty"`  // added to the built-in versions
+}
+
+// CatalogOverridePath returns catalog.override_file, or
+// <user config dir>/vmbootstrap/catalog.yaml when it is empty.
+func CatalogOverridePath() string {
+	if p := Defaults.Catalog.OverrideFile; p != "" {
+		return p
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(base, "vmbootstrap", "catalog.yaml")
+}
+
+// LoadCatalogOverride reads the override catalog at path ("" =
+// CatalogOverridePath()). A missing file is an empty override.
+func LoadCatalogOverride(path string) (*CatalogOverride, error) {
+	if path == "" {
+		path = CatalogOverridePath()
+	}
+	c := &CatalogOverride{}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid release catalog %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the override catalog to path ("" = CatalogOverridePath()).
+func (c *CatalogOverride) Save(path string) error {
+	if path == "" {
+		path = CatalogOverridePath()
+	}
+	if path == "" {
+		return fmt.Errorf("no release catalog path: set catalog.override_file")
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append([]byte("# Written by vmbootstrap catalog update; merged over the built-in release catalogs.\n"), data...), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Merge adds the releases and versions of other, replacing Ubuntu releases
+// of the same version.
+func (c *CatalogOverride) Merge(other *CatalogOverride) {
+	if len(other.Ubuntu) > 0 && c.Ubuntu == nil {
+		c.Ubuntu = map[string]UbuntuRelease{}
+	}
+	for v, r := range other.Ubuntu {
+		c.Ubuntu[v] = r
+	}
+	c.Talos = SortTalosVersions(append(c.Talos, other.Talos...))
+	if other.UpdatedAt.After(c.UpdatedAt) {
+		c.UpdatedAt = other.UpdatedAt
+	}
+}
+
+// loadOverrideOrWarn returns the override catalog, or an empty one when it
+// cannot be read, so a broken file never hides the built-in releases.
+func loadOverrideOrWarn() *CatalogOverride {
+	c, err := LoadCatalogOverride("")
+	if err != nil {
+		fmt.Printf("⚠️  Ignoring release catalog override: %v\n", err)
+		return &CatalogOverride{}
+	}
+	return c
+}
+
+// MergedUbuntuReleases returns the built-in Ubuntu releases with the
+// override catalog applied.
+func MergedUbuntuReleases() map[string]UbuntuRelease {
+	result := make(map[string]UbuntuRelease, len(UbuntuReleases.Releases))
+	for v, r := range UbuntuReleases.Releases {
+		result[v] = r
+	}
+	for v, r := range loadOverrideOrWarn().Ubuntu {
+		if strings.TrimSpace(r.URL) != "" {
+			result[v] = r
+		}
+	}
+	return result
+}
+
+// MergedTalosVersions returns the built-in and override Talos versions,
+// newest first.
+func MergedTalosVersions() []string {
+	versions := append([]string{}, TalosReleases.Versions...)
+	return SortTalosVersions(append(versions, loadOverrideOrWarn().Talos...))
+}
+
+// SortTalosVersions normalizes versions to the "v" form, drops duplicates
+// and sorts them newest first (a release sorts after its pre-releases).
+func SortTalosVersions(versions []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range versions {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.HasPrefix(v, "v") {
+			v = "v" + v
+		}
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return CompareVersions(out[i], out[j]) > 0 })
+	return out
+}
+
+// CompareVersions compares "v1.12.4", "v1.13.0-beta.1" and "24.04.4" style
+// versions; the result is negative, zero or positive like strings.Compare.
+func CompareVersions(a, b string) int {
+	coreA, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	coreB, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	pa, pb := strings.Split(coreA, "."), strings.Split(coreB, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return strings.Compare(preA, preB)
+}
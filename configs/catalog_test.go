@@ -0,0 +1,68 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortTalosVersions(t *testing.T) {
+	got := SortTalosVersions([]string{"1.11.9", "v1.12.0", "v1.12.0-beta.1", " v1.12.10 ", "v1.12.0", ""})
+	want := "v1.12.10,v1.12.0,v1.12.0-beta.1,v1.11.9"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("SortTalosVersions = %v, want %s", got, want)
+	}
+	if CompareVersions("24.04.10", "24.04.9") <= 0 || CompareVersions("24.04", "24.04.0") != 0 {
+		t.Fatal("CompareVersions does not compare numerically")
+	}
+}
+
+func TestCatalogOverride_MergedIntoBuiltinCatalogs(t *testing.T) {
+	old := Defaults.Catalog
+	Defaults.Catalog.OverrideFile = filepath.Join(t.TempDir(), "catalog.yaml")
+	t.Cleanup(func() { Defaults.Catalog = old })
+
+	c, err := LoadCatalogOverride("")
+	if err != nil || len(c.Ubuntu) != 0 || len(c.Talos) != 0 {
+		t.Fatalf("missing override = %+v, %v", c, err)
+	}
+	c.Merge(&CatalogOverride{
+		UpdatedAt: time.Now(),
+		Ubuntu: map[string]UbuntuRelease{
+			"24.04": {URL: "https://releases.invalid/24.04/ubuntu-24.04.99-live-server-amd64.iso", Checksum: "abc"},
+			"26.04": {URL: "https://releases.invalid/26.04/ubuntu-26.04-live-server-amd64.iso", Checksum: "def"},
+			"30.04": {}, // no URL: never replaces a release
+		},
+		Talos: []string{"v9.0.0", TalosReleases.Versions[0]},
+	})
+	if err := c.Save(""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	releases := MergedUbuntuReleases()
+	if releases["24.04"].Checksum != "abc" || releases["26.04"].Checksum != "def" {
+		t.Fatalf("override releases not merged: %+v", releases)
+	}
+	if _, ok := releases["30.04"]; ok {
+		t.Fatal("release without URL merged")
+	}
+	for v, r := range UbuntuReleases.Releases {
+		if v != "24.04" && releases[v].URL != r.URL {
+			t.Fatalf("built-in release %s replaced", v)
+		}
+	}
+
+	versions := MergedTalosVersions()
+	if versions[0] != "v9.0.0" || len(versions) != len(SortTalosVersions(TalosReleases.Versions))+1 {
+		t.Fatalf("MergedTalosVersions = %v", versions)
+	}
+
+	if err := os.WriteFile(Defaults.Catalog.OverrideFile, []byte("ubuntu: [broken"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := MergedUbuntuReleases(); len(got) != len(UbuntuReleases.Releases) {
+		t.Fatalf("broken override: got %d releases", len(got))
+	}
+}
@@ -109,19 +109,45 @@ type LibDefaults struct {
 	Talos     TalosDefaults     `yaml:"talos"`
 	CloudInit CloudInitDefaults `yaml:"cloudinit"`
 	Timeouts  TimeoutDefaults   `yaml:"timeouts"`
+	Snapshots SnapshotDefaults  `yaml:"snapshots"`
 	ISO       ISODefaults       `yaml:"iso"`
+	Catalog   CatalogDefaults   `yaml:"catalog"`
 	Output    OutputDefaults    `yaml:"output"`
 }
 
 // VCenterDefaults holds vCenter connection defaults.
 type VCenterDefaults struct {
-	Port int `yaml:"port"`
+	Port             int    `yaml:"port"`
+	SessionCacheDir  string `yaml:"session_cache_dir"`
+	KeepAliveSeconds int    `yaml:"keepalive_seconds"`
+}
+
+// KeepAlive returns the vCenter session keep-alive interval (0 = disabled).
+func (v VCenterDefaults) KeepAlive() time.Duration {
+	return time.Duration(v.KeepAliveSeconds) * time.Second
 }
 
 // VMDefaults holds VM hardware defaults.
 type VMDefaults struct {
-	Firmware string `yaml:"firmware"`
-	GuestOS  string `yaml:"guest_os"`
+	Firmware string                       `yaml:"firmware"`
+	GuestOS  string                       `yaml:"guest_os"`
+	Profiles map[string]VMProfileDefaults `yaml:"profiles"`
+	Classes  map[string]VMClassDefaults   `yaml:"classes"`
+}
+
+// VMProfileDefaults holds per-OS-profile hardware defaults.
+type VMProfileDefaults struct {
+	GuestOS         string            `yaml:"guest_os"`
+	HardwareVersion string            `yaml:"hardware_version"`
+	ExtraConfig     map[string]string `yaml:"extra_config"`
+}
+
+// VMClassDefaults is a named VM size class (vm.class in VM files).
+type VMClassDefaults struct {
+	CPUs           int `yaml:"cpus,omitempty"`
+	MemoryMB       int `yaml:"memory_mb,omitempty"`
+	DiskSizeGB     int `yaml:"disk_size_gb,omitempty"`
+	DataDiskSizeGB int `yaml:"data_disk_size_gb,omitempty"`
 }
 
 // NetworkDefaults holds network configuration defaults.
@@ -175,17 +201,22 @@ type CloudInitDefaults struct {
 
 // TimeoutDefaults holds all timeout and retry values.
 type TimeoutDefaults struct {
-	InstallationMinutes    int `yaml:"installation_minutes"`
-	PollingSeconds         int `yaml:"polling_seconds"`
-	HostnameChecks         int `yaml:"hostname_checks"`
-	ServiceStartupSeconds  int `yaml:"service_startup_seconds"`
-	SSHRetries             int `yaml:"ssh_retries"`
-	SSHConnectSeconds      int `yaml:"ssh_connect_seconds"`
-	SSHRetryDelaySeconds   int `yaml:"ssh_retry_delay_seconds"`
-	HardwareInitSeconds    int `yaml:"hardware_init_seconds"`
-	DownloadMinutes        int `yaml:"download_minutes"`
-	UploadProgressSeconds  int `yaml:"upload_progress_seconds"`
-	ExtractProgressSeconds int `yaml:"extract_progress_seconds"`
+	InstallationMinutes       int `yaml:"installation_minutes"`
+	PollingSeconds            int `yaml:"polling_seconds"`
+	HostnameChecks            int `yaml:"hostname_checks"`
+	ServiceStartupSeconds     int `yaml:"service_startup_seconds"`
+	SSHRetries                int `yaml:"ssh_retries"`
+	SSHConnectSeconds         int `yaml:"ssh_connect_seconds"`
+	SSHRetryDelaySeconds      int `yaml:"ssh_retry_delay_seconds"`
+	HardwareInitSeconds       int `yaml:"hardware_init_seconds"`
+	GuestShutdownSeconds      int `yaml:"guest_shutdown_seconds"`
+	DownloadMinutes           int `yaml:"download_minutes"`
+	DownloadRetries           int `yaml:"download_retries"`
+	DownloadRetryDelaySeconds int `yaml:"download_retry_delay_seconds"`
+	UploadRetries             int `yaml:"upload_retries"`
+	UploadRetryDelaySeconds   int `yaml:"upload_retry_delay_seconds"`
+	UploadProgressSeconds     int `yaml:"upload_progress_seconds"`
+	ExtractProgressSeconds    int `yaml:"extract_progress_seconds"`
 }
 
 // As time.Duration convenience methods.
@@ -208,9 +239,18 @@ func (t TimeoutDefaults) SSHRetryDelay() time.Duration {
 func (t TimeoutDefaults) HardwareInit() time.Duration {
 	return time.Duration(t.HardwareInitSeconds) * time.Second
 }
+func (t TimeoutDefaults) GuestShutdown() time.Duration {
+	return time.Duration(t.GuestShutdownSeconds) * time.Second
+}
 func (t TimeoutDefaults) Download() time.Duration {
 	return time.Duration(t.DownloadMinutes) * time.Minute
 }
+func (t TimeoutDefaults) DownloadRetryDelay() time.Duration {
+	return time.Duration(t.DownloadRetryDelaySeconds) * time.Second
+}
+func (t TimeoutDefaults) UploadRetryDelay() time.Duration {
+	return time.Duration(t.UploadRetryDelaySeconds) * time.Second
+}
 func (t TimeoutDefaults) UploadProgress() time.Duration {
 	return time.Duration(t.UploadProgressSeconds) * time.Second
 }
@@ -218,14 +258,37 @@ func (t TimeoutDefaults) ExtractProgress() time.Duration {
 	return time.Duration(t.ExtractProgressSeconds) * time.Second
 }
 
+// SnapshotDefaults holds automatic pre-change snapshot defaults.
+type SnapshotDefaults struct {
+	NamePrefix     string `yaml:"name_prefix"`
+	RetentionHours int    `yaml:"retention_hours"`
+	Keep           int    `yaml:"keep"`
+}
+
+func (s SnapshotDefaults) Retention() time.Duration {
+	return time.Duration(s.RetentionHours) * time.Hour
+}
+
 // ISODefaults holds ISO-related defaults.
 type ISODefaults struct {
 	NoCloudVolumeID      string `yaml:"nocloud_volume_id"`
 	GRUBTimeoutSeconds   int    `yaml:"grub_timeout_seconds"`
 	CacheDir             string `yaml:"cache_dir"`
+	CacheMaxSizeGB       int    `yaml:"cache_max_size_gb"`
+	UploadVerify         string `yaml:"upload_verify"`
+	SeedListen           string `yaml:"seed_listen"`
+	SeedURL              string `yaml:"seed_url"`
+	Offline              bool   `yaml:"offline"`
 	UbuntuModifiedSuffix string `yaml:"ubuntu_modified_suffix"`
-	ExtractDirName       string `yaml:"extract_dir_name"`
-	UbuntuVolumeID       string `yaml:"ubuntu_volume_id"`
+}
+
+// CatalogDefaults holds the release catalog override location and the
+// endpoints `vmbootstrap catalog update` reads.
+type CatalogDefaults struct {
+	OverrideFile string `yaml:"override_file"`
+	UbuntuURL    string `yaml:"ubuntu_url"`
+	TalosURL     string `yaml:"talos_url"`
+	TalosLimit   int    `yaml:"talos_limit"`
 }
 
 // OutputDefaults holds CLI output defaults.
@@ -236,13 +299,16 @@ type OutputDefaults struct {
 
 // UbuntuRelease holds download info for a single Ubuntu release.
 type UbuntuRelease struct {
-	URL      string `yaml:"url"`
-	Checksum string `yaml:"checksum"`
+	URL      string   `yaml:"url"`
+	Mirrors  []string `yaml:"mirrors"`  // tried in order when url fails
+	Checksum string   `yaml:"checksum"` // empty = taken from the signed SHA256SUMS beside url
 }
 
 // ReleasesConfig holds all known Ubuntu releases.
 type ReleasesConfig struct {
-	Releases map[string]UbuntuRelease `yaml:"releases"`
+	SigningKeys []string                 `yaml:"signing_keys"` // pinned OpenPGP fingerprints for SHA256SUMS.gpg
+	Keyserver   string                   `yaml:"keyserver"`
+	Releases    map[string]UbuntuRelease `yaml:"releases"`
 }
 
 // TalosReleasesConfig holds Talos versions used by config wizard.
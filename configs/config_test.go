@@ -21,7 +21,9 @@ func TestDefaultsLoaded(t *testing.T) {
 		{"CloudInit.Locale", Defaults.CloudInit.Locale, "en_US.UTF-8"},
 		{"CloudInit.Timezone", Defaults.CloudInit.Timezone, "UTC"},
 		{"ISO.NoCloudVolumeID", Defaults.ISO.NoCloudVolumeID, "CIDATA"},
-		{"ISO.CacheDir", Defaults.ISO.CacheDir, "cache"},
+		{"ISO.CacheDir", Defaults.ISO.CacheDir, ""},
+		{"Snapshots.NamePrefix", Defaults.Snapshots.NamePrefix, "pre-change"},
+		{"Snapshots.Keep", Defaults.Snapshots.Keep, 3},
 	}
 
 	for _, tt := range tests {
@@ -33,6 +35,22 @@ func TestDefaultsLoaded(t *testing.T) {
 	}
 }
 
+func TestVMProfileDefaultsLoaded(t *testing.T) {
+	talos, ok := Defaults.VM.Profiles["talos"]
+	if !ok {
+		t.Fatal("vm.profiles.talos defaults missing")
+	}
+	if talos.GuestOS != "other5xLinux64Guest" {
+		t.Errorf("talos guest_os = %q, want other5xLinux64Guest", talos.GuestOS)
+	}
+	if talos.ExtraConfig["disk.EnableUUID"] != "TRUE" {
+		t.Errorf("talos extra_config disk.EnableUUID = %q, want TRUE", talos.ExtraConfig["disk.EnableUUID"])
+	}
+	if Defaults.VM.Profiles["ubuntu"].GuestOS != "ubuntu64Guest" {
+		t.Errorf("ubuntu guest_os = %q, want ubuntu64Guest", Defaults.VM.Profiles["ubuntu"].GuestOS)
+	}
+}
+
 func TestTalosPlanNetworkDefaultsLoaded(t *testing.T) {
 	n := Defaults.Talos.PlanNetwork
 	if n.CIDR == "" || n.StartIP == "" || n.Gateway == "" || n.DNS == "" {
@@ -53,7 +71,9 @@ func TestTimeoutDurationsPositive(t *testing.T) {
 		{"SSHConnect", d.SSHConnect()},
 		{"SSHRetryDelay", d.SSHRetryDelay()},
 		{"HardwareInit", d.HardwareInit()},
+		{"GuestShutdown", d.GuestShutdown()},
 		{"Download", d.Download()},
+		{"UploadRetryDelay", d.UploadRetryDelay()},
 		{"UploadProgress", d.UploadProgress()},
 		{"ExtractProgress", d.ExtractProgress()},
 	}
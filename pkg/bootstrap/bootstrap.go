@@ -7,8 +7,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/infrakit-io/vmware-vm-bootstrap/internal/utils"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
 	talosprofile "github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile/talos"
@@ -26,34 +26,44 @@ var defaultLogger = slog.Default()
 // sshVerifier is used by VM.Verify to allow tests to stub SSH checks.
 var sshVerifier = verifySSHAccess
 
+// guestShutdown/guestReboot are package-level so VM methods (receiver "vm") can reach pkg/vm.
+var (
+	guestShutdown = vm.ShutdownGuest
+	guestReboot   = vm.RebootGuest
+)
+
 // bootstrapper holds injectable service factories.
 // Production code uses defaultBootstrapper(); tests inject mocks.
 type bootstrapper struct {
 	connectVCenter func(ctx context.Context, cfg *VMConfig) (vcenter.ClientInterface, error)
-	newVMCreator   func(ctx context.Context) vm.CreatorInterface
-	newISOManager  func(ctx context.Context) iso.ManagerInterface
+	newVMCreator   func(ctx context.Context, cfg *VMConfig) vm.CreatorInterface
+	newISOManager  func(ctx context.Context, cfg *VMConfig) iso.ManagerInterface
 	resolveProfile func(profileName string) (profile.Provisioner, error)
 	waitInstall    func(ctx context.Context, vmObj *object.VirtualMachine, cfg *VMConfig, logger *slog.Logger) error
-	checkSSH       func(ctx context.Context, ipAddr string) error
+	checkSSH       func(ctx context.Context, ipAddr string, opts Options) error
 }
 
 // defaultBootstrapper returns a bootstrapper with real production implementations.
 func defaultBootstrapper() *bootstrapper {
 	return &bootstrapper{
 		connectVCenter: func(ctx context.Context, cfg *VMConfig) (vcenter.ClientInterface, error) {
-			return vcenter.NewClient(ctx, &vcenter.Config{
-				Host:     cfg.VCenterHost,
-				Username: cfg.VCenterUsername,
-				Password: cfg.VCenterPassword,
-				Port:     cfg.VCenterPort,
-				Insecure: cfg.VCenterInsecure,
-			})
+			return vcenter.NewClient(ctx, cfg.VCenterConfig())
 		},
-		newVMCreator: func(ctx context.Context) vm.CreatorInterface {
-			return vm.NewCreator(ctx)
+		newVMCreator: func(ctx context.Context, cfg *VMConfig) vm.CreatorInterface {
+			creator := vm.NewCreator(ctx)
+			creator.SetShutdownTimeout(cfg.Options.withDefaults().GuestShutdown)
+			return creator
 		},
-		newISOManager: func(ctx context.Context) iso.ManagerInterface {
-			return iso.NewManager(ctx)
+		newISOManager: func(ctx context.Context, cfg *VMConfig) iso.ManagerInterface {
+			opts := cfg.Options.withDefaults()
+			mgr := iso.NewManager(ctx)
+			if err := mgr.SetCacheDir(opts.CacheDir); err != nil {
+				fmt.Printf("⚠️  %v\n", err)
+			}
+			mgr.SetDownloadTimeout(opts.DownloadTimeout)
+			mgr.SetHardwareInit(opts.HardwareInit)
+			mgr.SetOffline(opts.Offline)
+			return mgr
 		},
 		resolveProfile: func(profileName string) (profile.Provisioner, error) {
 			if profileName == "" || profileName == "ubuntu" {
@@ -83,6 +93,21 @@ func BootstrapWithLogger(ctx context.Context, cfg *VMConfig, logger *slog.Logger
 	return defaultBootstrapper().run(ctx, cfg, logger)
 }
 
+// RenderUserData returns the autoinstall user-data Bootstrap generates for an
+// ubuntu cfg, with cfg.CloudInit merged, without connecting to vCenter.
+// PasswordProvider is not resolved: only Password and PasswordHash are used,
+// so callers that want the real password resolve it into Password first.
+func RenderUserData(cfg *VMConfig) (string, error) {
+	cfg.SetDefaults()
+	if p := cfg.EffectiveProfile(); p != "ubuntu" {
+		return "", fmt.Errorf("profile %q has no cloud-init user-data", p)
+	}
+	if err := cfg.CloudInit.Validate(); err != nil {
+		return "", fmt.Errorf("CloudInit: %w", err)
+	}
+	return ubuntuprofile.UserData(cfg.profileInput(cfg.Password))
+}
+
 // run is the internal implementation, testable via injected dependencies.
 func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logger) (*VM, error) {
 	// STEP 1: Validate and set defaults
@@ -90,6 +115,11 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	if cfg.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Options.Timeout)
+		defer cancel()
+	}
 
 	logger.Info("Starting VM bootstrap",
 		"name", cfg.Name,
@@ -128,6 +158,18 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 		return nil, fmt.Errorf("failed to find resource pool: %w", err)
 	}
 
+	// Check CPU/memory tuning against host limits before creating anything.
+	if cfg.hasResourceOptions() {
+		caps, err := vclient.HostCapabilities(resourcePool)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read host capabilities: %w", err)
+		}
+		if err := caps.Validate(int32(cfg.CPUs), int64(cfg.MemoryMB),
+			int64(cfg.CPUReservationMHz), int64(cfg.MemoryReservationMB)); err != nil {
+			return nil, fmt.Errorf("VM resources exceed host capabilities: %w", err)
+		}
+	}
+
 	datastore, err := vclient.FindDatastore(cfg.Datacenter, cfg.Datastore)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find datastore: %w", err)
@@ -156,8 +198,14 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 		"network", cfg.NetworkName,
 	)
 
+	provisioner, err := b.resolveProfile(cfg.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve profile: %w", err)
+	}
+	guestID, hwVersion, extraConfig := cfg.effectiveHardware(provisioner.HardwareDefaults())
+
 	// STEP 5: Create VM hardware
-	creator := b.newVMCreator(ctx)
+	creator := b.newVMCreator(ctx, cfg)
 
 	vmConfig := &vm.Config{
 		Name:         cfg.Name,
@@ -170,6 +218,25 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 		ResourcePool: cfg.ResourcePool,
 		Datastore:    cfg.Datastore,
 		Firmware:     cfg.Firmware,
+
+		GuestOS:         guestID,
+		HardwareVersion: hwVersion,
+		ExtraConfig:     extraConfig,
+
+		CoresPerSocket:      int32(cfg.CoresPerSocket),
+		CPUHotAdd:           cfg.CPUHotAdd,
+		MemoryHotAdd:        cfg.MemoryHotAdd,
+		CPUReservationMHz:   int64(cfg.CPUReservationMHz),
+		CPULimitMHz:         int64(cfg.CPULimitMHz),
+		CPUShares:           cfg.CPUShares,
+		MemoryReservationMB: int64(cfg.MemoryReservationMB),
+		MemoryLimitMB:       int64(cfg.MemoryLimitMB),
+		MemoryShares:        cfg.MemoryShares,
+		LatencySensitivity:  cfg.LatencySensitivity,
+
+		SecureBoot:       cfg.SecureBoot,
+		VTPM:             cfg.VTPM,
+		EncryptionPolicy: cfg.EncryptionPolicy,
 	}
 
 	if cfg.DataDiskSizeGB != nil {
@@ -184,7 +251,7 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 	}
 
 	// Initialize ISO manager early (needed in defer cleanup)
-	isoMgr := b.newISOManager(ctx)
+	isoMgr := b.newISOManager(ctx, cfg)
 
 	// Cleanup partial VM and uploaded ISOs on failure (idempotency)
 	var bootstrapSuccess bool
@@ -197,9 +264,11 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 					logger.Error("Failed to cleanup partial VM", "error", deleteErr)
 				}
 			}
+			if profileResult.SeedUUID != "" && cfg.SeedServer != nil {
+				cfg.SeedServer.Unregister(profileResult.SeedUUID)
+			}
 			if profileResult.NoCloudUploadPath != "" {
-				if deleteErr := isoMgr.DeleteFromDatastore(isoDatastoreName, profileResult.NoCloudUploadPath,
-					cfg.VCenterHost, cfg.VCenterUsername, cfg.VCenterPassword, cfg.VCenterInsecure); deleteErr != nil {
+				if deleteErr := isoMgr.DeleteFromDatastore(isoDatastore, profileResult.NoCloudUploadPath); deleteErr != nil {
 					logger.Warn("Failed to cleanup NoCloud ISO from datastore", "error", deleteErr)
 				} else {
 					logger.Info("NoCloud ISO cleaned up from datastore", "path", profileResult.NoCloudUploadPath)
@@ -234,6 +303,14 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 		return nil, fmt.Errorf("failed to add network adapter: %w", err)
 	}
 
+	// STEP 9a: Propagate encryption policy to disks attached after creation
+	if cfg.EncryptionPolicy != "" {
+		if err := creator.ConfigureSecurity(createdVM, vmConfig); err != nil {
+			return nil, fmt.Errorf("failed to apply encryption policy: %w", err)
+		}
+		logger.Info("Encryption policy applied", "policy", cfg.EncryptionPolicy)
+	}
+
 	// STEP 9b: Apply static MAC address (if specified) or extract auto-assigned MAC
 	var assignedMAC string
 	if mac := strings.TrimSpace(cfg.MACAddress); mac != "" {
@@ -256,40 +333,19 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 
 	logger.Info("VM hardware configuration complete")
 
-	provisioner, err := b.resolveProfile(cfg.Profile)
+	guestPassword, err := credentials.Resolve(ctx, cfg.Password, cfg.PasswordProvider)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve profile: %w", err)
+		return nil, fmt.Errorf("failed to resolve guest password: %w", err)
 	}
 
-	profileResult, err = provisioner.ProvisionAndBoot(ctx, profile.Input{
-		VMName:            cfg.Name,
-		Username:          cfg.Username,
-		Password:          cfg.Password,
-		PasswordHash:      cfg.PasswordHash,
-		SSHPublicKeys:     cfg.SSHPublicKeys,
-		AllowPasswordSSH:  cfg.AllowPasswordSSH,
-		Timezone:          cfg.Timezone,
-		Locale:            cfg.Locale,
-		NetworkInterface:  cfg.NetworkInterface,
-		IPAddress:         cfg.IPAddress,
-		Netmask:           cfg.Netmask,
-		Gateway:           cfg.Gateway,
-		DNS:               cfg.DNS,
-		DataDiskMountPath: cfg.DataDiskMountPath,
-		SwapSizeGB:        cfg.SwapSizeGB,
-		OSVersion:         cfg.EffectiveOSVersion(),
-		OSSchematicID:     cfg.EffectiveOSSchematicID(),
-		VCenterHost:       cfg.VCenterHost,
-		VCenterUsername:   cfg.VCenterUsername,
-		VCenterPassword:   cfg.VCenterPassword,
-		VCenterInsecure:   cfg.VCenterInsecure,
-	}, profile.Runtime{
+	profileResult, err = provisioner.ProvisionAndBoot(ctx, cfg.profileInput(guestPassword), profile.Runtime{
 		CreatedVM:        createdVM,
 		Creator:          creator,
 		ISOManager:       isoMgr,
 		ISODatastore:     isoDatastore,
 		ISODatastoreName: isoDatastoreName,
 		Logger:           logger,
+		SeedServer:       cfg.SeedServer,
 	})
 	if err != nil {
 		return nil, err
@@ -304,35 +360,14 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 	}
 
 	// STEP 16.5: Profile post-install actions
-	if err := provisioner.PostInstall(ctx, profile.Input{
-		VMName:            cfg.Name,
-		Username:          cfg.Username,
-		Password:          cfg.Password,
-		PasswordHash:      cfg.PasswordHash,
-		SSHPublicKeys:     cfg.SSHPublicKeys,
-		AllowPasswordSSH:  cfg.AllowPasswordSSH,
-		Timezone:          cfg.Timezone,
-		Locale:            cfg.Locale,
-		NetworkInterface:  cfg.NetworkInterface,
-		IPAddress:         cfg.IPAddress,
-		Netmask:           cfg.Netmask,
-		Gateway:           cfg.Gateway,
-		DNS:               cfg.DNS,
-		DataDiskMountPath: cfg.DataDiskMountPath,
-		SwapSizeGB:        cfg.SwapSizeGB,
-		OSVersion:         cfg.EffectiveOSVersion(),
-		OSSchematicID:     cfg.EffectiveOSSchematicID(),
-		VCenterHost:       cfg.VCenterHost,
-		VCenterUsername:   cfg.VCenterUsername,
-		VCenterPassword:   cfg.VCenterPassword,
-		VCenterInsecure:   cfg.VCenterInsecure,
-	}, profile.Runtime{
+	if err := provisioner.PostInstall(ctx, cfg.profileInput(guestPassword), profile.Runtime{
 		CreatedVM:        createdVM,
 		Creator:          creator,
 		ISOManager:       isoMgr,
 		ISODatastore:     isoDatastore,
 		ISODatastoreName: isoDatastoreName,
 		Logger:           logger,
+		SeedServer:       cfg.SeedServer,
 	}, profileResult); err != nil {
 		return nil, err
 	}
@@ -342,7 +377,7 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 	if skipSSHVerify {
 		logger.Warn("Skipping SSH verification", "profile", cfg.EffectiveProfile(), "skip_ssh_verify", cfg.SkipSSHVerify)
 	} else {
-		if err := b.checkSSH(ctx, cfg.IPAddress); err != nil {
+		if err := b.checkSSH(ctx, cfg.IPAddress, cfg.Options); err != nil {
 			return nil, fmt.Errorf("SSH verification failed: %w", err)
 		}
 		logger.Info("SSH access verified")
@@ -363,19 +398,20 @@ func (b *bootstrapper) run(ctx context.Context, cfg *VMConfig, logger *slog.Logg
 		VCenterUser:     cfg.VCenterUsername,
 		VCenterPass:     cfg.VCenterPassword,
 		VCenterInsecure: cfg.VCenterInsecure,
+
+		VCenterPasswordProvider: cfg.VCenterPasswordProvider,
+		VCenterThumbprint:       cfg.VCenterThumbprint,
+		VCenterCACertFile:       cfg.VCenterCACertFile,
+		VCenterCACertPEM:        cfg.VCenterCACertPEM,
+		VCenterSessionCacheDir:  cfg.VCenterSessionCacheDir,
+		Options:                 cfg.Options,
 	}, nil
 }
 
 // Verify performs a basic health check: VM powered on, VMware Tools running (if available),
 // hostname matches (if available), and SSH port is reachable (if IP is set).
 func (vm *VM) Verify(ctx context.Context) error {
-	client, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     vm.VCenterHost,
-		Port:     vm.VCenterPort,
-		Username: vm.VCenterUser,
-		Password: vm.VCenterPass,
-		Insecure: vm.VCenterInsecure,
-	})
+	client, err := vcenter.NewClient(ctx, vm.vcenterConfig())
 	if err != nil {
 		return fmt.Errorf("vCenter connection failed: %w", err)
 	}
@@ -409,57 +445,56 @@ func (vm *VM) Verify(ctx context.Context) error {
 		return fmt.Errorf("IPAddress is required for SSH verification")
 	}
 
-	if err := sshVerifier(ctx, vm.IPAddress); err != nil {
+	if err := sshVerifier(ctx, vm.IPAddress, vm.Options); err != nil {
 		return fmt.Errorf("SSH verification failed: %w", err)
 	}
 
 	return nil
 }
 
-// PowerOff powers off the VM and waits for completion.
+// PowerOff shuts the guest down via VMware Tools and waits for poweredOff,
+// falling back to a hard power-off after the configured guest shutdown timeout.
 func (vm *VM) PowerOff(ctx context.Context) error {
-	client, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     vm.VCenterHost,
-		Port:     vm.VCenterPort,
-		Username: vm.VCenterUser,
-		Password: vm.VCenterPass,
-		Insecure: vm.VCenterInsecure,
-	})
+	return vm.ShutdownGuest(ctx, vm.Options.withDefaults().GuestShutdown)
+}
+
+// ShutdownGuest shuts the guest down via VMware Tools and waits up to timeout for
+// poweredOff, then falls back to a hard power-off. timeout <= 0 uses the configured default.
+func (vm *VM) ShutdownGuest(ctx context.Context, timeout time.Duration) error {
+	client, vmObj, err := vm.connect(ctx)
 	if err != nil {
-		return fmt.Errorf("vCenter connection failed: %w", err)
+		return err
 	}
 	defer func() {
 		_ = client.Disconnect()
 	}()
 
-	vmObj := object.NewVirtualMachine(client.Client().Client, vm.ManagedObject)
-	state, err := vmObj.PowerState(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get power state: %w", err)
-	}
-	if state != types.VirtualMachinePowerStatePoweredOn {
-		return nil
+	if _, err := guestShutdown(ctx, vmObj, timeout); err != nil {
+		return fmt.Errorf("guest shutdown failed: %w", err)
 	}
+	return nil
+}
 
-	task, err := vmObj.PowerOff(ctx)
+// RebootGuest reboots the guest via VMware Tools, falling back to a hard reset when
+// the guest does not go down within timeout. timeout <= 0 uses the configured default.
+func (vm *VM) RebootGuest(ctx context.Context, timeout time.Duration) error {
+	client, vmObj, err := vm.connect(ctx)
 	if err != nil {
-		return fmt.Errorf("power off failed: %w", err)
+		return err
 	}
-	if err := task.Wait(ctx); err != nil {
-		return fmt.Errorf("power off wait failed: %w", err)
+	defer func() {
+		_ = client.Disconnect()
+	}()
+
+	if _, err := guestReboot(ctx, vmObj, timeout); err != nil {
+		return fmt.Errorf("guest reboot failed: %w", err)
 	}
 	return nil
 }
 
 // PowerOn powers on the VM and waits for completion.
 func (vm *VM) PowerOn(ctx context.Context) error {
-	client, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     vm.VCenterHost,
-		Port:     vm.VCenterPort,
-		Username: vm.VCenterUser,
-		Password: vm.VCenterPass,
-		Insecure: vm.VCenterInsecure,
-	})
+	client, err := vcenter.NewClient(ctx, vm.vcenterConfig())
 	if err != nil {
 		return fmt.Errorf("vCenter connection failed: %w", err)
 	}
@@ -486,15 +521,10 @@ func (vm *VM) PowerOn(ctx context.Context) error {
 	return nil
 }
 
-// Delete powers off the VM if needed and removes it from vCenter.
+// Delete shuts the VM down if needed (Tools shutdown, hard power-off on timeout)
+// and removes it from vCenter.
 func (vm *VM) Delete(ctx context.Context) error {
-	client, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     vm.VCenterHost,
-		Port:     vm.VCenterPort,
-		Username: vm.VCenterUser,
-		Password: vm.VCenterPass,
-		Insecure: vm.VCenterInsecure,
-	})
+	client, err := vcenter.NewClient(ctx, vm.vcenterConfig())
 	if err != nil {
 		return fmt.Errorf("vCenter connection failed: %w", err)
 	}
@@ -508,13 +538,9 @@ func (vm *VM) Delete(ctx context.Context) error {
 		return fmt.Errorf("failed to get power state: %w", err)
 	}
 	if state == types.VirtualMachinePowerStatePoweredOn {
-		task, err := vmObj.PowerOff(ctx)
-		if err != nil {
+		if _, err := guestShutdown(ctx, vmObj, vm.Options.withDefaults().GuestShutdown); err != nil {
 			return fmt.Errorf("power off failed: %w", err)
 		}
-		if err := task.Wait(ctx); err != nil {
-			return fmt.Errorf("power off wait failed: %w", err)
-		}
 	}
 	task, err := vmObj.Destroy(ctx)
 	if err != nil {
@@ -526,14 +552,46 @@ func (vm *VM) Delete(ctx context.Context) error {
 	return nil
 }
 
+// Reconfigure applies spec to the VM and waits for completion.
+// When snapshot is non-nil, a pre-change snapshot is taken first (see WithPreChangeSnapshot).
+func (vm *VM) Reconfigure(ctx context.Context, spec types.VirtualMachineConfigSpec, snapshot *PreChangeSnapshot) error {
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+
+	reconfigure := func(ctx context.Context) error {
+		task, err := vmObj.Reconfigure(ctx, spec)
+		if err != nil {
+			return fmt.Errorf("reconfigure failed: %w", err)
+		}
+		if err := task.Wait(ctx); err != nil {
+			return fmt.Errorf("reconfigure wait failed: %w", err)
+		}
+		return nil
+	}
+	if snapshot == nil {
+		return reconfigure(ctx)
+	}
+	snap := *snapshot
+	if snap.Label == "" {
+		snap.Label = "reconfigure"
+	}
+	return vm.withPreChangeSnapshot(ctx, vmObj, snap, nil, reconfigure)
+}
+
 // waitForInstallation monitors VM until OS installed.
 // Matches Python _wait_for_installation_complete() exactly:
 // Phase 1: Wait for VMware Tools running (installation started)
 // Phase 2: Wait for VM to reboot (Tools stop = autoinstall complete)
 // Phase 3: Wait for Tools running + hostname set (first boot complete)
 func waitForInstallation(ctx context.Context, vmObj *object.VirtualMachine, cfg *VMConfig, logger *slog.Logger) error {
-	timeout := configs.Defaults.Timeouts.Installation()
-	ticker := time.NewTicker(configs.Defaults.Timeouts.Polling())
+	opts := cfg.Options.withDefaults()
+	timeout := opts.InstallTimeout
+	ticker := time.NewTicker(opts.PollInterval)
 	defer ticker.Stop()
 
 	started := time.Now()
@@ -545,7 +603,7 @@ func waitForInstallation(ctx context.Context, vmObj *object.VirtualMachine, cfg
 	toolsWasRunning := false
 	rebootDetected := false
 	hostnameCheckCount := 0
-	requiredHostnameChecks := configs.Defaults.Timeouts.HostnameChecks
+	requiredHostnameChecks := opts.HostnameChecks
 
 	logger.Info("Phase 1: Waiting for installation to start (VMware Tools)...")
 	logger.Info("Installation progress",
@@ -650,8 +708,8 @@ func waitForInstallation(ctx context.Context, vmObj *object.VirtualMachine, cfg
 					"required", requiredHostnameChecks)
 				if hostnameCheckCount >= requiredHostnameChecks {
 					logger.Info("Installation complete, waiting for services to start...",
-						"wait", configs.Defaults.Timeouts.ServiceStartup())
-					time.Sleep(configs.Defaults.Timeouts.ServiceStartup())
+						"wait", opts.ServiceStartup)
+					time.Sleep(opts.ServiceStartup)
 					_ = recordInstallDuration(cfg, time.Since(started))
 					return nil
 				}
@@ -667,8 +725,8 @@ func waitForInstallation(ctx context.Context, vmObj *object.VirtualMachine, cfg
 					"checks", hostnameCheckCount)
 				if hostnameCheckCount >= requiredHostnameChecks {
 					logger.Info("Installation complete (stable hostname), waiting for services...",
-						"wait", configs.Defaults.Timeouts.ServiceStartup())
-					time.Sleep(configs.Defaults.Timeouts.ServiceStartup())
+						"wait", opts.ServiceStartup)
+					time.Sleep(opts.ServiceStartup)
 					_ = recordInstallDuration(cfg, time.Since(started))
 					return nil
 				}
@@ -690,13 +748,13 @@ func currentInstallPhase(toolsWasRunning, rebootDetected bool) string {
 }
 
 // verifySSHAccess verifies SSH port 22 is accessible.
-func verifySSHAccess(ctx context.Context, ipAddr string) error {
-	t := configs.Defaults.Timeouts
-	for i := 0; i < t.SSHRetries; i++ {
-		if utils.IsPortOpen(ipAddr, 22, t.SSHConnect()) {
+func verifySSHAccess(ctx context.Context, ipAddr string, opts Options) error {
+	opts = opts.withDefaults()
+	for i := 0; i < opts.SSHRetries; i++ {
+		if utils.IsPortOpen(ipAddr, 22, opts.SSHConnectTimeout) {
 			return nil
 		}
-		time.Sleep(t.SSHRetryDelay())
+		time.Sleep(opts.SSHRetryDelay)
 	}
 	return fmt.Errorf("SSH port 22 not accessible at %s", ipAddr)
 }
@@ -38,10 +38,10 @@ func TestCurrentInstallPhase(t *testing.T) {
 func TestDefaultBootstrapperFactories(t *testing.T) {
 	b := defaultBootstrapper()
 
-	if b.newVMCreator(context.Background()) == nil {
+	if b.newVMCreator(context.Background(), &VMConfig{}) == nil {
 		t.Fatal("expected vm creator factory to return non-nil")
 	}
-	if b.newISOManager(context.Background()) == nil {
+	if b.newISOManager(context.Background(), &VMConfig{}) == nil {
 		t.Fatal("expected iso manager factory to return non-nil")
 	}
 
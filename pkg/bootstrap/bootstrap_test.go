@@ -16,6 +16,7 @@ import (
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/infrakit-io/vmware-vm-bootstrap/internal/utils"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 	isomocks "github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso/mocks"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
 	ubuntuprofile "github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile/ubuntu"
@@ -65,10 +66,10 @@ func testBootstrapper(
 		connectVCenter: func(ctx context.Context, cfg *VMConfig) (vcface.ClientInterface, error) {
 			return vc, nil
 		},
-		newVMCreator: func(ctx context.Context) vmiface.CreatorInterface {
+		newVMCreator: func(ctx context.Context, cfg *VMConfig) vmiface.CreatorInterface {
 			return creator
 		},
-		newISOManager: func(ctx context.Context) isoiface.ManagerInterface {
+		newISOManager: func(ctx context.Context, cfg *VMConfig) isoiface.ManagerInterface {
 			return isoMgr
 		},
 		resolveProfile: func(profileName string) (profile.Provisioner, error) {
@@ -77,7 +78,7 @@ func testBootstrapper(
 		waitInstall: func(ctx context.Context, vmObj *object.VirtualMachine, cfg *VMConfig, logger *slog.Logger) error {
 			return nil
 		},
-		checkSSH: func(ctx context.Context, ipAddr string) error {
+		checkSSH: func(ctx context.Context, ipAddr string, opts Options) error {
 			return nil
 		},
 	}
@@ -110,18 +111,18 @@ func wireSuccessfulMocks(vc *vcmocks.ClientInterface, creator *vmmocks.CreatorIn
 	creator.On("AddNetworkAdapter", vm, mock.Anything).Return(nil)
 	creator.On("GetMACAddress", vm).Return("00:50:56:00:00:01", nil)
 	creator.On("PowerOn", vm).Return(nil)
-	creator.On("PowerOff", vm).Return(nil)
+	creator.On("ShutdownGuest", vm).Return(nil)
 	creator.On("Delete", vm).Maybe().Return(nil) // only called in defer cleanup on failure
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").Return("/tmp/nocloud.iso", nil)
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", vm).Return(nil)
 	isoMgr.On("RemoveAllCDROMs", vm).Return(nil)
-	isoMgr.On("DeleteFromDatastore", "SSD01", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 }
 
 // =============================================================================
@@ -149,8 +150,8 @@ func TestBootstrap_VCenterConnectionFails(t *testing.T) {
 		connectVCenter: func(ctx context.Context, cfg *VMConfig) (vcface.ClientInterface, error) {
 			return nil, errors.New("connection refused")
 		},
-		newVMCreator:  func(ctx context.Context) vmiface.CreatorInterface { return nil },
-		newISOManager: func(ctx context.Context) isoiface.ManagerInterface { return nil },
+		newVMCreator:  func(ctx context.Context, cfg *VMConfig) vmiface.CreatorInterface { return nil },
+		newISOManager: func(ctx context.Context, cfg *VMConfig) isoiface.ManagerInterface { return nil },
 	}
 
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -276,7 +277,7 @@ func TestBootstrap_EnsureSCSIControllerFails(t *testing.T) {
 	creator.On("EnsureSCSIController", vm).Return(int32(0), errors.New("scsi fail"))
 	creator.On("Delete", vm).Return(nil)
 
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -308,11 +309,11 @@ func TestBootstrap_UploadUbuntuFails(t *testing.T) {
 	creator.On("Delete", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").Return("/tmp/nocloud.iso", nil)
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).
 		Return(errors.New("upload failed"))
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -344,12 +345,12 @@ func TestBootstrap_MountISOsFails(t *testing.T) {
 	creator.On("Delete", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").Return("/tmp/nocloud.iso", nil)
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(errors.New("mount failed"))
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -382,12 +383,12 @@ func TestBootstrap_PowerOnFails(t *testing.T) {
 	creator.On("Delete", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").Return("/tmp/nocloud.iso", nil)
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -418,7 +419,7 @@ func TestBootstrap_ISODownloadFails_cleansUpVM(t *testing.T) {
 	creator.On("Delete", vm).Return(nil) // VM cleanup on failure
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("", errors.New("network error"))
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -436,7 +437,7 @@ func TestBootstrap_SSHVerificationFails(t *testing.T) {
 	wireSuccessfulMocks(vc, creator, isoMgr)
 
 	b := testBootstrapper(vc, creator, isoMgr)
-	b.checkSSH = func(ctx context.Context, ipAddr string) error {
+	b.checkSSH = func(ctx context.Context, ipAddr string, opts Options) error {
 		return errors.New("SSH port not accessible")
 	}
 
@@ -484,7 +485,7 @@ func TestBootstrap_InvalidNetmask(t *testing.T) {
 	creator.On("AddNetworkAdapter", vm, mock.Anything).Return(nil)
 	creator.On("GetMACAddress", vm).Return("00:50:56:00:00:01", nil)
 	creator.On("Delete", vm).Return(nil)
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	cfg := minimalConfig()
 	cfg.Netmask = "invalid"
@@ -550,17 +551,17 @@ func TestBootstrap_WithDataDisk(t *testing.T) {
 	creator.On("AddNetworkAdapter", vm, mock.Anything).Return(nil)
 	creator.On("GetMACAddress", vm).Return("00:50:56:00:00:01", nil)
 	creator.On("PowerOn", vm).Return(nil)
-	creator.On("PowerOff", vm).Return(nil)
+	creator.On("ShutdownGuest", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").Return("/tmp/nocloud.iso", nil)
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", vm).Return(nil)
 	isoMgr.On("RemoveAllCDROMs", vm).Return(nil)
-	isoMgr.On("DeleteFromDatastore", "SSD01", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	result, err := b.run(context.Background(), cfg, slog.Default())
@@ -570,6 +571,75 @@ func TestBootstrap_WithDataDisk(t *testing.T) {
 	creator.AssertNumberOfCalls(t, "AddDisk", 2) // OS + data disk
 }
 
+func TestBootstrap_SecurityOptions(t *testing.T) {
+	vc := new(vcmocks.ClientInterface)
+	creator := new(vmmocks.CreatorInterface)
+	isoMgr := new(isomocks.ManagerInterface)
+
+	wireSuccessfulMocks(vc, creator, isoMgr)
+	creator.On("ConfigureSecurity", mock.Anything, mock.Anything).Return(nil)
+
+	cfg := minimalConfig()
+	cfg.SecureBoot = true
+	cfg.VTPM = true
+	cfg.EncryptionPolicy = "policy-1"
+
+	b := testBootstrapper(vc, creator, isoMgr)
+	_, err := b.run(context.Background(), cfg, slog.Default())
+	require.NoError(t, err)
+
+	creator.AssertCalled(t, "CreateSpec", mock.MatchedBy(func(c *vmiface.Config) bool {
+		return c.SecureBoot && c.VTPM && c.EncryptionPolicy == "policy-1" && c.Firmware == "efi"
+	}))
+	creator.AssertCalled(t, "ConfigureSecurity", mock.Anything, mock.MatchedBy(func(c *vmiface.Config) bool {
+		return c.EncryptionPolicy == "policy-1"
+	}))
+}
+
+func TestBootstrap_ResourceOptionsExceedHost(t *testing.T) {
+	vc := new(vcmocks.ClientInterface)
+	creator := new(vmmocks.CreatorInterface)
+	isoMgr := new(isomocks.ManagerInterface)
+
+	pool := &object.ResourcePool{}
+	vc.On("FindVM", "DC1", "test-vm").Return(nil, nil)
+	vc.On("FindFolder", "DC1", "Production").Return(&object.Folder{}, nil)
+	vc.On("FindResourcePool", "DC1", "pool").Return(pool, nil)
+	vc.On("HostCapabilities", pool).Return(&vcface.HostCapabilities{MaxVCPUs: 8, MemoryMB: 4096, CPUMHz: 20000}, nil)
+	vc.On("Disconnect").Return(nil)
+
+	cfg := minimalConfig()
+	cfg.MemoryReservationMB = 2048
+	cfg.MemoryMB = 8192
+
+	b := testBootstrapper(vc, creator, isoMgr)
+	_, err := b.run(context.Background(), cfg, slog.Default())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceed host capabilities")
+	creator.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBootstrap_ResourceOptionsApplied(t *testing.T) {
+	vc := new(vcmocks.ClientInterface)
+	creator := new(vmmocks.CreatorInterface)
+	isoMgr := new(isomocks.ManagerInterface)
+
+	wireSuccessfulMocks(vc, creator, isoMgr)
+	vc.On("HostCapabilities", mock.Anything).Return(&vcface.HostCapabilities{MaxVCPUs: 64, MemoryMB: 65536, CPUMHz: 40000}, nil)
+
+	cfg := minimalConfig()
+	cfg.CoresPerSocket = 2
+	cfg.MemoryReservationMB = 2048
+
+	b := testBootstrapper(vc, creator, isoMgr)
+	_, err := b.run(context.Background(), cfg, slog.Default())
+	require.NoError(t, err)
+
+	creator.AssertCalled(t, "CreateSpec", mock.MatchedBy(func(c *vmiface.Config) bool {
+		return c.CoresPerSocket == 2 && c.MemoryReservationMB == 2048
+	}))
+}
+
 func TestBootstrap_InvalidConfig(t *testing.T) {
 	cfg := &VMConfig{}
 	_, err := Bootstrap(context.Background(), cfg)
@@ -603,10 +673,10 @@ func TestBootstrap_PasswordHashPrecedence(t *testing.T) {
 	creator.On("AddNetworkAdapter", vm, mock.Anything).Return(nil)
 	creator.On("GetMACAddress", vm).Return("00:50:56:00:00:01", nil)
 	creator.On("PowerOn", vm).Return(nil)
-	creator.On("PowerOff", vm).Return(nil)
+	creator.On("ShutdownGuest", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 
 	var capturedUserData string
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").
@@ -614,12 +684,12 @@ func TestBootstrap_PasswordHashPrecedence(t *testing.T) {
 			capturedUserData = args.String(0)
 		}).Return("/tmp/nocloud.iso", nil)
 
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", vm).Return(nil)
 	isoMgr.On("RemoveAllCDROMs", vm).Return(nil)
-	isoMgr.On("DeleteFromDatastore", "SSD01", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), cfg, slog.Default())
@@ -629,20 +699,58 @@ func TestBootstrap_PasswordHashPrecedence(t *testing.T) {
 	require.NotContains(t, capturedUserData, "plaintext")
 }
 
+func TestBootstrap_PassesOptions(t *testing.T) {
+	vc := new(vcmocks.ClientInterface)
+	creator := new(vmmocks.CreatorInterface)
+	isoMgr := new(isomocks.ManagerInterface)
+
+	wireSuccessfulMocks(vc, creator, isoMgr)
+
+	cfg := minimalConfig()
+	cfg.Options = Options{Timeout: time.Hour, SSHRetries: 7}
+
+	var got Options
+	b := testBootstrapper(vc, creator, isoMgr)
+	b.checkSSH = func(ctx context.Context, ipAddr string, opts Options) error {
+		got = opts
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("Options.Timeout not applied to ctx")
+		}
+		return nil
+	}
+
+	vm, err := b.run(context.Background(), cfg, slog.Default())
+
+	require.NoError(t, err)
+	require.Equal(t, 7, got.SSHRetries)
+	require.Equal(t, cfg.Options, vm.Options)
+}
+
+func TestOptions_WithDefaults(t *testing.T) {
+	d := configs.Defaults.Timeouts
+
+	got := Options{}.withDefaults()
+	require.Equal(t, d.Installation(), got.InstallTimeout)
+	require.Equal(t, d.Polling(), got.PollInterval)
+	require.Equal(t, d.HostnameChecks, got.HostnameChecks)
+	require.Equal(t, d.SSHRetries, got.SSHRetries)
+	require.Equal(t, d.Download(), got.DownloadTimeout)
+	require.Equal(t, d.HardwareInit(), got.HardwareInit)
+	require.Equal(t, d.GuestShutdown(), got.GuestShutdown)
+	require.Equal(t, iso.DefaultCacheDir(), got.CacheDir)
+	require.Zero(t, got.Timeout)
+
+	got = Options{InstallTimeout: 5 * time.Minute, HostnameChecks: 1}.withDefaults()
+	require.Equal(t, 5*time.Minute, got.InstallTimeout)
+	require.Equal(t, 1, got.HostnameChecks)
+}
+
 func TestVerifySSHAccess_FailsFast(t *testing.T) {
-	oldRetries := configs.Defaults.Timeouts.SSHRetries
-	oldConnect := configs.Defaults.Timeouts.SSHConnectSeconds
-	oldDelay := configs.Defaults.Timeouts.SSHRetryDelaySeconds
-	configs.Defaults.Timeouts.SSHRetries = 1
-	configs.Defaults.Timeouts.SSHConnectSeconds = 1
-	configs.Defaults.Timeouts.SSHRetryDelaySeconds = 0
-	t.Cleanup(func() {
-		configs.Defaults.Timeouts.SSHRetries = oldRetries
-		configs.Defaults.Timeouts.SSHConnectSeconds = oldConnect
-		configs.Defaults.Timeouts.SSHRetryDelaySeconds = oldDelay
+	err := verifySSHAccess(context.Background(), "203.0.113.1", Options{
+		SSHRetries:        1,
+		SSHConnectTimeout: time.Second,
+		SSHRetryDelay:     time.Millisecond,
 	})
-
-	err := verifySSHAccess(context.Background(), "203.0.113.1")
 	require.Error(t, err)
 }
 
@@ -673,10 +781,10 @@ func TestBootstrap_PasswordIsHashed(t *testing.T) {
 	creator.On("AddNetworkAdapter", vm, mock.Anything).Return(nil)
 	creator.On("GetMACAddress", vm).Return("00:50:56:00:00:01", nil)
 	creator.On("PowerOn", vm).Return(nil)
-	creator.On("PowerOff", vm).Return(nil)
+	creator.On("ShutdownGuest", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 
 	var capturedUserData string
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").
@@ -684,12 +792,12 @@ func TestBootstrap_PasswordIsHashed(t *testing.T) {
 			capturedUserData = args.String(0)
 		}).Return("/tmp/nocloud.iso", nil)
 
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", vm).Return(nil)
 	isoMgr.On("RemoveAllCDROMs", vm).Return(nil)
-	isoMgr.On("DeleteFromDatastore", "SSD01", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), cfg, slog.Default())
@@ -726,10 +834,10 @@ func TestBootstrap_NoPasswordKeyOnly(t *testing.T) {
 	creator.On("AddNetworkAdapter", vm, mock.Anything).Return(nil)
 	creator.On("GetMACAddress", vm).Return("00:50:56:00:00:01", nil)
 	creator.On("PowerOn", vm).Return(nil)
-	creator.On("PowerOff", vm).Return(nil)
+	creator.On("ShutdownGuest", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 
 	var capturedUserData string
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").
@@ -737,12 +845,12 @@ func TestBootstrap_NoPasswordKeyOnly(t *testing.T) {
 			capturedUserData = args.String(0)
 		}).Return("/tmp/nocloud.iso", nil)
 
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("MountISOs", vm, mock.Anything, mock.Anything).Return(nil)
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", vm).Return(nil)
 	isoMgr.On("RemoveAllCDROMs", vm).Return(nil)
-	isoMgr.On("DeleteFromDatastore", "SSD01", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), cfg, slog.Default())
@@ -774,12 +882,12 @@ func TestBootstrap_UploadNoCloudFails(t *testing.T) {
 	creator.On("Delete", vm).Return(nil)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil)
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-autoinstall.iso", false, nil)
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-autoinstall.iso", false, nil)
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "test-vm").Return("/tmp/nocloud.iso", nil)
-	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+	isoMgr.On("UploadToDatastore", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("UploadAlways", mock.Anything, mock.Anything, mock.Anything).
 		Return(errors.New("upload nocloud failed"))
-	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	isoMgr.On("DeleteFromDatastore", mock.Anything, mock.Anything).Return(nil)
 
 	b := testBootstrapper(vc, creator, isoMgr)
 	_, err := b.run(context.Background(), minimalConfig(), slog.Default())
@@ -814,8 +922,8 @@ func TestBootstrap_ContextCancelled(t *testing.T) {
 		connectVCenter: func(ctx context.Context, cfg *VMConfig) (vcface.ClientInterface, error) {
 			return nil, ctx.Err()
 		},
-		newVMCreator:  func(ctx context.Context) vmiface.CreatorInterface { return nil },
-		newISOManager: func(ctx context.Context) isoiface.ManagerInterface { return nil },
+		newVMCreator:  func(ctx context.Context, cfg *VMConfig) vmiface.CreatorInterface { return nil },
+		newISOManager: func(ctx context.Context, cfg *VMConfig) isoiface.ManagerInterface { return nil },
 	}
 
 	_, err := b.run(ctx, minimalConfig(), slog.Default())
@@ -855,7 +963,7 @@ func TestVerifySSHAccess_portNotOpen(t *testing.T) {
 	if utils.IsPortOpen("127.0.0.1", 22, 200*time.Millisecond) {
 		t.Skip("SSH daemon running on localhost - port 22 is open, skipping negative test")
 	}
-	err := verifySSHAccess(context.Background(), "127.0.0.1")
+	err := verifySSHAccess(context.Background(), "127.0.0.1", Options{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "SSH port 22 not accessible")
 }
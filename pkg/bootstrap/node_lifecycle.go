@@ -23,13 +23,7 @@ func CreateNodeWithLogger(ctx context.Context, cfg *VMConfig, logger *slog.Logge
 func NodeExists(ctx context.Context, cfg *VMConfig) (bool, error) {
 	cfg.SetDefaults()
 
-	vclient, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     cfg.VCenterHost,
-		Username: cfg.VCenterUsername,
-		Password: cfg.VCenterPassword,
-		Port:     cfg.VCenterPort,
-		Insecure: cfg.VCenterInsecure,
-	})
+	vclient, err := vcenter.NewClient(ctx, cfg.VCenterConfig())
 	if err != nil {
 		return false, fmt.Errorf("vCenter connection failed: %w", err)
 	}
@@ -47,13 +41,7 @@ func NodeExists(ctx context.Context, cfg *VMConfig) (bool, error) {
 func DeleteNode(ctx context.Context, cfg *VMConfig) error {
 	cfg.SetDefaults()
 
-	vclient, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     cfg.VCenterHost,
-		Username: cfg.VCenterUsername,
-		Password: cfg.VCenterPassword,
-		Port:     cfg.VCenterPort,
-		Insecure: cfg.VCenterInsecure,
-	})
+	vclient, err := vcenter.NewClient(ctx, cfg.VCenterConfig())
 	if err != nil {
 		return fmt.Errorf("vCenter connection failed: %w", err)
 	}
@@ -72,12 +60,9 @@ func DeleteNode(ctx context.Context, cfg *VMConfig) error {
 		return fmt.Errorf("failed to get node power state: %w", err)
 	}
 	if state == types.VirtualMachinePowerStatePoweredOn {
-		task, err := vmObj.PowerOff(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to power off node: %w", err)
-		}
-		if err := task.Wait(ctx); err != nil {
-			return fmt.Errorf("failed waiting for node power off: %w", err)
+		// Graceful Tools shutdown first: hard power-off can corrupt data disks.
+		if _, err := guestShutdown(ctx, vmObj, cfg.Options.withDefaults().GuestShutdown); err != nil {
+			return fmt.Errorf("failed to shut down node: %w", err)
 		}
 	}
 
@@ -0,0 +1,68 @@
+package bootstrap
+
+import (
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+)
+
+// Options holds per-call operational settings for a bootstrap.
+// Zero fields fall back to configs.Defaults, so concurrent bootstraps with
+// different needs can share one process without touching global state.
+type Options struct {
+	Timeout           time.Duration // Overall deadline for Bootstrap/CreateNode (0 = only ctx applies)
+	InstallTimeout    time.Duration // Max wait for the OS installation to complete
+	PollInterval      time.Duration // Interval between installation status checks
+	HostnameChecks    int           // Consecutive checks with correct hostname = install done
+	ServiceStartup    time.Duration // Extra wait after install before SSH verification
+	SSHRetries        int           // SSH availability verification attempts
+	SSHConnectTimeout time.Duration // Timeout per SSH connection attempt
+	SSHRetryDelay     time.Duration // Delay between SSH retry attempts
+	DownloadTimeout   time.Duration // Max time to download an installer ISO
+	HardwareInit      time.Duration // Wait for VM hardware after power-on before checking CD-ROMs
+	GuestShutdown     time.Duration // Max wait for a Tools guest shutdown before a hard power-off
+
+	CacheDir string // Local ISO cache ("" = iso.cache_dir, else the per-user cache dir)
+	Offline  bool   // Resolve ISOs, OVAs and checksums from imported bundles only (or iso.offline)
+}
+
+// withDefaults returns o with zero fields filled from configs.Defaults.
+func (o Options) withDefaults() Options {
+	t := configs.Defaults.Timeouts
+	if o.InstallTimeout <= 0 {
+		o.InstallTimeout = t.Installation()
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = t.Polling()
+	}
+	if o.HostnameChecks <= 0 {
+		o.HostnameChecks = t.HostnameChecks
+	}
+	if o.ServiceStartup <= 0 {
+		o.ServiceStartup = t.ServiceStartup()
+	}
+	if o.SSHRetries <= 0 {
+		o.SSHRetries = t.SSHRetries
+	}
+	if o.SSHConnectTimeout <= 0 {
+		o.SSHConnectTimeout = t.SSHConnect()
+	}
+	if o.SSHRetryDelay <= 0 {
+		o.SSHRetryDelay = t.SSHRetryDelay()
+	}
+	if o.DownloadTimeout <= 0 {
+		o.DownloadTimeout = t.Download()
+	}
+	if o.HardwareInit <= 0 {
+		o.HardwareInit = t.HardwareInit()
+	}
+	if o.GuestShutdown <= 0 {
+		o.GuestShutdown = t.GuestShutdown()
+	}
+	if o.CacheDir == "" {
+		o.CacheDir = iso.DefaultCacheDir()
+	}
+	o.Offline = o.Offline || configs.Defaults.ISO.Offline
+	return o
+}
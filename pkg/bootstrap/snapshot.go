@@ -0,0 +1,351 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// SnapshotOptions controls how a snapshot is taken.
+type SnapshotOptions struct {
+	Description string // Optional snapshot description
+	Memory      bool   // Include guest memory (VM must be powered on)
+	Quiesce     bool   // Quiesce guest file systems via VMware Tools (ignored when Memory is set)
+}
+
+// SnapshotInfo describes a VM snapshot.
+type SnapshotInfo struct {
+	ID          string    // Snapshot managed object ID (unique, e.g. "snapshot-42")
+	Name        string    // Snapshot name (may not be unique)
+	Description string    // Snapshot description
+	CreatedAt   time.Time // Creation time
+	PowerState  string    // VM power state when the snapshot was taken
+	Quiesced    bool      // Whether the guest was quiesced
+	Current     bool      // Whether this is the VM's current snapshot
+}
+
+// PreChangeSnapshot configures automatic snapshots taken before in-place changes
+// (e.g. UpgradeTalosNode). Automatic snapshots are pruned after each successful change.
+type PreChangeSnapshot struct {
+	Label     string        // Change label used in the snapshot name (e.g. "talos-upgrade-v1.12.5")
+	Prefix    string        // Name prefix identifying automatic snapshots (default from configs/defaults.yaml)
+	Retention time.Duration // Prune automatic snapshots older than this (0 = default; negative = never by age)
+	Keep      int           // Keep at most this many automatic snapshots (0 = default; negative = unlimited)
+	Memory    bool          // Include guest memory
+	Quiesce   bool          // Quiesce guest file systems
+}
+
+// connect opens a vCenter session for post-create operations on vm.
+// The caller must call Disconnect on the returned client.
+func (vm *VM) connect(ctx context.Context) (*vcenter.Client, *object.VirtualMachine, error) {
+	client, err := vcenter.NewClient(ctx, vm.vcenterConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("vCenter connection failed: %w", err)
+	}
+	return client, object.NewVirtualMachine(client.Client().Client, vm.ManagedObject), nil
+}
+
+// CreateSnapshot takes a snapshot named name and returns its info.
+func (vm *VM) CreateSnapshot(ctx context.Context, name string, opts SnapshotOptions) (*SnapshotInfo, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("snapshot name is required")
+	}
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+	return createSnapshot(ctx, vmObj, name, opts)
+}
+
+func createSnapshot(ctx context.Context, vmObj *object.VirtualMachine, name string, opts SnapshotOptions) (*SnapshotInfo, error) {
+	task, err := vmObj.CreateSnapshot(ctx, name, opts.Description, opts.Memory, opts.Quiesce && !opts.Memory)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot failed: %w", err)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot wait failed: %w", err)
+	}
+
+	ref, ok := info.Result.(types.ManagedObjectReference)
+	if !ok {
+		return nil, fmt.Errorf("create snapshot returned unexpected result %T", info.Result)
+	}
+	snapshots, err := listSnapshots(ctx, vmObj)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.ID == ref.Value {
+			return &s, nil
+		}
+	}
+	return &SnapshotInfo{ID: ref.Value, Name: name, Description: opts.Description}, nil
+}
+
+// ListSnapshots returns all snapshots of the VM, oldest first.
+func (vm *VM) ListSnapshots(ctx context.Context) ([]SnapshotInfo, error) {
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+	return listSnapshots(ctx, vmObj)
+}
+
+// RevertToSnapshot reverts the VM to the snapshot identified by ID or (unique) name.
+// When suppressPowerOn is false, a VM snapshotted while powered on is powered on after revert.
+func (vm *VM) RevertToSnapshot(ctx context.Context, snapshot string, suppressPowerOn bool) error {
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+
+	task, err := vmObj.RevertToSnapshot(ctx, snapshot, suppressPowerOn)
+	if err != nil {
+		return fmt.Errorf("revert to snapshot %q failed: %w", snapshot, err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("revert to snapshot %q wait failed: %w", snapshot, err)
+	}
+	return nil
+}
+
+// RemoveSnapshot deletes the snapshot identified by ID or (unique) name.
+// When removeChildren is true, the whole subtree below the snapshot is removed as well.
+func (vm *VM) RemoveSnapshot(ctx context.Context, snapshot string, removeChildren bool) error {
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+	return removeSnapshot(ctx, vmObj, snapshot, removeChildren)
+}
+
+// WithPreChangeSnapshot takes a labelled snapshot, runs change, and on success prunes
+// automatic snapshots according to the retention settings. On failure the snapshot is
+// kept so the change can be rolled back with RevertToSnapshot.
+func (vm *VM) WithPreChangeSnapshot(ctx context.Context, opts PreChangeSnapshot, logger *slog.Logger, change func(context.Context) error) error {
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+	return vm.withPreChangeSnapshot(ctx, vmObj, opts, logger, change)
+}
+
+// withPreChangeSnapshot is WithPreChangeSnapshot on an open session, shared by
+// the snapshot, the change and the pruning.
+func (vm *VM) withPreChangeSnapshot(ctx context.Context, vmObj *object.VirtualMachine, opts PreChangeSnapshot, logger *slog.Logger, change func(context.Context) error) error {
+	if logger == nil {
+		logger = defaultLogger
+	}
+	opts = opts.withDefaults()
+
+	name := preChangeSnapshotName(opts.Prefix, opts.Label, time.Now())
+	logger.Info("Taking pre-change snapshot", "vm", vm.Name, "snapshot", name)
+	snap, err := createSnapshot(ctx, vmObj, name, SnapshotOptions{
+		Description: fmt.Sprintf("Automatic snapshot before %s", strOrFallback(opts.Label, "change")),
+		Memory:      opts.Memory,
+		Quiesce:     opts.Quiesce,
+	})
+	if err != nil {
+		return fmt.Errorf("pre-change snapshot failed: %w", err)
+	}
+
+	if err := change(ctx); err != nil {
+		logger.Warn("Change failed - pre-change snapshot kept for rollback", "snapshot", snap.Name, "id", snap.ID)
+		return err
+	}
+
+	pruned, err := pruneSnapshots(ctx, vmObj, opts.Prefix, opts.Retention, opts.Keep)
+	if err != nil {
+		logger.Warn("Failed to prune pre-change snapshots", "error", err)
+		return nil
+	}
+	if len(pruned) > 0 {
+		logger.Info("Pruned pre-change snapshots", "count", len(pruned))
+	}
+	return nil
+}
+
+// PruneSnapshots removes automatic snapshots (named <prefix>-<label>-<timestamp>, as
+// WithPreChangeSnapshot names them) that are older than retention, then trims the
+// remainder to the newest keep snapshots. Other snapshots are never touched.
+// retention <= 0 disables age-based pruning; keep <= 0 disables count-based pruning.
+// Returns the removed snapshots.
+func (vm *VM) PruneSnapshots(ctx context.Context, prefix string, retention time.Duration, keep int) ([]SnapshotInfo, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("snapshot prefix is required")
+	}
+	client, vmObj, err := vm.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Disconnect()
+	}()
+	return pruneSnapshots(ctx, vmObj, prefix, retention, keep)
+}
+
+func pruneSnapshots(ctx context.Context, vmObj *object.VirtualMachine, prefix string, retention time.Duration, keep int) ([]SnapshotInfo, error) {
+	snapshots, err := listSnapshots(ctx, vmObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []SnapshotInfo
+	for _, s := range snapshotsToPrune(snapshots, prefix, retention, keep, time.Now()) {
+		if err := removeSnapshot(ctx, vmObj, s.ID, false); err != nil {
+			return removed, err
+		}
+		removed = append(removed, s)
+	}
+	return removed, nil
+}
+
+// snapshotsToPrune selects automatic snapshots of prefix to remove.
+// snapshots must be sorted oldest first.
+func snapshotsToPrune(snapshots []SnapshotInfo, prefix string, retention time.Duration, keep int, now time.Time) []SnapshotInfo {
+	var auto []SnapshotInfo
+	for _, s := range snapshots {
+		if isPreChangeSnapshotName(prefix, s.Name) {
+			auto = append(auto, s)
+		}
+	}
+
+	var prune, kept []SnapshotInfo
+	for _, s := range auto {
+		if retention > 0 && now.Sub(s.CreatedAt) > retention {
+			prune = append(prune, s)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	if keep > 0 && len(kept) > keep {
+		prune = append(prune, kept[:len(kept)-keep]...)
+	}
+	return prune
+}
+
+func (o PreChangeSnapshot) withDefaults() PreChangeSnapshot {
+	d := configs.Defaults.Snapshots
+	if o.Prefix == "" {
+		o.Prefix = d.NamePrefix
+	}
+	if o.Retention == 0 {
+		o.Retention = d.Retention()
+	}
+	if o.Keep == 0 {
+		o.Keep = d.Keep
+	}
+	return o
+}
+
+var snapshotLabelRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func preChangeSnapshotName(prefix, label string, now time.Time) string {
+	label = strings.Trim(snapshotLabelRe.ReplaceAllString(strings.TrimSpace(label), "-"), "-")
+	if label == "" {
+		label = "change"
+	}
+	return fmt.Sprintf("%s-%s-%s", prefix, label, now.UTC().Format(preChangeTimeFormat))
+}
+
+const preChangeTimeFormat = "20060102T150405Z"
+
+// isPreChangeSnapshotName reports whether name has the exact form
+// preChangeSnapshotName generates for prefix.
+func isPreChangeSnapshotName(prefix, name string) bool {
+	rest, ok := strings.CutPrefix(name, prefix+"-")
+	if !ok {
+		return false
+	}
+	i := strings.LastIndex(rest, "-")
+	if i <= 0 {
+		return false
+	}
+	label, stamp := rest[:i], rest[i+1:]
+	if snapshotLabelRe.MatchString(label) || strings.Trim(label, "-") != label {
+		return false
+	}
+	_, err := time.Parse(preChangeTimeFormat, stamp)
+	return err == nil
+}
+
+func strOrFallback(s, fallback string) string {
+	if strings.TrimSpace(s) == "" {
+		return fallback
+	}
+	return s
+}
+
+func listSnapshots(ctx context.Context, vmObj *object.VirtualMachine) ([]SnapshotInfo, error) {
+	var o mo.VirtualMachine
+	if err := vmObj.Properties(ctx, vmObj.Reference(), []string{"snapshot"}, &o); err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	if o.Snapshot == nil {
+		return nil, nil
+	}
+
+	var current string
+	if o.Snapshot.CurrentSnapshot != nil {
+		current = o.Snapshot.CurrentSnapshot.Value
+	}
+
+	var result []SnapshotInfo
+	var walk func([]types.VirtualMachineSnapshotTree)
+	walk = func(trees []types.VirtualMachineSnapshotTree) {
+		for _, t := range trees {
+			result = append(result, SnapshotInfo{
+				ID:          t.Snapshot.Value,
+				Name:        t.Name,
+				Description: t.Description,
+				CreatedAt:   t.CreateTime,
+				PowerState:  string(t.State),
+				Quiesced:    t.Quiesced,
+				Current:     t.Snapshot.Value == current,
+			})
+			walk(t.ChildSnapshotList)
+		}
+	}
+	walk(o.Snapshot.RootSnapshotList)
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+func removeSnapshot(ctx context.Context, vmObj *object.VirtualMachine, snapshot string, removeChildren bool) error {
+	consolidate := true
+	task, err := vmObj.RemoveSnapshot(ctx, snapshot, removeChildren, &consolidate)
+	if err != nil {
+		return fmt.Errorf("remove snapshot %q failed: %w", snapshot, err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("remove snapshot %q wait failed: %w", snapshot, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestVM_SnapshotLifecycle(t *testing.T) {
+	env, cleanup := newSimBootstrapEnv(t)
+	defer cleanup()
+
+	vm := env.newVM(t)
+	ctx := context.Background()
+
+	snapshots, err := vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	require.Empty(t, snapshots)
+
+	first, err := vm.CreateSnapshot(ctx, "base", SnapshotOptions{Description: "baseline"})
+	require.NoError(t, err)
+	require.Equal(t, "base", first.Name)
+	require.Equal(t, "baseline", first.Description)
+	require.NotEmpty(t, first.ID)
+
+	second, err := vm.CreateSnapshot(ctx, "child", SnapshotOptions{Quiesce: true})
+	require.NoError(t, err)
+
+	snapshots, err = vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.True(t, snapshots[1].Current)
+	require.Equal(t, second.ID, snapshots[1].ID)
+
+	require.NoError(t, vm.RevertToSnapshot(ctx, "base", true))
+	require.NoError(t, vm.RemoveSnapshot(ctx, first.ID, true))
+
+	snapshots, err = vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	require.Empty(t, snapshots)
+}
+
+func TestVM_CreateSnapshot_RequiresName(t *testing.T) {
+	_, err := (&VM{}).CreateSnapshot(context.Background(), " ", SnapshotOptions{})
+	require.EqualError(t, err, "snapshot name is required")
+}
+
+func TestVM_RemoveSnapshot_NotFound(t *testing.T) {
+	env, cleanup := newSimBootstrapEnv(t)
+	defer cleanup()
+
+	vm := env.newVM(t)
+	err := vm.RemoveSnapshot(context.Background(), "missing", false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing")
+}
+
+func TestVM_WithPreChangeSnapshot(t *testing.T) {
+	env, cleanup := newSimBootstrapEnv(t)
+	defer cleanup()
+
+	vm := env.newVM(t)
+	ctx := context.Background()
+	opts := PreChangeSnapshot{Label: "talos upgrade/v1.12.5", Keep: 1}
+
+	ran := 0
+	for range 3 {
+		require.NoError(t, vm.WithPreChangeSnapshot(ctx, opts, nil, func(context.Context) error {
+			ran++
+			return nil
+		}))
+	}
+	require.Equal(t, 3, ran)
+
+	snapshots, err := vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	require.True(t, strings.HasPrefix(snapshots[0].Name, "pre-change-talos-upgrade-v1.12.5-"), snapshots[0].Name)
+
+	// A failed change keeps its snapshot for rollback and skips pruning.
+	err = vm.WithPreChangeSnapshot(ctx, opts, nil, func(context.Context) error {
+		return errors.New("boom")
+	})
+	require.EqualError(t, err, "boom")
+
+	snapshots, err = vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+
+	// Snapshots taken by hand are never pruned, whatever their name.
+	_, err = vm.CreateSnapshot(ctx, "pre-change-before-upgrade", SnapshotOptions{})
+	require.NoError(t, err)
+	require.NoError(t, vm.WithPreChangeSnapshot(ctx, opts, nil, func(context.Context) error { return nil }))
+	snapshots, err = vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	names := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		names = append(names, s.Name)
+	}
+	require.Contains(t, names, "pre-change-before-upgrade")
+	require.Len(t, snapshots, 2)
+}
+
+func TestVM_Reconfigure_WithSnapshot(t *testing.T) {
+	env, cleanup := newSimBootstrapEnv(t)
+	defer cleanup()
+
+	vm := env.newVM(t)
+	ctx := context.Background()
+
+	require.NoError(t, vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{Annotation: "changed"}, &PreChangeSnapshot{}))
+
+	snapshots, err := vm.ListSnapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	require.True(t, strings.HasPrefix(snapshots[0].Name, "pre-change-reconfigure-"), snapshots[0].Name)
+}
+
+func TestSnapshotsToPrune(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	snap := func(id string, age time.Duration) SnapshotInfo {
+		created := now.Add(-age)
+		return SnapshotInfo{ID: id, Name: preChangeSnapshotName("pre-change", id, created), CreatedAt: created}
+	}
+	manual := func(name string, age time.Duration) SnapshotInfo {
+		return SnapshotInfo{ID: name, Name: name, CreatedAt: now.Add(-age)}
+	}
+	snapshots := []SnapshotInfo{
+		snap("pre-change-a", 100*time.Hour),
+		manual("manual", 90*time.Hour),
+		manual("pre-change-before-upgrade", 80*time.Hour),
+		manual("pre-change-db-2026-01-01", 70*time.Hour),
+		snap("pre-change-b", 5*time.Hour),
+		snap("pre-change-c", 3*time.Hour),
+		snap("pre-change-d", 1*time.Hour),
+	}
+
+	ids := func(list []SnapshotInfo) []string {
+		var out []string
+		for _, s := range list {
+			out = append(out, s.ID)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"pre-change-a", "pre-change-b"}, ids(snapshotsToPrune(snapshots, "pre-change", 72*time.Hour, 2, now)))
+	require.Equal(t, []string{"pre-change-a"}, ids(snapshotsToPrune(snapshots, "pre-change", 72*time.Hour, 0, now)))
+	require.Equal(t, []string{"pre-change-a", "pre-change-b", "pre-change-c"}, ids(snapshotsToPrune(snapshots, "pre-change", -1, 1, now)))
+	require.Empty(t, snapshotsToPrune(snapshots, "pre-change", 0, 0, now))
+}
+
+func TestPreChangeSnapshotName(t *testing.T) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.Equal(t, "pre-change-talos-upgrade-v1.2.3-20260304T050607Z", preChangeSnapshotName("pre-change", "talos upgrade v1.2.3", now))
+	require.Equal(t, "snap-change-20260304T050607Z", preChangeSnapshotName("snap", " // ", now))
+}
+
+func TestIsPreChangeSnapshotName(t *testing.T) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.True(t, isPreChangeSnapshotName("pre-change", preChangeSnapshotName("pre-change", "talos upgrade v1.2.3", now)))
+	require.True(t, isPreChangeSnapshotName("snap", preChangeSnapshotName("snap", "", now)))
+
+	for _, name := range []string{
+		"pre-change",
+		"pre-change-before-upgrade",
+		"pre-change-20260304T050607Z",
+		"pre-change-my label-20260304T050607Z",
+		"pre-change-upgrade-20260304",
+		"pre-changes-upgrade-20260304T050607Z",
+		"snap-upgrade-20260304T050607Z",
+	} {
+		require.False(t, isPreChangeSnapshotName("pre-change", name), name)
+	}
+}
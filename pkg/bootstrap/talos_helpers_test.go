@@ -1,12 +1,13 @@
 package bootstrap
 
 import (
-	"context"
 	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 )
 
 func TestTalosOVAHelpers(t *testing.T) {
@@ -25,80 +26,36 @@ func TestTalosOVAHelpers(t *testing.T) {
 	if got := talosLibraryItemName("", ""); got != "talos-latest-default" {
 		t.Fatalf("unexpected default item name: %q", got)
 	}
-	if !govcErrContains(errors.New("invalid_library_item"), "invalid_library_item") {
-		t.Fatal("expected contains match")
-	}
-	if govcErrContains(nil, "x") {
-		t.Fatal("expected false on nil err")
-	}
 }
 
-func TestGovcEnv(t *testing.T) {
-	cfg := &VMConfig{
-		VCenterHost:     "vc.example.local",
-		VCenterUsername: "user",
-		VCenterPassword: "pass",
-		Datacenter:      "DC1",
-		VCenterInsecure: true,
-	}
-	env := govcEnv(cfg)
-	asMap := map[string]string{}
-	for _, kv := range env {
-		parts := strings.SplitN(kv, "=", 2)
-		if len(parts) == 2 {
-			asMap[parts[0]] = parts[1]
-		}
-	}
-	if asMap["GOVC_URL"] != "https://vc.example.local/sdk" {
-		t.Fatalf("unexpected GOVC_URL: %q", asMap["GOVC_URL"])
-	}
-	if asMap["GOVC_USERNAME"] != "user" || asMap["GOVC_PASSWORD"] != "pass" {
-		t.Fatalf("missing govc creds in env: %#v", asMap)
-	}
-}
+func TestTalosOVASource_OfflineBundle(t *testing.T) {
+	opts := Options{CacheDir: t.TempDir()}
 
-func TestRunGovc_SuccessAndErrors(t *testing.T) {
-	tmp := t.TempDir()
-	script := filepath.Join(tmp, "govc")
-	content := `#!/usr/bin/env bash
-set -euo pipefail
-mode="${1:-ok}"
-if [[ "$mode" == "ok" ]]; then
-  echo "hello"
-  exit 0
-fi
-if [[ "$mode" == "err-stderr" ]]; then
-  echo "stderr-msg" 1>&2
-  exit 2
-fi
-echo "stdout-msg"
-exit 3
-`
-	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
-		t.Fatal(err)
+	ovaURL := talosOVAURL("1.2.3", "schem")
+	if got, err := talosOVASource(ovaURL, opts); err != nil || got != ovaURL {
+		t.Fatalf("without bundle: %q, %v", got, err)
 	}
-
-	oldPath := os.Getenv("PATH")
-	if err := os.Setenv("PATH", tmp+string(os.PathListSeparator)+oldPath); err != nil {
-		t.Fatal(err)
+	opts.Offline = true
+	if _, err := talosOVASource(ovaURL, opts); !errors.Is(err, iso.ErrNotInBundle) {
+		t.Fatalf("offline without bundle: expected ErrNotInBundle, got %v", err)
 	}
-	t.Cleanup(func() { _ = os.Setenv("PATH", oldPath) })
 
-	out, err := runGovc(context.Background(), nil, "ok")
+	idx, err := iso.LoadOfflineIndex(iso.NewCache(opts.CacheDir).OfflineDir())
 	if err != nil {
-		t.Fatalf("unexpected err: %v", err)
+		t.Fatalf("LoadOfflineIndex: %v", err)
 	}
-	if strings.TrimSpace(string(out)) != "hello" {
-		t.Fatalf("unexpected out: %q", string(out))
+	if err := os.MkdirAll(filepath.Join(idx.Dir, "files"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
 	}
-
-	_, err = runGovc(context.Background(), nil, "err-stderr")
-	if err == nil || !strings.Contains(err.Error(), "stderr-msg") {
-		t.Fatalf("expected stderr error, got: %v", err)
+	if err := os.WriteFile(filepath.Join(idx.Dir, "files", "vmware-amd64.ova"), []byte("ova"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
 	}
-
-	_, err = runGovc(context.Background(), nil, "err-stdout")
-	if err == nil || !strings.Contains(err.Error(), "stdout-msg") {
-		t.Fatalf("expected stdout fallback error, got: %v", err)
+	idx.Add(iso.OfflineArtifact{URL: ovaURL, Path: "files/vmware-amd64.ova", Size: 3})
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := talosOVASource(ovaURL, opts)
+	if err != nil || !strings.HasPrefix(got, "file://") || !strings.HasSuffix(got, "/files/vmware-amd64.ova") {
+		t.Fatalf("with bundle: %q, %v", got, err)
 	}
 }
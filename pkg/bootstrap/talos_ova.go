@@ -1,28 +1,23 @@
 package bootstrap
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/exec"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
 
-	contentlibrary "github.com/infrakit-io/vmware-content-library-core"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	talosprofile "github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile/talos"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vm"
 )
 
-type ovfImportSpec struct {
-	Name           string `json:"Name,omitempty"`
-	NetworkMapping []struct {
-		Name    string `json:"Name,omitempty"`
-		Network string `json:"Network,omitempty"`
-	} `json:"NetworkMapping,omitempty"`
-}
+// talosFactoryURL is the Talos Image Factory base URL (overridden in tests).
+var talosFactoryURL = "https://factory.talos.dev"
 
 func normalizeTalosVersion(v string) string {
 	v = strings.TrimSpace(v)
@@ -35,44 +30,20 @@ func normalizeTalosVersion(v string) string {
 	return v
 }
 
-func talosOVAURL(version, schematicID string) string {
-	return fmt.Sprintf("https://factory.talos.dev/image/%s/%s/vmware-amd64.ova",
-		strings.TrimSpace(schematicID), normalizeTalosVersion(version))
+// TalosOVAURL returns the Image Factory URL of the Talos VMware OVA.
+func TalosOVAURL(version, schematicID string) string {
+	return talosOVAURL(version, schematicID)
 }
 
-func govcEnv(cfg *VMConfig) []string {
-	url := cfg.VCenterHost
-	if !strings.Contains(url, "://") {
-		url = "https://" + url + "/sdk"
-	}
-	return append(os.Environ(),
-		"GOVC_URL="+url,
-		"GOVC_USERNAME="+cfg.VCenterUsername,
-		"GOVC_PASSWORD="+cfg.VCenterPassword,
-		"GOVC_DATACENTER="+cfg.Datacenter,
-		fmt.Sprintf("GOVC_INSECURE=%t", cfg.VCenterInsecure),
-	)
+// TalosLibraryItemName returns the content library item name the OVA of a
+// Talos version and schematic is imported as.
+func TalosLibraryItemName(version, schematicID string) string {
+	return talosLibraryItemName(version, schematicID)
 }
 
-func runGovc(ctx context.Context, env []string, args ...string) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, "govc", args...)
-	cmd.Env = env
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-	err := cmd.Run()
-	if err != nil {
-		msg := strings.TrimSpace(stderr.String())
-		if msg == "" {
-			msg = strings.TrimSpace(out.String())
-		}
-		if msg != "" {
-			return nil, fmt.Errorf("%w: %s", err, msg)
-		}
-		return nil, err
-	}
-	return out.Bytes(), nil
+func talosOVAURL(version, schematicID string) string {
+	return fmt.Sprintf("%s/image/%s/%s/vmware-amd64.ova", talosFactoryURL,
+		strings.TrimSpace(schematicID), normalizeTalosVersion(version))
 }
 
 var talosLibraryItemSanitizer = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
@@ -93,11 +64,18 @@ func talosLibraryItemName(version, schematicID string) string {
 	return talosLibraryItemSanitizer.ReplaceAllString(name, "-")
 }
 
-func govcErrContains(err error, needle string) bool {
-	if err == nil || strings.TrimSpace(needle) == "" {
-		return false
-	}
-	return strings.Contains(strings.ToLower(err.Error()), strings.ToLower(needle))
+// talosOVASource returns the file:// URL of the OVA in an offline bundle
+// imported into opts.CacheDir, or ovaURL itself. With opts.Offline set the OVA
+// must be bundled.
+func talosOVASource(ovaURL string, opts Options) (string, error) {
+	opts = opts.withDefaults()
+	if p, ok := iso.LookupOffline(iso.NewCache(opts.CacheDir).OfflineDir(), ovaURL); ok {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String(), nil
+	}
+	if opts.Offline {
+		return "", fmt.Errorf("talos OVA %s: %w", ovaURL, iso.ErrNotInBundle)
+	}
+	return ovaURL, nil
 }
 
 // CreateTalosNodeFromOVA deploys a Talos VMware OVA and powers the VM on.
@@ -112,6 +90,11 @@ func CreateTalosNodeFromOVA(ctx context.Context, cfg *VMConfig, logger *slog.Log
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	if cfg.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Options.Timeout)
+		defer cancel()
+	}
 	if cfg.Name == "" {
 		return nil, fmt.Errorf("name is required")
 	}
@@ -129,145 +112,125 @@ func CreateTalosNodeFromOVA(ctx context.Context, cfg *VMConfig, logger *slog.Log
 		return nil, fmt.Errorf("Profiles.Talos.SchematicID is required")
 	}
 	ovaURL := talosOVAURL(version, schematicID)
+	ovaSource, err := talosOVASource(ovaURL, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	vc, err := vcenter.NewClient(ctx, cfg.VCenterConfig())
+	if err != nil {
+		return nil, fmt.Errorf("vCenter connection failed: %w", err)
+	}
+	defer func() { _ = vc.Disconnect() }()
+
+	if cfg.hasResourceOptions() {
+		if err := checkTalosHostCapabilities(vc, cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	logger.Info("Deploying Talos OVA", "url", ovaURL, "version", version, "schematic_id", schematicID)
 
-	env := govcEnv(cfg)
-	libClient := contentlibrary.NewClient(contentlibrary.GovcRunner{Env: env})
-	libraryTarget := strings.TrimSpace(cfg.ContentLibraryID)
-	libraryName := libraryTarget
-	if libraryTarget == "" {
+	libraryID := strings.TrimSpace(cfg.ContentLibraryID)
+	libraryName := libraryID
+	if libraryID == "" {
 		libraryName = strings.TrimSpace(cfg.ContentLibrary)
 		if libraryName == "" {
 			libraryName = "talos-images"
 		}
-		libRef, err := libClient.EnsureLibrary(ctx, libraryName)
+		lib, err := vc.EnsureLibrary(libraryName, cfg.Datacenter, cfg.Datastore)
 		if err != nil {
 			return nil, fmt.Errorf("ensure content library %q: %w", libraryName, err)
 		}
-		libraryTarget = libRef.Target
+		libraryID = lib.ID
 	}
 	itemName := talosLibraryItemName(version, schematicID)
 	logger.Info("Talos content library target", "library", libraryName, "item", itemName)
-	if err := libClient.EnsureItemFromURL(ctx, libraryTarget, itemName, ovaURL); err != nil {
-		return nil, fmt.Errorf("ensure library item: %w", err)
+	if ovaSource != ovaURL {
+		logger.Info("Using Talos OVA from offline bundle", "source", ovaSource)
 	}
-
-	specRaw, err := runGovc(ctx, env, "import.spec", ovaURL)
+	itemID, err := vc.EnsureLibraryItemFromURL(libraryID, itemName, ovaSource)
 	if err != nil {
-		return nil, fmt.Errorf("govc import.spec failed: %w", err)
-	}
-	var spec ovfImportSpec
-	if err := json.Unmarshal(specRaw, &spec); err != nil {
-		return nil, fmt.Errorf("parse import spec: %w", err)
-	}
-	spec.Name = cfg.Name
-	if cfg.NetworkName != "" {
-		for i := range spec.NetworkMapping {
-			spec.NetworkMapping[i].Network = cfg.NetworkName
-		}
-	}
-
-	if err := os.MkdirAll("tmp", 0o755); err != nil {
-		return nil, err
-	}
-	specPath := filepath.Join("tmp", fmt.Sprintf("ova-import-%s.json", cfg.Name))
-	specBytes, err := json.MarshalIndent(spec, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("marshal import spec: %w", err)
-	}
-	if err := os.WriteFile(specPath, specBytes, 0o600); err != nil {
-		return nil, fmt.Errorf("write import spec: %w", err)
+		return nil, fmt.Errorf("ensure library item: %w", err)
 	}
-	defer func() { _ = os.Remove(specPath) }()
 
-	deployOpt := contentlibrary.DeployOptions{
+	deployOpts := vcenter.LibraryDeployOptions{
+		ItemID:       itemID,
+		VMName:       cfg.Name,
 		Datacenter:   cfg.Datacenter,
 		Datastore:    cfg.Datastore,
 		Folder:       cfg.Folder,
 		ResourcePool: cfg.ResourcePool,
-		OptionsPath:  specPath,
-		ItemPath:     contentlibrary.ItemPath(libraryTarget, itemName),
-		VMName:       cfg.Name,
+		Network:      cfg.NetworkName,
 	}
-	if err := libClient.DeployItem(ctx, deployOpt); err != nil {
+	vmObj, err := vc.DeployLibraryItem(deployOpts)
+	if errors.Is(err, vcenter.ErrInvalidLibraryItem) {
 		// Recover once from broken library item state (e.g. partial failed import).
-		if govcErrContains(err, "invalid_library_item") || govcErrContains(err, "not an OVF") {
-			libClient.RemoveItem(ctx, libraryTarget, itemName)
-			if impErr := libClient.ImportItemFromURL(ctx, libraryTarget, itemName, ovaURL); impErr == nil {
-				if retryErr := libClient.DeployItem(ctx, deployOpt); retryErr == nil {
-					goto deployed
-				} else {
-					return nil, fmt.Errorf("library deploy failed after item reimport: %w", retryErr)
-				}
-			} else {
-				return nil, fmt.Errorf("recover invalid library item failed: %w", impErr)
-			}
+		logger.Warn("Library item is not a valid OVF - reimporting", "item", itemName, "error", err)
+		if rmErr := vc.RemoveLibraryItem(itemID); rmErr != nil {
+			return nil, fmt.Errorf("recover invalid library item failed: %w", rmErr)
+		}
+		if deployOpts.ItemID, err = vc.ImportLibraryItemFromURL(libraryID, itemName, ovaSource); err != nil {
+			return nil, fmt.Errorf("recover invalid library item failed: %w", err)
+		}
+		if vmObj, err = vc.DeployLibraryItem(deployOpts); err != nil {
+			return nil, fmt.Errorf("library deploy failed after item reimport: %w", err)
 		}
+	} else if err != nil {
 		return nil, err
 	}
-deployed:
 
 	// Reconfigure VM hardware from config (OVA ships with minimal defaults).
-	if cfg.CPUs > 0 || cfg.MemoryMB > 0 {
-		changeArgs := []string{"vm.change", "-vm", cfg.Name}
-		if cfg.CPUs > 0 {
-			changeArgs = append(changeArgs, "-c", fmt.Sprintf("%d", cfg.CPUs))
-		}
-		if cfg.MemoryMB > 0 {
-			changeArgs = append(changeArgs, "-m", fmt.Sprintf("%d", cfg.MemoryMB))
-		}
-		logger.Info("Reconfiguring VM hardware", "cpus", cfg.CPUs, "memory_mb", cfg.MemoryMB)
-		if _, err := runGovc(ctx, env, changeArgs...); err != nil {
-			return nil, fmt.Errorf("reconfigure VM hardware: %w", err)
-		}
+	creator := vm.NewCreator(ctx)
+	guestID, hwVersion, extraConfig := cfg.effectiveHardware(talosprofile.New().HardwareDefaults())
+	logger.Info("Reconfiguring VM hardware", "cpus", cfg.CPUs, "memory_mb", cfg.MemoryMB,
+		"hardware_version", hwVersion, "cores_per_socket", cfg.CoresPerSocket,
+		"memory_reservation_mb", cfg.MemoryReservationMB)
+	if err := creator.ReconfigureHardware(vmObj, talosHardwareConfig(cfg, guestID, hwVersion, extraConfig)); err != nil {
+		return nil, fmt.Errorf("reconfigure VM hardware: %w", err)
 	}
 
 	// Resize OS disk if configured larger than OVA default.
 	if cfg.DiskSizeGB > 0 {
 		logger.Info("Resizing OS disk", "size_gb", cfg.DiskSizeGB)
-		if _, err := runGovc(ctx, env, "vm.disk.change", "-vm", cfg.Name,
-			"-size", fmt.Sprintf("%dG", cfg.DiskSizeGB)); err != nil {
+		if err := creator.ResizeDisk(vmObj, int64(cfg.DiskSizeGB)); err != nil {
 			return nil, fmt.Errorf("resize OS disk: %w", err)
 		}
 	}
 
 	// Add data disk if specified.
 	if cfg.DataDiskSizeGB != nil && *cfg.DataDiskSizeGB > 0 {
-		diskName := fmt.Sprintf("%s/data-disk", cfg.Name)
-		logger.Info("Adding data disk", "size_gb", *cfg.DataDiskSizeGB, "name", diskName)
-		createArgs := []string{"vm.disk.create", "-vm", cfg.Name,
-			"-size", fmt.Sprintf("%dG", *cfg.DataDiskSizeGB),
-			"-name", diskName}
-		if cfg.Datastore != "" {
-			createArgs = append(createArgs, "-ds", cfg.Datastore)
+		logger.Info("Adding data disk", "size_gb", *cfg.DataDiskSizeGB)
+		ds, err := vc.FindDatastore(cfg.Datacenter, cfg.Datastore)
+		if err != nil {
+			return nil, fmt.Errorf("add data disk: %w", err)
 		}
-		if _, err := runGovc(ctx, env, createArgs...); err != nil {
+		scsiKey, err := creator.EnsureSCSIController(vmObj)
+		if err != nil {
+			return nil, fmt.Errorf("add data disk: %w", err)
+		}
+		if err := creator.AddDisk(vmObj, ds, int64(*cfg.DataDiskSizeGB), scsiKey); err != nil {
 			return nil, fmt.Errorf("add data disk: %w", err)
 		}
 	}
 
-	if _, err := runGovc(ctx, env, "vm.power", "-on", cfg.Name); err != nil {
-		return nil, fmt.Errorf("failed to power on Talos VM: %w", err)
-	}
-
-	vc, err := vcenter.NewClient(ctx, &vcenter.Config{
-		Host:     cfg.VCenterHost,
-		Port:     cfg.VCenterPort,
-		Username: cfg.VCenterUsername,
-		Password: cfg.VCenterPassword,
-		Insecure: cfg.VCenterInsecure,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("vCenter connection failed after OVA deploy: %w", err)
+	// Apply Secure Boot / vTPM / encryption policy (OVA ships without them).
+	if cfg.SecureBoot || cfg.VTPM || cfg.EncryptionPolicy != "" {
+		logger.Info("Applying VM security options",
+			"secure_boot", cfg.SecureBoot, "vtpm", cfg.VTPM, "encryption_policy", cfg.EncryptionPolicy)
+		if err := creator.ConfigureSecurity(vmObj, &vm.Config{
+			Name:             cfg.Name,
+			Firmware:         cfg.Firmware,
+			SecureBoot:       cfg.SecureBoot,
+			VTPM:             cfg.VTPM,
+			EncryptionPolicy: cfg.EncryptionPolicy,
+		}); err != nil {
+			return nil, fmt.Errorf("apply VM security options: %w", err)
+		}
 	}
-	defer func() { _ = vc.Disconnect() }()
 
-	vmObj, err := vc.FindVM(cfg.Datacenter, cfg.Name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to locate created Talos VM: %w", err)
-	}
-	if vmObj == nil {
-		return nil, fmt.Errorf("created Talos VM not found: %s", cfg.Name)
+	if err := creator.PowerOn(vmObj); err != nil {
+		return nil, fmt.Errorf("failed to power on Talos VM: %w", err)
 	}
 
 	managed := vmObj.Reference()
@@ -282,5 +245,53 @@ deployed:
 		VCenterUser:     cfg.VCenterUsername,
 		VCenterPass:     cfg.VCenterPassword,
 		VCenterInsecure: cfg.VCenterInsecure,
+
+		VCenterPasswordProvider: cfg.VCenterPasswordProvider,
+		VCenterThumbprint:       cfg.VCenterThumbprint,
+		VCenterCACertFile:       cfg.VCenterCACertFile,
+		VCenterCACertPEM:        cfg.VCenterCACertPEM,
+		VCenterSessionCacheDir:  cfg.VCenterSessionCacheDir,
+		Options:                 cfg.Options,
 	}, nil
 }
+
+// checkTalosHostCapabilities validates CPU/memory tuning against the hosts behind
+// the target resource pool before the OVA is deployed.
+func checkTalosHostCapabilities(vc *vcenter.Client, cfg *VMConfig) error {
+	pool, err := vc.FindResourcePool(cfg.Datacenter, cfg.ResourcePool)
+	if err != nil {
+		return fmt.Errorf("failed to find resource pool: %w", err)
+	}
+	caps, err := vc.HostCapabilities(pool)
+	if err != nil {
+		return fmt.Errorf("failed to read host capabilities: %w", err)
+	}
+	if err := caps.Validate(int32(cfg.CPUs), int64(cfg.MemoryMB),
+		int64(cfg.CPUReservationMHz), int64(cfg.MemoryReservationMB)); err != nil {
+		return fmt.Errorf("VM resources exceed host capabilities: %w", err)
+	}
+	return nil
+}
+
+// talosHardwareConfig maps the VM config onto the hardware settings applied to a
+// freshly deployed OVA VM: sizing, tuning, guest OS ID, hardware version and extraConfig.
+func talosHardwareConfig(cfg *VMConfig, guestID, hwVersion string, extraConfig map[string]string) *vm.Config {
+	return &vm.Config{
+		Name:                cfg.Name,
+		CPUs:                int32(cfg.CPUs),
+		MemoryMB:            int64(cfg.MemoryMB),
+		GuestOS:             guestID,
+		HardwareVersion:     hwVersion,
+		ExtraConfig:         extraConfig,
+		CoresPerSocket:      int32(cfg.CoresPerSocket),
+		CPUHotAdd:           cfg.CPUHotAdd,
+		MemoryHotAdd:        cfg.MemoryHotAdd,
+		CPUReservationMHz:   int64(cfg.CPUReservationMHz),
+		CPULimitMHz:         int64(cfg.CPULimitMHz),
+		CPUShares:           cfg.CPUShares,
+		MemoryReservationMB: int64(cfg.MemoryReservationMB),
+		MemoryLimitMB:       int64(cfg.MemoryLimitMB),
+		MemoryShares:        cfg.MemoryShares,
+		LatencySensitivity:  cfg.LatencySensitivity,
+	}
+}
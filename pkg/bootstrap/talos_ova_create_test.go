@@ -1,23 +1,30 @@
 package bootstrap
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
-	"os"
-	"path/filepath"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
-)
 
-func withFakeGovc(t *testing.T, script string) {
-	t.Helper()
-	tmp := t.TempDir()
-	govcPath := filepath.Join(tmp, "govc")
-	if err := os.WriteFile(govcPath, []byte(script), 0o755); err != nil {
-		t.Fatalf("write fake govc: %v", err)
-	}
-	oldPath := os.Getenv("PATH")
-	t.Setenv("PATH", tmp+string(os.PathListSeparator)+oldPath)
-}
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	talosprofile "github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile/talos"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+)
 
 func validTalosOVAConfig() *VMConfig {
 	return &VMConfig{
@@ -67,178 +74,285 @@ func TestCreateTalosNodeFromOVA_ValidateConfigError(t *testing.T) {
 	}
 }
 
-func TestCreateTalosNodeFromOVA_ImportSpecFailure(t *testing.T) {
-	script := `#!/usr/bin/env bash
-set -euo pipefail
-if [[ "${1:-}" == "library.info" ]]; then
-  echo "null"
-  exit 0
-fi
-if [[ "${1:-}" == "library.import" ]]; then
-  exit 0
-fi
-if [[ "${1:-}" == "import.spec" ]]; then
-  echo "import.spec failed from fake govc" >&2
-  exit 1
-fi
-echo "unexpected govc call: $*" >&2
-exit 1
-`
-	withFakeGovc(t, script)
-
+func TestCreateTalosNodeFromOVA_VCenterConnectFails(t *testing.T) {
 	cfg := validTalosOVAConfig()
+	cfg.VCenterHost = "https://127.0.0.1:1/sdk"
+
 	_, err := CreateTalosNodeFromOVA(context.Background(), cfg, nil)
-	if err == nil || !strings.Contains(err.Error(), "govc import.spec failed") {
-		t.Fatalf("expected import.spec error, got: %v", err)
+	if err == nil || !strings.Contains(err.Error(), "vCenter connection failed") {
+		t.Fatalf("expected vCenter connection error, got: %v", err)
 	}
 }
 
-func TestCreateTalosNodeFromOVA_ImportSpecParseError(t *testing.T) {
-	script := `#!/usr/bin/env bash
-set -euo pipefail
-if [[ "${1:-}" == "library.info" ]]; then
-  echo '{"name":"already-there"}'
-  exit 0
-fi
-if [[ "${1:-}" == "import.spec" ]]; then
-  echo 'not-json'
-  exit 0
-fi
-echo "unexpected govc call: $*" >&2
-exit 1
+// talosTestOVF is a minimal single-VM OVF with a blank disk and one network,
+// enough for vcsim to deploy it from a content library.
+const talosTestOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References/>
+  <DiskSection>
+    <Info>Virtual disk information</Info>
+    <Disk ovf:capacity="1" ovf:capacityAllocationUnits="byte * 2^30" ovf:diskId="vmdisk1"/>
+  </DiskSection>
+  <NetworkSection>
+    <Info>The list of logical networks</Info>
+    <Network ovf:name="VM Network">
+      <Description>The VM Network network</Description>
+    </Network>
+  </NetworkSection>
+  <VirtualSystem ovf:id="talos">
+    <Info>A virtual machine</Info>
+    <Name>talos</Name>
+    <OperatingSystemSection ovf:id="101">
+      <Info>The kind of installed guest operating system</Info>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemType>vmx-15</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
+        <rasd:ElementName>1 virtual CPU(s)</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>1</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:ElementName>512MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>512</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:Address>0</rasd:Address>
+        <rasd:ElementName>SCSI Controller 0</rasd:ElementName>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceSubType>VirtualSCSI</rasd:ResourceSubType>
+        <rasd:ResourceType>6</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>0</rasd:AddressOnParent>
+        <rasd:ElementName>Hard Disk 1</rasd:ElementName>
+        <rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource>
+        <rasd:InstanceID>4</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>7</rasd:AddressOnParent>
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Connection>VM Network</rasd:Connection>
+        <rasd:ElementName>Ethernet 1</rasd:ElementName>
+        <rasd:InstanceID>5</rasd:InstanceID>
+        <rasd:ResourceSubType>VmxNet3</rasd:ResourceSubType>
+        <rasd:ResourceType>10</rasd:ResourceType>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
 `
-	withFakeGovc(t, script)
 
-	cfg := validTalosOVAConfig()
-	_, err := CreateTalosNodeFromOVA(context.Background(), cfg, nil)
-	if err == nil || !strings.Contains(err.Error(), "parse import spec") {
-		t.Fatalf("expected import spec parse error, got: %v", err)
-	}
+// serveTalosOVA serves talosTestOVF packed as an OVA for every Image Factory path
+// and points talosFactoryURL at it. Returns a counter of OVA downloads.
+func serveTalosOVA(t *testing.T) *atomic.Int32 {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "talos.ovf", Mode: 0o644, Size: int64(len(talosTestOVF))}))
+	_, err := tw.Write([]byte(talosTestOVF))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	var downloads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/vmware-amd64.ova") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			downloads.Add(1)
+		}
+		_, _ = w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+
+	old := talosFactoryURL
+	talosFactoryURL = srv.URL
+	t.Cleanup(func() { talosFactoryURL = old })
+	return &downloads
 }
 
-func TestCreateTalosNodeFromOVA_EnsureItemError(t *testing.T) {
-	script := `#!/usr/bin/env bash
-set -euo pipefail
-if [[ "${1:-}" == "library.info" ]]; then
-  echo "govc: matches 0 items" >&2
-  exit 1
-fi
-echo "unexpected govc call: $*" >&2
-exit 1
-`
-	withFakeGovc(t, script)
+// newSimTalos starts a TLS vcsim (with the vAPI content library endpoints) and
+// returns a Talos config targeting its inventory.
+func newSimTalos(t *testing.T) (*VMConfig, *find.Finder) {
+	t.Helper()
+
+	model := simulator.VPX()
+	model.Datacenter = 1
+	model.Cluster = 1
+	model.Host = 0
+	model.Pool = 1
+	model.Machine = 0
+	require.NoError(t, model.Create())
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	s := model.Service.NewServer()
+	t.Cleanup(func() {
+		s.Close()
+		model.Remove()
+	})
+
+	u := *s.URL
+	u.User = simulator.DefaultLogin
+	c, err := govmomi.NewClient(context.Background(), &u, true)
+	require.NoError(t, err)
 
+	password, _ := simulator.DefaultLogin.Password()
 	cfg := validTalosOVAConfig()
-	_, err := CreateTalosNodeFromOVA(context.Background(), cfg, nil)
-	if err == nil || !strings.Contains(err.Error(), "ensure library item") {
-		t.Fatalf("expected ensure item error, got: %v", err)
-	}
+	cfg.VCenterHost = s.URL.String()
+	cfg.VCenterUsername = simulator.DefaultLogin.Username()
+	cfg.VCenterPassword = password
+	cfg.Datacenter = "DC0"
+	cfg.Datastore = "LocalDS_0"
+	cfg.NetworkName = "VM Network"
+	cfg.ContentLibraryID = ""
+	cfg.ContentLibrary = "talos-images"
+	return cfg, find.NewFinder(c.Client, true)
 }
 
-func TestCreateTalosNodeFromOVA_DeployThenPowerOnFail(t *testing.T) {
-	script := `#!/usr/bin/env bash
-set -euo pipefail
-if [[ "${1:-}" == "library.info" ]]; then
-  echo '{"name":"already-there"}'
-  exit 0
-fi
-if [[ "${1:-}" == "import.spec" ]]; then
-  echo '{}'
-  exit 0
-fi
-if [[ "${1:-}" == "library.deploy" ]]; then
-  exit 0
-fi
-if [[ "${1:-}" == "vm.power" ]]; then
-  echo "power on failed in fake govc" >&2
-  exit 1
-fi
-echo "unexpected govc call: $*" >&2
-exit 1
-`
-	withFakeGovc(t, script)
+func TestCreateTalosNodeFromOVA_Sim(t *testing.T) {
+	downloads := serveTalosOVA(t)
+	cfg, f := newSimTalos(t)
+	cfg.CPUs = 4
+	cfg.MemoryMB = 8192
+	cfg.DiskSizeGB = 20
+	dataDisk := 10
+	cfg.DataDiskSizeGB = &dataDisk
+	cfg.DataDiskMountPath = "/var/mnt/data"
 
-	cfg := validTalosOVAConfig()
-	_, err := CreateTalosNodeFromOVA(context.Background(), cfg, nil)
-	if err == nil || !strings.Contains(err.Error(), "failed to power on Talos VM") {
-		t.Fatalf("expected power-on failure, got: %v", err)
-	}
+	ctx := context.Background()
+	node, err := CreateTalosNodeFromOVA(ctx, cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, "talos-vm-01", node.Name)
+
+	vmObj, err := f.VirtualMachine(ctx, "/DC0/vm/talos-vm-01")
+	require.NoError(t, err)
+
+	var mvm mo.VirtualMachine
+	require.NoError(t, vmObj.Properties(ctx, vmObj.Reference(), []string{"config", "runtime"}, &mvm))
+	require.Equal(t, types.VirtualMachinePowerStatePoweredOn, mvm.Runtime.PowerState)
+	require.Equal(t, int32(4), mvm.Config.Hardware.NumCPU)
+	require.Equal(t, int32(8192), mvm.Config.Hardware.MemoryMB)
+	require.Equal(t, "other5xLinux64Guest", mvm.Config.GuestId)
+
+	disks := object.VirtualDeviceList(mvm.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))
+	require.Len(t, disks, 2)
+	require.Equal(t, int64(20*1024*1024), disks[0].(*types.VirtualDisk).CapacityInKB)
+
+	// A second node reuses the library item instead of importing the OVA again.
+	cfg.Name = "talos-vm-02"
+	cfg.DataDiskSizeGB = nil
+	_, err = CreateTalosNodeFromOVA(ctx, cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), downloads.Load())
 }
 
-func TestCreateTalosNodeFromOVA_RecoverInvalidLibraryItem(t *testing.T) {
-	stateFile := filepath.Join(t.TempDir(), "govc-deploy-count")
-	t.Setenv("GOVC_FAKE_STATE", stateFile)
-	script := `#!/usr/bin/env bash
-set -euo pipefail
-state="${GOVC_FAKE_STATE}"
-if [[ "${1:-}" == "library.info" ]]; then
-  echo '{"name":"already-there"}'
-  exit 0
-fi
-if [[ "${1:-}" == "import.spec" ]]; then
-  echo '{}'
-  exit 0
-fi
-if [[ "${1:-}" == "library.deploy" ]]; then
-  n=0
-  if [[ -f "$state" ]]; then n=$(cat "$state"); fi
-  n=$((n+1))
-  echo "$n" > "$state"
-  if [[ "$n" -eq 1 ]]; then
-    echo "not an OVF" >&2
-    exit 1
-  fi
-  exit 0
-fi
-if [[ "${1:-}" == "library.rm" ]]; then
-  exit 0
-fi
-if [[ "${1:-}" == "library.import" ]]; then
-  exit 0
-fi
-if [[ "${1:-}" == "vm.power" ]]; then
-  echo "power on failed in fake govc" >&2
-  exit 1
-fi
-echo "unexpected govc call: $*" >&2
-exit 1
-`
-	withFakeGovc(t, script)
+// failLibraryFilter puts a proxy in front of cfg's vCenter that answers the
+// first OVF filter request of a library item with status and body.
+func failLibraryFilter(t *testing.T, cfg *VMConfig, status int, body string) {
+	t.Helper()
 
-	cfg := validTalosOVAConfig()
-	_, err := CreateTalosNodeFromOVA(context.Background(), cfg, nil)
-	if err == nil || !strings.Contains(err.Error(), "failed to power on Talos VM") {
-		t.Fatalf("expected power-on failure after recovery path, got: %v", err)
-	}
+	target, err := url.Parse(cfg.VCenterHost)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: target.Scheme, Host: target.Host})
+	proxy.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	var failed atomic.Bool
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.RawQuery, "action=filter") && failed.CompareAndSwap(false, true) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	cfg.VCenterHost = srv.URL + target.Path
 }
 
-func TestCreateTalosNodeFromOVA_PostDeployVCenterConnectFails(t *testing.T) {
-	script := `#!/usr/bin/env bash
-set -euo pipefail
-if [[ "${1:-}" == "library.info" ]]; then
-  echo '{"name":"already-there"}'
-  exit 0
-fi
-if [[ "${1:-}" == "import.spec" ]]; then
-  echo '{}'
-  exit 0
-fi
-if [[ "${1:-}" == "library.deploy" ]]; then
-  exit 0
-fi
-if [[ "${1:-}" == "vm.power" ]]; then
-  exit 0
-fi
-echo "unexpected govc call: $*" >&2
-exit 1
-`
-	withFakeGovc(t, script)
+func TestCreateTalosNodeFromOVA_SimFilterErrorKeepsItem(t *testing.T) {
+	downloads := serveTalosOVA(t)
+	cfg, _ := newSimTalos(t)
+	failLibraryFilter(t, cfg, http.StatusForbidden, "")
 
-	cfg := validTalosOVAConfig()
-	cfg.VCenterHost = "https://127.0.0.1:1/sdk"
+	ctx := context.Background()
+	_, err := CreateTalosNodeFromOVA(ctx, cfg, nil)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, vcenter.ErrInvalidLibraryItem)
+
+	// The shared item survives and the next node deploys from it.
+	_, err = CreateTalosNodeFromOVA(ctx, cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), downloads.Load())
+}
+
+func TestCreateTalosNodeFromOVA_SimUnrelatedErrorKeepsItem(t *testing.T) {
+	downloads := serveTalosOVA(t)
+	cfg, _ := newSimTalos(t)
+	// Mentions the wording of an invalid item but carries another message ID.
+	failLibraryFilter(t, cfg, http.StatusBadRequest,
+		`{"type":"com.vmware.vapi.std.errors.invalid_argument","value":{"messages":[{"id":"com.vmware.vcenter.ovf.target_invalid","default_message":"target is not an OVF invalid_library_item host"}]}}`)
+
+	ctx := context.Background()
+	_, err := CreateTalosNodeFromOVA(ctx, cfg, nil)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, vcenter.ErrInvalidLibraryItem)
+
+	_, err = CreateTalosNodeFromOVA(ctx, cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), downloads.Load())
+}
+
+func TestCreateTalosNodeFromOVA_SimInvalidItemReimported(t *testing.T) {
+	downloads := serveTalosOVA(t)
+	cfg, _ := newSimTalos(t)
+	failLibraryFilter(t, cfg, http.StatusBadRequest,
+		`{"type":"com.vmware.vapi.std.errors.invalid_argument","value":{"messages":[{"id":"com.vmware.ovfs.ovfs-main.ovfs.invalid_library_item"}]}}`)
 
 	_, err := CreateTalosNodeFromOVA(context.Background(), cfg, nil)
-	if err == nil || !strings.Contains(err.Error(), "vCenter connection failed after OVA deploy") {
-		t.Fatalf("expected vCenter connection error, got: %v", err)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), downloads.Load())
+}
+
+func TestTalosHardwareConfig(t *testing.T) {
+	cfg := validTalosOVAConfig()
+	cfg.CoresPerSocket = 2
+	cfg.CPUHotAdd = true
+	cfg.MemoryReservationMB = 4096
+	cfg.CPUShares = "High"
+	cfg.LatencySensitivity = "high"
+	cfg.ExtraConfig = map[string]string{"guestinfo.talos.config": "abc"}
+
+	guestID, hwVersion, extra := cfg.effectiveHardware(talosprofile.New().HardwareDefaults())
+	if guestID != "other5xLinux64Guest" {
+		t.Fatalf("guestID = %q, want other5xLinux64Guest", guestID)
+	}
+	if hwVersion != "" {
+		t.Fatalf("hwVersion = %q, want empty", hwVersion)
+	}
+
+	got := talosHardwareConfig(cfg, guestID, hwVersion, extra)
+	if got.Name != "talos-vm-01" || got.CPUs != 2 || got.MemoryMB != 4096 {
+		t.Fatalf("unexpected sizing: %+v", got)
+	}
+	if got.GuestOS != guestID || got.ExtraConfig["disk.EnableUUID"] != "TRUE" || got.ExtraConfig["guestinfo.talos.config"] != "abc" {
+		t.Fatalf("unexpected guest/extraConfig: %q %v", got.GuestOS, got.ExtraConfig)
+	}
+	if got.CoresPerSocket != 2 || !got.CPUHotAdd || got.MemoryReservationMB != 4096 ||
+		got.CPUShares != "High" || got.LatencySensitivity != "high" {
+		t.Fatalf("unexpected tuning: %+v", got)
 	}
 }
@@ -18,6 +18,11 @@ type TalosNodeUpdateConfig struct {
 	Preserve       bool
 	Insecure       bool
 	AdditionalArgs []string
+
+	// VM and Snapshot enable an automatic pre-change snapshot around the upgrade.
+	// Both must be set; the snapshot label defaults to "talos-upgrade-<version>".
+	VM       *VM
+	Snapshot *PreChangeSnapshot
 }
 
 // UpgradeTalosNode upgrades a Talos node using talosctl.
@@ -68,6 +73,20 @@ func UpgradeTalosNode(ctx context.Context, cfg *TalosNodeUpdateConfig) error {
 		args = append(args, cfg.AdditionalArgs...)
 	}
 
+	upgrade := func(ctx context.Context) error {
+		return runTalosctlUpgrade(ctx, talosctlPath, args)
+	}
+	if cfg.VM != nil && cfg.Snapshot != nil {
+		snap := *cfg.Snapshot
+		if snap.Label == "" {
+			snap.Label = "talos-upgrade-" + version
+		}
+		return cfg.VM.WithPreChangeSnapshot(ctx, snap, nil, upgrade)
+	}
+	return upgrade(ctx)
+}
+
+func runTalosctlUpgrade(ctx context.Context, talosctlPath string, args []string) error {
 	cmd := exec.CommandContext(ctx, talosctlPath, args...)
 	var out bytes.Buffer
 	var stderr bytes.Buffer
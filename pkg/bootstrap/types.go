@@ -3,11 +3,19 @@ package bootstrap
 
 import (
 	"fmt"
+	"maps"
 	"regexp"
 	"strings"
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/infrakit-io/vmware-vm-bootstrap/internal/utils"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/cloudinit"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/seed"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vm"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
@@ -20,6 +28,18 @@ type VMConfig struct {
 	VCenterPort     int    // vCenter port (default: 443)
 	VCenterInsecure bool   // Skip TLS verification (not recommended for production)
 
+	// VCenterPasswordProvider resolves the vCenter password at connect time when
+	// VCenterPassword is empty (env, exec helper, Vault, keyring - see pkg/credentials).
+	VCenterPasswordProvider credentials.Provider
+
+	VCenterThumbprint string // Pinned SHA-1/SHA-256 certificate thumbprint (optional)
+	VCenterCACertFile string // PEM CA bundle used instead of system roots (optional)
+	VCenterCACertPEM  string // Inline PEM CA bundle (optional)
+
+	// VCenterSessionCacheDir stores encrypted sessions for reuse across runs
+	// (empty = vcenter.session_cache_dir, empty there = always log in).
+	VCenterSessionCacheDir string
+
 	// === VM Specifications ===
 	Name              string // VM name (e.g., "web-server-01")
 	CPUs              int    // Number of CPUs (e.g., 4)
@@ -28,6 +48,18 @@ type VMConfig struct {
 	DataDiskSizeGB    *int   // Optional data disk size in GB (e.g., 500) - nil = not created
 	DataDiskMountPath string // Mount point for data disk (e.g., "/data") - required if DataDiskSizeGB set
 
+	// === CPU & Memory Tuning (optional, zero = vCenter default) ===
+	CoresPerSocket      int    // Cores per virtual socket (must divide CPUs)
+	CPUHotAdd           bool   // Allow adding vCPUs while powered on
+	MemoryHotAdd        bool   // Allow adding memory while powered on
+	CPUReservationMHz   int    // Guaranteed CPU in MHz
+	CPULimitMHz         int    // CPU limit in MHz (0 = unlimited)
+	CPUShares           string // "low", "normal", "high" or custom number (e.g., "2000")
+	MemoryReservationMB int    // Guaranteed memory in MB (e.g., etcd/database nodes)
+	MemoryLimitMB       int    // Memory limit in MB (0 = unlimited)
+	MemoryShares        string // "low", "normal", "high" or custom number
+	LatencySensitivity  string // "low", "normal", "medium" or "high" (high reserves all memory)
+
 	// === Network Configuration ===
 	NetworkName      string   // Network name (e.g., "LAN_Management")
 	NetworkInterface string   // Guest NIC name (e.g., "ens192")
@@ -43,6 +75,12 @@ type VMConfig struct {
 	ResourcePool string // Resource pool path (e.g., "WebTier")
 	Datastore    string // VM datastore name (e.g., "VMwareSSD01")
 	ISODatastore string // Datastore for ISO uploads (e.g., "VMwareStorage01"); falls back to Datastore if empty
+
+	// Optional seed server: Ubuntu installs fetch their cloud-init seed from it
+	// over HTTP (nocloud-net) instead of a per-VM NoCloud ISO. The VM network
+	// needs DHCP during installation to reach it.
+	SeedServer *seed.Server
+
 	// vCenter Content Library used for Talos OVA cache/deploy.
 	// Name is used when ID is empty; ID is preferred when both are set.
 	ContentLibrary   string
@@ -57,18 +95,48 @@ type VMConfig struct {
 	SSHPublicKeys []string // SSH public keys (one or more)
 	Password      string   // Optional plain text password (auto-hashed with bcrypt before use)
 	PasswordHash  string   // Optional pre-computed password hash (bcrypt); overrides Password if both set
+	// PasswordProvider resolves the guest password right before provisioning when Password is empty.
+	PasswordProvider credentials.Provider
 	// Allow SSH password authentication (default: false). Requires Password or PasswordHash.
 	AllowPasswordSSH bool
 	// Skip SSH verification during bootstrap (default: false).
 	SkipSSHVerify bool
 	// Keep VM/ISO on bootstrap failure for debugging (default: false).
 	SkipCleanupOnError bool
+	// Timeouts and retries for this call; zero fields fall back to configs/defaults.yaml.
+	Options Options
 
 	// === Advanced Options ===
 	Timezone   string // System timezone (default: "UTC")
 	Locale     string // System locale (default: "en_US.UTF-8")
 	SwapSizeGB *int   // Swap size in GB (default from configs/defaults.yaml)
-	Firmware   string // Firmware type: "bios" or "efi" (default: "bios")
+	Firmware   string // Firmware type: "bios" or "efi" (default: "bios"; "efi" when SecureBoot or VTPM is set)
+	// vSphere guest OS identifier (default: per-profile, e.g. "ubuntu64Guest", "other5xLinux64Guest").
+	GuestID string
+	// Virtual hardware version (e.g., "vmx-19"); empty = profile default, then vCenter default.
+	HardwareVersion string
+	// VMX advanced settings merged over profile defaults (e.g., "disk.EnableUUID": "TRUE", "guestinfo.foo": "bar").
+	// An empty value removes a profile default key.
+	ExtraConfig map[string]string
+	// Enable UEFI Secure Boot (requires efi firmware).
+	SecureBoot bool
+	// Add a virtual TPM 2.0 device (requires efi firmware and a vCenter key provider).
+	VTPM bool
+	// Optional storage policy ID used to encrypt the VM home and disks (e.g., "VM Encryption Policy" ID).
+	EncryptionPolicy string
+
+	// === Installer Boot (Ubuntu) ===
+	// Extra installer kernel parameters (e.g., "console=ttyS0,115200", "ip=dhcp", "debug").
+	// Each distinct set of installer options is remastered and cached as its own ISO.
+	InstallerKernelArgs []string
+	// Default installer boot menu entry, as index or title (default: "0").
+	InstallerMenuDefault string
+	// Installer boot menu timeout in seconds (default: iso.grub_timeout_seconds).
+	InstallerMenuTimeout *int
+
+	// Cloud-init additions merged into the generated autoinstall user-data (Ubuntu):
+	// extra packages, write_files, runcmd, bootcmd, late-commands and a raw fragment.
+	CloudInit cloudinit.Extensions
 }
 
 // VMProfiles contains profile-specific settings.
@@ -97,12 +165,56 @@ type VM struct {
 	SSHReady      bool                         // SSH port 22 accessible
 	Hostname      string                       // Configured hostname
 	// vCenter connection data for post-create operations (Verify/PowerOn/PowerOff/Delete).
-	// These fields are intentionally not serialized.
+	// These fields are intentionally not serialized. VCenterPass is empty when the
+	// password comes from VCenterPasswordProvider, which is resolved again on each connect.
 	VCenterHost     string `json:"-"`
 	VCenterPort     int    `json:"-"`
 	VCenterUser     string `json:"-"`
 	VCenterPass     string `json:"-"`
 	VCenterInsecure bool   `json:"-"`
+
+	VCenterPasswordProvider credentials.Provider `json:"-"`
+
+	VCenterThumbprint string `json:"-"`
+	VCenterCACertFile string `json:"-"`
+	VCenterCACertPEM  string `json:"-"`
+
+	VCenterSessionCacheDir string `json:"-"`
+
+	// Options used by Verify (SSH retries), captured at bootstrap time.
+	Options Options `json:"-"`
+}
+
+// VCenterConfig returns the vCenter connection settings of cfg.
+func (cfg *VMConfig) VCenterConfig() *vcenter.Config {
+	return &vcenter.Config{
+		Host:             cfg.VCenterHost,
+		Username:         cfg.VCenterUsername,
+		Password:         cfg.VCenterPassword,
+		PasswordProvider: cfg.VCenterPasswordProvider,
+		Port:             cfg.VCenterPort,
+		Insecure:         cfg.VCenterInsecure,
+		Thumbprint:       cfg.VCenterThumbprint,
+		CACertFile:       cfg.VCenterCACertFile,
+		CACertPEM:        cfg.VCenterCACertPEM,
+		SessionCacheDir:  cfg.VCenterSessionCacheDir,
+	}
+}
+
+// vcenterConfig returns the vCenter connection settings captured at bootstrap time.
+func (vm *VM) vcenterConfig() *vcenter.Config {
+	return &vcenter.Config{
+		Host:             vm.VCenterHost,
+		Username:         vm.VCenterUser,
+		Password:         vm.VCenterPass,
+		PasswordProvider: vm.VCenterPasswordProvider,
+		Port:             vm.VCenterPort,
+		Insecure:         vm.VCenterInsecure,
+		Thumbprint:       vm.VCenterThumbprint,
+		CACertFile:       vm.VCenterCACertFile,
+		CACertPEM:        vm.VCenterCACertPEM,
+		SessionCacheDir:  vm.VCenterSessionCacheDir,
+	}
 }
 
 // Validate checks if the VM configuration is valid.
@@ -113,8 +225,8 @@ func (cfg *VMConfig) Validate() error {
 	if cfg.VCenterUsername == "" {
 		return fmt.Errorf("VCenterUsername is required")
 	}
-	if cfg.VCenterPassword == "" {
-		return fmt.Errorf("VCenterPassword is required")
+	if cfg.VCenterPassword == "" && cfg.VCenterPasswordProvider == nil {
+		return fmt.Errorf("VCenterPassword or VCenterPasswordProvider is required")
 	}
 	if cfg.Name == "" {
 		return fmt.Errorf("name is required")
@@ -127,10 +239,11 @@ func (cfg *VMConfig) Validate() error {
 		if cfg.Username == "" {
 			return fmt.Errorf("username is required")
 		}
-		if len(cfg.SSHPublicKeys) == 0 && cfg.Password == "" && cfg.PasswordHash == "" {
+		hasPassword := cfg.Password != "" || cfg.PasswordProvider != nil || cfg.PasswordHash != ""
+		if len(cfg.SSHPublicKeys) == 0 && !hasPassword {
 			return fmt.Errorf("at least one of SSHPublicKeys, Password, or PasswordHash is required")
 		}
-		if cfg.AllowPasswordSSH && cfg.Password == "" && cfg.PasswordHash == "" {
+		if cfg.AllowPasswordSSH && !hasPassword {
 			return fmt.Errorf("AllowPasswordSSH requires Password or PasswordHash")
 		}
 	}
@@ -174,13 +287,144 @@ func (cfg *VMConfig) Validate() error {
 	if err := utils.ValidateNetworkConfig(cfg.IPAddress, cfg.Netmask, cfg.Gateway, cfg.DNS); err != nil {
 		return err
 	}
+	if err := cfg.validateResourceOptions(); err != nil {
+		return err
+	}
+	if cfg.HardwareVersion != "" && !hardwareVersionRe.MatchString(cfg.HardwareVersion) {
+		return fmt.Errorf("invalid HardwareVersion %q (expected vmx-<number>, e.g. vmx-19)", cfg.HardwareVersion)
+	}
+	for k := range cfg.ExtraConfig {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("ExtraConfig keys must not be empty")
+		}
+	}
+	if cfg.Firmware == "bios" && (cfg.SecureBoot || cfg.VTPM) {
+		return fmt.Errorf("SecureBoot and VTPM require efi firmware (got %q)", cfg.Firmware)
+	}
 	if mac := strings.TrimSpace(cfg.MACAddress); mac != "" && !macAddressRe.MatchString(strings.ToLower(mac)) {
 		return fmt.Errorf("invalid MACAddress format: %q (expected aa:bb:cc:dd:ee:ff)", cfg.MACAddress)
 	}
+	if err := cfg.installerOptions().Validate(); err != nil {
+		return err
+	}
+	if !cfg.CloudInit.IsZero() && profile != "ubuntu" {
+		return fmt.Errorf("CloudInit is only supported by the ubuntu profile")
+	}
+	if err := cfg.CloudInit.Validate(); err != nil {
+		return fmt.Errorf("CloudInit: %w", err)
+	}
 	return nil
 }
 
-var macAddressRe = regexp.MustCompile(`^[0-9a-f]{2}(:[0-9a-f]{2}){5}$`)
+// installerOptions returns the Ubuntu installer remaster options of cfg.
+func (cfg *VMConfig) installerOptions() iso.RemasterOptions {
+	return iso.RemasterOptions{
+		KernelArgs:  cfg.InstallerKernelArgs,
+		MenuDefault: cfg.InstallerMenuDefault,
+		MenuTimeout: cfg.InstallerMenuTimeout,
+	}
+}
+
+// profileInput returns the provisioning input of cfg for OS profiles.
+func (cfg *VMConfig) profileInput(guestPassword string) profile.Input {
+	opts := cfg.Options.withDefaults()
+	return profile.Input{
+		VMName:            cfg.Name,
+		Username:          cfg.Username,
+		Password:          guestPassword,
+		PasswordHash:      cfg.PasswordHash,
+		SSHPublicKeys:     cfg.SSHPublicKeys,
+		AllowPasswordSSH:  cfg.AllowPasswordSSH,
+		Timezone:          cfg.Timezone,
+		Locale:            cfg.Locale,
+		NetworkInterface:  cfg.NetworkInterface,
+		IPAddress:         cfg.IPAddress,
+		Netmask:           cfg.Netmask,
+		Gateway:           cfg.Gateway,
+		DNS:               cfg.DNS,
+		DataDiskMountPath: cfg.DataDiskMountPath,
+		SwapSizeGB:        cfg.SwapSizeGB,
+		Firmware:          cfg.Firmware,
+		OSVersion:         cfg.EffectiveOSVersion(),
+		OSSchematicID:     cfg.EffectiveOSSchematicID(),
+		DownloadTimeout:   opts.DownloadTimeout,
+		CacheDir:          opts.CacheDir,
+		Offline:           opts.Offline,
+		Installer:         cfg.installerOptions(),
+		CloudInit:         cfg.CloudInit,
+		VCenterHost:       cfg.VCenterHost,
+		VCenterUsername:   cfg.VCenterUsername,
+		VCenterPassword:   cfg.VCenterPassword,
+		VCenterInsecure:   cfg.VCenterInsecure,
+	}
+}
+
+// hasResourceOptions reports whether any CPU/memory tuning option is set.
+func (cfg *VMConfig) hasResourceOptions() bool {
+	return cfg.CoresPerSocket > 0 || cfg.CPUHotAdd || cfg.MemoryHotAdd ||
+		cfg.CPUReservationMHz > 0 || cfg.CPULimitMHz > 0 || cfg.CPUShares != "" ||
+		cfg.MemoryReservationMB > 0 || cfg.MemoryLimitMB > 0 || cfg.MemoryShares != "" ||
+		cfg.LatencySensitivity != ""
+}
+
+// validateResourceOptions checks CPU/memory tuning options for internal consistency.
+// Host limits are checked against vCenter at bootstrap time (vcenter.HostCapabilities).
+func (cfg *VMConfig) validateResourceOptions() error {
+	if cfg.CoresPerSocket < 0 || cfg.CPUReservationMHz < 0 || cfg.CPULimitMHz < 0 ||
+		cfg.MemoryReservationMB < 0 || cfg.MemoryLimitMB < 0 {
+		return fmt.Errorf("CPU/memory reservations, limits and CoresPerSocket must not be negative")
+	}
+	if cfg.CoresPerSocket > 0 && cfg.CPUs > 0 && cfg.CPUs%cfg.CoresPerSocket != 0 {
+		return fmt.Errorf("CPUs (%d) must be a multiple of CoresPerSocket (%d)", cfg.CPUs, cfg.CoresPerSocket)
+	}
+	if cfg.CPULimitMHz > 0 && cfg.CPUReservationMHz > cfg.CPULimitMHz {
+		return fmt.Errorf("CPUReservationMHz (%d) exceeds CPULimitMHz (%d)", cfg.CPUReservationMHz, cfg.CPULimitMHz)
+	}
+	if cfg.MemoryMB > 0 && cfg.MemoryReservationMB > cfg.MemoryMB {
+		return fmt.Errorf("MemoryReservationMB (%d) exceeds MemoryMB (%d)", cfg.MemoryReservationMB, cfg.MemoryMB)
+	}
+	if cfg.MemoryLimitMB > 0 && cfg.MemoryReservationMB > cfg.MemoryLimitMB {
+		return fmt.Errorf("MemoryReservationMB (%d) exceeds MemoryLimitMB (%d)", cfg.MemoryReservationMB, cfg.MemoryLimitMB)
+	}
+	if _, err := vm.ParseShares(cfg.CPUShares); err != nil {
+		return fmt.Errorf("CPUShares: %w", err)
+	}
+	if _, err := vm.ParseShares(cfg.MemoryShares); err != nil {
+		return fmt.Errorf("MemoryShares: %w", err)
+	}
+	return vm.ValidateLatencySensitivity(cfg.LatencySensitivity)
+}
+
+var (
+	macAddressRe      = regexp.MustCompile(`^[0-9a-f]{2}(:[0-9a-f]{2}){5}$`)
+	hardwareVersionRe = regexp.MustCompile(`^vmx-[0-9]+$`)
+)
+
+// effectiveHardware merges profile hardware defaults with per-VM overrides.
+// Per-VM GuestID/HardwareVersion win; ExtraConfig keys are merged, and an empty
+// per-VM value drops the profile default for that key.
+func (cfg *VMConfig) effectiveHardware(defaults profile.HardwareDefaults) (guestID, hwVersion string, extra map[string]string) {
+	guestID = cfg.GuestID
+	if guestID == "" {
+		guestID = defaults.GuestID
+	}
+	hwVersion = cfg.HardwareVersion
+	if hwVersion == "" {
+		hwVersion = defaults.HardwareVersion
+	}
+	extra = maps.Clone(defaults.ExtraConfig)
+	if extra == nil {
+		extra = map[string]string{}
+	}
+	for k, v := range cfg.ExtraConfig {
+		if v == "" {
+			delete(extra, k)
+			continue
+		}
+		extra[k] = v
+	}
+	return guestID, hwVersion, extra
+}
 
 // SetDefaults sets default values for optional fields from configs/defaults.yaml.
 func (cfg *VMConfig) SetDefaults() {
@@ -197,6 +441,9 @@ func (cfg *VMConfig) SetDefaults() {
 	if cfg.Locale == "" {
 		cfg.Locale = d.CloudInit.Locale
 	}
+	if cfg.Firmware == "" && (cfg.SecureBoot || cfg.VTPM) {
+		cfg.Firmware = "efi"
+	}
 	if cfg.Firmware == "" {
 		cfg.Firmware = d.VM.Firmware
 	}
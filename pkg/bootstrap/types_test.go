@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
 )
 
 func TestSetDefaults_appliesDefaults(t *testing.T) {
@@ -152,6 +154,48 @@ func TestValidate_MissingAuthInputs(t *testing.T) {
 	}
 }
 
+func TestValidate_CredentialProviders(t *testing.T) {
+	cfg := &VMConfig{
+		VCenterHost:             "vcenter",
+		VCenterUsername:         "admin",
+		VCenterPasswordProvider: credentials.Env{Name: "VC_PASSWORD"},
+		Name:                    "vm1",
+		Username:                "sysadmin",
+		PasswordProvider:        credentials.Env{Name: "GUEST_PASSWORD"},
+		AllowPasswordSSH:        true,
+		IPAddress:               "192.168.1.10",
+		Netmask:                 "255.255.255.0",
+		Gateway:                 "192.168.1.1",
+		DNS:                     []string{"8.8.8.8"},
+		DiskSizeGB:              20,
+		Profile:                 "ubuntu",
+		Profiles: VMProfiles{
+			Ubuntu: UbuntuProfile{Version: "24.04"},
+		},
+		Datacenter:  "DC1",
+		Datastore:   "DS1",
+		NetworkName: "LAN",
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() unexpected error: %v", err)
+	}
+	if got := cfg.VCenterConfig().PasswordProvider; got != cfg.VCenterPasswordProvider {
+		t.Errorf("VCenterConfig().PasswordProvider = %v, want %v", got, cfg.VCenterPasswordProvider)
+	}
+}
+
+func TestVCenterConfig_SessionCacheDir(t *testing.T) {
+	cfg := &VMConfig{VCenterHost: "vcenter", VCenterSessionCacheDir: "/tmp/sessions"}
+	if got := cfg.VCenterConfig().SessionCacheDir; got != "/tmp/sessions" {
+		t.Errorf("VCenterConfig().SessionCacheDir = %q, want /tmp/sessions", got)
+	}
+	vm := &VM{VCenterHost: "vcenter", VCenterSessionCacheDir: "/tmp/sessions"}
+	if got := vm.vcenterConfig().SessionCacheDir; got != "/tmp/sessions" {
+		t.Errorf("vcenterConfig().SessionCacheDir = %q, want /tmp/sessions", got)
+	}
+}
+
 func TestValidate_NetworkErrors(t *testing.T) {
 	cfg := &VMConfig{
 		VCenterHost:     "vcenter",
@@ -279,3 +323,118 @@ func TestEffectiveVersionHelpers(t *testing.T) {
 		t.Fatalf("EffectiveTalosVersion() = %q, want %q", got, "v1.12.4")
 	}
 }
+
+func TestSetDefaults_SecureBootImpliesEFI(t *testing.T) {
+	cfg := &VMConfig{SecureBoot: true}
+	cfg.SetDefaults()
+	if cfg.Firmware != "efi" {
+		t.Errorf("Firmware = %q, want efi when SecureBoot is set", cfg.Firmware)
+	}
+
+	cfg = &VMConfig{VTPM: true}
+	cfg.SetDefaults()
+	if cfg.Firmware != "efi" {
+		t.Errorf("Firmware = %q, want efi when VTPM is set", cfg.Firmware)
+	}
+}
+
+func TestValidate_SecureBootRequiresEFI(t *testing.T) {
+	cfg := minimalValidConfigForTests()
+	cfg.Firmware = "bios"
+	cfg.SecureBoot = true
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for SecureBoot with bios firmware")
+	}
+
+	cfg.Firmware = "efi"
+	cfg.VTPM = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() unexpected error: %v", err)
+	}
+}
+
+func TestValidate_ResourceOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*VMConfig)
+		wantErr bool
+	}{
+		{"valid tuning", func(c *VMConfig) {
+			c.CPUs = 4
+			c.MemoryMB = 8192
+			c.CoresPerSocket = 2
+			c.CPUShares = "high"
+			c.MemoryReservationMB = 8192
+			c.LatencySensitivity = "high"
+		}, false},
+		{"cores per socket does not divide CPUs", func(c *VMConfig) { c.CPUs = 3; c.CoresPerSocket = 2 }, true},
+		{"memory reservation above memory", func(c *VMConfig) { c.MemoryMB = 2048; c.MemoryReservationMB = 4096 }, true},
+		{"memory reservation above limit", func(c *VMConfig) { c.MemoryLimitMB = 1024; c.MemoryReservationMB = 2048 }, true},
+		{"cpu reservation above limit", func(c *VMConfig) { c.CPULimitMHz = 1000; c.CPUReservationMHz = 2000 }, true},
+		{"negative reservation", func(c *VMConfig) { c.CPUReservationMHz = -1 }, true},
+		{"invalid shares", func(c *VMConfig) { c.MemoryShares = "plenty" }, true},
+		{"invalid latency", func(c *VMConfig) { c.LatencySensitivity = "extreme" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := minimalValidConfigForTests()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEffectiveHardware(t *testing.T) {
+	defaults := profile.HardwareDefaults{
+		GuestID:     "other5xLinux64Guest",
+		ExtraConfig: map[string]string{"disk.EnableUUID": "TRUE", "keep": "1"},
+	}
+
+	cfg := &VMConfig{}
+	guestID, hwVersion, extra := cfg.effectiveHardware(defaults)
+	if guestID != "other5xLinux64Guest" || hwVersion != "" || extra["disk.EnableUUID"] != "TRUE" {
+		t.Fatalf("profile defaults not applied: %q %q %v", guestID, hwVersion, extra)
+	}
+
+	cfg = &VMConfig{
+		GuestID:         "debian12_64Guest",
+		HardwareVersion: "vmx-19",
+		ExtraConfig:     map[string]string{"keep": "", "guestinfo.role": "db"},
+	}
+	guestID, hwVersion, extra = cfg.effectiveHardware(defaults)
+	if guestID != "debian12_64Guest" || hwVersion != "vmx-19" {
+		t.Errorf("overrides not applied: %q %q", guestID, hwVersion)
+	}
+	if _, ok := extra["keep"]; ok {
+		t.Error("empty override should remove profile default key")
+	}
+	if extra["guestinfo.role"] != "db" || extra["disk.EnableUUID"] != "TRUE" {
+		t.Errorf("ExtraConfig merge = %v", extra)
+	}
+	if _, ok := defaults.ExtraConfig["guestinfo.role"]; ok {
+		t.Error("effectiveHardware must not mutate profile defaults")
+	}
+}
+
+func TestValidate_HardwareOptions(t *testing.T) {
+	cfg := minimalValidConfigForTests()
+	cfg.HardwareVersion = "19"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for HardwareVersion without vmx- prefix")
+	}
+	cfg.HardwareVersion = "vmx-19"
+	cfg.ExtraConfig = map[string]string{" ": "x"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for empty ExtraConfig key")
+	}
+	cfg.ExtraConfig = map[string]string{"disk.EnableUUID": "TRUE"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() unexpected error: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/vmware/govmomi"
@@ -92,7 +93,7 @@ func TestVM_Verify(t *testing.T) {
 
 	vm := env.newVM(t)
 	old := sshVerifier
-	sshVerifier = func(_ context.Context, _ string) error { return nil }
+	sshVerifier = func(_ context.Context, _ string, _ Options) error { return nil }
 	t.Cleanup(func() { sshVerifier = old })
 
 	simObj := env.model.Service.Context.Map.Get(vm.ManagedObject).(*simulator.VirtualMachine)
@@ -113,6 +114,25 @@ func TestVM_PowerOnOffDelete(t *testing.T) {
 	require.NoError(t, vm.Delete(context.Background()))
 }
 
+func TestVM_ShutdownAndRebootGuest(t *testing.T) {
+	env, cleanup := newSimBootstrapEnv(t)
+	defer cleanup()
+
+	vm := env.newVM(t)
+	ctx := context.Background()
+	simObj := env.model.Service.Context.Map.Get(vm.ManagedObject).(*simulator.VirtualMachine)
+
+	require.NoError(t, vm.PowerOn(ctx))
+	simObj.Guest.ToolsRunningStatus = string(types.VirtualMachineToolsRunningStatusGuestToolsNotRunning)
+	require.NoError(t, vm.RebootGuest(ctx, time.Second))
+
+	simObj.Guest.ToolsRunningStatus = string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+	require.NoError(t, vm.ShutdownGuest(ctx, 10*time.Second))
+	require.Equal(t, types.VirtualMachinePowerStatePoweredOff, simObj.Runtime.PowerState)
+
+	require.Error(t, vm.RebootGuest(ctx, time.Second))
+}
+
 func TestVM_Verify_FailsWhenIPMissing(t *testing.T) {
 	env, cleanup := newSimBootstrapEnv(t)
 	defer cleanup()
@@ -137,7 +157,7 @@ func TestVM_Verify_FailsWhenSSHFails(t *testing.T) {
 	simObj.Guest.ToolsRunningStatus = string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
 
 	old := sshVerifier
-	sshVerifier = func(_ context.Context, _ string) error { return errors.New("ssh down") }
+	sshVerifier = func(_ context.Context, _ string, _ Options) error { return errors.New("ssh down") }
 	t.Cleanup(func() { sshVerifier = old })
 
 	err := vm.Verify(context.Background())
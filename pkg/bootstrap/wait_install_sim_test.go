@@ -12,8 +12,6 @@ import (
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/simulator"
 	"github.com/vmware/govmomi/vim25/types"
-
-	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 )
 
 func newSimVM(t *testing.T) (*simulator.Model, *govmomi.Client, *find.Finder, func()) {
@@ -48,15 +46,14 @@ func newSimVM(t *testing.T) (*simulator.Model, *govmomi.Client, *find.Finder, fu
 	return model, c, f, cleanup
 }
 
-func overrideTimeouts(t *testing.T, fn func()) {
-	t.Helper()
-	old := configs.Defaults.Timeouts
-	configs.Defaults.Timeouts.InstallationMinutes = 1
-	configs.Defaults.Timeouts.PollingSeconds = 1
-	configs.Defaults.Timeouts.HostnameChecks = 1
-	configs.Defaults.Timeouts.ServiceStartupSeconds = 0
-	fn()
-	configs.Defaults.Timeouts = old
+// fastInstallOptions keeps the installation wait short without touching configs.Defaults.
+func fastInstallOptions() Options {
+	return Options{
+		InstallTimeout: time.Minute,
+		PollInterval:   time.Second,
+		HostnameChecks: 1,
+		ServiceStartup: time.Millisecond,
+	}
 }
 
 func TestWaitForInstallation_RebootPath(t *testing.T) {
@@ -80,6 +77,7 @@ func TestWaitForInstallation_RebootPath(t *testing.T) {
 	simVM.Guest = &types.GuestInfo{}
 
 	cfg := minimalConfig()
+	cfg.Options = fastInstallOptions()
 
 	go func() {
 		time.Sleep(500 * time.Millisecond)
@@ -94,11 +92,8 @@ func TestWaitForInstallation_RebootPath(t *testing.T) {
 		simVM.Guest.HostName = cfg.Name
 	}()
 
-	overrideTimeouts(t, func() {
-		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-		err := waitForInstallation(ctx, vmObj, cfg, logger)
-		require.NoError(t, err)
-	})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	require.NoError(t, waitForInstallation(ctx, vmObj, cfg, logger))
 }
 
 func TestWaitForInstallation_NoRebootPath(t *testing.T) {
@@ -124,11 +119,9 @@ func TestWaitForInstallation_NoRebootPath(t *testing.T) {
 	}
 
 	cfg := minimalConfig()
+	cfg.Options = fastInstallOptions()
 	simVM.Guest.HostName = cfg.Name
 
-	overrideTimeouts(t, func() {
-		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-		err := waitForInstallation(ctx, vmObj, cfg, logger)
-		require.NoError(t, err)
-	})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	require.NoError(t, waitForInstallation(ctx, vmObj, cfg, logger))
 }
@@ -0,0 +1,253 @@
+// Package bundle builds and imports offline artifact bundles for sites without
+// internet access: one tar file with the Ubuntu/Talos ISOs, Talos OVAs,
+// checksum files, signing keys, release catalogs, Talos schematics and
+// optional apt mirrors a set of VMs needs, described by a manifest.
+//
+// Import verifies every file against the manifest, keeps the files in
+// iso.OfflineDir and records the URLs they were downloaded from in the offline
+// index, so iso.Download and the Talos OVA import resolve them from disk.
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+)
+
+// ManifestName is the first entry of a bundle.
+const ManifestName = "manifest.json"
+
+// OverrideCatalogName is the bundled release catalog override (see
+// configs.CatalogOverride), below catalogs/.
+const OverrideCatalogName = "catalog-override.yaml"
+
+// FormatVersion is the manifest format written by this release.
+const FormatVersion = 1
+
+// Artifact kinds.
+const (
+	KindUbuntuISO  = "ubuntu-iso"
+	KindTalosISO   = "talos-iso"
+	KindTalosOVA   = "talos-ova"
+	KindChecksum   = "checksum" // checksum files, signatures and signing keys
+	KindCatalog    = "catalog"
+	KindSchematics = "talos-schematics"
+	KindAptMirror  = "apt-mirror"
+)
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	Format    int        `json:"format"`
+	Created   time.Time  `json:"created"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Artifact is one file of a bundle.
+type Artifact struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`          // slash-separated path in the bundle
+	URL    string `json:"url,omitempty"` // source URL the file stands in for
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+
+	Version     string `json:"version,omitempty"`      // Ubuntu/Talos release
+	SchematicID string `json:"schematic_id,omitempty"` // Talos Image Factory schematic
+	LibraryItem string `json:"library_item,omitempty"` // content library item name (OVAs)
+}
+
+// File is a local file to be written into a bundle as Artifact.
+type File struct {
+	Artifact
+	Local string
+}
+
+// Write writes a bundle of files to w: the manifest, then each file. Checksums
+// and sizes are computed from the local files.
+func Write(w io.Writer, files []File) (*Manifest, error) {
+	m := &Manifest{Format: FormatVersion, Created: time.Now().UTC()}
+	seen := map[string]bool{}
+	var unique []File
+	for _, f := range files {
+		if !validPath(f.Path) {
+			return nil, fmt.Errorf("invalid bundle path %q", f.Path)
+		}
+		if seen[f.Path] {
+			continue
+		}
+		seen[f.Path] = true
+		sum, size, err := hashFile(f.Local)
+		if err != nil {
+			return nil, err
+		}
+		f.SHA256, f.Size = sum, size
+		m.Artifacts = append(m.Artifacts, f.Artifact)
+		unique = append(unique, f)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(data)), ModTime: m.Created}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	for _, f := range unique {
+		if err := writeFile(tw, f, m.Created); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func writeFile(tw *tar.Writer, f File, modTime time.Time) error {
+	in, err := os.Open(f.Local)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	if err := tw.WriteHeader(&tar.Header{Name: f.Path, Mode: 0644, Size: f.Size, ModTime: modTime}); err != nil {
+		return err
+	}
+	// A file that changed since it was hashed fails here instead of on import.
+	if n, err := io.Copy(tw, in); err != nil || n != f.Size {
+		return fmt.Errorf("add %s to bundle: %d of %d bytes: %v", f.Local, n, f.Size, err)
+	}
+	return nil
+}
+
+// Import extracts the bundle read from r into dir ("" = iso.OfflineDir()),
+// verifies each file against the manifest and adds the files with a source
+// URL to the offline index. Files of earlier imports are kept.
+func Import(r io.Reader, dir string) (*Manifest, error) {
+	if dir == "" {
+		dir = iso.OfflineDir()
+	}
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	if hdr.Name != ManifestName {
+		return nil, fmt.Errorf("not a vmbootstrap bundle: first entry is %q, want %s", hdr.Name, ManifestName)
+	}
+	var m Manifest
+	if err := json.NewDecoder(io.LimitReader(tr, 64<<20)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("read bundle manifest: %w", err)
+	}
+	if m.Format != FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format %d (this release reads format %d)", m.Format, FormatVersion)
+	}
+	want := make(map[string]Artifact, len(m.Artifacts))
+	for _, a := range m.Artifacts {
+		if !validPath(a.Path) {
+			return nil, fmt.Errorf("invalid bundle path %q", a.Path)
+		}
+		want[a.Path] = a
+	}
+
+	done := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		a, ok := want[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("bundle entry %q is not in the manifest", hdr.Name)
+		}
+		if err := extract(tr, filepath.Join(dir, filepath.FromSlash(a.Path)), a); err != nil {
+			return nil, err
+		}
+		done[a.Path] = true
+	}
+	for _, a := range m.Artifacts {
+		if !done[a.Path] {
+			return nil, fmt.Errorf("bundle is incomplete: %s is missing", a.Path)
+		}
+	}
+
+	idx, err := iso.LoadOfflineIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range m.Artifacts {
+		if a.URL != "" {
+			idx.Add(iso.OfflineArtifact{URL: a.URL, Path: a.Path, SHA256: a.SHA256, Size: a.Size})
+		}
+	}
+	if err := idx.Save(); err != nil {
+		return nil, fmt.Errorf("write offline index: %w", err)
+	}
+	return &m, nil
+}
+
+// extract writes one entry to dest, moving it into place only when it
+// matches the manifest.
+func extract(r io.Reader, dest string, a Artifact) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	part := dest + ".part"
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(part)
+		return fmt.Errorf("extract %s: %w", a.Path, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); n != a.Size || got != a.SHA256 {
+		_ = os.Remove(part)
+		return fmt.Errorf("%s does not match the manifest (%d bytes, sha256 %s; want %d bytes, %s)", a.Path, n, got, a.Size, a.SHA256)
+	}
+	return os.Rename(part, dest)
+}
+
+// validPath reports whether p is a clean relative path in a directory of the
+// bundle (the top level holds the manifest and the offline index).
+func validPath(p string) bool {
+	return strings.Contains(p, "/") && path.Clean(p) == p && filepath.IsLocal(filepath.FromSlash(p))
+}
+
+func hashFile(name string) (string, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("hash %s: %w", name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
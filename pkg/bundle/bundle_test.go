@@ -0,0 +1,141 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+)
+
+func writeTemp(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return p
+}
+
+func TestWriteImport_RoundTrip(t *testing.T) {
+	src := t.TempDir()
+	const isoURL = "https://cdimage.invalid/24.04/ubuntu.iso"
+	files := []File{
+		{Artifact: Artifact{Kind: KindUbuntuISO, URL: isoURL, Path: bundlePath(isoURL), Version: "24.04"}, Local: writeTemp(t, src, "ubuntu.iso", "iso-bytes")},
+	}
+	c := NewCollector(context.Background(), t.TempDir())
+	if err := c.AddDir(KindAptMirror, "apt/ubuntu", filepath.Join(src, "mirror")); err == nil {
+		t.Fatal("expected error for missing apt mirror")
+	}
+	writeTemp(t, src, "mirror/dists/noble/Release", "release")
+	writeTemp(t, src, "mirror/pool/main/a.deb", "deb")
+	if err := c.AddDir(KindAptMirror, "apt/ubuntu", filepath.Join(src, "mirror")); err != nil {
+		t.Fatalf("AddDir: %v", err)
+	}
+	files = append(files, c.Files()...)
+
+	var buf bytes.Buffer
+	written, err := Write(&buf, files)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(written.Artifacts) != 3 || written.Artifacts[0].SHA256 == "" || written.Artifacts[0].Size != 9 {
+		t.Fatalf("manifest = %+v", written.Artifacts)
+	}
+
+	dir := t.TempDir()
+	m, err := Import(bytes.NewReader(buf.Bytes()), dir)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(m.Artifacts) != 3 || m.Artifacts[0].Version != "24.04" {
+		t.Fatalf("imported manifest = %+v", m.Artifacts)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "apt", "ubuntu", "pool", "main", "a.deb")); string(got) != "deb" {
+		t.Fatalf("apt mirror file = %q", got)
+	}
+
+	idx, err := iso.LoadOfflineIndex(dir)
+	if err != nil {
+		t.Fatalf("LoadOfflineIndex: %v", err)
+	}
+	_, p, ok := idx.Lookup(isoURL)
+	if !ok {
+		t.Fatal("ISO URL not in the offline index")
+	}
+	if got, _ := os.ReadFile(p); string(got) != "iso-bytes" {
+		t.Fatalf("indexed file = %q", got)
+	}
+	if len(idx.Artifacts) != 1 {
+		t.Fatalf("expected only URL-backed files in the index, got %d", len(idx.Artifacts))
+	}
+}
+
+func TestImport_RejectsTamperedOrIncompleteBundle(t *testing.T) {
+	src := t.TempDir()
+	files := []File{{Artifact: Artifact{Kind: KindCatalog, Path: "catalogs/a.yaml"}, Local: writeTemp(t, src, "a.yaml", "original")}}
+	var buf bytes.Buffer
+	if _, err := Write(&buf, files); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Same length, different content.
+	tampered := bytes.Replace(buf.Bytes(), []byte("original"), []byte("modified"), 1)
+	if _, err := Import(bytes.NewReader(tampered), t.TempDir()); err == nil || !strings.Contains(err.Error(), "does not match the manifest") {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	// Manifest only.
+	var short bytes.Buffer
+	tw := tar.NewWriter(&short)
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	hdr, _ := tr.Next()
+	manifest := new(bytes.Buffer)
+	_, _ = manifest.ReadFrom(tr)
+	_ = tw.WriteHeader(hdr)
+	_, _ = tw.Write(manifest.Bytes())
+	_ = tw.Close()
+	if _, err := Import(bytes.NewReader(short.Bytes()), t.TempDir()); err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Fatalf("expected incomplete error, got %v", err)
+	}
+}
+
+func TestImport_RejectsUnsafePaths(t *testing.T) {
+	for _, p := range []string{"../evil", "files/../../evil", "/etc/passwd", "index.json", "files//x"} {
+		if validPath(p) {
+			t.Errorf("validPath(%q) = true", p)
+		}
+	}
+	if _, err := Write(new(bytes.Buffer), []File{{Artifact: Artifact{Path: "../x"}, Local: "x"}}); err == nil {
+		t.Fatal("expected Write to reject an unsafe path")
+	}
+
+	m, _ := json.Marshal(Manifest{Format: FormatVersion, Artifacts: []Artifact{{Path: "files/../../x"}}})
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(m))})
+	_, _ = tw.Write(m)
+	_ = tw.Close()
+	if _, err := Import(&buf, t.TempDir()); err == nil || !strings.Contains(err.Error(), "invalid bundle path") {
+		t.Fatalf("expected invalid path error, got %v", err)
+	}
+}
+
+func TestBundlePath(t *testing.T) {
+	a := bundlePath("https://cdimage.invalid/24.04/SHA256SUMS")
+	b := bundlePath("https://cdimage.invalid/22.04/SHA256SUMS")
+	if a == b || !strings.HasPrefix(a, "files/") || !strings.HasSuffix(a, "/SHA256SUMS") {
+		t.Fatalf("bundlePath = %q, %q", a, b)
+	}
+	if k := bundlePath("https://keys.invalid/pks/lookup?op=get&search=0xAB"); !strings.HasSuffix(k, "/lookup") || !validPath(k) {
+		t.Fatalf("key path = %q", k)
+	}
+}
@@ -0,0 +1,185 @@
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	talosprofile "github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile/talos"
+	"gopkg.in/yaml.v3"
+)
+
+// Collector gathers the files of a bundle, downloading artifacts that are not
+// cached yet. ISOs are taken from the shared cache; OVAs, checksum files and
+// catalogs are staged in a work directory.
+type Collector struct {
+	ctx   context.Context
+	work  string
+	mgr   *iso.Manager
+	files []File
+}
+
+// NewCollector returns a collector staging downloads in workDir.
+func NewCollector(ctx context.Context, workDir string) *Collector {
+	return &Collector{ctx: ctx, work: workDir, mgr: iso.NewManager(ctx)}
+}
+
+// SetCacheDir sets the local ISO cache ISOs are taken from and downloaded into.
+func (c *Collector) SetCacheDir(dir string) error {
+	return c.mgr.SetCacheDir(dir)
+}
+
+// Files returns the collected files in the order they were added.
+func (c *Collector) Files() []File {
+	return c.files
+}
+
+// AddVM adds what cfg needs to bootstrap offline: the Ubuntu ISO, or the
+// Talos ISO and OVA.
+func (c *Collector) AddVM(cfg *bootstrap.VMConfig) error {
+	switch cfg.EffectiveProfile() {
+	case "ubuntu":
+		return c.AddUbuntu(cfg.EffectiveOSVersion())
+	case "talos":
+		return c.AddTalos(cfg.EffectiveOSVersion(), cfg.EffectiveOSSchematicID())
+	default:
+		return fmt.Errorf("%s: unsupported profile %q", cfg.Name, cfg.EffectiveProfile())
+	}
+}
+
+// AddUbuntu adds an Ubuntu release ISO with its signed SHA256SUMS and the
+// signing keys, unless the release checksum is pinned in the catalog.
+func (c *Collector) AddUbuntu(version string) error {
+	spec, err := c.mgr.UbuntuDownloadSpec(version)
+	if err != nil {
+		return err
+	}
+	return c.addDownload(spec, Artifact{Kind: KindUbuntuISO, Version: version})
+}
+
+// AddTalos adds the Talos ISO and, for Image Factory schematics, the VMware
+// OVA deployed through the content library.
+func (c *Collector) AddTalos(version, schematicID string) error {
+	spec := talosprofile.ISODownloadSpec(version, schematicID, talosprofile.ISOOptions{CacheDir: c.mgr.Cache().Dir})
+	if err := os.MkdirAll(filepath.Dir(spec.Dest), 0755); err != nil {
+		return err
+	}
+	if err := c.addDownload(spec, Artifact{Kind: KindTalosISO, Version: version, SchematicID: schematicID}); err != nil {
+		return err
+	}
+	if strings.TrimSpace(schematicID) == "" {
+		return nil
+	}
+	ovaURL := bootstrap.TalosOVAURL(version, schematicID)
+	ova := iso.DownloadSpec{URLs: []string{ovaURL}, Dest: c.stagePath(ovaURL)}
+	return c.addDownload(ova, Artifact{
+		Kind:        KindTalosOVA,
+		Version:     version,
+		SchematicID: schematicID,
+		LibraryItem: bootstrap.TalosLibraryItemName(version, schematicID),
+	})
+}
+
+// AddCatalogs adds the Ubuntu and Talos release catalogs of this release and
+// the release catalog override, which bundle import merges into its own.
+func (c *Collector) AddCatalogs() error {
+	override, err := configs.LoadCatalogOverride("")
+	if err != nil {
+		return err
+	}
+	catalogs := map[string]any{
+		"ubuntu-releases.yaml": configs.UbuntuReleases,
+		"talos-releases.yaml":  configs.TalosReleases,
+		OverrideCatalogName:    override,
+	}
+	for name, v := range catalogs {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		local := filepath.Join(c.work, "catalogs", name)
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(local, data, 0644); err != nil {
+			return err
+		}
+		c.files = append(c.files, File{Artifact: Artifact{Kind: KindCatalog, Path: "catalogs/" + name}, Local: local})
+	}
+	return nil
+}
+
+// AddFile adds a local file as dir/<file name> in the bundle.
+func (c *Collector) AddFile(kind, dir, local string) error {
+	if _, err := os.Stat(local); err != nil {
+		return err
+	}
+	c.files = append(c.files, File{Artifact: Artifact{Kind: kind, Path: path.Join(dir, filepath.Base(local))}, Local: local})
+	return nil
+}
+
+// AddDir adds the regular files below root as dir/<relative path>.
+func (c *Collector) AddDir(kind, dir, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		c.files = append(c.files, File{Artifact: Artifact{Kind: kind, Path: path.Join(dir, filepath.ToSlash(rel))}, Local: p})
+		return nil
+	})
+}
+
+// addDownload downloads spec and the checksum files, signatures and keys used
+// to verify it, so Download repeats the same verification offline.
+func (c *Collector) addDownload(spec iso.DownloadSpec, a Artifact) error {
+	if err := iso.Download(c.ctx, spec); err != nil {
+		return fmt.Errorf("download %s: %w", spec.URLs[0], err)
+	}
+	a.URL = spec.URLs[0]
+	a.Path = bundlePath(a.URL)
+	c.files = append(c.files, File{Artifact: a, Local: spec.Dest})
+
+	primary := spec
+	primary.URLs = spec.URLs[:1]
+	for _, u := range primary.SidecarURLs() {
+		sidecar := iso.DownloadSpec{URLs: []string{u}, Dest: c.stagePath(u), Timeout: spec.Timeout}
+		if err := iso.Download(c.ctx, sidecar); err != nil {
+			return fmt.Errorf("download %s: %w", u, err)
+		}
+		c.files = append(c.files, File{
+			Artifact: Artifact{Kind: KindChecksum, URL: u, Path: bundlePath(u), Version: a.Version},
+			Local:    sidecar.Dest,
+		})
+	}
+	return nil
+}
+
+// stagePath returns the work directory path of a downloaded URL.
+func (c *Collector) stagePath(rawURL string) string {
+	return filepath.Join(c.work, filepath.FromSlash(bundlePath(rawURL)))
+}
+
+// bundlePath returns files/<url hash>/<file name>: unique per URL while
+// keeping the name readable.
+func bundlePath(rawURL string) string {
+	name := "file"
+	if u, err := url.Parse(rawURL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	return path.Join("files", hex.EncodeToString(sum[:6]), name)
+}
@@ -0,0 +1,156 @@
+// Package catalog refreshes the Ubuntu and Talos release catalogs from their
+// upstream endpoints into the user-level override catalog, so new point
+// releases are available without a new build (see configs.CatalogOverride).
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+)
+
+// UpdateOptions selects the endpoints and releases of Update. Empty fields
+// use the catalog.* defaults.
+type UpdateOptions struct {
+	UbuntuURL string // base URL with a <version>/ directory per series
+	TalosURL  string // GitHub releases API URL
+	// UbuntuVersions are the series to look up (default: all known series).
+	UbuntuVersions []string
+	TalosLimit     int // newest stable Talos releases to keep
+	KeyDir         string
+	Timeout        time.Duration
+}
+
+// Update fetches the newest point release of each Ubuntu series with its
+// checksum from the GPG-signed SHA256SUMS, and the stable Talos releases.
+// The result is meant to be merged into the override catalog.
+func Update(ctx context.Context, opts UpdateOptions) (*configs.CatalogOverride, error) {
+	if opts.UbuntuURL == "" {
+		opts.UbuntuURL = configs.Defaults.Catalog.UbuntuURL
+	}
+	if opts.TalosURL == "" {
+		opts.TalosURL = configs.Defaults.Catalog.TalosURL
+	}
+	if opts.TalosLimit <= 0 {
+		opts.TalosLimit = configs.Defaults.Catalog.TalosLimit
+	}
+	if opts.KeyDir == "" {
+		opts.KeyDir = filepath.Join(iso.DefaultCacheDir(), "keys")
+	}
+	if len(opts.UbuntuVersions) == 0 {
+		for v := range configs.MergedUbuntuReleases() {
+			opts.UbuntuVersions = append(opts.UbuntuVersions, v)
+		}
+		sort.Strings(opts.UbuntuVersions)
+	}
+
+	result := &configs.CatalogOverride{UpdatedAt: time.Now().UTC(), Ubuntu: map[string]configs.UbuntuRelease{}}
+	if opts.UbuntuURL != "" {
+		for _, series := range opts.UbuntuVersions {
+			r, err := ubuntuRelease(ctx, opts, series)
+			if err != nil {
+				return nil, fmt.Errorf("Ubuntu %s: %w", series, err)
+			}
+			result.Ubuntu[series] = r
+		}
+	}
+	if opts.TalosURL != "" {
+		versions, err := talosVersions(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Talos releases: %w", err)
+		}
+		result.Talos = versions
+	}
+	return result, nil
+}
+
+var liveServerISO = regexp.MustCompile(`^ubuntu-(\d+\.\d+(?:\.\d+)?)-live-server-amd64\.iso$`)
+
+// ubuntuRelease returns the newest live-server ISO listed in the signed
+// SHA256SUMS of a series.
+func ubuntuRelease(ctx context.Context, opts UpdateOptions, series string) (configs.UbuntuRelease, error) {
+	dir := strings.TrimSuffix(opts.UbuntuURL, "/") + "/" + series + "/"
+	sums, err := iso.LookupChecksums(ctx, iso.DownloadSpec{
+		URLs:          []string{dir + "SHA256SUMS"},
+		ChecksumFile:  "SHA256SUMS",
+		SignatureFile: "SHA256SUMS.gpg",
+		SigningKeys:   configs.UbuntuReleases.SigningKeys,
+		Keyserver:     configs.UbuntuReleases.Keyserver,
+		KeyDir:        opts.KeyDir,
+		Timeout:       opts.Timeout,
+	})
+	if err != nil {
+		return configs.UbuntuRelease{}, err
+	}
+	var best, bestVersion string
+	for name := range sums {
+		m := liveServerISO.FindStringSubmatch(name)
+		if m == nil || (m[1] != series && !strings.HasPrefix(m[1], series+".")) {
+			continue
+		}
+		if best == "" || configs.CompareVersions(m[1], bestVersion) > 0 {
+			best, bestVersion = name, m[1]
+		}
+	}
+	if best == "" {
+		return configs.UbuntuRelease{}, fmt.Errorf("no live-server ISO listed in %sSHA256SUMS", dir)
+	}
+	return configs.UbuntuRelease{URL: dir + best, Checksum: sums[best]}, nil
+}
+
+// githubRelease is the part of a GitHub release the catalog needs.
+type githubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// talosVersions returns the newest stable Talos releases, newest first.
+func talosVersions(ctx context.Context, opts UpdateOptions) ([]string, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.TalosURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, opts.TalosURL)
+	}
+	var releases []githubRelease
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", opts.TalosURL, err)
+	}
+	var versions []string
+	for _, r := range releases {
+		if r.Draft || r.Prerelease || strings.Contains(r.TagName, "-") || strings.TrimSpace(r.TagName) == "" {
+			continue
+		}
+		versions = append(versions, r.TagName)
+	}
+	versions = configs.SortTalosVersions(versions)
+	if len(versions) > opts.TalosLimit {
+		versions = versions[:opts.TalosLimit]
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no stable releases in %s", opts.TalosURL)
+	}
+	return versions, nil
+}
@@ -0,0 +1,122 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// releaseServer serves signed SHA256SUMS for the Ubuntu series in sums and
+// the GitHub releases JSON, and pins its signing key in the built-in catalog.
+func releaseServer(t *testing.T, sums map[string]string, releases string) *httptest.Server {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test CD Image", "", "cdimage@example.org", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	var pub bytes.Buffer
+	aw, _ := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	if err := entity.Serialize(aw); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	_ = aw.Close()
+	sigs := map[string][]byte{}
+	for series, content := range sums {
+		var sig bytes.Buffer
+		if err := openpgp.DetachSign(&sig, entity, strings.NewReader(content), nil); err != nil {
+			t.Fatalf("DetachSign: %v", err)
+		}
+		sigs[series] = sig.Bytes()
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		series, file := filepath.Split(strings.TrimPrefix(r.URL.Path, "/ubuntu/"))
+		series = strings.TrimSuffix(series, "/")
+		switch {
+		case r.URL.Path == "/pks/lookup":
+			_, _ = w.Write(pub.Bytes())
+		case r.URL.Path == "/talos/releases":
+			_, _ = w.Write([]byte(releases))
+		case file == "SHA256SUMS" && sums[series] != "":
+			_, _ = w.Write([]byte(sums[series]))
+		case file == "SHA256SUMS.gpg" && sigs[series] != nil:
+			_, _ = w.Write(sigs[series])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	old := configs.UbuntuReleases
+	configs.UbuntuReleases.SigningKeys = []string{strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))}
+	configs.UbuntuReleases.Keyserver = srv.URL
+	t.Cleanup(func() { configs.UbuntuReleases = old })
+	return srv
+}
+
+func TestUpdate_PicksNewestPointReleaseAndStableTalos(t *testing.T) {
+	srv := releaseServer(t, map[string]string{
+		"24.04": sha256Hex("a") + " *ubuntu-24.04.2-live-server-amd64.iso\n" +
+			sha256Hex("b") + " *ubuntu-24.04.10-live-server-amd64.iso\n" +
+			sha256Hex("c") + " *ubuntu-24.04.10-desktop-amd64.iso\n",
+	}, `[
+		{"tag_name": "v1.13.0-beta.1", "prerelease": true},
+		{"tag_name": "v1.12.1"},
+		{"tag_name": "v1.12.0"},
+		{"tag_name": "v1.11.9"},
+		{"tag_name": "v1.13.0", "draft": true}
+	]`)
+
+	got, err := Update(context.Background(), UpdateOptions{
+		UbuntuURL:      srv.URL + "/ubuntu/",
+		TalosURL:       srv.URL + "/talos/releases",
+		UbuntuVersions: []string{"24.04"},
+		TalosLimit:     2,
+		KeyDir:         t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	r := got.Ubuntu["24.04"]
+	if r.URL != srv.URL+"/ubuntu/24.04/ubuntu-24.04.10-live-server-amd64.iso" || r.Checksum != sha256Hex("b") {
+		t.Fatalf("Ubuntu 24.04 = %+v", r)
+	}
+	if strings.Join(got.Talos, ",") != "v1.12.1,v1.12.0" {
+		t.Fatalf("Talos = %v", got.Talos)
+	}
+}
+
+func TestUpdate_Errors(t *testing.T) {
+	srv := releaseServer(t, map[string]string{
+		"22.04": sha256Hex("a") + " *ubuntu-22.04.5-desktop-amd64.iso\n",
+	}, `[{"tag_name": "v1.13.0-alpha.1", "prerelease": true}]`)
+
+	opts := UpdateOptions{UbuntuURL: srv.URL + "/ubuntu", UbuntuVersions: []string{"22.04"}, KeyDir: t.TempDir()}
+	if _, err := Update(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "no live-server ISO") {
+		t.Fatalf("expected missing ISO error, got %v", err)
+	}
+	opts.UbuntuVersions = []string{"20.04"}
+	if _, err := Update(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "Ubuntu 20.04") {
+		t.Fatalf("expected lookup error, got %v", err)
+	}
+
+	_, err := talosVersions(context.Background(), UpdateOptions{TalosURL: srv.URL + "/talos/releases", TalosLimit: 5})
+	if err == nil || !strings.Contains(err.Error(), "no stable releases") {
+		t.Fatalf("expected no stable releases error, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package cloudinit
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions are user additions merged into the generated autoinstall
+// user-data, so a VM can add packages, files and commands without a forked
+// template.
+type Extensions struct {
+	Packages     []string    // installed with the default packages
+	WriteFiles   []WriteFile // user-data write_files
+	BootCmd      []string    // user-data bootcmd (every boot, early)
+	RunCmd       []string    // user-data runcmd (first boot)
+	LateCommands []string    // autoinstall late-commands (installer, target mounted at /target)
+	// UserData is a raw cloud-config fragment deep-merged into the user-data
+	// section: maps are merged, lists appended; replacing a generated value
+	// is an error.
+	UserData string
+}
+
+// WriteFile is a cloud-init write_files entry.
+type WriteFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content,omitempty"`
+	Owner       string `yaml:"owner,omitempty"`       // e.g. root:root
+	Permissions string `yaml:"permissions,omitempty"` // octal, e.g. "0644"
+	Encoding    string `yaml:"encoding,omitempty"`    // b64, gzip or gz+b64
+	Append      bool   `yaml:"append,omitempty"`
+}
+
+// IsZero reports whether e adds nothing.
+func (e Extensions) IsZero() bool {
+	return len(e.Packages) == 0 && len(e.WriteFiles) == 0 && len(e.BootCmd) == 0 &&
+		len(e.RunCmd) == 0 && len(e.LateCommands) == 0 && strings.TrimSpace(e.UserData) == ""
+}
+
+var (
+	permissionsRe  = regexp.MustCompile(`^[0-7]{3,4}$`)
+	validEncodings = map[string]bool{"": true, "b64": true, "base64": true, "gz": true, "gzip": true, "gz+b64": true, "gzip+base64": true, "gz+base64": true, "gzip+b64": true}
+)
+
+// Validate checks the extensions before they are merged.
+func (e Extensions) Validate() error {
+	for _, p := range e.Packages {
+		if p == "" || strings.ContainsAny(p, " \t\r\n") {
+			return fmt.Errorf("invalid package name %q", p)
+		}
+	}
+	for i, f := range e.WriteFiles {
+		switch {
+		case !path.IsAbs(f.Path):
+			return fmt.Errorf("write_files[%d]: path must be absolute (got %q)", i, f.Path)
+		case f.Permissions != "" && !permissionsRe.MatchString(f.Permissions):
+			return fmt.Errorf("write_files[%d]: invalid permissions %q (expected octal, e.g. \"0644\")", i, f.Permissions)
+		case !validEncodings[f.Encoding]:
+			return fmt.Errorf("write_files[%d]: unsupported encoding %q (b64, gzip or gz+b64)", i, f.Encoding)
+		}
+	}
+	for _, list := range []struct {
+		name string
+		cmds []string
+	}{{"bootcmd", e.BootCmd}, {"runcmd", e.RunCmd}, {"late-commands", e.LateCommands}} {
+		for i, c := range list.cmds {
+			if strings.TrimSpace(c) == "" {
+				return fmt.Errorf("%s[%d]: empty command", list.name, i)
+			}
+		}
+	}
+	if _, err := e.userDataNode(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// userDataNode parses the raw UserData fragment (nil when empty).
+func (e Extensions) userDataNode() (*yaml.Node, error) {
+	if strings.TrimSpace(e.UserData) == "" {
+		return nil, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(e.UserData), &doc); err != nil {
+		return nil, fmt.Errorf("user-data fragment: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("user-data fragment must be a YAML mapping")
+	}
+	return doc.Content[0], nil
+}
+
+// applyExtensions merges e into the generated autoinstall document content.
+func applyExtensions(content string, e Extensions) (string, error) {
+	if err := e.Validate(); err != nil {
+		return "", err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return "", err
+	}
+	autoinstall := mappingValue(doc.Content[0], "autoinstall")
+	if autoinstall == nil || autoinstall.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("generated user-data has no autoinstall section")
+	}
+
+	add := &yaml.Node{Kind: yaml.MappingNode}
+	setValue(add, "packages", e.Packages)
+	setValue(add, "late-commands", e.LateCommands)
+	if err := mergeNode(autoinstall, add, "autoinstall", true); err != nil {
+		return "", err
+	}
+
+	userData := mappingValue(autoinstall, "user-data")
+	if userData == nil {
+		userData = &yaml.Node{Kind: yaml.MappingNode}
+		setNode(autoinstall, "user-data", userData)
+	}
+	add = &yaml.Node{Kind: yaml.MappingNode}
+	setValue(add, "bootcmd", e.BootCmd)
+	setValue(add, "write_files", e.WriteFiles)
+	setValue(add, "runcmd", e.RunCmd)
+	if err := mergeNode(userData, add, "user-data", false); err != nil {
+		return "", err
+	}
+	fragment, err := e.userDataNode()
+	if err != nil {
+		return "", err
+	}
+	if fragment != nil {
+		if err := mergeNode(userData, fragment, "user-data", false); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// mergeNode deep-merges the mapping src into dst: maps are merged and lists
+// appended (skipping duplicate scalars when dedupe is set). A scalar that
+// differs from the generated one is an error, so extensions never silently
+// replace generated settings.
+func mergeNode(dst, src *yaml.Node, at string, dedupe bool) error {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		field := at + "." + key.Value
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			setNode(dst, key.Value, value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			if err := mergeNode(existing, value, field, dedupe); err != nil {
+				return err
+			}
+		case existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			for _, item := range value.Content {
+				if dedupe && item.Kind == yaml.ScalarNode && containsScalar(existing, item.Value) {
+					continue
+				}
+				existing.Content = append(existing.Content, item)
+			}
+		case existing.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && existing.Value == value.Value:
+		default:
+			return fmt.Errorf("%s: cannot replace the generated value", field)
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in mapping node m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setNode(m *yaml.Node, key string, value *yaml.Node) {
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// setValue adds key: v to mapping node m unless v is an empty list.
+func setValue[T any](m *yaml.Node, key string, v []T) {
+	if len(v) == 0 {
+		return
+	}
+	n := &yaml.Node{}
+	if err := n.Encode(v); err != nil {
+		return
+	}
+	setNode(m, key, n)
+}
+
+func containsScalar(seq *yaml.Node, value string) bool {
+	for _, item := range seq.Content {
+		if item.Kind == yaml.ScalarNode && item.Value == value {
+			return true
+		}
+	}
+	return false
+}
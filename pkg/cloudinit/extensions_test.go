@@ -0,0 +1,131 @@
+package cloudinit
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func extensionsInput(e Extensions) *UserDataInput {
+	return &UserDataInput{
+		Hostname:       "test-vm",
+		Username:       "ubuntu",
+		PasswordHash:   "$6$rounds=5000$salt$hash",
+		SSHPublicKeys:  []string{"ssh-ed25519 AAAA... test@example.com"},
+		Locale:         "en_US.UTF-8",
+		Timezone:       "UTC",
+		KeyboardLayout: "us",
+		Packages:       []string{"open-vm-tools", "curl"},
+		UserGroups:     "sudo,adm",
+		UserShell:      "/bin/bash",
+		IPAddress:      "192.168.1.10",
+		CIDR:           24,
+		Gateway:        "192.168.1.1",
+		DNS:            []string{"8.8.8.8"},
+		Extensions:     e,
+	}
+}
+
+func TestGenerateUserData_Extensions(t *testing.T) {
+	gen, err := NewGenerator()
+	if err != nil {
+		t.Fatalf("NewGenerator() failed: %v", err)
+	}
+	userData, err := gen.GenerateUserData(extensionsInput(Extensions{
+		Packages:     []string{"curl", "htop"},
+		WriteFiles:   []WriteFile{{Path: "/etc/motd", Content: "hello\n", Permissions: "0644"}},
+		BootCmd:      []string{"echo boot"},
+		RunCmd:       []string{"echo first-boot"},
+		LateCommands: []string{"curtin in-target -- true"},
+		UserData:     "ntp:\n  enabled: true\n  servers: [ntp.example.com]\n",
+	}))
+	if err != nil {
+		t.Fatalf("GenerateUserData() failed: %v", err)
+	}
+	if !strings.HasPrefix(userData, "#cloud-config\n") {
+		t.Errorf("merged user-data lost the #cloud-config header:\n%s", userData)
+	}
+
+	var doc struct {
+		Autoinstall struct {
+			Packages     []string `yaml:"packages"`
+			LateCommands []string `yaml:"late-commands"`
+			UserData     struct {
+				BootCmd    []string    `yaml:"bootcmd"`
+				RunCmd     []string    `yaml:"runcmd"`
+				WriteFiles []WriteFile `yaml:"write_files"`
+				NTP        struct {
+					Enabled bool     `yaml:"enabled"`
+					Servers []string `yaml:"servers"`
+				} `yaml:"ntp"`
+			} `yaml:"user-data"`
+		} `yaml:"autoinstall"`
+	}
+	if err := yaml.Unmarshal([]byte(userData), &doc); err != nil {
+		t.Fatalf("merged user-data is not valid YAML: %v\n%s", err, userData)
+	}
+	ai := doc.Autoinstall
+	if strings.Join(ai.Packages, ",") != "open-vm-tools,curl,htop" {
+		t.Errorf("packages = %v, want generated packages plus htop (curl deduplicated)", ai.Packages)
+	}
+	if n := len(ai.LateCommands); n < 2 || ai.LateCommands[n-1] != "curtin in-target -- true" {
+		t.Errorf("late-commands = %v, want extension appended", ai.LateCommands)
+	}
+	if n := len(ai.UserData.RunCmd); n < 2 || ai.UserData.RunCmd[n-1] != "echo first-boot" {
+		t.Errorf("runcmd = %v, want extension appended to generated commands", ai.UserData.RunCmd)
+	}
+	if len(ai.UserData.BootCmd) != 1 {
+		t.Errorf("bootcmd = %v", ai.UserData.BootCmd)
+	}
+	if n := len(ai.UserData.WriteFiles); n < 2 || ai.UserData.WriteFiles[n-1].Path != "/etc/motd" {
+		t.Errorf("write_files = %+v, want /etc/motd appended", ai.UserData.WriteFiles)
+	}
+	if !ai.UserData.NTP.Enabled || len(ai.UserData.NTP.Servers) != 1 {
+		t.Errorf("user-data fragment not merged: %+v", ai.UserData.NTP)
+	}
+}
+
+func TestGenerateUserData_ExtensionsConflict(t *testing.T) {
+	gen, err := NewGenerator()
+	if err != nil {
+		t.Fatalf("NewGenerator() failed: %v", err)
+	}
+	_, err = gen.GenerateUserData(extensionsInput(Extensions{UserData: "timezone: Europe/Prague\n"}))
+	if err == nil || !strings.Contains(err.Error(), "user-data.timezone") {
+		t.Fatalf("GenerateUserData() error = %v, want conflict on user-data.timezone", err)
+	}
+
+	// Repeating a generated value is not a conflict.
+	if _, err := gen.GenerateUserData(extensionsInput(Extensions{UserData: "timezone: UTC\n"})); err != nil {
+		t.Fatalf("GenerateUserData() with an equal value: %v", err)
+	}
+}
+
+func TestExtensionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		ext  Extensions
+		want string
+	}{
+		{"valid", Extensions{Packages: []string{"htop"}, WriteFiles: []WriteFile{{Path: "/etc/x", Permissions: "600", Encoding: "b64"}}}, ""},
+		{"package with space", Extensions{Packages: []string{"htop curl"}}, "invalid package name"},
+		{"relative path", Extensions{WriteFiles: []WriteFile{{Path: "etc/x"}}}, "path must be absolute"},
+		{"bad permissions", Extensions{WriteFiles: []WriteFile{{Path: "/etc/x", Permissions: "rw-r--r--"}}}, "invalid permissions"},
+		{"bad encoding", Extensions{WriteFiles: []WriteFile{{Path: "/etc/x", Encoding: "hex"}}}, "unsupported encoding"},
+		{"empty command", Extensions{RunCmd: []string{"echo", " "}}, "runcmd[1]: empty command"},
+		{"fragment not a mapping", Extensions{UserData: "- a\n- b\n"}, "must be a YAML mapping"},
+		{"fragment invalid", Extensions{UserData: "ntp: [broken"}, "user-data fragment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ext.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
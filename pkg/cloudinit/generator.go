@@ -43,6 +43,7 @@ type UserDataInput struct {
 	UserGroups       string   // e.g., "sudo,adm,dialout"
 	UserShell        string   // e.g., "/bin/bash"
 	InterfaceName    string   // Guest NIC name (e.g., "ens192")
+	Firmware         string   // "efi" = EFI-only partition layout; anything else = hybrid BIOS+EFI
 	// Data disk mount point (empty = no data disk, uses layout:lvm)
 	DataDiskMountPath string // e.g., "/data"
 	// Network (required for package installation during autoinstall)
@@ -50,6 +51,8 @@ type UserDataInput struct {
 	CIDR      int
 	Gateway   string
 	DNS       []string
+	// User additions merged into the generated config (see Extensions)
+	Extensions Extensions
 }
 
 // MetaDataInput contains data for meta-data generation.
@@ -113,6 +116,14 @@ func (g *Generator) GenerateUserData(input *UserDataInput) (string, error) {
 		return "", fmt.Errorf("generated user-data is invalid YAML: %w", err)
 	}
 
+	if !input.Extensions.IsZero() {
+		merged, err := applyExtensions(content, input.Extensions)
+		if err != nil {
+			return "", fmt.Errorf("cloud-init extensions: %w", err)
+		}
+		content = merged
+	}
+
 	return content, nil
 }
 
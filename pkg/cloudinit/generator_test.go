@@ -255,6 +255,58 @@ func TestGenerateUserData_NoSwap(t *testing.T) {
 	}
 }
 
+func TestGenerateUserData_EFIOnlyLayout(t *testing.T) {
+	gen, err := NewGenerator()
+	if err != nil {
+		t.Fatalf("NewGenerator() failed: %v", err)
+	}
+
+	input := &UserDataInput{
+		Hostname:       "test-vm",
+		Username:       "ubuntu",
+		SSHPublicKeys:  []string{"ssh-ed25519 AAAA... test@example.com"},
+		Locale:         "en_US.UTF-8",
+		Timezone:       "UTC",
+		KeyboardLayout: "us",
+		SwapSize:       "2G",
+		SwapSizeGB:     2,
+		Packages:       []string{"open-vm-tools"},
+		UserGroups:     "sudo",
+		UserShell:      "/bin/bash",
+		Firmware:       "efi",
+		IPAddress:      "192.168.1.10",
+		CIDR:           24,
+		Gateway:        "192.168.1.1",
+		DNS:            []string{"8.8.8.8"},
+	}
+
+	userData, err := gen.GenerateUserData(input)
+	if err != nil {
+		t.Fatalf("GenerateUserData() failed: %v", err)
+	}
+	if err := gen.ValidateYAML(userData); err != nil {
+		t.Fatalf("generated user-data is not valid YAML: %v", err)
+	}
+	if strings.Contains(userData, "bios-boot-partition") || strings.Contains(userData, "bios_grub") {
+		t.Error("Expected BIOS boot partition to be omitted for efi firmware")
+	}
+	if !strings.Contains(userData, "size: 512M\n        flag: boot\n        grub_device: true") {
+		t.Error("Expected EFI partition to be the grub device for efi firmware")
+	}
+	if strings.Contains(userData, "preserve: false\n        grub_device: true") {
+		t.Error("Expected disk-level grub_device to be omitted for efi firmware")
+	}
+
+	input.Firmware = "bios"
+	userData, err = gen.GenerateUserData(input)
+	if err != nil {
+		t.Fatalf("GenerateUserData() failed: %v", err)
+	}
+	if !strings.Contains(userData, "bios-boot-partition") {
+		t.Error("Expected hybrid layout with BIOS boot partition for bios firmware")
+	}
+}
+
 func TestGenerateMetaData_BadTemplate(t *testing.T) {
 	gen := &Generator{
 		metaDataTmpl: template.Must(template.New("meta").Parse("{{ index . 1 }}")),
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"gopkg.in/yaml.v3"
+)
+
+// maxExtendsDepth bounds extends chains so a misconfigured chain fails fast.
+const maxExtendsDepth = 16
+
+// StringList is a YAML value that may be a single string or a list of strings.
+type StringList []string
+
+// UnmarshalYAML accepts `key: a` as well as `key: [a, b]`.
+func (l *StringList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		if n.Tag == "!!null" || n.Value == "" {
+			*l = nil
+			return nil
+		}
+		*l = StringList{n.Value}
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// resolveVM parses a VM file and merges the files it extends beneath it.
+// Relative extends paths are resolved against the directory of name; base files
+// may be SOPS-encrypted and may extend further files themselves.
+// The result still contains classes and vm.class; see applyClass.
+func resolveVM(name string, data []byte, chain []string) (*yaml.Node, *source, error) {
+	var raw VMFile
+	doc, src, err := decodeStrict(name, data, &raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	removeKey(doc, "extends")
+	if len(raw.Extends) == 0 {
+		return doc, src, nil
+	}
+
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if slices.Contains(chain, abs) {
+		return nil, nil, &ValidationError{Errors: []*FieldError{src.at("extends", "cycle: %s", strings.Join(append(chain, abs), " -> "))}}
+	}
+	if len(chain) >= maxExtendsDepth {
+		return nil, nil, &ValidationError{Errors: []*FieldError{src.at("extends", "more than %d levels of extends", maxExtendsDepth)}}
+	}
+	chain = append(chain, abs)
+
+	var base *yaml.Node
+	pos := map[string]position{}
+	for _, ref := range raw.Extends {
+		path := expandHome(ref)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(name), path)
+		}
+		baseData, err := ReadFile(path)
+		if err != nil {
+			return nil, nil, &ValidationError{Errors: []*FieldError{src.at("extends", "%v", err)}}
+		}
+		baseDoc, baseSrc, err := resolveVM(path, baseData, chain)
+		if err != nil {
+			return nil, nil, err
+		}
+		base = mergeNodes(base, baseDoc)
+		for k, p := range baseSrc.pos {
+			pos[k] = p
+		}
+	}
+	for k, p := range src.pos {
+		pos[k] = p
+	}
+	return mergeNodes(base, doc), &source{file: name, pos: pos}, nil
+}
+
+// applyClass merges the size class named by vm.class beneath the vm block and
+// removes the inheritance keys, leaving a self-contained VM document.
+// Classes come from configs/defaults.yaml (vm.classes), overridden by classes:
+// blocks in the file and its bases. Values set in the files win over the class.
+func applyClass(doc *yaml.Node, src *source) error {
+	classes := mappingGet(doc, "classes")
+	removeKey(doc, "classes")
+	vm := mappingGet(doc, "vm")
+	if vm == nil {
+		return nil
+	}
+	nameNode := mappingGet(vm, "class")
+	if nameNode == nil || nameNode.Value == "" {
+		removeKey(vm, "class")
+		return nil
+	}
+	className := nameNode.Value
+
+	var defaults yaml.Node
+	if err := defaults.Encode(configs.Defaults.VM.Classes); err != nil {
+		return err
+	}
+	all := mergeNodes(&defaults, classes)
+	class := mappingGet(all, className)
+	if class == nil {
+		var known []string
+		for i := 0; i+1 < len(all.Content); i += 2 {
+			known = append(known, all.Content[i].Value)
+		}
+		sort.Strings(known)
+		return &ValidationError{Errors: []*FieldError{
+			src.at("vm.class", "unknown class %q (known: %s)", className, strings.Join(known, ", ")),
+		}}
+	}
+
+	merged := mergeNodes(class, vm)
+	removeKey(merged, "class")
+	mappingSet(doc, "vm", merged)
+
+	// Errors in class-provided values point at the class definition.
+	prefix := "classes." + className + "."
+	for k, p := range src.pos {
+		if rest, ok := strings.CutPrefix(k, prefix); ok {
+			if _, set := src.pos["vm."+rest]; !set {
+				src.pos["vm."+rest] = p
+			}
+		}
+	}
+	return nil
+}
+
+// mergeNodes deep-merges over onto base and returns a new node: mappings are
+// merged key by key, everything else (scalars, lists) in over replaces base.
+// A null value in over removes the key. Inputs are not modified.
+func mergeNodes(base, over *yaml.Node) *yaml.Node {
+	if base == nil {
+		return over
+	}
+	if over == nil {
+		return base
+	}
+	if base.Kind != yaml.MappingNode || over.Kind != yaml.MappingNode {
+		return over
+	}
+	out := *base
+	out.Content = slices.Clone(base.Content)
+	for i := 0; i+1 < len(over.Content); i += 2 {
+		k, v := over.Content[i], over.Content[i+1]
+		if v.Kind == yaml.ScalarNode && v.Tag == "!!null" {
+			removeKey(&out, k.Value)
+			continue
+		}
+		if existing := mappingGet(&out, k.Value); existing != nil {
+			mappingSet(&out, k.Value, mergeNodes(existing, v))
+			continue
+		}
+		out.Content = append(out.Content, k, v)
+	}
+	return &out
+}
+
+func mappingGet(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func mappingSet(m *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = v
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v)
+}
+
+func removeKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = slices.Delete(slices.Clone(m.Content), i, i+2)
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadVMFile_Extends(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "bases", "site.yaml"), `vm:
+  username: sysadmin
+  ssh_key: ssh-ed25519 AAAA site
+  netmask: 255.255.255.0
+  gateway: 192.168.1.1
+  dns: 8.8.8.8
+  dns2: 1.1.1.1
+  datastore: SSD01
+  network_name: LAN
+  extra_config:
+    disk.EnableUUID: "TRUE"
+  profiles:
+    ubuntu:
+      version: "24.04"
+`)
+	writeTestFile(t, filepath.Join(dir, "bases", "web.yaml"), `extends: site.yaml
+classes:
+  web:
+    cpus: 6
+    memory_mb: 12288
+vm:
+  datastore: SSD02
+  data_disk_size_gb: 50
+  data_disk_mount_path: /srv
+`)
+	path := filepath.Join(dir, "vm.web-01.yaml")
+	writeTestFile(t, path, `apiVersion: vmbootstrap.infrakit.io/v1
+extends: [bases/web.yaml]
+vm:
+  name: web-01
+  class: web
+  memory_mb: 16384
+  ip_address: 192.168.1.10
+  dns2:
+  extra_config:
+    guestinfo.role: web
+`)
+
+	f, err := LoadVMFile(path)
+	if err != nil {
+		t.Fatalf("LoadVMFile: %v", err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	v := f.VM
+	if v.Name != "web-01" || v.Username != "sysadmin" || v.Gateway != "192.168.1.1" {
+		t.Errorf("base values not inherited: %+v", v)
+	}
+	if v.Datastore != "SSD02" || v.DataDiskSizeGB != 50 {
+		t.Errorf("intermediate base did not override site: datastore=%q data=%d", v.Datastore, v.DataDiskSizeGB)
+	}
+	if v.CPUs != 6 || v.MemoryMB != 16384 {
+		t.Errorf("class/override: cpus=%d memory=%d, want 6/16384", v.CPUs, v.MemoryMB)
+	}
+	if v.DNS2 != "" {
+		t.Errorf("dns2 = %q, want removed by null", v.DNS2)
+	}
+	wantExtra := map[string]string{"disk.EnableUUID": "TRUE", "guestinfo.role": "web"}
+	if !reflect.DeepEqual(v.ExtraConfig, wantExtra) {
+		t.Errorf("extra_config = %v, want deep merge %v", v.ExtraConfig, wantExtra)
+	}
+	if v.Class != "" || len(f.Extends) != 0 || len(f.Classes) != 0 {
+		t.Errorf("inheritance keys not resolved: class=%q extends=%v classes=%v", v.Class, f.Extends, f.Classes)
+	}
+
+	out, err := f.Render()
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(string(out), "extends") || !strings.Contains(string(out), "datastore: SSD02") {
+		t.Errorf("Render =\n%s", out)
+	}
+}
+
+func TestLoadVMFile_DefaultClassAndPositions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "base.yaml"), "vm:\n  username: sysadmin\n  gateway: 192.168.1.999\n")
+	path := filepath.Join(dir, "vm.db.yaml")
+	writeTestFile(t, path, "extends: base.yaml\nvm:\n  name: db\n  class: large\n")
+
+	f, err := LoadVMFile(path)
+	if err != nil {
+		t.Fatalf("LoadVMFile: %v", err)
+	}
+	if f.VM.CPUs != 8 || f.VM.MemoryMB != 16384 {
+		t.Errorf("default class large not applied: %+v", f.VM)
+	}
+
+	// Errors in inherited values point at the base file.
+	err = f.Validate()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "base.yaml")+":3:3: vm.gateway") {
+		t.Errorf("Validate = %v, want gateway error in base.yaml:3", err)
+	}
+}
+
+func TestLoadVMFile_ExtendsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.yaml"), "extends: b.yaml\nvm:\n  cpus: 1\n")
+	writeTestFile(t, filepath.Join(dir, "b.yaml"), "extends: a.yaml\n")
+	if _, err := LoadVMFile(filepath.Join(dir, "a.yaml")); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "missing.yaml"), "extends: nope.yaml\n")
+	_, err := LoadVMFile(filepath.Join(dir, "missing.yaml"))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Errors[0].Field != "extends" || ve.Errors[0].Line != 1 {
+		t.Errorf("expected positioned extends error for missing base, got %v", err)
+	}
+
+	path := filepath.Join(dir, "vm.x.yaml")
+	writeTestFile(t, path, "vm:\n  name: x\n  class: huge\n")
+	_, err = LoadVMFile(path)
+	if err == nil || !strings.Contains(err.Error(), path+":3:3: vm.class: unknown class \"huge\"") {
+		t.Errorf("expected unknown class error, got %v", err)
+	}
+}
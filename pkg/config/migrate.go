@@ -0,0 +1,179 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIVersion is the current schema version of VM and vCenter files.
+// Files without apiVersion predate versioning and are read as the first version.
+const APIVersion = "vmbootstrap.infrakit.io/v1"
+
+// File kinds, identified by the top-level key of a config file.
+const (
+	KindVM      = "vm"
+	KindVCenter = "vcenter"
+)
+
+// ErrUnknownKind is returned when a file is neither a VM nor a vCenter config.
+var ErrUnknownKind = errors.New("not a vm or vcenter config file")
+
+// migration upgrades a document from one apiVersion to the next.
+type migration struct {
+	from, to string
+	apply    func(kind string, doc *yaml.Node) error
+}
+
+// migrations are applied in order until the document reaches APIVersion.
+var migrations = []migration{
+	// Unversioned files only gain apiVersion; the schema itself is unchanged.
+	{from: "", to: APIVersion, apply: func(string, *yaml.Node) error { return nil }},
+}
+
+// checkAPIVersion reports an apiVersion this build cannot read.
+func checkAPIVersion(errs *fieldErrors, version string) {
+	if version != "" && version != APIVersion {
+		errs.add("apiVersion", "unsupported apiVersion %q (this build reads %s)", version, APIVersion)
+	}
+}
+
+// Kind returns KindVM or KindVCenter for plaintext config YAML.
+func Kind(data []byte) (string, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	switch {
+	case doc[KindVM] != nil, doc["extends"] != nil, doc["classes"] != nil:
+		// Base files may hold only shared classes or extend other bases.
+		return KindVM, nil
+	case doc[KindVCenter] != nil:
+		return KindVCenter, nil
+	}
+	return "", ErrUnknownKind
+}
+
+// Migrate upgrades plaintext VM or vCenter YAML to APIVersion.
+// Comments and key order are kept. changed is false when data is already current.
+func Migrate(data []byte) (out []byte, changed bool, err error) {
+	kind, err := Kind(data)
+	if err != nil {
+		return nil, false, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, false, err
+	}
+	doc := root.Content[0]
+
+	version := mappingValue(doc, "apiVersion")
+	for version != APIVersion {
+		m := findMigration(version)
+		if m == nil {
+			return nil, false, fmt.Errorf("unsupported apiVersion %q (this build reads %s)", version, APIVersion)
+		}
+		if err := m.apply(kind, doc); err != nil {
+			return nil, false, fmt.Errorf("migrate %s -> %s: %w", versionName(m.from), m.to, err)
+		}
+		setAPIVersion(doc, m.to)
+		version = m.to
+		changed = true
+	}
+	if !changed {
+		return data, false, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, false, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// MigrateFile upgrades a VM or vCenter file in place. SOPS-encrypted files are
+// decrypted, migrated and re-encrypted with the matching .sops.yaml creation rule;
+// plain files stay plain. Files that are already current are left untouched.
+func MigrateFile(path string) (changed bool, err error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
+	plain, err := ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	out, changed, err := Migrate(plain)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	// Never write a file the loaders would reject.
+	kind, _ := Kind(out)
+	if kind == KindVM {
+		_, err = ParseVMFile(path, out)
+	} else {
+		_, err = ParseVCenterFile(path, out)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if IsEncrypted(raw) {
+		return true, EncryptFile(path, out)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, out, info.Mode().Perm())
+}
+
+func findMigration(from string) *migration {
+	for i := range migrations {
+		if migrations[i].from == from {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+func versionName(v string) string {
+	if v == "" {
+		return "(unversioned)"
+	}
+	return v
+}
+
+func mappingValue(m *yaml.Node, key string) string {
+	if v := mappingGet(m, key); v != nil {
+		return v.Value
+	}
+	return ""
+}
+
+// setAPIVersion sets apiVersion, adding it as the first key when missing.
+func setAPIVersion(m *yaml.Node, version string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == "apiVersion" {
+			m.Content[i+1].Value = version
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "apiVersion"}
+	val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: version}
+	if len(m.Content) > 0 {
+		// Keep a leading file comment at the top of the file.
+		key.HeadComment, m.Content[0].HeadComment = m.Content[0].HeadComment, ""
+	}
+	m.Content = append([]*yaml.Node{key, val}, m.Content...)
+}
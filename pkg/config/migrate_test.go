@@ -0,0 +1,105 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	legacy := "# web tier\nvm:\n  name: web-01 # primary\n  cpus: 2\n"
+
+	out, changed, err := Migrate([]byte(legacy))
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected unversioned file to change")
+	}
+	want := "# web tier\napiVersion: " + APIVersion + "\nvm:\n  name: web-01 # primary\n  cpus: 2\n"
+	if string(out) != want {
+		t.Errorf("Migrate =\n%s\nwant\n%s", out, want)
+	}
+
+	again, changed, err := Migrate(out)
+	if err != nil || changed || string(again) != string(out) {
+		t.Errorf("second Migrate changed=%v err=%v", changed, err)
+	}
+
+	if _, _, err := Migrate([]byte("apiVersion: vmbootstrap.infrakit.io/v99\nvm:\n  name: x\n")); err == nil {
+		t.Error("expected error for unknown apiVersion")
+	}
+	if _, _, err := Migrate([]byte("other: 1\n")); err == nil {
+		t.Error("expected error for non-config YAML")
+	}
+}
+
+func TestMigrateFile_SOPS(t *testing.T) {
+	dir := setupSOPS(t)
+	path := filepath.Join(dir, "configs", "vcenter.sops.yaml")
+	if err := EncryptFile(path, []byte(testVCenterYAML)); err != nil {
+		t.Fatalf("EncryptFile: %v", err)
+	}
+
+	changed, err := MigrateFile(path)
+	if err != nil || !changed {
+		t.Fatalf("MigrateFile changed=%v err=%v", changed, err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !IsEncrypted(raw) {
+		t.Fatal("migrated file is no longer encrypted")
+	}
+	vc, err := LoadVCenterFile(path)
+	if err != nil {
+		t.Fatalf("LoadVCenterFile: %v", err)
+	}
+	if vc.APIVersion != APIVersion || vc.VCenter.Host != "vcenter.example.com" {
+		t.Errorf("unexpected migrated file: %+v", vc)
+	}
+	if changed, err := MigrateFile(path); err != nil || changed {
+		t.Errorf("second MigrateFile changed=%v err=%v", changed, err)
+	}
+}
+
+func TestValidate_APIVersion(t *testing.T) {
+	vm, _ := parseTestFiles(t, "apiVersion: example.com/v2\n"+testVMYAML)
+	err := vm.Validate()
+	if err == nil || !strings.Contains(err.Error(), "vm.web-01.yaml:1:1: apiVersion: unsupported") {
+		t.Errorf("Validate = %v, want unsupported apiVersion at line 1", err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	for _, kind := range []string{KindVM, KindVCenter} {
+		data, err := JSONSchema(kind)
+		if err != nil {
+			t.Fatalf("JSONSchema(%s): %v", kind, err)
+		}
+		var s struct {
+			Properties map[string]struct {
+				Required   []string                  `json:"required"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("schema is not valid JSON: %v", err)
+		}
+		block := s.Properties[kind]
+		if len(block.Required) == 0 || len(block.Properties) == 0 {
+			t.Errorf("%s schema lacks properties or required keys:\n%s", kind, data)
+		}
+	}
+
+	data, _ := JSONSchema(KindVM)
+	var s map[string]any
+	_ = json.Unmarshal(data, &s)
+	vm := s["properties"].(map[string]any)["vm"].(map[string]any)["properties"].(map[string]any)
+	if vm["swap_size_gb"].(map[string]any)["type"] != "integer" {
+		t.Errorf("swap_size_gb schema = %v", vm["swap_size_gb"])
+	}
+	if _, err := JSONSchema("talos"); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// schemaRules adds constraints that the Go types alone cannot express.
+// Keys are dotted YAML paths as used in FieldError.Field.
+var schemaRules = map[string]map[string]any{
+	"extends":                             {"type": []string{"string", "array"}},
+	"vm":                                  {"required": []string{"name", "ip_address", "netmask", "gateway", "dns"}},
+	"vm.profile":                          {"enum": []string{"ubuntu", "talos"}},
+	"vm.cpus":                             {"minimum": 0},
+	"vm.memory_mb":                        {"minimum": 0},
+	"vm.disk_size_gb":                     {"minimum": 0},
+	"vm.data_disk_size_gb":                {"minimum": 0},
+	"vm.swap_size_gb":                     {"minimum": 0},
+	"vm.ssh_port":                         {"minimum": 0, "maximum": 65535},
+	"vm.timeout_minutes":                  {"minimum": 0},
+	"vm.options.install_timeout_minutes":  {"minimum": 0},
+	"vm.options.poll_interval_seconds":    {"minimum": 0},
+	"vm.options.hostname_checks":          {"minimum": 0},
+	"vm.options.ssh_retries":              {"minimum": 0},
+	"vm.options.download_timeout_minutes": {"minimum": 0},
+	"vm.installer.menu_timeout_seconds":   {"minimum": 0},
+	"vm.firmware":                         {"enum": []string{"efi", "bios"}},
+	"vm.ip_address":                       {"format": "ipv4"},
+	"vm.netmask":                          {"format": "ipv4"},
+	"vm.gateway":                          {"format": "ipv4"},
+	"vm.dns":                              {"format": "ipv4"},
+	"vm.dns2":                             {"format": "ipv4"},
+	"vm.hardware_version":                 {"pattern": `^vmx-[0-9]+$`},
+	"vm.latency_sensitivity":              {"enum": []string{"low", "normal", "medium", "high"}},
+	"vm.password_from":                    {"pattern": `^(env|exec|vault|keyring):.+`},
+	"vcenter":                             {"required": []string{"host", "username", "datacenter"}},
+	"vcenter.port":                        {"minimum": 0, "maximum": 65535},
+	"vcenter.password_from":               {"pattern": `^(env|exec|vault|keyring):.+`},
+	"vcenter.thumbprint":                  {"pattern": `^[0-9A-Fa-f: -]+$`},
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for the plaintext form of a
+// config file of the given kind (KindVM or KindVCenter). Editors and pre-commit
+// hooks can use it to check files before they reach the CLI.
+func JSONSchema(kind string) ([]byte, error) {
+	var t reflect.Type
+	switch kind {
+	case KindVM:
+		t = reflect.TypeOf(VMFile{})
+	case KindVCenter:
+		t = reflect.TypeOf(VCenterFile{})
+	default:
+		return nil, fmt.Errorf("unknown config kind %q (expected %s or %s)", kind, KindVM, KindVCenter)
+	}
+	s := schemaFor(t, "")
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["$id"] = "https://infrakit.io/schemas/vmbootstrap/" + kind + ".json"
+	s["title"] = "vmbootstrap " + kind + " config (" + APIVersion + ")"
+	if kind == KindVCenter {
+		// VM base files may hold only extends or classes, so vm: stays optional.
+		s["required"] = []string{kind}
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func schemaFor(t reflect.Type, path string) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.String:
+		s = map[string]any{"type": "string"}
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		s = map[string]any{"type": "integer"}
+	case reflect.Slice:
+		s = map[string]any{"type": "array", "items": schemaFor(t.Elem(), "")}
+	case reflect.Map:
+		s = map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), "")}
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			key := name
+			if path != "" {
+				key = path + "." + name
+			}
+			props[name] = schemaFor(f.Type, key)
+		}
+		s = map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	default:
+		s = map[string]any{}
+	}
+	if path == "apiVersion" {
+		s["enum"] = []string{APIVersion}
+	}
+	for k, v := range schemaRules[path] {
+		s[k] = v
+	}
+	return s
+}
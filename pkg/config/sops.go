@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	sopsconfig "github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/decrypt"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/version"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoSOPSConfig is returned by EncryptFile when no .sops.yaml is found above the target file.
+var ErrNoSOPSConfig = errors.New(".sops.yaml not found")
+
+// ErrNoCreationRule is returned by EncryptFile when .sops.yaml has no creation rule for the target file.
+var ErrNoCreationRule = errors.New("sops config has no matching creation rules")
+
+// IsEncrypted reports whether data is a SOPS-encrypted YAML document.
+func IsEncrypted(data []byte) bool {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	_, ok := doc["sops"]
+	return ok
+}
+
+// ReadFile returns the plaintext YAML of path.
+// SOPS-encrypted files are decrypted in-process (age keys from SOPS_AGE_KEY,
+// SOPS_AGE_KEY_FILE or the default sops key location); plain YAML is returned as is,
+// so CI can use unencrypted files with secrets taken from the environment.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	plain, err := decrypt.DataWithFormat(data, formats.Yaml)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", filepath.Base(path), err)
+	}
+	return plain, nil
+}
+
+// EncryptFile encrypts plaintext YAML with the keys of the .sops.yaml creation rule
+// matching path and writes the result to path (mode 0600).
+// .sops.yaml is looked up from the directory of path upwards, and path_regex is
+// matched against path relative to the .sops.yaml directory, as sops does.
+func EncryptFile(path string, plaintext []byte) error {
+	out, err := Encrypt(path, plaintext)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Encrypt returns plaintext YAML encrypted for path without writing it.
+func Encrypt(path string, plaintext []byte) ([]byte, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	confPath, err := sopsconfig.FindConfigFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("%w (searched from %s)", ErrNoSOPSConfig, filepath.Dir(abs))
+	}
+	rule, err := sopsconfig.LoadCreationRuleForFile(confPath, abs, nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "no matching creation rules") {
+			return nil, fmt.Errorf("%w for %s", ErrNoCreationRule, filepath.Base(path))
+		}
+		return nil, fmt.Errorf("load %s: %w", confPath, err)
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("%w for %s", ErrNoCreationRule, filepath.Base(path))
+	}
+
+	if len(bytes.TrimSpace(plaintext)) == 0 {
+		return nil, fmt.Errorf("%s: nothing to encrypt", filepath.Base(path))
+	}
+	store := common.StoreForFormat(formats.Yaml, sopsconfig.NewStoresConfig())
+	branches, err := store.LoadPlainFile(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("%s: nothing to encrypt", filepath.Base(path))
+	}
+	if store.HasSopsTopLevelKey(branches[0]) {
+		return nil, fmt.Errorf("%s is already encrypted", filepath.Base(path))
+	}
+
+	tree := sops.Tree{
+		Branches: branches,
+		Metadata: sops.Metadata{
+			KeyGroups:               rule.KeyGroups,
+			ShamirThreshold:         rule.ShamirThreshold,
+			UnencryptedSuffix:       rule.UnencryptedSuffix,
+			EncryptedSuffix:         rule.EncryptedSuffix,
+			UnencryptedRegex:        rule.UnencryptedRegex,
+			EncryptedRegex:          rule.EncryptedRegex,
+			UnencryptedCommentRegex: rule.UnencryptedCommentRegex,
+			EncryptedCommentRegex:   rule.EncryptedCommentRegex,
+			MACOnlyEncrypted:        rule.MACOnlyEncrypted,
+			Version:                 version.Version,
+		},
+		FilePath: abs,
+	}
+	dataKey, errs := tree.GenerateDataKeyWithKeyServices([]keyservice.KeyServiceClient{keyservice.NewLocalClient()})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("generate data key: %v", errors.Join(errs...))
+	}
+	if err := common.EncryptTree(common.EncryptTreeOpts{DataKey: dataKey, Tree: &tree, Cipher: aes.NewCipher()}); err != nil {
+		return nil, err
+	}
+	return store.EmitEncryptedFile(tree)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+// setupSOPS writes a .sops.yaml with a fresh age recipient for configs/*.sops.yaml
+// and exposes the matching identity through SOPS_AGE_KEY.
+func setupSOPS(t *testing.T) string {
+	t.Helper()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("generate age identity: %v", err)
+	}
+	dir := t.TempDir()
+	rules := "creation_rules:\n  - path_regex: configs/.*\\.sops\\.yaml$\n    age: " + id.Recipient().String() + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ".sops.yaml"), []byte(rules), 0o600); err != nil {
+		t.Fatalf("write .sops.yaml: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "configs"), 0o700); err != nil {
+		t.Fatalf("mkdir configs: %v", err)
+	}
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	t.Setenv("SOPS_AGE_KEY", id.String())
+	return dir
+}
+
+func TestEncryptFileAndReadFile(t *testing.T) {
+	dir := setupSOPS(t)
+	path := filepath.Join(dir, "configs", "vm.web01.sops.yaml")
+	plain := []byte("vm:\n  name: web01\n  password: s3cret\n")
+
+	if err := EncryptFile(path, plain); err != nil {
+		t.Fatalf("EncryptFile: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read encrypted file: %v", err)
+	}
+	if !IsEncrypted(raw) || strings.Contains(string(raw), "s3cret") {
+		t.Fatalf("file is not encrypted:\n%s", raw)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %04o, want 0600", info.Mode().Perm())
+	}
+
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var doc struct {
+		VM struct {
+			Name     string `yaml:"name"`
+			Password string `yaml:"password"`
+		} `yaml:"vm"`
+	}
+	if err := yaml.Unmarshal(got, &doc); err != nil {
+		t.Fatalf("parse decrypted YAML: %v", err)
+	}
+	if doc.VM.Name != "web01" || doc.VM.Password != "s3cret" {
+		t.Errorf("ReadFile = %q, want content of %q", got, plain)
+	}
+
+	// A different key cannot decrypt the file.
+	other, _ := age.GenerateX25519Identity()
+	t.Setenv("SOPS_AGE_KEY", other.String())
+	if _, err := ReadFile(path); err == nil {
+		t.Error("expected decrypt error with unrelated age key")
+	}
+}
+
+func TestEncryptFile_NoCreationRule(t *testing.T) {
+	dir := setupSOPS(t)
+	err := EncryptFile(filepath.Join(dir, "other.yaml"), []byte("a: 1\n"))
+	if !errors.Is(err, ErrNoCreationRule) {
+		t.Fatalf("expected ErrNoCreationRule, got %v", err)
+	}
+}
+
+func TestLoadVMFile_PlainYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm.ci.yaml")
+	content := "vm:\n  name: ci-vm\n  cpus: 2\n  password_from: env:CI_VM_PASSWORD\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	f, err := LoadVMFile(path)
+	if err != nil {
+		t.Fatalf("LoadVMFile: %v", err)
+	}
+	if f.VM.Name != "ci-vm" || f.VM.CPUs != 2 || f.VM.PasswordFrom != "env:CI_VM_PASSWORD" {
+		t.Fatalf("unexpected VM file: %+v", f.VM)
+	}
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a config error tied to a key of a YAML file.
+// Line and Column are 1-based; they are zero when the key is absent from the file.
+type FieldError struct {
+	File   string
+	Line   int
+	Column int
+	Field  string // dotted key path, e.g. vm.ip_address
+	Msg    string
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d", e.Line)
+		}
+		if e.Line > 0 && e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+		b.WriteString(": ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// ValidationError collects all FieldErrors found in a config file.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// position is the location of a YAML key.
+type position struct {
+	file         string
+	line, column int
+}
+
+// source remembers where a config was parsed from so errors can point at file:line.
+// With extends, keys can come from different files.
+type source struct {
+	file string
+	pos  map[string]position // dotted key path -> location
+}
+
+// at returns a FieldError for field. An absent key is reported at its closest
+// present parent, so "vm.name: is required" still points into the vm block.
+func (s *source) at(field, format string, args ...any) *FieldError {
+	fe := &FieldError{File: s.file, Field: field, Msg: fmt.Sprintf(format, args...)}
+	for key := field; key != ""; {
+		if p, ok := s.pos[key]; ok {
+			fe.File, fe.Line, fe.Column = p.file, p.line, p.column
+			break
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return fe
+}
+
+// fieldErrors accumulates FieldErrors while validating a file.
+type fieldErrors struct {
+	src  *source
+	list []*FieldError
+}
+
+func (f *fieldErrors) add(field, format string, args ...any) {
+	f.list = append(f.list, f.src.at(field, format, args...))
+}
+
+func (f *fieldErrors) err() error {
+	if len(f.list) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: f.list}
+}
+
+var yamlLineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// decodeStrict decodes data into out, rejecting unknown keys, and records the
+// position of every mapping key. It returns the top-level mapping node (empty for
+// an empty document). YAML syntax and type errors are returned as a
+// ValidationError with file:line positions.
+func decodeStrict(file string, data []byte, out any) (*yaml.Node, *source, error) {
+	src := &source{file: file, pos: map[string]position{}}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, yamlError(file, err)
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if len(root.Content) > 0 && root.Content[0].Kind == yaml.MappingNode {
+		doc = root.Content[0]
+		collectPositions(doc, "", file, src.pos)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, yamlError(file, err)
+	}
+	return doc, src, nil
+}
+
+func collectPositions(n *yaml.Node, prefix, file string, pos map[string]position) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		key := k.Value
+		if prefix != "" {
+			key = prefix + "." + k.Value
+		}
+		pos[key] = position{file: file, line: k.Line, column: k.Column}
+		collectPositions(v, key, file, pos)
+	}
+}
+
+// yamlError converts yaml.v3 errors ("line N: ...") into a positioned ValidationError.
+func yamlError(file string, err error) error {
+	var msgs []string
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		msgs = te.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	ve := &ValidationError{}
+	for _, msg := range msgs {
+		fe := &FieldError{File: file, Msg: msg}
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			fe.Line, _ = strconv.Atoi(m[1])
+			fe.Msg = m[2]
+		}
+		ve.Errors = append(ve.Errors, fe)
+	}
+	return ve
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vcenter"
+)
+
+// VCenterFile is the YAML structure of vcenter.sops.yaml.
+type VCenterFile struct {
+	APIVersion string      `yaml:"apiVersion,omitempty"` // empty for files written before versioning
+	VCenter    VCenterSpec `yaml:"vcenter"`
+
+	src *source
+}
+
+// VCenterSpec is the vcenter: block of a vCenter file.
+type VCenterSpec struct {
+	Host             string `yaml:"host"`
+	Username         string `yaml:"username"`
+	Password         string `yaml:"password"`
+	PasswordFrom     string `yaml:"password_from,omitempty"` // credential reference used when password is empty
+	Datacenter       string `yaml:"datacenter"`
+	ContentLibrary   string `yaml:"content_library,omitempty"`
+	ContentLibraryID string `yaml:"content_library_id,omitempty"`
+	ISODatastore     string `yaml:"iso_datastore"`
+	Folder           string `yaml:"folder"`        // default VM folder for new VMs
+	ResourcePool     string `yaml:"resource_pool"` // default resource pool for new VMs
+	Network          string `yaml:"network"`       // default network for new VMs
+	Port             int    `yaml:"port"`
+	Insecure         bool   `yaml:"insecure"`
+	Thumbprint       string `yaml:"thumbprint,omitempty"`   // pinned SHA-256/SHA-1 certificate thumbprint
+	CACertFile       string `yaml:"ca_cert_file,omitempty"` // PEM CA bundle instead of system roots
+	CACertPEM        string `yaml:"ca_cert_pem,omitempty"`  // inline PEM CA bundle
+}
+
+// LoadVCenterFile reads a vCenter config file, decrypting it with SOPS when it is encrypted.
+// Unknown keys are rejected. The file is only parsed; call Validate to check its values.
+func LoadVCenterFile(path string) (*VCenterFile, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVCenterFile(path, data)
+}
+
+// ParseVCenterFile parses plaintext vCenter file YAML. name is used in error positions.
+func ParseVCenterFile(name string, data []byte) (*VCenterFile, error) {
+	var f VCenterFile
+	_, src, err := decodeStrict(name, data, &f)
+	if err != nil {
+		return nil, err
+	}
+	f.src = src
+	return &f, nil
+}
+
+// Validate checks the vCenter file and returns a *ValidationError with file:line positions.
+func (f *VCenterFile) Validate() error {
+	src := f.src
+	if src == nil {
+		src = &source{}
+	}
+	errs := &fieldErrors{src: src}
+	checkAPIVersion(errs, f.APIVersion)
+	v := &f.VCenter
+
+	if strings.TrimSpace(v.Host) == "" {
+		errs.add("vcenter.host", "is required")
+	}
+	if v.Username == "" {
+		errs.add("vcenter.username", "is required")
+	}
+	if v.Password == "" && v.PasswordFrom == "" {
+		errs.add("vcenter.password", "either vcenter.password or vcenter.password_from is required")
+	}
+	if v.PasswordFrom != "" {
+		if _, err := PasswordProvider(v.PasswordFrom); err != nil {
+			errs.add("vcenter.password_from", "%v", err)
+		}
+	}
+	if v.Datacenter == "" {
+		errs.add("vcenter.datacenter", "is required")
+	}
+	if v.Port < 0 || v.Port > 65535 {
+		errs.add("vcenter.port", "must be in range 1..65535 (got %d)", v.Port)
+	}
+	if v.Thumbprint != "" {
+		if _, err := vcenter.NormalizeThumbprint(v.Thumbprint); err != nil {
+			errs.add("vcenter.thumbprint", "%v", err)
+		}
+	}
+	if v.Insecure && (v.Thumbprint != "" || v.CACertFile != "" || v.CACertPEM != "") {
+		errs.add("vcenter.insecure", "cannot be combined with thumbprint, ca_cert_file or ca_cert_pem")
+	}
+	return errs.err()
+}
+
+// ClientConfig returns the vcenter.Config for connecting with these settings.
+func (f *VCenterFile) ClientConfig() (*vcenter.Config, error) {
+	v := f.VCenter
+	provider, err := PasswordProvider(v.PasswordFrom)
+	if err != nil {
+		return nil, fmt.Errorf("vcenter.password_from: %w", err)
+	}
+	return &vcenter.Config{
+		Host:             v.Host,
+		Username:         v.Username,
+		Password:         v.Password,
+		PasswordProvider: provider,
+		Port:             v.Port,
+		Insecure:         v.Insecure,
+		Thumbprint:       v.Thumbprint,
+		CACertFile:       v.CACertFile,
+		CACertPEM:        v.CACertPEM,
+	}, nil
+}
+
+// PasswordProvider parses a password_from credential reference (nil when unset).
+// The secret itself is only resolved when a connection or provisioning step needs it.
+func PasswordProvider(ref string) (credentials.Provider, error) {
+	if strings.TrimSpace(ref) == "" {
+		return nil, nil
+	}
+	return credentials.Parse(ref)
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+)
+
+// defaultTimeoutMinutes is the overall deadline when vm.timeout_minutes is unset.
+const defaultTimeoutMinutes = 45
+
+// LoadVMConfig loads, validates and converts a VM file and a vCenter file into a
+// bootstrap.VMConfig, exactly as `vmbootstrap run` does. Both files may be
+// SOPS-encrypted or plain YAML. Validation problems of both files are reported
+// together as a *ValidationError.
+func LoadVMConfig(vmPath, vcenterPath string) (*bootstrap.VMConfig, error) {
+	vc, err := LoadVCenterFile(vcenterPath)
+	if err != nil {
+		return nil, err
+	}
+	vm, err := LoadVMFile(vmPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAll(vc.Validate(), vm.Validate()); err != nil {
+		return nil, err
+	}
+	return ToVMConfig(vm, vc)
+}
+
+func validateAll(errs ...error) error {
+	all := &ValidationError{}
+	for _, err := range errs {
+		var ve *ValidationError
+		if errors.As(err, &ve) {
+			all.Errors = append(all.Errors, ve.Errors...)
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(all.Errors) == 0 {
+		return nil
+	}
+	return all
+}
+
+// ToVMConfig converts a VM file and a vCenter file into a bootstrap.VMConfig.
+//
+// The mapping follows the CLI: dns and dns2 become DNS, the SSH public key is read
+// from ssh_key_path (or taken from ssh_key), the data disk is only set when
+// data_disk_size_gb > 0, and password_from references become credential providers.
+// Guest access settings are only applied for the ubuntu profile.
+// ToVMConfig does not call Validate; bootstrap validates the result before use.
+func ToVMConfig(vm *VMFile, vc *VCenterFile) (*bootstrap.VMConfig, error) {
+	v := &vm.VM
+	c := &vc.VCenter
+
+	vcPassword, err := PasswordProvider(c.PasswordFrom)
+	if err != nil {
+		return nil, fmt.Errorf("vcenter.password_from: %w", err)
+	}
+
+	cfg := &bootstrap.VMConfig{
+		VCenterHost:     c.Host,
+		VCenterUsername: c.Username,
+		VCenterPassword: c.Password,
+		VCenterPort:     c.Port,
+		VCenterInsecure: c.Insecure,
+
+		VCenterPasswordProvider: vcPassword,
+		VCenterThumbprint:       c.Thumbprint,
+		VCenterCACertFile:       c.CACertFile,
+		VCenterCACertPEM:        c.CACertPEM,
+
+		Name:       v.Name,
+		CPUs:       v.CPUs,
+		MemoryMB:   v.MemoryMB,
+		DiskSizeGB: v.DiskSizeGB,
+		Profile:    v.EffectiveProfile(),
+
+		NetworkName:      v.NetworkName,
+		NetworkInterface: v.NetworkInterface,
+		IPAddress:        v.IPAddress,
+		Netmask:          v.Netmask,
+		Gateway:          v.Gateway,
+		DNS:              buildDNS(v.DNS, v.DNS2),
+
+		Datacenter:       c.Datacenter,
+		Folder:           v.Folder,
+		ResourcePool:     v.ResourcePool,
+		Datastore:        v.Datastore,
+		ISODatastore:     c.ISODatastore,
+		ContentLibrary:   c.ContentLibrary,
+		ContentLibraryID: c.ContentLibraryID,
+
+		CoresPerSocket:      v.CoresPerSocket,
+		CPUHotAdd:           v.CPUHotAdd,
+		MemoryHotAdd:        v.MemoryHotAdd,
+		CPUReservationMHz:   v.CPUReservationMHz,
+		CPULimitMHz:         v.CPULimitMHz,
+		CPUShares:           v.CPUShares,
+		MemoryReservationMB: v.MemoryReservationMB,
+		MemoryLimitMB:       v.MemoryLimitMB,
+		MemoryShares:        v.MemoryShares,
+		LatencySensitivity:  v.LatencySensitivity,
+
+		Firmware:         v.Firmware,
+		GuestID:          v.GuestID,
+		HardwareVersion:  v.HardwareVersion,
+		ExtraConfig:      v.ExtraConfig,
+		SecureBoot:       v.SecureBoot,
+		VTPM:             v.VTPM,
+		EncryptionPolicy: v.EncryptionPolicy,
+
+		InstallerKernelArgs:  v.Installer.KernelArgs,
+		InstallerMenuDefault: v.Installer.MenuDefault,
+		InstallerMenuTimeout: v.Installer.MenuTimeoutSeconds,
+		CloudInit:            v.CloudInit.extensions(),
+
+		Options: v.bootstrapOptions(),
+	}
+	cfg.Profiles.Ubuntu.Version = v.Profiles.Ubuntu.Version
+	cfg.Profiles.Talos.Version = v.Profiles.Talos.Version
+	cfg.Profiles.Talos.SchematicID = v.Profiles.Talos.SchematicID
+
+	if cfg.Profile == "ubuntu" {
+		sshKey, err := v.sshPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("vm.ssh_key_path: %w", err)
+		}
+		if sshKey == "" {
+			return nil, fmt.Errorf("either vm.ssh_key or vm.ssh_key_path is required for ubuntu profile")
+		}
+		guestPassword, err := PasswordProvider(v.PasswordFrom)
+		if err != nil {
+			return nil, fmt.Errorf("vm.password_from: %w", err)
+		}
+		cfg.Username = v.Username
+		cfg.SSHPublicKeys = []string{sshKey}
+		cfg.Password = v.Password
+		cfg.PasswordProvider = guestPassword
+		cfg.AllowPasswordSSH = v.AllowPasswordSSH
+	}
+
+	if v.DataDiskSizeGB > 0 {
+		size := v.DataDiskSizeGB
+		cfg.DataDiskSizeGB = &size
+		cfg.DataDiskMountPath = v.DataDiskMountPath
+	}
+	if v.SwapSizeGB != nil {
+		size := *v.SwapSizeGB
+		cfg.SwapSizeGB = &size
+	}
+	return cfg, nil
+}
+
+func buildDNS(primary, secondary string) []string {
+	if secondary != "" {
+		return []string{primary, secondary}
+	}
+	return []string{primary}
+}
+
+// bootstrapOptions converts timeout_minutes and vm.options into per-call
+// bootstrap options. timeout_minutes bounds the whole call and defaults to 45.
+func (v *VMSpec) bootstrapOptions() bootstrap.Options {
+	timeout := v.TimeoutMinutes
+	if timeout == 0 {
+		timeout = defaultTimeoutMinutes
+	}
+	o := v.Options
+	return bootstrap.Options{
+		Timeout:         time.Duration(timeout) * time.Minute,
+		InstallTimeout:  time.Duration(o.InstallTimeoutMinutes) * time.Minute,
+		PollInterval:    time.Duration(o.PollIntervalSeconds) * time.Second,
+		HostnameChecks:  o.HostnameChecks,
+		SSHRetries:      o.SSHRetries,
+		DownloadTimeout: time.Duration(o.DownloadTimeoutMinutes) * time.Minute,
+	}
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/bootstrap"
+)
+
+const testVCenterYAML = `vcenter:
+  host: vcenter.example.com
+  username: administrator@vsphere.local
+  password_from: env:VC_PASSWORD
+  datacenter: DC1
+  iso_datastore: SSD01
+  port: 443
+  insecure: false
+`
+
+const testVMYAML = `vm:
+  name: web-01
+  cpus: 2
+  memory_mb: 4096
+  disk_size_gb: 40
+  data_disk_size_gb: 100
+  data_disk_mount_path: /data
+  username: sysadmin
+  ssh_key: ssh-ed25519 AAAA test
+  ip_address: 192.168.1.10
+  netmask: 255.255.255.0
+  gateway: 192.168.1.1
+  dns: 8.8.8.8
+  dns2: 1.1.1.1
+  datastore: SSD01
+  network_name: LAN
+  timeout_minutes: 30
+  profiles:
+    ubuntu:
+      version: "24.04"
+`
+
+func parseTestFiles(t *testing.T, vmYAML string) (*VMFile, *VCenterFile) {
+	t.Helper()
+	vm, err := ParseVMFile("vm.web-01.yaml", []byte(vmYAML))
+	if err != nil {
+		t.Fatalf("ParseVMFile: %v", err)
+	}
+	vc, err := ParseVCenterFile("vcenter.yaml", []byte(testVCenterYAML))
+	if err != nil {
+		t.Fatalf("ParseVCenterFile: %v", err)
+	}
+	return vm, vc
+}
+
+func TestToVMConfig(t *testing.T) {
+	vm, vc := parseTestFiles(t, testVMYAML)
+	if err := vm.Validate(); err != nil {
+		t.Fatalf("VM Validate: %v", err)
+	}
+	if err := vc.Validate(); err != nil {
+		t.Fatalf("vCenter Validate: %v", err)
+	}
+
+	cfg, err := ToVMConfig(vm, vc)
+	if err != nil {
+		t.Fatalf("ToVMConfig: %v", err)
+	}
+	if cfg.Profile != "ubuntu" || cfg.Datacenter != "DC1" || cfg.VCenterHost != "vcenter.example.com" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.DNS, []string{"8.8.8.8", "1.1.1.1"}) {
+		t.Errorf("DNS = %v", cfg.DNS)
+	}
+	if !reflect.DeepEqual(cfg.SSHPublicKeys, []string{"ssh-ed25519 AAAA test"}) {
+		t.Errorf("SSHPublicKeys = %v", cfg.SSHPublicKeys)
+	}
+	if cfg.DataDiskSizeGB == nil || *cfg.DataDiskSizeGB != 100 || cfg.DataDiskMountPath != "/data" {
+		t.Errorf("data disk = %v %q", cfg.DataDiskSizeGB, cfg.DataDiskMountPath)
+	}
+	if cfg.SwapSizeGB != nil {
+		t.Errorf("SwapSizeGB = %d, want nil (profile default)", *cfg.SwapSizeGB)
+	}
+	if cfg.VCenterPasswordProvider == nil {
+		t.Error("VCenterPasswordProvider not set from password_from")
+	}
+	if cfg.Profiles.Ubuntu.Version != "24.04" {
+		t.Errorf("Ubuntu version = %q", cfg.Profiles.Ubuntu.Version)
+	}
+}
+
+func TestToVMConfig_SwapAndKeyPath(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id.pub")
+	if err := os.WriteFile(keyPath, []byte("ssh-ed25519 BBBB file\n"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	doc := strings.Replace(testVMYAML, "  ssh_key: ssh-ed25519 AAAA test\n",
+		"  ssh_key_path: "+keyPath+"\n  swap_size_gb: 0\n", 1)
+	vm, vc := parseTestFiles(t, doc)
+
+	cfg, err := ToVMConfig(vm, vc)
+	if err != nil {
+		t.Fatalf("ToVMConfig: %v", err)
+	}
+	if cfg.SwapSizeGB == nil || *cfg.SwapSizeGB != 0 {
+		t.Errorf("SwapSizeGB = %v, want explicit 0", cfg.SwapSizeGB)
+	}
+	if vm.VM.EffectiveSwapSizeGB() != 0 {
+		t.Errorf("EffectiveSwapSizeGB = %d, want 0", vm.VM.EffectiveSwapSizeGB())
+	}
+	if !reflect.DeepEqual(cfg.SSHPublicKeys, []string{"ssh-ed25519 BBBB file"}) {
+		t.Errorf("SSHPublicKeys = %v", cfg.SSHPublicKeys)
+	}
+}
+
+func TestToVMConfig_Options(t *testing.T) {
+	doc := strings.Replace(testVMYAML, "  timeout_minutes: 30\n",
+		"  timeout_minutes: 30\n  options:\n    install_timeout_minutes: 20\n    ssh_retries: 10\n", 1)
+	vm, vc := parseTestFiles(t, doc)
+
+	cfg, err := ToVMConfig(vm, vc)
+	if err != nil {
+		t.Fatalf("ToVMConfig: %v", err)
+	}
+	want := bootstrap.Options{Timeout: 30 * time.Minute, InstallTimeout: 20 * time.Minute, SSHRetries: 10}
+	if cfg.Options != want {
+		t.Errorf("Options = %+v, want %+v", cfg.Options, want)
+	}
+
+	vm.VM.TimeoutMinutes = 0
+	if cfg, _ := ToVMConfig(vm, vc); cfg.Options.Timeout != 45*time.Minute {
+		t.Errorf("default Timeout = %s, want 45m", cfg.Options.Timeout)
+	}
+
+	vm.VM.Options.SSHRetries = -1
+	if err := vm.Validate(); err == nil || !strings.Contains(err.Error(), "vm.options.ssh_retries") {
+		t.Errorf("Validate = %v, want vm.options.ssh_retries error", err)
+	}
+}
+
+func TestToVMConfig_Installer(t *testing.T) {
+	doc := strings.Replace(testVMYAML, "  timeout_minutes: 30\n",
+		"  timeout_minutes: 30\n  installer:\n    kernel_args: [\"console=ttyS0,115200\", ip=dhcp]\n    menu_timeout_seconds: 0\n", 1)
+	vm, vc := parseTestFiles(t, doc)
+
+	cfg, err := ToVMConfig(vm, vc)
+	if err != nil {
+		t.Fatalf("ToVMConfig: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.InstallerKernelArgs, []string{"console=ttyS0,115200", "ip=dhcp"}) {
+		t.Errorf("InstallerKernelArgs = %v", cfg.InstallerKernelArgs)
+	}
+	if cfg.InstallerMenuTimeout == nil || *cfg.InstallerMenuTimeout != 0 {
+		t.Errorf("InstallerMenuTimeout = %v, want explicit 0", cfg.InstallerMenuTimeout)
+	}
+
+	vm.VM.Installer.KernelArgs = append(vm.VM.Installer.KernelArgs, "quiet splash")
+	if err := vm.Validate(); err == nil || !strings.Contains(err.Error(), "vm.installer") {
+		t.Errorf("Validate = %v, want vm.installer error", err)
+	}
+}
+
+func TestToVMConfig_CloudInit(t *testing.T) {
+	doc := strings.Replace(testVMYAML, "  timeout_minutes: 30\n",
+		"  timeout_minutes: 30\n  cloud_init:\n    packages: [htop]\n    write_files:\n      - path: /etc/motd\n        content: hi\n        permissions: \"0644\"\n    late_commands: [\"curtin in-target -- true\"]\n    user_data: |\n      ntp:\n        enabled: true\n", 1)
+	vm, vc := parseTestFiles(t, doc)
+
+	cfg, err := ToVMConfig(vm, vc)
+	if err != nil {
+		t.Fatalf("ToVMConfig: %v", err)
+	}
+	ci := cfg.CloudInit
+	if !reflect.DeepEqual(ci.Packages, []string{"htop"}) || len(ci.WriteFiles) != 1 || ci.WriteFiles[0].Permissions != "0644" {
+		t.Errorf("CloudInit = %+v", ci)
+	}
+	if len(ci.LateCommands) != 1 || !strings.Contains(ci.UserData, "ntp:") {
+		t.Errorf("CloudInit = %+v", ci)
+	}
+
+	vm.VM.CloudInit.WriteFiles[0].Path = "etc/motd"
+	if err := vm.Validate(); err == nil || !strings.Contains(err.Error(), "vm.cloud_init") {
+		t.Errorf("Validate = %v, want vm.cloud_init error", err)
+	}
+	vm.VM.CloudInit.WriteFiles[0].Path = "/etc/motd"
+	vm.VM.Profile = "talos"
+	if err := vm.Validate(); err == nil || !strings.Contains(err.Error(), "only supported by the ubuntu profile") {
+		t.Errorf("Validate = %v, want ubuntu-only error", err)
+	}
+}
+
+func TestParseVMFile_UnknownKey(t *testing.T) {
+	doc := strings.Replace(testVMYAML, "  cpus: 2\n", "  cpus: 2\n  cpu: 4\n", 1)
+	_, err := ParseVMFile("vm.web-01.yaml", []byte(doc))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Errors) != 1 {
+		t.Fatalf("expected one ValidationError entry, got %v", err)
+	}
+	if got := ve.Errors[0]; got.Line != 4 || !strings.HasPrefix(got.Error(), "vm.web-01.yaml:4: ") {
+		t.Errorf("error = %q (line %d), want position vm.web-01.yaml:4", got.Error(), got.Line)
+	}
+}
+
+func TestVMFileValidate_Positions(t *testing.T) {
+	doc := strings.NewReplacer(
+		"  ip_address: 192.168.1.10\n", "  ip_address: 192.168.1.300\n",
+		"  data_disk_mount_path: /data\n", "",
+		"  ssh_key: ssh-ed25519 AAAA test\n", "",
+	).Replace(testVMYAML)
+	vm, _ := parseTestFiles(t, doc)
+
+	err := vm.Validate()
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	// A missing key is reported at its closest present parent (vm:).
+	want := []string{
+		"vm.web-01.yaml:1:1: vm.data_disk_mount_path: is required",
+		"vm.web-01.yaml:8:3: vm.ip_address: invalid IPv4 address",
+		"vm.web-01.yaml:1:1: vm.ssh_key: either",
+	}
+	if len(ve.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(ve.Errors), len(want), err)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(ve.Errors[i].Error(), prefix) {
+			t.Errorf("error[%d] = %q, want prefix %q", i, ve.Errors[i].Error(), prefix)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/cloudinit"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	"gopkg.in/yaml.v3"
+)
+
+// VMFile is the YAML structure of vm.*.sops.yaml files.
+// It is shared by the CLI wizard (which writes it) and the loaders below.
+//
+// Extends and Classes are only set on a file as written; LoadVMFile and
+// ParseVMFile return the effective file with them (and VM.Class) resolved.
+type VMFile struct {
+	APIVersion string            `yaml:"apiVersion,omitempty"` // empty for files written before versioning
+	Extends    StringList        `yaml:"extends,omitempty"`    // base files merged beneath this one, in order
+	Classes    map[string]VMSpec `yaml:"classes,omitempty"`    // size classes for vm.class, beside configs/defaults.yaml
+	VM         VMSpec            `yaml:"vm"`
+
+	src *source
+}
+
+// VMSpec is the vm: block of a VM file.
+type VMSpec struct {
+	Name                string            `yaml:"name"`
+	Class               string            `yaml:"class,omitempty"` // size class merged beneath this block
+	Profile             string            `yaml:"profile,omitempty"`
+	CPUs                int               `yaml:"cpus"`
+	MemoryMB            int               `yaml:"memory_mb"`
+	DiskSizeGB          int               `yaml:"disk_size_gb"`
+	DataDiskSizeGB      int               `yaml:"data_disk_size_gb,omitempty"`
+	DataDiskMountPath   string            `yaml:"data_disk_mount_path,omitempty"`
+	SwapSizeGB          *int              `yaml:"swap_size_gb,omitempty"` // nil = default from configs/defaults.yaml, 0 = no swap
+	Username            string            `yaml:"username"`
+	SSHKeyPath          string            `yaml:"ssh_key_path,omitempty"`
+	SSHKey              string            `yaml:"ssh_key,omitempty"`
+	Password            string            `yaml:"password,omitempty"`
+	PasswordFrom        string            `yaml:"password_from,omitempty"` // credential reference used when password is empty
+	AllowPasswordSSH    bool              `yaml:"allow_password_ssh,omitempty"`
+	SSHPort             int               `yaml:"ssh_port,omitempty"`
+	IPAddress           string            `yaml:"ip_address"`
+	Netmask             string            `yaml:"netmask"`
+	Gateway             string            `yaml:"gateway"`
+	DNS                 string            `yaml:"dns"`
+	DNS2                string            `yaml:"dns2,omitempty"`
+	Datastore           string            `yaml:"datastore,omitempty"`
+	NetworkName         string            `yaml:"network_name,omitempty"`
+	NetworkInterface    string            `yaml:"network_interface,omitempty"`
+	Folder              string            `yaml:"folder,omitempty"`
+	ResourcePool        string            `yaml:"resource_pool,omitempty"`
+	TimeoutMinutes      int               `yaml:"timeout_minutes"` // overall deadline of run/smoke (0 = 45)
+	Options             VMOptions         `yaml:"options,omitempty"`
+	CoresPerSocket      int               `yaml:"cores_per_socket,omitempty"`
+	CPUHotAdd           bool              `yaml:"cpu_hot_add,omitempty"`
+	MemoryHotAdd        bool              `yaml:"memory_hot_add,omitempty"`
+	CPUReservationMHz   int               `yaml:"cpu_reservation_mhz,omitempty"`
+	CPULimitMHz         int               `yaml:"cpu_limit_mhz,omitempty"`
+	CPUShares           string            `yaml:"cpu_shares,omitempty"`
+	MemoryReservationMB int               `yaml:"memory_reservation_mb,omitempty"`
+	MemoryLimitMB       int               `yaml:"memory_limit_mb,omitempty"`
+	MemoryShares        string            `yaml:"memory_shares,omitempty"`
+	LatencySensitivity  string            `yaml:"latency_sensitivity,omitempty"`
+	Firmware            string            `yaml:"firmware,omitempty"`
+	GuestID             string            `yaml:"guest_id,omitempty"`
+	HardwareVersion     string            `yaml:"hardware_version,omitempty"`
+	ExtraConfig         map[string]string `yaml:"extra_config,omitempty"`
+	SecureBoot          bool              `yaml:"secure_boot,omitempty"`
+	VTPM                bool              `yaml:"vtpm,omitempty"`
+	EncryptionPolicy    string            `yaml:"encryption_policy,omitempty"`
+	Installer           VMInstaller       `yaml:"installer,omitempty"`
+	CloudInit           VMCloudInit       `yaml:"cloud_init,omitempty"`
+	Profiles            struct {
+		Ubuntu struct {
+			Version string `yaml:"version,omitempty"`
+		} `yaml:"ubuntu,omitempty"`
+		Talos struct {
+			Version     string `yaml:"version,omitempty"`
+			SchematicID string `yaml:"schematic_id,omitempty"`
+		} `yaml:"talos,omitempty"`
+	} `yaml:"profiles,omitempty"`
+}
+
+// VMOptions is the vm.options: block: per-VM timeouts and retries.
+// Zero values fall back to timeouts: in configs/defaults.yaml.
+type VMOptions struct {
+	InstallTimeoutMinutes  int `yaml:"install_timeout_minutes,omitempty"`
+	PollIntervalSeconds    int `yaml:"poll_interval_seconds,omitempty"`
+	HostnameChecks         int `yaml:"hostname_checks,omitempty"`
+	SSHRetries             int `yaml:"ssh_retries,omitempty"`
+	DownloadTimeoutMinutes int `yaml:"download_timeout_minutes,omitempty"`
+}
+
+// VMInstaller is the vm.installer: block: the Ubuntu installer boot menu and
+// kernel command line of the remastered ISO.
+type VMInstaller struct {
+	KernelArgs         []string `yaml:"kernel_args,omitempty"`          // e.g. console=ttyS0,115200, ip=dhcp
+	MenuDefault        string   `yaml:"menu_default,omitempty"`         // entry index or title (default 0)
+	MenuTimeoutSeconds *int     `yaml:"menu_timeout_seconds,omitempty"` // nil = iso.grub_timeout_seconds
+}
+
+// remasterOptions returns the installer settings as ISO remaster options.
+func (i VMInstaller) remasterOptions() iso.RemasterOptions {
+	return iso.RemasterOptions{KernelArgs: i.KernelArgs, MenuDefault: i.MenuDefault, MenuTimeout: i.MenuTimeoutSeconds}
+}
+
+// VMCloudInit is the vm.cloud_init: block: additions merged into the
+// generated Ubuntu autoinstall user-data.
+type VMCloudInit struct {
+	Packages     []string              `yaml:"packages,omitempty"`
+	WriteFiles   []cloudinit.WriteFile `yaml:"write_files,omitempty"`
+	BootCmd      []string              `yaml:"bootcmd,omitempty"`
+	RunCmd       []string              `yaml:"runcmd,omitempty"`
+	LateCommands []string              `yaml:"late_commands,omitempty"` // run by the installer, target at /target
+	UserData     string                `yaml:"user_data,omitempty"`     // raw cloud-config fragment, deep-merged
+}
+
+// extensions returns the block as cloud-init extensions.
+func (c VMCloudInit) extensions() cloudinit.Extensions {
+	return cloudinit.Extensions{
+		Packages:     c.Packages,
+		WriteFiles:   c.WriteFiles,
+		BootCmd:      c.BootCmd,
+		RunCmd:       c.RunCmd,
+		LateCommands: c.LateCommands,
+		UserData:     c.UserData,
+	}
+}
+
+// EffectiveProfile returns the OS profile, defaulting to ubuntu.
+func (v *VMSpec) EffectiveProfile() string {
+	if p := strings.TrimSpace(v.Profile); p != "" {
+		return p
+	}
+	return "ubuntu"
+}
+
+// EffectiveSwapSizeGB returns the swap size the VM will get.
+func (v *VMSpec) EffectiveSwapSizeGB() int {
+	if v.SwapSizeGB != nil {
+		return *v.SwapSizeGB
+	}
+	return configs.Defaults.CloudInit.SwapSizeGB
+}
+
+// LoadVMFile reads a VM config file, decrypting it with SOPS when it is encrypted.
+// Plain (unencrypted) YAML is accepted as well. Unknown keys are rejected.
+// Base files named in extends and the size class in vm.class are deep-merged
+// beneath the file. The file is only parsed; call Validate to check its values.
+func LoadVMFile(path string) (*VMFile, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVMFile(path, data)
+}
+
+// ParseVMFile parses plaintext VM file YAML. name is used in error positions and
+// to resolve relative extends paths.
+func ParseVMFile(name string, data []byte) (*VMFile, error) {
+	doc, src, err := resolveVM(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyClass(doc, src); err != nil {
+		return nil, err
+	}
+	var f VMFile
+	if err := doc.Decode(&f); err != nil {
+		return nil, yamlError(name, err)
+	}
+	f.src = src
+	return &f, nil
+}
+
+// Render returns the effective VM file as YAML, with extends and classes resolved.
+func (f *VMFile) Render() ([]byte, error) {
+	return yaml.Marshal(f)
+}
+
+// Validate checks the VM file for errors that can be detected without vCenter.
+// It returns a *ValidationError listing every problem with its file:line position.
+func (f *VMFile) Validate() error {
+	src := f.src
+	if src == nil {
+		src = &source{}
+	}
+	errs := &fieldErrors{src: src}
+	checkAPIVersion(errs, f.APIVersion)
+	v := &f.VM
+
+	if strings.TrimSpace(v.Name) == "" {
+		errs.add("vm.name", "is required")
+	}
+	profile := v.EffectiveProfile()
+	if profile != "ubuntu" && profile != "talos" {
+		errs.add("vm.profile", "unsupported profile %q (supported: ubuntu, talos)", v.Profile)
+	}
+
+	for _, n := range []struct {
+		field string
+		value int
+	}{
+		{"vm.cpus", v.CPUs},
+		{"vm.memory_mb", v.MemoryMB},
+		{"vm.disk_size_gb", v.DiskSizeGB},
+		{"vm.data_disk_size_gb", v.DataDiskSizeGB},
+		{"vm.timeout_minutes", v.TimeoutMinutes},
+		{"vm.options.install_timeout_minutes", v.Options.InstallTimeoutMinutes},
+		{"vm.options.poll_interval_seconds", v.Options.PollIntervalSeconds},
+		{"vm.options.hostname_checks", v.Options.HostnameChecks},
+		{"vm.options.ssh_retries", v.Options.SSHRetries},
+		{"vm.options.download_timeout_minutes", v.Options.DownloadTimeoutMinutes},
+	} {
+		if n.value < 0 {
+			errs.add(n.field, "must not be negative (got %d)", n.value)
+		}
+	}
+	if v.SwapSizeGB != nil && *v.SwapSizeGB < 0 {
+		errs.add("vm.swap_size_gb", "must not be negative (got %d)", *v.SwapSizeGB)
+	}
+	if v.SSHPort < 0 || v.SSHPort > 65535 {
+		errs.add("vm.ssh_port", "must be in range 1..65535 (got %d)", v.SSHPort)
+	}
+	if v.DataDiskSizeGB > 0 {
+		if v.DataDiskMountPath == "" {
+			errs.add("vm.data_disk_mount_path", "is required when data_disk_size_gb is set")
+		} else if !path.IsAbs(v.DataDiskMountPath) {
+			errs.add("vm.data_disk_mount_path", "must be an absolute path (got %q)", v.DataDiskMountPath)
+		}
+	}
+
+	for _, a := range []struct {
+		field, value string
+		required     bool
+	}{
+		{"vm.ip_address", v.IPAddress, true},
+		{"vm.netmask", v.Netmask, true},
+		{"vm.gateway", v.Gateway, true},
+		{"vm.dns", v.DNS, true},
+		{"vm.dns2", v.DNS2, false},
+	} {
+		switch {
+		case a.value == "" && a.required:
+			errs.add(a.field, "is required")
+		case a.value != "" && net.ParseIP(a.value).To4() == nil:
+			errs.add(a.field, "invalid IPv4 address %q", a.value)
+		}
+	}
+
+	if profile == "ubuntu" {
+		if v.Username == "" {
+			errs.add("vm.username", "is required for ubuntu profile")
+		}
+		if v.SSHKeyPath == "" && v.SSHKey == "" {
+			errs.add("vm.ssh_key", "either vm.ssh_key or vm.ssh_key_path is required for ubuntu profile")
+		}
+		if v.AllowPasswordSSH && v.Password == "" && v.PasswordFrom == "" {
+			errs.add("vm.allow_password_ssh", "requires password or password_from")
+		}
+	}
+	if err := v.Installer.remasterOptions().Validate(); err != nil {
+		errs.add("vm.installer", "%v", err)
+	}
+	if ext := v.CloudInit.extensions(); !ext.IsZero() {
+		if profile != "ubuntu" {
+			errs.add("vm.cloud_init", "is only supported by the ubuntu profile")
+		} else if err := ext.Validate(); err != nil {
+			errs.add("vm.cloud_init", "%v", err)
+		}
+	}
+	if v.PasswordFrom != "" {
+		if _, err := PasswordProvider(v.PasswordFrom); err != nil {
+			errs.add("vm.password_from", "%v", err)
+		}
+	}
+	return errs.err()
+}
+
+// sshPublicKey returns the public key from ssh_key_path or inline ssh_key.
+func (v *VMSpec) sshPublicKey() (string, error) {
+	if v.SSHKeyPath != "" {
+		data, err := os.ReadFile(expandHome(v.SSHKeyPath))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return v.SSHKey, nil
+}
+
+// expandHome expands a leading ~/ to the user's home directory.
+func expandHome(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return home + p[1:]
+}
@@ -0,0 +1,115 @@
+// Package credentials resolves secrets (vCenter and guest passwords) from pluggable
+// sources: literals, environment variables, credential helper commands, HashiCorp
+// Vault and a local keyring file.
+//
+// Providers are resolved on demand, right before a secret is needed, so callers
+// never have to keep the plain value in long-lived structs.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned when a provider has no secret for the requested key.
+var ErrNotFound = errors.New("credential not found")
+
+// Provider resolves a secret on demand.
+// Implementations must not cache the value between calls.
+type Provider interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// Static is a literal secret.
+type Static string
+
+// Secret returns the literal value.
+func (s Static) Secret(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// Env reads a secret from an environment variable.
+type Env struct {
+	Name string
+}
+
+// Secret returns the value of the environment variable, or ErrNotFound if it is unset.
+func (e Env) Secret(context.Context) (string, error) {
+	v, ok := os.LookupEnv(e.Name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, e.Name)
+	}
+	return v, nil
+}
+
+// Resolve returns literal when it is set, otherwise the secret from p.
+// An empty literal and a nil provider resolve to "".
+func Resolve(ctx context.Context, literal string, p Provider) (string, error) {
+	if literal != "" || p == nil {
+		return literal, nil
+	}
+	return p.Secret(ctx)
+}
+
+// Parse builds a provider from a reference string as used in config files:
+//
+//	env:VCENTER_PASSWORD
+//	exec:/usr/local/bin/vc-creds --host vc01   (JSON on stdout, "password" field)
+//	exec:/usr/local/bin/vc-creds#secret        (select another JSON field)
+//	vault:secret/vcenter#password              (KV v2 at mount "secret", path "vcenter")
+//	keyring:vmbootstrap/administrator@vsphere.local
+//
+// Vault address and token come from VAULT_ADDR and VAULT_TOKEN.
+func Parse(ref string) (Provider, error) {
+	kind, rest, ok := strings.Cut(strings.TrimSpace(ref), ":")
+	if !ok || strings.TrimSpace(rest) == "" {
+		return nil, fmt.Errorf("invalid credential reference %q (expected kind:value)", ref)
+	}
+	rest = strings.TrimSpace(rest)
+
+	switch kind {
+	case "env":
+		return Env{Name: rest}, nil
+	case "exec":
+		command, field := splitField(rest)
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("invalid credential reference %q: empty command", ref)
+		}
+		return &Exec{Command: args[0], Args: args[1:], Field: field}, nil
+	case "vault":
+		path, field := splitField(rest)
+		mount, secretPath, ok := strings.Cut(strings.Trim(path, "/"), "/")
+		if !ok || secretPath == "" {
+			return nil, fmt.Errorf("invalid credential reference %q (expected vault:mount/path#field)", ref)
+		}
+		return &Vault{Mount: mount, Path: secretPath, Field: field}, nil
+	case "keyring":
+		service, account, ok := strings.Cut(rest, "/")
+		if !ok || service == "" || account == "" {
+			return nil, fmt.Errorf("invalid credential reference %q (expected keyring:service/account)", ref)
+		}
+		return &Keyring{Service: service, Account: account}, nil
+	default:
+		return nil, fmt.Errorf("unsupported credential provider %q (supported: env, exec, vault, keyring)", kind)
+	}
+}
+
+// splitField splits "value#field" into its parts.
+func splitField(s string) (string, string) {
+	if i := strings.LastIndex(s, "#"); i >= 0 {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+// fieldOrDefault returns field, or "password" when it is empty.
+func fieldOrDefault(field string) string {
+	if field == "" {
+		return "password"
+	}
+	return field
+}
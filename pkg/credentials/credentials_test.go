@@ -0,0 +1,167 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+
+	got, err := Resolve(ctx, "literal", Static("ignored"))
+	require.NoError(t, err)
+	require.Equal(t, "literal", got)
+
+	got, err = Resolve(ctx, "", Static("from-provider"))
+	require.NoError(t, err)
+	require.Equal(t, "from-provider", got)
+
+	got, err = Resolve(ctx, "", nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("VMBOOTSTRAP_TEST_SECRET", "s3cret")
+	got, err := Env{Name: "VMBOOTSTRAP_TEST_SECRET"}.Secret(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", got)
+
+	_, err = Env{Name: "VMBOOTSTRAP_TEST_UNSET"}.Secret(context.Background())
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestExec(t *testing.T) {
+	helper := filepath.Join(t.TempDir(), "helper.sh")
+	script := "#!/bin/sh\necho '{\"username\":\"admin\",\"password\":\"'\"$HELPER_PW\"'\"}'\n"
+	require.NoError(t, os.WriteFile(helper, []byte(script), 0o700))
+
+	p := &Exec{Command: helper, Env: []string{"HELPER_PW=from-helper"}}
+	got, err := p.Secret(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "from-helper", got)
+
+	p.Field = "username"
+	got, err = p.Secret(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "admin", got)
+
+	p.Field = "token"
+	_, err = p.Secret(context.Background())
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = (&Exec{Command: "false"}).Secret(context.Background())
+	require.Error(t, err)
+}
+
+// newVaultStandIn serves a minimal subset of the Vault KV API.
+func newVaultStandIn(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		secret := map[string]string{"password": "vault-pw", "username": "svc"}
+		switch r.URL.Path {
+		case "/v1/secret/data/vcenter":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": secret}})
+		case "/v1/kv/vcenter":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": secret})
+		default:
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVault(t *testing.T) {
+	srv := newVaultStandIn(t, "root")
+	ctx := context.Background()
+
+	v2 := &Vault{Address: srv.URL, Token: "root", Mount: "secret", Path: "vcenter"}
+	got, err := v2.Secret(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "vault-pw", got)
+
+	v1 := &Vault{Address: srv.URL, Token: "root", Mount: "kv", Path: "vcenter", Field: "username", KVVersion: 1}
+	got, err = v1.Secret(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "svc", got)
+
+	missing := &Vault{Address: srv.URL, Token: "root", Mount: "secret", Path: "other"}
+	_, err = missing.Secret(ctx)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	denied := &Vault{Address: srv.URL, Token: "wrong", Mount: "secret", Path: "vcenter"}
+	_, err = denied.Secret(ctx)
+	require.Error(t, err)
+
+	// Address and token fall back to the standard Vault environment.
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	p, err := Parse("vault:secret/vcenter#password")
+	require.NoError(t, err)
+	got, err = p.Secret(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "vault-pw", got)
+}
+
+func TestKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	k := &Keyring{Path: path, Service: "vmbootstrap", Account: "admin@vsphere.local"}
+
+	_, err := k.Secret(context.Background())
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, k.Store("pw1"))
+	require.NoError(t, (&Keyring{Path: path, Service: "vmbootstrap", Account: "other"}).Store("pw2"))
+
+	got, err := k.Secret(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "pw1", got)
+
+	_, err = (&Keyring{Path: path, Service: "nope", Account: "admin"}).Secret(context.Background())
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, os.Chmod(path, 0o644))
+	_, err = k.Secret(context.Background())
+	require.ErrorContains(t, err, "chmod 600")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    Provider
+		wantErr bool
+	}{
+		{ref: "env:VC_PASSWORD", want: Env{Name: "VC_PASSWORD"}},
+		{ref: "exec:/bin/helper --host vc01", want: &Exec{Command: "/bin/helper", Args: []string{"--host", "vc01"}}},
+		{ref: "exec:/bin/helper#secret", want: &Exec{Command: "/bin/helper", Args: []string{}, Field: "secret"}},
+		{ref: "vault:secret/infra/vcenter#pw", want: &Vault{Mount: "secret", Path: "infra/vcenter", Field: "pw"}},
+		{ref: "keyring:vmbootstrap/admin", want: &Keyring{Service: "vmbootstrap", Account: "admin"}},
+		{ref: "plain", wantErr: true},
+		{ref: "env:", wantErr: true},
+		{ref: "vault:secret", wantErr: true},
+		{ref: "keyring:admin", wantErr: true},
+		{ref: "ftp:x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.ref)
+		if tt.wantErr {
+			require.Error(t, err, tt.ref)
+			continue
+		}
+		require.NoError(t, err, tt.ref)
+		require.Equal(t, tt.want, got, tt.ref)
+	}
+}
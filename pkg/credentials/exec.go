@@ -0,0 +1,46 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Exec runs a credential helper and reads the secret from the JSON object it
+// prints on stdout, e.g. {"username": "...", "password": "..."}.
+type Exec struct {
+	Command string
+	Args    []string
+	Field   string   // JSON field holding the secret (default "password")
+	Env     []string // extra KEY=VALUE entries added to the helper environment
+}
+
+// Secret runs the helper and returns the selected field.
+func (e *Exec) Secret(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...) // #nosec G204 -- helper command is operator-configured
+	cmd.Env = append(os.Environ(), e.Env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("credential helper %s failed: %w: %s", e.Command, err, msg)
+		}
+		return "", fmt.Errorf("credential helper %s failed: %w", e.Command, err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(out, &fields); err != nil {
+		return "", fmt.Errorf("credential helper %s: invalid JSON output: %w", e.Command, err)
+	}
+	field := fieldOrDefault(e.Field)
+	v, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: credential helper %s returned no %q field", ErrNotFound, e.Command, field)
+	}
+	return v, nil
+}
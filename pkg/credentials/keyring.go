@@ -0,0 +1,96 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Keyring reads a secret from a local keyring file: a JSON document mapping
+// service -> account -> secret, readable only by its owner.
+type Keyring struct {
+	Path    string // keyring file (default: DefaultKeyringPath())
+	Service string
+	Account string
+}
+
+// DefaultKeyringPath returns the per-user keyring file location.
+func DefaultKeyringPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "vmbootstrap", "keyring.json")
+}
+
+// Secret returns the stored secret for Service/Account.
+func (k *Keyring) Secret(context.Context) (string, error) {
+	path := k.path()
+	entries, err := readKeyring(path)
+	if err != nil {
+		return "", err
+	}
+	secret, ok := entries[k.Service][k.Account]
+	if !ok {
+		return "", fmt.Errorf("%w: %s/%s in keyring %s", ErrNotFound, k.Service, k.Account, path)
+	}
+	return secret, nil
+}
+
+// Store saves secret for Service/Account, creating the keyring file if needed.
+func (k *Keyring) Store(secret string) error {
+	path := k.path()
+	entries, err := readKeyring(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if entries == nil {
+		entries = map[string]map[string]string{}
+	}
+	if entries[k.Service] == nil {
+		entries[k.Service] = map[string]string{}
+	}
+	entries[k.Service][k.Account] = secret
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (k *Keyring) path() string {
+	if k.Path != "" {
+		return k.Path
+	}
+	return DefaultKeyringPath()
+}
+
+func readKeyring(path string) (map[string]map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("keyring %s is accessible by other users (mode %04o); run chmod 600", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	var entries map[string]map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("keyring %s: invalid JSON: %w", path, err)
+	}
+	return entries, nil
+}
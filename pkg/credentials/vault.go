@@ -0,0 +1,110 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vault reads a secret from a HashiCorp Vault KV secrets engine.
+type Vault struct {
+	Address   string // Vault address (default: $VAULT_ADDR)
+	Token     string // Vault token (default: $VAULT_TOKEN)
+	Namespace string // Vault Enterprise namespace (default: $VAULT_NAMESPACE)
+	Mount     string // KV mount path (e.g. "secret")
+	Path      string // secret path below the mount
+	Field     string // key within the secret (default "password")
+	KVVersion int    // 1 or 2 (default 2)
+
+	HTTPClient *http.Client // default: client with a 30s timeout
+}
+
+// Secret reads the secret from Vault.
+func (v *Vault) Secret(ctx context.Context) (string, error) {
+	addr := strings.TrimRight(firstNonEmpty(v.Address, os.Getenv("VAULT_ADDR")), "/")
+	if addr == "" {
+		return "", fmt.Errorf("vault address is not set (VAULT_ADDR)")
+	}
+	token := firstNonEmpty(v.Token, os.Getenv("VAULT_TOKEN"))
+	if token == "" {
+		return "", fmt.Errorf("vault token is not set (VAULT_TOKEN)")
+	}
+
+	mount := strings.Trim(v.Mount, "/")
+	secretPath := strings.Trim(v.Path, "/")
+	endpoint := addr + "/v1/" + mount + "/data/" + secretPath
+	if v.KVVersion == 1 {
+		endpoint = addr + "/v1/" + mount + "/" + secretPath
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := firstNonEmpty(v.Namespace, os.Getenv("VAULT_NAMESPACE")); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	client := v.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("vault response read failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: vault secret %s/%s", ErrNotFound, mount, secretPath)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s/%s", resp.Status, mount, secretPath)
+	}
+
+	var payload struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	data := payload.Data
+	if v.KVVersion != 1 {
+		// KV v2 nests the secret under data.data.
+		var nested struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &nested); err != nil {
+			return "", fmt.Errorf("invalid vault KV v2 response: %w", err)
+		}
+		data = nested.Data
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("invalid vault secret data: %w", err)
+	}
+	field := fieldOrDefault(v.Field)
+	s, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: vault secret %s/%s has no %q field", ErrNotFound, mount, secretPath, field)
+	}
+	return s, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,438 @@
+package iso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+)
+
+// Cache artifact kinds.
+const (
+	KindUbuntu      = "ubuntu"             // downloaded Ubuntu Server ISO
+	KindAutoinstall = "ubuntu-autoinstall" // remastered Ubuntu ISO
+	KindTalos       = "talos"              // Talos ISO (<cache>/talos)
+	KindNoCloud     = "nocloud"            // per-VM cloud-init seed ISO
+	KindPartial     = "partial"            // interrupted download (.part)
+	KindOrphan      = "orphan"             // sidecar whose artifact is gone
+	KindOther       = "other"
+)
+
+const (
+	keysDirName      = "keys"
+	verifiedMetaExt  = ".verified.json"
+	modifierMetaExt  = ".meta.json"
+	uploadedHashExt  = ".uploaded.sha256" // written by releases without datastore manifests
+	partialExt       = ".part"
+	remasterTmpExt   = ".tmp"
+	bytesPerGB       = 1 << 30
+	cacheDirFallback = "cache"
+	legacyCacheDir   = "cache" // repo-relative iso.cache_dir default before the shared cache
+)
+
+// sidecarExts are files belonging to the artifact named by their prefix.
+var sidecarExts = []string{verifiedMetaExt, modifierMetaExt, uploadedHashExt, partialExt, remasterTmpExt}
+
+// remasterVariant is the tag of non-default remasters, see RemasterOptions.
+var remasterVariant = regexp.MustCompile(`^-[0-9a-f]{8}$`)
+
+var talosCacheName = regexp.MustCompile(`^talos-([0-9][^-]*(?:-[a-z]+\.[0-9]+)?)(?:-sch-[0-9a-f]+)?\.iso$`)
+
+// DefaultCacheDir returns iso.cache_dir, or <user cache dir>/vmbootstrap/iso
+// when it is empty, so all checkouts and processes share one cache.
+func DefaultCacheDir() string {
+	if dir := configs.Defaults.ISO.CacheDir; dir != "" {
+		return dir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return cacheDirFallback
+	}
+	dir := filepath.Join(base, "vmbootstrap", "iso")
+	legacyWarning.Do(func() {
+		if msg := legacyCacheWarning(legacyCacheDir, dir); msg != "" {
+			fmt.Printf("⚠️  %s\n", msg)
+		}
+	})
+	return dir
+}
+
+var legacyWarning sync.Once
+
+// legacyCacheWarning explains how to keep using the ISOs in legacy, the cache
+// of earlier releases, now that dir is the default. Returns "" when legacy
+// holds no ISOs.
+func legacyCacheWarning(legacy, dir string) string {
+	isos, _ := filepath.Glob(filepath.Join(legacy, "*.iso"))
+	talos, _ := filepath.Glob(filepath.Join(legacy, "talos", "*.iso"))
+	if len(isos)+len(talos) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Found %d ISOs in the old cache %s; the cache is now %s. "+
+		"Move them there (mkdir -p %s && mv %s/* %s/) or set iso.cache_dir: %s to keep using it.",
+		len(isos)+len(talos), legacy, dir, dir, legacy, dir, legacy)
+}
+
+// Cache manages the local artifact cache: downloaded and remastered Ubuntu
+// ISOs, Talos ISOs, NoCloud seeds and their sidecars (.verified.json,
+// .meta.json, .uploaded.sha256). Artifacts in use by another process are
+// locked and never removed.
+type Cache struct {
+	Dir string
+}
+
+// NewCache returns the cache in dir ("" = DefaultCacheDir()).
+func NewCache(dir string) *Cache {
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	return &Cache{Dir: dir}
+}
+
+// CacheEntry is one cached artifact with its sidecars.
+type CacheEntry struct {
+	Path     string    // artifact path
+	Name     string    // path relative to the cache dir
+	Kind     string    // Kind* constant
+	Version  string    // release version, when known
+	Size     int64     // artifact plus sidecars
+	ModTime  time.Time // artifact modification time
+	LastUsed time.Time // last download, remaster or cache hit
+	Current  bool      // still referenced by the release catalogs
+	Files    []string  // artifact (if present) and sidecars
+}
+
+// List returns all cached artifacts, least recently used first.
+func (c *Cache) List() ([]CacheEntry, error) {
+	groups := map[string]*CacheEntry{}
+	err := filepath.WalkDir(c.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == c.Dir && os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != c.Dir && (d.Name() == lockDirName || d.Name() == keysDirName || d.Name() == offlineDirName) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		artifact := path
+		for _, ext := range sidecarExts {
+			if trimmed, ok := strings.CutSuffix(path, ext); ok {
+				artifact = trimmed
+				break
+			}
+		}
+		e := groups[artifact]
+		if e == nil {
+			e = &CacheEntry{Path: artifact}
+			groups[artifact] = e
+		}
+		e.Files = append(e.Files, path)
+		e.Size += info.Size()
+		if path == artifact {
+			e.ModTime = info.ModTime()
+		}
+		if info.ModTime().After(e.LastUsed) {
+			e.LastUsed = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan cache %s: %w", c.Dir, err)
+	}
+
+	cat := loadReleaseCatalog()
+	entries := make([]CacheEntry, 0, len(groups))
+	for _, e := range groups {
+		if info, err := os.Stat(lockPath(e.Path)); err == nil && info.ModTime().After(e.LastUsed) {
+			e.LastUsed = info.ModTime()
+		}
+		e.Name, _ = filepath.Rel(c.Dir, e.Path)
+		cat.classify(e)
+		sort.Strings(e.Files)
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].LastUsed.Equal(entries[j].LastUsed) {
+			return entries[i].LastUsed.Before(entries[j].LastUsed)
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// releaseCatalog is a snapshot of the merged release catalogs.
+type releaseCatalog struct {
+	ubuntu map[string]configs.UbuntuRelease
+	talos  []string
+}
+
+func loadReleaseCatalog() releaseCatalog {
+	return releaseCatalog{ubuntu: configs.MergedUbuntuReleases(), talos: configs.MergedTalosVersions()}
+}
+
+// classify sets Kind, Version and Current from the artifact name and the
+// release catalogs.
+func (cat releaseCatalog) classify(e *CacheEntry) {
+	base := filepath.Base(e.Path)
+	if !slices.Contains(e.Files, e.Path) {
+		e.Kind = KindOrphan
+		if slices.Contains(e.Files, e.Path+partialExt) {
+			e.Kind = KindPartial
+		}
+		return
+	}
+	switch {
+	case filepath.Base(filepath.Dir(e.Path)) == "talos":
+		e.Kind = KindTalos
+		if m := talosCacheName.FindStringSubmatch(base); m != nil {
+			e.Version = "v" + m[1]
+			e.Current = e.Version == configs.Defaults.Talos.DefaultVersion ||
+				slices.Contains(cat.talos, e.Version)
+		}
+	case strings.HasPrefix(base, "nocloud-") && strings.HasSuffix(base, ".iso"):
+		e.Kind = KindNoCloud
+	case remasterSource(base) != "":
+		e.Kind = KindAutoinstall
+		e.Version, e.Current = cat.ubuntuVersion(remasterSource(base))
+	case strings.HasSuffix(base, ".iso"):
+		e.Version, e.Current = cat.ubuntuVersion(base)
+		if e.Current || strings.HasPrefix(base, "ubuntu-") {
+			e.Kind = KindUbuntu
+		} else {
+			e.Kind = KindOther
+		}
+	default:
+		e.Kind = KindOther
+	}
+}
+
+// ubuntuVersion maps an ISO file name to its Ubuntu catalog entry.
+func (cat releaseCatalog) ubuntuVersion(isoName string) (string, bool) {
+	for version, r := range cat.ubuntu {
+		if filepath.Base(r.URL) == isoName {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// Verify result states.
+const (
+	VerifyOK         = "ok"
+	VerifyCorrupt    = "corrupt"    // content does not match the recorded SHA256
+	VerifyStale      = "stale"      // remaster of a changed/missing source, partial or orphan files
+	VerifyUnverified = "unverified" // no checksum recorded
+)
+
+// VerifyResult is the outcome of verifying one cache entry.
+type VerifyResult struct {
+	Entry  CacheEntry
+	Status string
+	Detail string
+}
+
+// Verify re-hashes downloaded artifacts against the checksum recorded when they
+// were verified and checks that remasters still match their source ISO.
+func (c *Cache) Verify(ctx context.Context) ([]VerifyResult, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]VerifyResult, 0, len(entries))
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		status, detail := verifyEntry(e)
+		results = append(results, VerifyResult{Entry: e, Status: status, Detail: detail})
+	}
+	return results, nil
+}
+
+func verifyEntry(e CacheEntry) (string, string) {
+	switch e.Kind {
+	case KindPartial:
+		return VerifyStale, "interrupted download"
+	case KindOrphan:
+		return VerifyStale, "artifact missing"
+	case KindAutoinstall:
+		meta, err := readISOMeta(e.Path + modifierMetaExt)
+		if err != nil {
+			return VerifyStale, "no remaster metadata"
+		}
+		if meta.Version != isoModifierVersion {
+			return VerifyStale, "remastered by an older version"
+		}
+		src, err := os.Stat(meta.SourcePath)
+		if err != nil {
+			return VerifyStale, "source ISO missing"
+		}
+		if src.Size() != meta.SourceSize || src.ModTime().Unix() != meta.SourceModTime {
+			return VerifyStale, "source ISO changed"
+		}
+		return VerifyOK, ""
+	}
+
+	data, err := os.ReadFile(e.Path + verifiedMetaExt)
+	if err != nil {
+		return VerifyUnverified, "no checksum recorded"
+	}
+	var meta verifiedMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.SHA256 == "" {
+		return VerifyUnverified, "unreadable checksum record"
+	}
+	got, err := computeSHA256(e.Path)
+	if err != nil {
+		return VerifyCorrupt, err.Error()
+	}
+	if got != meta.SHA256 {
+		return VerifyCorrupt, fmt.Sprintf("SHA256 %s, expected %s", got, meta.SHA256)
+	}
+	return VerifyOK, ""
+}
+
+// PruneOptions selects cache entries to remove. Criteria are combined: an
+// entry is removed when any of them matches.
+type PruneOptions struct {
+	OlderThan time.Duration // not used for this long (0 = ignore age)
+	MaxSize   int64         // evict least recently used entries until the cache fits (0 = no cap)
+	Unused    bool          // releases no longer in the catalogs, stale remasters, NoCloud seeds, partial and orphan files
+	Keep      []string      // artifact paths never removed
+	DryRun    bool          // report only
+}
+
+// Prune removes the selected entries and returns them. Entries locked by
+// another process are skipped.
+func (c *Cache) Prune(opts PruneOptions) ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	var removed []CacheEntry
+	now := time.Now()
+	for _, e := range entries { // least recently used first
+		if slices.Contains(opts.Keep, e.Path) {
+			continue
+		}
+		selected := (opts.OlderThan > 0 && now.Sub(e.LastUsed) > opts.OlderThan) ||
+			(opts.MaxSize > 0 && total > opts.MaxSize) ||
+			(opts.Unused && isUnused(e))
+		if !selected {
+			continue
+		}
+		if !opts.DryRun {
+			ok, err := removeEntry(e)
+			if err != nil {
+				return removed, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		total -= e.Size
+		removed = append(removed, e)
+	}
+	return removed, nil
+}
+
+// Remove deletes the given entries and returns those removed; entries locked
+// by another process are skipped.
+func (c *Cache) Remove(entries ...CacheEntry) ([]CacheEntry, error) {
+	var removed []CacheEntry
+	for _, e := range entries {
+		ok, err := removeEntry(e)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, e)
+		}
+	}
+	return removed, nil
+}
+
+func isUnused(e CacheEntry) bool {
+	switch e.Kind {
+	case KindPartial, KindOrphan, KindNoCloud:
+		return true
+	case KindUbuntu, KindTalos:
+		return !e.Current
+	case KindAutoinstall:
+		status, _ := verifyEntry(e)
+		return !e.Current || status != VerifyOK
+	}
+	return false
+}
+
+// removeEntry deletes e under its lock; false means another process holds it.
+func removeEntry(e CacheEntry) (bool, error) {
+	unlock, ok, err := tryLockArtifact(e.Path)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer unlock()
+	for _, f := range e.Files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("remove %s: %w", f, err)
+		}
+	}
+	// Removing the lock file while holding it is safe: lockers re-check
+	// that the file they locked is still in place.
+	_ = os.Remove(lockPath(e.Path))
+	return true, nil
+}
+
+// EnforceLimit evicts least recently used artifacts while the cache exceeds
+// iso.cache_max_size_gb. Paths in keep (artifacts about to be used) stay.
+func (c *Cache) EnforceLimit(keep ...string) error {
+	limit := int64(configs.Defaults.ISO.CacheMaxSizeGB) * bytesPerGB
+	if limit <= 0 {
+		return nil
+	}
+	removed, err := c.Prune(PruneOptions{MaxSize: limit, Keep: keep})
+	for _, e := range removed {
+		fmt.Printf("🧹 Evicted %s from cache (%.1f MB, cache_max_size_gb=%d)\n",
+			e.Name, float64(e.Size)/(1024*1024), configs.Defaults.ISO.CacheMaxSizeGB)
+	}
+	return err
+}
+
+// remasterSource returns the source ISO name of a remaster named
+// <source><suffix>[-<variant>].iso, or "" if base is not a remaster.
+func remasterSource(base string) string {
+	suffix := configs.Defaults.ISO.UbuntuModifiedSuffix
+	name, ok := strings.CutSuffix(base, ".iso")
+	if !ok || suffix == "" {
+		return ""
+	}
+	if i := len(name) - 9; i > 0 && remasterVariant.MatchString(name[i:]) {
+		name = name[:i]
+	}
+	src, ok := strings.CutSuffix(name, suffix)
+	if !ok {
+		return ""
+	}
+	return src + ".iso"
+}
@@ -0,0 +1,233 @@
+package iso
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+)
+
+// writeCacheFile creates name under dir with size bytes, last used age ago.
+func writeCacheFile(t *testing.T, dir, name string, size int, age time.Duration) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	ts := time.Now().Add(-age)
+	if err := os.Chtimes(p, ts, ts); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	return p
+}
+
+func cacheNames(entries []CacheEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestCache_ListClassifiesArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Base(configs.UbuntuReleases.Releases["24.04"].URL)
+	remaster := strings.TrimSuffix(current, ".iso") + configs.Defaults.ISO.UbuntuModifiedSuffix + ".iso"
+	writeCacheFile(t, dir, current, 10, 3*time.Hour)
+	writeCacheFile(t, dir, current+verifiedMetaExt, 5, 3*time.Hour)
+	writeCacheFile(t, dir, current+uploadedHashExt, 5, time.Hour)
+	writeCacheFile(t, dir, remaster, 10, 2*time.Hour)
+	writeCacheFile(t, dir, "ubuntu-24.04.1-live-server-amd64.iso", 10, 4*time.Hour)
+	writeCacheFile(t, dir, "talos/talos-"+strings.TrimPrefix(configs.Defaults.Talos.DefaultVersion, "v")+"-sch-abc123.iso", 10, time.Minute)
+	writeCacheFile(t, dir, "nocloud-web01.iso", 10, 5*time.Hour)
+	writeCacheFile(t, dir, "gone.iso"+partialExt, 3, 6*time.Hour)
+	writeCacheFile(t, dir, "keys/ABC.asc", 3, 0)
+
+	entries, err := NewCache(dir).List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []struct {
+		name, kind, version string
+		current             bool
+		size                int64
+	}{
+		{"gone.iso", KindPartial, "", false, 3},
+		{"nocloud-web01.iso", KindNoCloud, "", false, 10},
+		{"ubuntu-24.04.1-live-server-amd64.iso", KindUbuntu, "", false, 10},
+		{remaster, KindAutoinstall, "24.04", true, 10},
+		{current, KindUbuntu, "24.04", true, 20},
+		{filepath.Join("talos", "talos-"+strings.TrimPrefix(configs.Defaults.Talos.DefaultVersion, "v")+"-sch-abc123.iso"), KindTalos, configs.Defaults.Talos.DefaultVersion, true, 10},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %v", cacheNames(entries))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Name != w.name || e.Kind != w.kind || e.Version != w.version || e.Current != w.current || e.Size != w.size {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir)
+	current := filepath.Base(configs.UbuntuReleases.Releases["24.04"].URL)
+	old := writeCacheFile(t, dir, "ubuntu-20.04.1-live-server-amd64.iso", 100, 48*time.Hour)
+	writeCacheFile(t, dir, filepath.Base(old)+uploadedHashExt, 10, 48*time.Hour)
+	writeCacheFile(t, dir, current, 100, 24*time.Hour)
+	recent := writeCacheFile(t, dir, "talos/talos-1.0.0.iso", 100, time.Minute)
+
+	// Dry run reports without removing.
+	removed, err := c.Prune(PruneOptions{Unused: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if got := cacheNames(removed); len(got) != 2 {
+		t.Fatalf("dry run selected %v", got)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("dry run removed files: %v", err)
+	}
+
+	// Age: only the artifact unused for two days goes, sidecars with it.
+	removed, err = c.Prune(PruneOptions{OlderThan: 36 * time.Hour})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if got := cacheNames(removed); len(got) != 1 || got[0] != filepath.Base(old) {
+		t.Fatalf("age prune removed %v", got)
+	}
+	if _, err := os.Stat(old + uploadedHashExt); !os.IsNotExist(err) {
+		t.Fatalf("sidecar kept: %v", err)
+	}
+
+	// Size cap evicts least recently used first and honours Keep.
+	removed, err = c.Prune(PruneOptions{MaxSize: 150, Keep: []string{filepath.Join(dir, current)}})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if got := cacheNames(removed); len(got) != 1 || got[0] != filepath.Join("talos", "talos-1.0.0.iso") {
+		t.Fatalf("size prune removed %v", got)
+	}
+	if _, err := os.Stat(recent); !os.IsNotExist(err) {
+		t.Fatalf("evicted file still present: %v", err)
+	}
+}
+
+func TestCache_PruneSkipsLockedEntries(t *testing.T) {
+	dir := t.TempDir()
+	p := writeCacheFile(t, dir, "nocloud-vm.iso", 10, time.Hour)
+	unlock, err := lockArtifact(context.Background(), p)
+	if err != nil {
+		t.Fatalf("lockArtifact: %v", err)
+	}
+
+	removed, err := NewCache(dir).Prune(PruneOptions{Unused: true})
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("locked entry pruned: %v %v", cacheNames(removed), err)
+	}
+	unlock()
+
+	removed, err = NewCache(dir).Prune(PruneOptions{Unused: true})
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("unlocked entry not pruned: %v %v", cacheNames(removed), err)
+	}
+	if _, err := os.Stat(lockPath(p)); !os.IsNotExist(err) {
+		t.Fatalf("lock file kept: %v", err)
+	}
+
+	// A waiting locker gives up when its context ends.
+	unlock, err = lockArtifact(context.Background(), p)
+	if err != nil {
+		t.Fatalf("lockArtifact: %v", err)
+	}
+	defer unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPollInterval)
+	defer cancel()
+	if _, err := lockArtifact(ctx, p); err == nil {
+		t.Fatal("expected second lock to wait and time out")
+	}
+}
+
+func TestCache_Verify(t *testing.T) {
+	dir := t.TempDir()
+	good := writeCacheFile(t, dir, "ubuntu-good.iso", 10, 0)
+	if err := writeVerifiedMeta(good, sha256Hex([]byte(strings.Repeat("x", 10)))); err != nil {
+		t.Fatalf("writeVerifiedMeta: %v", err)
+	}
+	bad := writeCacheFile(t, dir, "ubuntu-bad.iso", 10, 0)
+	if err := writeVerifiedMeta(bad, sha256Hex([]byte("other"))); err != nil {
+		t.Fatalf("writeVerifiedMeta: %v", err)
+	}
+	writeCacheFile(t, dir, "ubuntu-plain.iso", 10, 0)
+	remaster := writeCacheFile(t, dir, "ubuntu-good"+configs.Defaults.ISO.UbuntuModifiedSuffix+".iso", 10, 0)
+	if err := writeISOMeta(remaster+modifierMetaExt, isoMeta{Version: isoModifierVersion, SourcePath: good, SourceSize: 1}); err != nil {
+		t.Fatalf("writeISOMeta: %v", err)
+	}
+
+	results, err := NewCache(dir).Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	got := map[string]string{}
+	for _, r := range results {
+		got[r.Entry.Name] = r.Status
+	}
+	want := map[string]string{
+		"ubuntu-good.iso":       VerifyOK,
+		"ubuntu-bad.iso":        VerifyCorrupt,
+		"ubuntu-plain.iso":      VerifyUnverified,
+		filepath.Base(remaster): VerifyStale,
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s: status %q, want %q", name, got[name], status)
+		}
+	}
+}
+
+func TestDatastoreKind(t *testing.T) {
+	suffix := configs.Defaults.ISO.UbuntuModifiedSuffix
+	tests := []struct {
+		dir, name, want string
+	}{
+		{DatastoreUbuntuDir, "ubuntu-24.04.3-live-server-amd64.iso", KindUbuntu},
+		{DatastoreUbuntuDir, "ubuntu-24.04.3-live-server-amd64" + suffix + ".iso", KindAutoinstall},
+		{DatastoreUbuntuDir, "ubuntu-24.04.3-live-server-amd64" + suffix + "-0a1b2c3d.iso", KindAutoinstall},
+		{DatastoreUbuntuDir, "ubuntu-24.04.3-live-server-amd64-0a1b2c3d.iso", KindUbuntu},
+		{DatastoreTalosDir, "talos-1.12.4.iso", KindTalos},
+		{DatastoreNoCloudDir, "nocloud-web01.iso", KindNoCloud},
+	}
+	for _, tt := range tests {
+		if got := datastoreKind(tt.dir, tt.name); got != tt.want {
+			t.Errorf("datastoreKind(%q, %q) = %q, want %q", tt.dir, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLegacyCacheWarning(t *testing.T) {
+	legacy := filepath.Join(t.TempDir(), "cache")
+	dir := filepath.Join(t.TempDir(), "iso")
+	if msg := legacyCacheWarning(legacy, dir); msg != "" {
+		t.Fatalf("missing legacy cache: got %q", msg)
+	}
+	writeCacheFile(t, legacy, "keys/ABC.asc", 3, 0)
+	if msg := legacyCacheWarning(legacy, dir); msg != "" {
+		t.Fatalf("legacy cache without ISOs: got %q", msg)
+	}
+	writeCacheFile(t, legacy, "ubuntu-24.04.3-live-server-amd64.iso", 10, 0)
+	writeCacheFile(t, legacy, "talos/talos-1.12.4.iso", 10, 0)
+	msg := legacyCacheWarning(legacy, dir)
+	if !strings.Contains(msg, "Found 2 ISOs") || !strings.Contains(msg, dir) || !strings.Contains(msg, "iso.cache_dir: "+legacy) {
+		t.Fatalf("warning = %q", msg)
+	}
+}
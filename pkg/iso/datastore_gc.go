@@ -0,0 +1,184 @@
+package iso
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Datastore directories vmbootstrap uploads to.
+const (
+	DatastoreUbuntuDir  = "ISO/ubuntu"
+	DatastoreTalosDir   = "ISO/talos"
+	DatastoreNoCloudDir = "ISO/nocloud"
+)
+
+// DatastoreDirs are the directories scanned by the datastore inventory.
+var DatastoreDirs = []string{DatastoreUbuntuDir, DatastoreTalosDir, DatastoreNoCloudDir}
+
+// DatastoreArtifact is an ISO uploaded by vmbootstrap to the ISO datastore.
+type DatastoreArtifact struct {
+	Path      string    // datastore-relative path, e.g. "ISO/nocloud/nocloud-web01.iso"
+	Kind      string    // KindUbuntu, KindAutoinstall, KindTalos or KindNoCloud
+	Size      int64     // bytes
+	Modified  time.Time // last upload
+	MountedBy []string  // VMs with a CD-ROM backed by this file
+}
+
+// Orphan reports whether no VM mounts the artifact.
+func (a DatastoreArtifact) Orphan() bool {
+	return len(a.MountedBy) == 0
+}
+
+// ListDatastoreArtifacts lists the ISOs under DatastoreDirs on ds together
+// with the VMs (of the datastore's datacenter) whose CD-ROMs mount them.
+func (m *Manager) ListDatastoreArtifacts(ds *object.Datastore) ([]DatastoreArtifact, error) {
+	mounted, err := m.mountedISOs(ds)
+	if err != nil {
+		return nil, err
+	}
+	browser, err := ds.Browser(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datastore browser: %w", err)
+	}
+	spec := types.HostDatastoreBrowserSearchSpec{
+		MatchPattern: []string{"*.iso"},
+		Details:      &types.FileQueryFlags{FileType: true, FileSize: true, Modification: true},
+	}
+
+	var artifacts []DatastoreArtifact
+	for _, dir := range DatastoreDirs {
+		task, err := browser.SearchDatastore(m.ctx, ds.Path(dir), &spec)
+		if err != nil {
+			return nil, fmt.Errorf("datastore search %s failed: %w", ds.Path(dir), err)
+		}
+		info, err := task.WaitForResult(m.ctx, nil)
+		if err != nil {
+			if types.IsFileNotFound(err) {
+				continue // nothing uploaded to this directory yet
+			}
+			return nil, fmt.Errorf("datastore search %s failed: %w", ds.Path(dir), err)
+		}
+		result, ok := info.Result.(types.HostDatastoreBrowserSearchResults)
+		if !ok {
+			continue
+		}
+		for _, f := range result.File {
+			fi := f.GetFileInfo()
+			p := path.Join(dir, fi.Path)
+			a := DatastoreArtifact{
+				Path:      p,
+				Kind:      datastoreKind(dir, fi.Path),
+				Size:      fi.FileSize,
+				MountedBy: mounted[p],
+			}
+			if fi.Modification != nil {
+				a.Modified = *fi.Modification
+			}
+			artifacts = append(artifacts, a)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path < artifacts[j].Path })
+	return artifacts, nil
+}
+
+func datastoreKind(dir, name string) string {
+	switch dir {
+	case DatastoreTalosDir:
+		return KindTalos
+	case DatastoreNoCloudDir:
+		return KindNoCloud
+	}
+	if remasterSource(name) != "" {
+		return KindAutoinstall
+	}
+	return KindUbuntu
+}
+
+// mountedISOs maps datastore-relative ISO paths on ds to the VMs mounting them.
+func (m *Manager) mountedISOs(ds *object.Datastore) (map[string][]string, error) {
+	dc, err := datastoreDatacenter(m.ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	v, err := view.NewManager(ds.Client()).CreateContainerView(m.ctx, dc.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM view: %w", err)
+	}
+	defer func() { _ = v.Destroy(m.ctx) }()
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(m.ctx, []string{"VirtualMachine"}, []string{"name", "config.hardware.device"}, &vms); err != nil {
+		return nil, fmt.Errorf("failed to list VM CD-ROMs: %w", err)
+	}
+
+	mounted := map[string][]string{}
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+		for _, dev := range vm.Config.Hardware.Device {
+			cdrom, ok := dev.(*types.VirtualCdrom)
+			if !ok {
+				continue
+			}
+			backing, ok := cdrom.Backing.(*types.VirtualCdromIsoBackingInfo)
+			if !ok {
+				continue
+			}
+			var dp object.DatastorePath
+			if !dp.FromString(backing.FileName) || dp.Datastore != ds.Name() {
+				continue
+			}
+			p := path.Clean(dp.Path)
+			if !slices.Contains(mounted[p], vm.Name) {
+				mounted[p] = append(mounted[p], vm.Name)
+			}
+		}
+	}
+	return mounted, nil
+}
+
+// DatastoreGCOptions selects the orphans removed by DatastoreGC.
+type DatastoreGCOptions struct {
+	OlderThan time.Duration // only artifacts uploaded longer ago (0 = any age)
+	Kinds     []string      // restrict to these kinds (empty = all)
+	DryRun    bool          // report only
+}
+
+// DatastoreGC deletes artifacts that no VM mounts and that match opts. It
+// returns the deleted (or, with DryRun, deletable) artifacts. The age
+// threshold protects seeds of bootstraps that are uploading right now.
+func (m *Manager) DatastoreGC(ds *object.Datastore, opts DatastoreGCOptions) ([]DatastoreArtifact, error) {
+	artifacts, err := m.ListDatastoreArtifacts(ds)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []DatastoreArtifact
+	now := time.Now()
+	for _, a := range artifacts {
+		if !a.Orphan() || (len(opts.Kinds) > 0 && !slices.Contains(opts.Kinds, a.Kind)) {
+			continue
+		}
+		if opts.OlderThan > 0 && now.Sub(a.Modified) < opts.OlderThan {
+			continue
+		}
+		if !opts.DryRun {
+			if err := m.DeleteFromDatastore(ds, a.Path); err != nil {
+				return deleted, err
+			}
+			if err := m.DeleteFromDatastore(ds, a.Path+manifestExt); err != nil {
+				return deleted, err
+			}
+		}
+		deleted = append(deleted, a)
+	}
+	return deleted, nil
+}
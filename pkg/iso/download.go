@@ -0,0 +1,495 @@
+package iso
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+)
+
+// maxSumsSize bounds checksum, signature and key files fetched during a download.
+const maxSumsSize = 1 << 20
+
+// DownloadSpec describes a resumable, verified download.
+type DownloadSpec struct {
+	URLs []string // primary URL first, then mirrors serving the same file
+	Dest string   // local path; content is staged in Dest+".part"
+
+	// SHA256 is the expected checksum. When empty, it is looked up in
+	// ChecksumFile (a SHA256SUMS-style file beside each URL), if set.
+	SHA256       string
+	ChecksumFile string
+	// SignatureFile is a detached OpenPGP signature of ChecksumFile beside each
+	// URL. It must verify against one of SigningKeys (pinned fingerprints); keys
+	// are fetched from Keyserver once and cached in KeyDir.
+	SignatureFile string
+	SigningKeys   []string
+	Keyserver     string
+	KeyDir        string
+
+	Timeout time.Duration // per HTTP request (0 = timeouts.download_minutes)
+
+	// OfflineDir holds imported bundles whose files answer bundled URLs
+	// ("" = OfflineDir()). With Offline (or iso.offline) set, other URLs fail
+	// with ErrNotInBundle instead of being downloaded.
+	OfflineDir string
+	Offline    bool
+}
+
+// verifiedMeta is stored beside a downloaded file so cached files are only
+// re-hashed when they changed on disk.
+type verifiedMeta struct {
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+// httpStatusError is a non-success HTTP response.
+type httpStatusError struct {
+	url  string
+	code int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d from %s", e.code, e.url)
+}
+
+// Download fetches spec.URLs[0] (falling back to the mirrors) into spec.Dest.
+// Interrupted transfers resume from the .part file with HTTP Range requests,
+// each URL is retried timeouts.download_retries times, and the result must
+// match the expected SHA256 before it is moved into place. A cached Dest is
+// reused when it still matches the checksum recorded when it was verified.
+func Download(ctx context.Context, spec DownloadSpec) error {
+	if len(spec.URLs) == 0 {
+		return fmt.Errorf("no download URL for %s", spec.Dest)
+	}
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = configs.Defaults.Timeouts.Download()
+	}
+	client := &http.Client{Timeout: timeout, Transport: newOfflineTransport(spec)}
+	want := strings.ToLower(strings.TrimSpace(spec.SHA256))
+
+	// Another process may be fetching the same file into a shared cache.
+	unlock, err := lockArtifact(ctx, spec.Dest)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(spec.Dest); err == nil {
+		if meta, ok := readVerifiedMeta(spec.Dest); ok && (want == "" || meta.SHA256 == want) {
+			return nil
+		}
+	}
+
+	if want == "" && spec.ChecksumFile != "" {
+		sum, err := lookupChecksum(ctx, client, spec)
+		if err != nil {
+			return err
+		}
+		want = sum
+	}
+
+	if _, err := os.Stat(spec.Dest); err == nil {
+		got, err := computeSHA256(spec.Dest)
+		if err == nil && (want == "" || got == want) {
+			return writeVerifiedMeta(spec.Dest, got)
+		}
+		fmt.Printf("⚠️  Cached %s does not match its checksum - downloading again\n", filepath.Base(spec.Dest))
+		_ = os.Remove(spec.Dest)
+	}
+
+	part := spec.Dest + ".part"
+	retries := max(configs.Defaults.Timeouts.DownloadRetries, 1)
+	var lastErr error
+	for _, u := range spec.URLs {
+		for attempt := 1; attempt <= retries; attempt++ {
+			if attempt > 1 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(configs.Defaults.Timeouts.DownloadRetryDelay()):
+				}
+				fmt.Printf("   Retrying %s (attempt %d/%d): %v\n", u, attempt, retries, lastErr)
+			}
+			lastErr = fetchResumable(ctx, client, u, part)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var status *httpStatusError
+			if errors.As(lastErr, &status) && status.code < 500 {
+				break // not found/forbidden: the next mirror may have it
+			}
+			if errors.Is(lastErr, ErrNotInBundle) {
+				break
+			}
+			if lastErr != nil {
+				continue
+			}
+
+			got, err := computeSHA256(part)
+			if err != nil {
+				return fmt.Errorf("failed to compute checksum: %w", err)
+			}
+			if want != "" && got != want {
+				// A corrupt or mixed-up transfer cannot be resumed; start over.
+				_ = os.Remove(part)
+				lastErr = fmt.Errorf("checksum mismatch for %s: expected %s, got %s", u, want, got)
+				break
+			}
+			if err := os.Rename(part, spec.Dest); err != nil {
+				return fmt.Errorf("failed to store download: %w", err)
+			}
+			return writeVerifiedMeta(spec.Dest, got)
+		}
+		if len(spec.URLs) > 1 {
+			fmt.Printf("⚠️  %s failed: %v\n", u, lastErr)
+		}
+	}
+	return fmt.Errorf("download failed: %w", lastErr)
+}
+
+// fetchResumable appends the remainder of url to part, resuming at its size.
+func fetchResumable(ctx context.Context, client *http.Client, url, part string) error {
+	out, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			_ = out.Truncate(0)
+			return fmt.Errorf("server resumed at an unexpected range %q", resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		return nil // .part already holds the whole file; the checksum decides
+	case resp.StatusCode == http.StatusOK:
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		offset = 0
+	default:
+		return &httpStatusError{url: url, code: resp.StatusCode}
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	filename := filepath.Base(url)
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	switch {
+	case offset > 0:
+		fmt.Printf("📥 Resuming %s at %.1f MB...\n", filename, float64(offset)/(1024*1024))
+	case total > 0:
+		fmt.Printf("📥 Downloading %s (%.1f MB)...\n", filename, float64(total)/(1024*1024))
+	default:
+		fmt.Printf("📥 Downloading %s...\n", filename)
+	}
+
+	counter := &progressCounter{
+		total:     total,
+		current:   offset,
+		startTime: time.Now(),
+	}
+	_, err = io.Copy(out, io.TeeReader(resp.Body, counter))
+	fmt.Println()
+	if err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	fmt.Printf("✅ Download complete: %s\n", filename)
+	return nil
+}
+
+// lookupChecksum reads the expected SHA256 of the download from the checksum
+// file beside each URL, verifying its signature when configured.
+func lookupChecksum(ctx context.Context, client *http.Client, spec DownloadSpec) (string, error) {
+	var lastErr error
+	for _, u := range spec.URLs {
+		sums, err := fetchChecksums(ctx, client, spec, u)
+		if err != nil {
+			var sigErr *signatureError
+			if errors.As(err, &sigErr) {
+				return "", err
+			}
+			lastErr = err
+			continue
+		}
+		if sum := findChecksum(sums, path.Base(u)); sum != "" {
+			return sum, nil
+		}
+		lastErr = fmt.Errorf("%s is not listed in %s", path.Base(u), siblingURL(u, spec.ChecksumFile))
+	}
+	return "", fmt.Errorf("failed to get checksum (set it in the release config to skip this lookup): %w", lastErr)
+}
+
+// signatureError is a checksum file whose signature does not verify; other
+// mirrors are not tried.
+type signatureError struct {
+	url string
+	err error
+}
+
+func (e *signatureError) Error() string { return e.url + ": " + e.err.Error() }
+func (e *signatureError) Unwrap() error { return e.err }
+
+// fetchChecksums fetches spec.ChecksumFile beside u and verifies its
+// signature when spec.SignatureFile is set.
+func fetchChecksums(ctx context.Context, client *http.Client, spec DownloadSpec, u string) ([]byte, error) {
+	sums, err := fetchSmall(ctx, client, siblingURL(u, spec.ChecksumFile))
+	if err != nil {
+		return nil, err
+	}
+	if spec.SignatureFile != "" {
+		sig, err := fetchSmall(ctx, client, siblingURL(u, spec.SignatureFile))
+		if err != nil {
+			return nil, err
+		}
+		if err := verifySignature(ctx, client, spec, sums, sig); err != nil {
+			return nil, &signatureError{url: siblingURL(u, spec.ChecksumFile), err: err}
+		}
+	}
+	return sums, nil
+}
+
+// LookupChecksums returns all "<sha256>  <name>" entries of the checksum file
+// beside spec.URLs[0], verified like Download verifies it (signature and
+// pinned signing keys when spec.SignatureFile is set).
+func LookupChecksums(ctx context.Context, spec DownloadSpec) (map[string]string, error) {
+	if len(spec.URLs) == 0 || spec.ChecksumFile == "" {
+		return nil, fmt.Errorf("no checksum file to look up")
+	}
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = configs.Defaults.Timeouts.Download()
+	}
+	client := &http.Client{Timeout: timeout, Transport: newOfflineTransport(spec)}
+	sums, err := fetchChecksums(ctx, client, spec, spec.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			continue
+		}
+		if _, err := hex.DecodeString(fields[0]); err == nil {
+			result[path.Base(strings.TrimPrefix(fields[1], "*"))] = strings.ToLower(fields[0])
+		}
+	}
+	return result, nil
+}
+
+// findChecksum returns the SHA256 of name from "<hex> [*]<path>" lines.
+func findChecksum(sums []byte, name string) string {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			continue
+		}
+		if path.Base(strings.TrimPrefix(fields[1], "*")) != name {
+			continue
+		}
+		if _, err := hex.DecodeString(fields[0]); err == nil {
+			return strings.ToLower(fields[0])
+		}
+	}
+	return ""
+}
+
+// verifySignature checks a detached (binary or armored) signature of data.
+func verifySignature(ctx context.Context, client *http.Client, spec DownloadSpec, data, sig []byte) error {
+	keyring, err := signingKeyring(ctx, client, spec)
+	if err != nil {
+		return err
+	}
+	check := openpgp.CheckDetachedSignature
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+	if _, err := check(keyring, bytes.NewReader(data), bytes.NewReader(sig), nil); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	return nil
+}
+
+// signingKeyring loads the pinned keys from KeyDir, fetching missing ones from
+// the keyserver. Only keys whose primary fingerprint is pinned are accepted.
+func signingKeyring(ctx context.Context, client *http.Client, spec DownloadSpec) (openpgp.EntityList, error) {
+	if len(spec.SigningKeys) == 0 {
+		return nil, fmt.Errorf("no signing keys pinned")
+	}
+	var keyring openpgp.EntityList
+	for _, fpr := range spec.SigningKeys {
+		fpr = normalizeFingerprint(fpr)
+		cached := filepath.Join(spec.KeyDir, fpr+".asc")
+		armored, err := os.ReadFile(cached)
+		fetched := false
+		if err != nil {
+			if spec.Keyserver == "" {
+				return nil, fmt.Errorf("signing key %s not in %s and no keyserver configured", fpr, spec.KeyDir)
+			}
+			armored, err = fetchSmall(ctx, client, keyURL(spec.Keyserver, fpr))
+			if err != nil {
+				return nil, fmt.Errorf("fetch signing key %s: %w", fpr, err)
+			}
+			fetched = true
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+		if err != nil {
+			return nil, fmt.Errorf("read signing key %s: %w", fpr, err)
+		}
+		matched := 0
+		for _, e := range entities {
+			if strings.EqualFold(hex.EncodeToString(e.PrimaryKey.Fingerprint), fpr) {
+				keyring = append(keyring, e)
+				matched++
+			}
+		}
+		if matched == 0 {
+			return nil, fmt.Errorf("signing key does not match pinned fingerprint %s", fpr)
+		}
+		if fetched && spec.KeyDir != "" {
+			if err := os.MkdirAll(spec.KeyDir, 0755); err == nil {
+				_ = os.WriteFile(cached, armored, 0644)
+			}
+		}
+	}
+	return keyring, nil
+}
+
+// SidecarURLs returns the URLs Download fetches besides the file itself to
+// verify spec when its checksum is not pinned: the checksum file and its
+// signature beside each URL, and the keyserver lookups of the signing keys.
+func (spec DownloadSpec) SidecarURLs() []string {
+	if spec.SHA256 != "" || spec.ChecksumFile == "" {
+		return nil
+	}
+	var urls []string
+	for _, u := range spec.URLs {
+		urls = append(urls, siblingURL(u, spec.ChecksumFile))
+		if spec.SignatureFile != "" {
+			urls = append(urls, siblingURL(u, spec.SignatureFile))
+		}
+	}
+	if spec.SignatureFile != "" && spec.Keyserver != "" {
+		for _, fpr := range spec.SigningKeys {
+			urls = append(urls, keyURL(spec.Keyserver, normalizeFingerprint(fpr)))
+		}
+	}
+	return urls
+}
+
+func normalizeFingerprint(fpr string) string {
+	return strings.ToUpper(strings.ReplaceAll(fpr, " ", ""))
+}
+
+// keyURL returns the HKP lookup URL of a key on keyserver.
+func keyURL(keyserver, fpr string) string {
+	q := url.Values{"op": {"get"}, "options": {"mr"}, "search": {"0x" + fpr}}
+	return strings.TrimSuffix(keyserver, "/") + "/pks/lookup?" + q.Encode()
+}
+
+// fetchSmall GETs a small file (checksums, signatures, keys).
+func fetchSmall(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{url: url, code: resp.StatusCode}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSumsSize))
+}
+
+// siblingURL returns the URL of name in the directory of fileURL.
+func siblingURL(fileURL, name string) string {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return fileURL[:strings.LastIndex(fileURL, "/")+1] + name
+	}
+	u.Path = path.Join(path.Dir(u.Path), name)
+	u.RawQuery = ""
+	return u.String()
+}
+
+func verifiedMetaPath(dest string) string {
+	return dest + ".verified.json"
+}
+
+func readVerifiedMeta(dest string) (verifiedMeta, bool) {
+	var meta verifiedMeta
+	data, err := os.ReadFile(verifiedMetaPath(dest))
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		return meta, false
+	}
+	info, err := os.Stat(dest)
+	if err != nil || info.Size() != meta.Size || info.ModTime().UnixNano() != meta.ModTime {
+		return meta, false
+	}
+	return meta, true
+}
+
+func writeVerifiedMeta(dest, sum string) error {
+	info, err := os.Stat(dest)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(verifiedMeta{SHA256: sum, Size: info.Size(), ModTime: info.ModTime().UnixNano()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(verifiedMetaPath(dest), data, 0644)
+}
@@ -1,6 +1,7 @@
 package iso
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -8,13 +9,18 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 )
 
 func TestDownloadFile_OKAndHTTPError(t *testing.T) {
+	fastRetries(t)
 	mgr := NewManager(context.Background())
 
 	data := []byte("hello")
@@ -55,6 +61,7 @@ func TestDownloadFile_OKAndHTTPError(t *testing.T) {
 }
 
 func TestDownloadUbuntu_UsesCacheAndRedownloadsOnCorruption(t *testing.T) {
+	fastRetries(t)
 	mgr := NewManager(context.Background())
 	if err := mgr.SetCacheDir(t.TempDir()); err != nil {
 		t.Fatalf("SetCacheDir: %v", err)
@@ -108,3 +115,173 @@ func TestDownloadUbuntu_UsesCacheAndRedownloadsOnCorruption(t *testing.T) {
 		t.Fatalf("expected re-download, got %d", hits)
 	}
 }
+
+// fastRetries removes retry delays for the duration of a test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	old := configs.Defaults.Timeouts
+	configs.Defaults.Timeouts.DownloadRetries = 2
+	configs.Defaults.Timeouts.DownloadRetryDelaySeconds = 0
+	t.Cleanup(func() { configs.Defaults.Timeouts = old })
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownload_ResumesPartialFile(t *testing.T) {
+	fastRetries(t)
+	payload := []byte(strings.Repeat("0123456789", 100))
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file.iso", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.iso")
+	if err := os.WriteFile(dest+".part", payload[:400], 0644); err != nil {
+		t.Fatalf("write part: %v", err)
+	}
+	if err := Download(context.Background(), DownloadSpec{URLs: []string{srv.URL + "/file.iso"}, Dest: dest, SHA256: sha256Hex(payload)}); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, payload) {
+		t.Fatalf("content mismatch after resume (%d bytes)", len(got))
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=400-" {
+		t.Fatalf("expected one ranged request from byte 400, got %q", ranges)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("part file left behind: %v", err)
+	}
+}
+
+func TestDownload_MirrorFallbackAndChecksumMismatch(t *testing.T) {
+	fastRetries(t)
+	payload := []byte("iso-content")
+	var badHits, goodHits int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		_, _ = w.Write([]byte("tampered"))
+	}))
+	defer bad.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer down.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+		_, _ = w.Write(payload)
+	}))
+	defer good.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.iso")
+	spec := DownloadSpec{
+		URLs:   []string{bad.URL + "/file.iso", down.URL + "/file.iso", good.URL + "/file.iso"},
+		Dest:   dest,
+		SHA256: sha256Hex(payload),
+	}
+	if err := Download(context.Background(), spec); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, payload) {
+		t.Fatalf("got %q", got)
+	}
+	if badHits != 1 || goodHits != 1 {
+		t.Fatalf("expected one request per mirror, got bad=%d good=%d", badHits, goodHits)
+	}
+
+	// All mirrors serving wrong content is an error and leaves nothing behind.
+	spec.URLs = spec.URLs[:1]
+	spec.Dest = filepath.Join(t.TempDir(), "file.iso")
+	if err := Download(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(spec.Dest + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("corrupt part file kept: %v", err)
+	}
+}
+
+func TestDownload_SignedChecksums(t *testing.T) {
+	fastRetries(t)
+	payload := []byte("signed-iso")
+	entity, err := openpgp.NewEntity("Test CD Image", "", "cdimage@example.org", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	var pub bytes.Buffer
+	aw, _ := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	if err := entity.Serialize(aw); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	_ = aw.Close()
+	fpr := strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))
+
+	sums := []byte(sha256Hex([]byte("other")) + " *other.iso\n" + sha256Hex(payload) + " *file.iso\n")
+	var sig bytes.Buffer
+	if err := openpgp.DetachSign(&sig, entity, bytes.NewReader(sums), nil); err != nil {
+		t.Fatalf("DetachSign: %v", err)
+	}
+
+	var keyHits int32
+	servedSums := sums
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pks/lookup":
+			atomic.AddInt32(&keyHits, 1)
+			if r.URL.Query().Get("search") != "0x"+fpr {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(pub.Bytes())
+		case "/rel/SHA256SUMS":
+			_, _ = w.Write(servedSums)
+		case "/rel/SHA256SUMS.gpg":
+			_, _ = w.Write(sig.Bytes())
+		case "/rel/file.iso":
+			_, _ = w.Write(payload)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	spec := DownloadSpec{
+		URLs:          []string{srv.URL + "/rel/file.iso"},
+		Dest:          filepath.Join(dir, "file.iso"),
+		ChecksumFile:  "SHA256SUMS",
+		SignatureFile: "SHA256SUMS.gpg",
+		SigningKeys:   []string{fpr},
+		Keyserver:     srv.URL,
+		KeyDir:        filepath.Join(dir, "keys"),
+	}
+	if err := Download(context.Background(), spec); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "keys", fpr+".asc")); err != nil {
+		t.Fatalf("signing key not cached: %v", err)
+	}
+
+	// Tampered SHA256SUMS fails verification; the cached key is reused.
+	servedSums = bytes.Replace(sums, []byte("other.iso"), []byte("evil.iso"), 1)
+	spec.Dest = filepath.Join(dir, "again.iso")
+	if err := Download(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+	if keyHits != 1 {
+		t.Fatalf("expected the key to be fetched once, got %d", keyHits)
+	}
+
+	// A key that does not match the pinned fingerprint is rejected.
+	spec.SigningKeys = []string{strings.Repeat("AB", 20)}
+	spec.KeyDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(spec.KeyDir, spec.SigningKeys[0]+".asc"), pub.Bytes(), 0644); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if err := Download(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "pinned fingerprint") {
+		t.Fatalf("expected fingerprint error, got %v", err)
+	}
+}
@@ -3,28 +3,25 @@ package iso
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/exec"
 
+	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
-// newGovcCmd creates a govc command with vCenter authentication environment variables.
-// Centralizes all govc invocations - avoids duplicating GOVC_URL + GOVC_INSECURE setup.
-func newGovcCmd(ctx context.Context, vcenterHost, vcenterUser, vcenterPass string, insecure bool, args ...string) *exec.Cmd {
-	insecureVal := "false"
-	if insecure {
-		insecureVal = "true"
+// datastoreDatacenter returns the datacenter that owns ds.
+// Datastore file operations need it when connected to vCenter.
+func datastoreDatacenter(ctx context.Context, ds *object.Datastore) (*object.Datacenter, error) {
+	if ds.DatacenterPath == "" {
+		if err := ds.FindInventoryPath(ctx); err != nil {
+			return nil, fmt.Errorf("failed to resolve datastore inventory path: %w", err)
+		}
+	}
+	dc, err := find.NewFinder(ds.Client(), false).Datacenter(ctx, ds.DatacenterPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find datacenter for datastore %q: %w", ds.Name(), err)
 	}
-	cmd := exec.CommandContext(ctx, "govc", args...)
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("GOVC_URL=https://%s/sdk", vcenterHost),
-		fmt.Sprintf("GOVC_USERNAME=%s", vcenterUser),
-		fmt.Sprintf("GOVC_PASSWORD=%s", vcenterPass),
-		fmt.Sprintf("GOVC_INSECURE=%s", insecureVal),
-	)
-	return cmd
+	return dc, nil
 }
 
 // getDevices returns all virtual devices of a VM.
@@ -12,12 +12,13 @@ var _ ManagerInterface = (*Manager)(nil)
 type ManagerInterface interface {
 	DownloadUbuntu(version string) (string, error)
 	ModifyUbuntuISO(originalISOPath string) (string, bool, error)
+	ModifyUbuntuISOWithOptions(originalISOPath string, opts RemasterOptions) (string, bool, error)
 	CreateNoCloudISO(userData, metaData, networkConfig, vmName string) (string, error)
-	UploadToDatastore(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error
-	UploadAlways(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error
+	UploadToDatastore(ds *object.Datastore, localPath, remotePath string) error
+	UploadAlways(ds *object.Datastore, localPath, remotePath string) error
 	MountSingleISO(vm *object.VirtualMachine, isoPath, label string) error
 	MountISOs(vm *object.VirtualMachine, ubuntuISO, nocloudISO string) error
 	RemoveAllCDROMs(vm *object.VirtualMachine) error
 	EnsureCDROMsConnectedAfterBoot(vm *object.VirtualMachine) error
-	DeleteFromDatastore(datastoreName, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error
+	DeleteFromDatastore(ds *object.Datastore, remotePath string) error
 }
@@ -0,0 +1,75 @@
+package iso
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache artifacts are guarded by advisory lock files in a .locks directory
+// beside them, so concurrent processes sharing one cache never download,
+// remaster or prune the same file at once. Lock files double as usage stamps:
+// their mtime is the artifact's last use (see Cache).
+
+const (
+	lockDirName      = ".locks"
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// lockPath returns the lock file guarding artifact.
+func lockPath(artifact string) string {
+	return filepath.Join(filepath.Dir(artifact), lockDirName, filepath.Base(artifact)+".lock")
+}
+
+// lockArtifact takes the exclusive lock of artifact, waiting for other holders
+// until ctx is done. The returned func releases it and marks the artifact used.
+func lockArtifact(ctx context.Context, artifact string) (func(), error) {
+	for waited := false; ; waited = true {
+		unlock, ok, err := tryLockArtifact(artifact)
+		if err != nil || ok {
+			return unlock, err
+		}
+		if !waited {
+			fmt.Printf("⏳ Waiting for another process using %s...\n", filepath.Base(artifact))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// tryLockArtifact takes the exclusive lock of artifact without waiting.
+func tryLockArtifact(artifact string) (unlock func(), ok bool, err error) {
+	path := lockPath(artifact)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, false, fmt.Errorf("create lock dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, fmt.Errorf("open lock: %w", err)
+	}
+	locked, err := tryLockFile(f)
+	if err != nil || !locked {
+		_ = f.Close()
+		return nil, false, err
+	}
+	// Prune removes lock files of deleted artifacts; a lock taken on a file that
+	// was unlinked meanwhile guards nothing, so start over on the new one.
+	held, _ := f.Stat()
+	current, statErr := os.Stat(path)
+	if statErr != nil || !os.SameFile(held, current) {
+		unlockFile(f)
+		_ = f.Close()
+		return tryLockArtifact(artifact)
+	}
+	return func() {
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		unlockFile(f)
+		_ = f.Close()
+	}, true, nil
+}
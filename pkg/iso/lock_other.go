@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package iso
+
+import "os"
+
+// Platforms without advisory file locks run unguarded.
+func tryLockFile(*os.File) (bool, error) { return true, nil }
+
+func unlockFile(*os.File) {}
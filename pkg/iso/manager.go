@@ -8,9 +8,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -18,20 +16,24 @@ import (
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/kdomanski/iso9660"
+	"github.com/vmware/govmomi/fault"
 	"github.com/vmware/govmomi/object"
-	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
 // Manager handles ISO download, creation, and upload operations.
 type Manager struct {
-	ctx      context.Context
-	cacheDir string // Local cache directory for downloaded ISOs
+	ctx             context.Context
+	cacheDir        string        // Local cache directory for downloaded ISOs
+	downloadTimeout time.Duration // 0 = configs.Defaults.Timeouts.Download()
+	hardwareInit    time.Duration // 0 = configs.Defaults.Timeouts.HardwareInit()
+	offline         bool          // downloads only from imported bundles
+	uploadProgress  func(UploadEvent)
 }
 
 // NewManager creates a new ISO manager.
 func NewManager(ctx context.Context) *Manager {
-	cacheDir := configs.Defaults.ISO.CacheDir
+	cacheDir := DefaultCacheDir()
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		fmt.Printf("⚠️  Failed to create cache dir %s: %v\n", cacheDir, err)
 	}
@@ -51,133 +53,114 @@ func (m *Manager) SetCacheDir(dir string) error {
 	return nil
 }
 
+// Cache returns the artifact cache in the manager's cache directory.
+func (m *Manager) Cache() *Cache {
+	return NewCache(m.cacheDir)
+}
+
+// SetDownloadTimeout sets the max time for one ISO download (0 = configured default).
+func (m *Manager) SetDownloadTimeout(d time.Duration) {
+	m.downloadTimeout = d
+}
+
+// SetOffline makes downloads resolve from bundles imported into the cache only
+// (false still honours iso.offline).
+func (m *Manager) SetOffline(offline bool) {
+	m.offline = offline
+}
+
+// SetHardwareInit sets the wait for VM hardware after power-on (0 = configured default).
+func (m *Manager) SetHardwareInit(d time.Duration) {
+	m.hardwareInit = d
+}
+
+func (m *Manager) hardwareInitDelay() time.Duration {
+	if m.hardwareInit > 0 {
+		return m.hardwareInit
+	}
+	return configs.Defaults.Timeouts.HardwareInit()
+}
+
 // UbuntuRelease represents an Ubuntu release with download info.
 type UbuntuRelease struct {
 	Version  string // e.g., "24.04"
 	URL      string
-	Checksum string // SHA256
+	Mirrors  []string // fallback URLs for the same ISO
+	Checksum string   // SHA256 (empty = from the signed SHA256SUMS)
 }
 
-// GetUbuntuReleases returns available Ubuntu releases from configs/ubuntu-releases.yaml.
+// GetUbuntuReleases returns available Ubuntu releases: the built-in
+// configs/ubuntu-releases.yaml merged with the user's catalog override.
 func GetUbuntuReleases() map[string]UbuntuRelease {
-	result := make(map[string]UbuntuRelease, len(configs.UbuntuReleases.Releases))
-	for version, r := range configs.UbuntuReleases.Releases {
+	merged := configs.MergedUbuntuReleases()
+	result := make(map[string]UbuntuRelease, len(merged))
+	for version, r := range merged {
 		result[version] = UbuntuRelease{
 			Version:  version,
 			URL:      r.URL,
+			Mirrors:  r.Mirrors,
 			Checksum: r.Checksum,
 		}
 	}
 	return result
 }
 
-// DownloadUbuntu downloads Ubuntu Server ISO with optional SHA256 verification.
-// Returns local path to downloaded/cached ISO.
-func (m *Manager) DownloadUbuntu(version string) (string, error) {
+// UbuntuDownloadSpec returns the download spec of an Ubuntu release: the
+// catalog URL and mirrors, verified against the pinned checksum or the
+// GPG-signed SHA256SUMS of the release.
+func (m *Manager) UbuntuDownloadSpec(version string) (DownloadSpec, error) {
 	releases := GetUbuntuReleases()
 	release, ok := releases[version]
 	if !ok {
-		supported := make([]string, 0, len(configs.UbuntuReleases.Releases))
-		for v := range configs.UbuntuReleases.Releases {
+		supported := make([]string, 0, len(releases))
+		for v := range releases {
 			supported = append(supported, v)
 		}
 		sort.Strings(supported)
-		return "", fmt.Errorf("unsupported Ubuntu version %q (supported: %s)", version, strings.Join(supported, ", "))
-	}
-
-	// Check if already cached
-	filename := filepath.Base(release.URL)
-	localPath := filepath.Join(m.cacheDir, filename)
-
-	if _, err := os.Stat(localPath); err == nil {
-		// File exists in cache
-		if release.Checksum != "" {
-			// Verify checksum
-			if err := m.verifyChecksum(localPath, release.Checksum); err != nil {
-				// Corrupted - re-download
-				_ = os.Remove(localPath)
-			} else {
-				return localPath, nil // Valid cached file
-			}
-		} else {
-			return localPath, nil // No checksum, assume valid
-		}
+		return DownloadSpec{}, fmt.Errorf("unsupported Ubuntu version %q (supported: %s)", version, strings.Join(supported, ", "))
+	}
+	return DownloadSpec{
+		URLs:          append([]string{release.URL}, release.Mirrors...),
+		Dest:          filepath.Join(m.cacheDir, filepath.Base(release.URL)),
+		SHA256:        release.Checksum,
+		ChecksumFile:  "SHA256SUMS",
+		SignatureFile: "SHA256SUMS.gpg",
+		SigningKeys:   configs.UbuntuReleases.SigningKeys,
+		Keyserver:     configs.UbuntuReleases.Keyserver,
+		KeyDir:        filepath.Join(m.cacheDir, keysDirName),
+		Timeout:       m.downloadTimeout,
+		OfflineDir:    m.Cache().OfflineDir(),
+		Offline:       m.offline,
+	}, nil
+}
+
+// DownloadUbuntu downloads Ubuntu Server ISO and verifies its SHA256, either
+// the pinned checksum or the one from the GPG-signed SHA256SUMS of the release.
+// Returns local path to downloaded/cached ISO.
+func (m *Manager) DownloadUbuntu(version string) (string, error) {
+	spec, err := m.UbuntuDownloadSpec(version)
+	if err != nil {
+		return "", err
 	}
-
-	// Download ISO
-	if err := m.downloadFile(release.URL, localPath); err != nil {
-		return "", fmt.Errorf("download failed: %w", err)
+	if err := Download(m.ctx, spec); err != nil {
+		return "", fmt.Errorf("download Ubuntu %s: %w", version, err)
 	}
-
-	// Verify checksum if provided
-	if release.Checksum != "" {
-		if err := m.verifyChecksum(localPath, release.Checksum); err != nil {
-			_ = os.Remove(localPath)
-			return "", fmt.Errorf("checksum verification failed: %w", err)
-		}
+	if err := m.Cache().EnforceLimit(spec.Dest); err != nil {
+		fmt.Printf("⚠️  Cache size limit: %v\n", err)
 	}
-
-	return localPath, nil
+	return spec.Dest, nil
 }
 
-// downloadFile downloads a file with progress tracking.
+// downloadFile downloads a file with progress tracking and resume, without
+// checksum verification.
 func (m *Manager) downloadFile(url, destPath string) error {
-	// Create destination file
-	out, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer func() {
-		_ = out.Close()
-	}()
-
-	// HTTP GET with context
-	req, err := http.NewRequestWithContext(m.ctx, "GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	client := &http.Client{
-		Timeout: configs.Defaults.Timeouts.Download(),
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
-	}
-
-	// Get file size
-	size := resp.ContentLength
-	filename := filepath.Base(url)
-
-	if size > 0 {
-		fmt.Printf("📥 Downloading %s (%.1f MB)...\n", filename, float64(size)/(1024*1024))
-	} else {
-		fmt.Printf("📥 Downloading %s...\n", filename)
-	}
-
-	// Copy with progress bar
-	counter := &progressCounter{
-		total:     size,
-		startTime: time.Now(),
-	}
-	_, err = io.Copy(out, io.TeeReader(resp.Body, counter))
-	if err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
-	}
-
-	// Final newline after progress
-	fmt.Println()
-	fmt.Printf("✅ Download complete: %s\n", filename)
-
-	return nil
+	return Download(m.ctx, DownloadSpec{
+		URLs:       []string{url},
+		Dest:       destPath,
+		Timeout:    m.downloadTimeout,
+		OfflineDir: m.Cache().OfflineDir(),
+		Offline:    m.offline,
+	})
 }
 
 // progressCounter tracks download progress
@@ -358,11 +341,6 @@ func (m *Manager) CheckFileExists(ds *object.Datastore, remotePath string) (bool
 	return false, nil // File not found
 }
 
-// hashFile returns the path where we store the SHA256 of the last uploaded version of localPath.
-func hashFile(localPath string) string {
-	return localPath + ".uploaded.sha256"
-}
-
 // computeSHA256 computes SHA256 hash of a file.
 func computeSHA256(path string) (string, error) {
 	f, err := os.Open(path)
@@ -380,161 +358,6 @@ func computeSHA256(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// needsUpload returns true if the local ISO has changed since last upload (hash mismatch).
-func needsUpload(localPath string) bool {
-	current, err := computeSHA256(localPath)
-	if err != nil {
-		return true // Can't compute → upload to be safe
-	}
-
-	saved, err := os.ReadFile(hashFile(localPath))
-	if err != nil {
-		return true // No saved hash → first upload
-	}
-
-	return string(saved) != current
-}
-
-// saveUploadedHash saves SHA256 of the uploaded file so future runs can detect changes.
-func saveUploadedHash(localPath string) {
-	hash, err := computeSHA256(localPath)
-	if err != nil {
-		return
-	}
-	_ = os.WriteFile(hashFile(localPath), []byte(hash), 0644)
-}
-
-// UploadToDatastore uploads ISO to vCenter datastore.
-// Uses govc CLI if available (more reliable for large files), otherwise falls back to govmomi.
-// Skips upload if file exists on datastore AND local hash matches last uploaded hash.
-func (m *Manager) UploadToDatastore(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	// Check if upload is needed: file must exist on datastore AND hash must match last upload
-	exists, err := m.CheckFileExists(ds, remotePath)
-	if err != nil {
-		return fmt.Errorf("failed to check file existence: %w", err)
-	}
-
-	if exists && !needsUpload(localPath) {
-		fmt.Printf("✅ ISO already exists on datastore (unchanged): %s\n", filepath.Base(localPath))
-		return nil
-	}
-
-	if exists {
-		fmt.Printf("🔄 Re-uploading (ISO changed since last upload): %s\n", filepath.Base(localPath))
-	}
-
-	return m.doUpload(ds, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass, insecure, true)
-}
-
-// UploadAlways uploads ISO unconditionally (no existence/hash check).
-// Use for small, VM-specific ISOs like NoCloud that must always be fresh (matches Python behavior).
-func (m *Manager) UploadAlways(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	return m.doUpload(ds, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass, insecure, false)
-}
-
-func (m *Manager) doUpload(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool, saveHash bool) error {
-
-	fileInfo, err := os.Stat(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
-	}
-
-	sizeMB := float64(fileInfo.Size()) / (1024 * 1024)
-	fmt.Printf("📤 Uploading %s (%.1f MB) to datastore...\n", filepath.Base(localPath), sizeMB)
-
-	// Try govc first (more reliable for large files)
-	if err := m.uploadWithGovc(ds.Name(), localPath, remotePath, vcenterHost, vcenterUser, vcenterPass, insecure); err == nil {
-		fmt.Printf("✅ Upload complete: %s\n", filepath.Base(localPath))
-		if saveHash {
-			saveUploadedHash(localPath)
-		}
-		return nil
-	} else {
-		fmt.Printf("⚠️  govc upload failed (%v) - using govmomi fallback...\n", err)
-	}
-
-	// Fallback to govmomi Upload
-	fmt.Println("⚠️  govmomi fallback (may timeout on large files)...")
-	if err := m.uploadWithGovmomi(ds, localPath, remotePath); err != nil {
-		return err
-	}
-	if saveHash {
-		saveUploadedHash(localPath)
-	}
-	return nil
-}
-
-// uploadWithGovc uploads using govc CLI tool
-func (m *Manager) uploadWithGovc(datastoreName, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	// Check if govc is available
-	if _, err := exec.LookPath("govc"); err != nil {
-		return fmt.Errorf("govc not found: %w", err)
-	}
-
-	cmd := newGovcCmd(m.ctx, vcenterHost, vcenterUser, vcenterPass, insecure,
-		"datastore.upload", "-ds", datastoreName, localPath, remotePath,
-	)
-
-	// Start upload with progress tracking
-	startTime := time.Now()
-	done := make(chan bool)
-
-	// Progress goroutine
-	go func() {
-		ticker := time.NewTicker(configs.Defaults.Timeouts.UploadProgress())
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				elapsed := time.Since(startTime)
-				fmt.Printf("\r   Uploading... elapsed: %dm %ds",
-					int(elapsed.Minutes()),
-					int(elapsed.Seconds())%60)
-			}
-		}
-	}()
-
-	// Run upload
-	output, err := cmd.CombinedOutput()
-	done <- true
-	fmt.Println() // Newline after progress
-
-	if err != nil {
-		return fmt.Errorf("govc upload failed: %w\nOutput: %s", err, string(output))
-	}
-
-	return nil
-}
-
-// uploadWithGovmomi uploads using govmomi library (fallback)
-func (m *Manager) uploadWithGovmomi(ds *object.Datastore, localPath, remotePath string) error {
-	file, err := os.Open(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to open local file: %w", err)
-	}
-	defer func() {
-		_ = file.Close()
-	}()
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
-	}
-
-	p := soap.Upload{
-		ContentLength: fileInfo.Size(),
-	}
-
-	if err := ds.Upload(m.ctx, file, remotePath, &p); err != nil {
-		return fmt.Errorf("failed to upload to datastore: %w", err)
-	}
-
-	return nil
-}
-
 // RemoveAllCDROMs removes all existing CD-ROM devices from VM.
 // Uses Reconfigure() directly like Python does (not wrapper methods).
 func (m *Manager) RemoveAllCDROMs(vm *object.VirtualMachine) error {
@@ -653,8 +476,8 @@ func (m *Manager) ConnectAllCDROMs(vm *object.VirtualMachine) error {
 // If disconnected: power-cycle VM to force reconnection.
 func (m *Manager) EnsureCDROMsConnectedAfterBoot(vm *object.VirtualMachine) error {
 	// Wait for VM hardware to initialize (matches Python: time.sleep(5))
-	fmt.Printf("   Waiting %ds for VM hardware to initialize...\n", configs.Defaults.Timeouts.HardwareInitSeconds)
-	time.Sleep(configs.Defaults.Timeouts.HardwareInit())
+	fmt.Printf("   Waiting %s for VM hardware to initialize...\n", m.hardwareInitDelay())
+	time.Sleep(m.hardwareInitDelay())
 
 	// Refresh device list (matches Python: _safe_reload_vm)
 	devices, err := getDevices(m.ctx, vm)
@@ -710,7 +533,7 @@ func (m *Manager) EnsureCDROMsConnectedAfterBoot(vm *object.VirtualMachine) erro
 	}
 
 	// Wait and verify (matches Python: time.sleep(5) + reload)
-	time.Sleep(configs.Defaults.Timeouts.HardwareInit())
+	time.Sleep(m.hardwareInitDelay())
 	devices, err = getDevices(m.ctx, vm)
 	if err != nil {
 		return err
@@ -861,30 +684,45 @@ func (m *Manager) getNextCDROMUnitNumber(vm *object.VirtualMachine, controller t
 	return 0, fmt.Errorf("no available unit numbers on SATA controller")
 }
 
-// CleanupNoCloudISO removes NoCloud ISO after first boot.
-// DeleteFromDatastore deletes a file from vCenter datastore using govc.
-// Used for cleanup of temporary ISOs (e.g., NoCloud ISO after installation).
-func (m *Manager) DeleteFromDatastore(datastoreName, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	if _, err := exec.LookPath("govc"); err != nil {
-		return fmt.Errorf("govc not found - cannot delete from datastore: %w", err)
+// makeDatastoreDir creates dir (and parents) on the datastore if it does not exist.
+func (m *Manager) makeDatastoreDir(ds *object.Datastore, dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
 	}
+	dc, err := datastoreDatacenter(m.ctx, ds)
+	if err != nil {
+		return err
+	}
+	fm := object.NewFileManager(ds.Client())
+	if err := fm.MakeDirectory(m.ctx, ds.Path(dir), dc, true); err != nil {
+		if fault.Is(err, &types.FileAlreadyExists{}) {
+			return nil
+		}
+		return fmt.Errorf("failed to create datastore directory %s: %w", ds.Path(dir), err)
+	}
+	return nil
+}
 
-	cmd := newGovcCmd(m.ctx, vcenterHost, vcenterUser, vcenterPass, insecure,
-		"datastore.rm", "-ds", datastoreName, remotePath,
-	)
-
-	output, err := cmd.CombinedOutput()
+// DeleteFromDatastore deletes a file from a vCenter datastore.
+// Used for cleanup of temporary ISOs (e.g., NoCloud ISO after installation).
+// A file that is already gone is not an error.
+func (m *Manager) DeleteFromDatastore(ds *object.Datastore, remotePath string) error {
+	dc, err := datastoreDatacenter(m.ctx, ds)
 	if err != nil {
-		msg := strings.TrimSpace(string(output))
-		if strings.Contains(msg, "was not found") {
+		return err
+	}
+
+	if err := ds.NewFileManager(dc, false).Delete(m.ctx, remotePath); err != nil {
+		if fault.Is(err, &types.FileNotFound{}) {
 			return nil
 		}
-		return fmt.Errorf("govc datastore.rm failed: %w\nOutput: %s", err, msg)
+		return fmt.Errorf("failed to delete %s: %w", ds.Path(remotePath), err)
 	}
 
 	return nil
 }
 
+// CleanupNoCloudISO removes NoCloud ISO after first boot.
 func (m *Manager) CleanupNoCloudISO(vm *object.VirtualMachine) error {
 	devices, err := vm.Device(m.ctx)
 	if err != nil {
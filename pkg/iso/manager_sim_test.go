@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vm"
@@ -271,52 +272,125 @@ func TestCheckFileExists(t *testing.T) {
 	require.False(t, missing)
 }
 
-func TestUploadToDatastore_UsesGovc(t *testing.T) {
+func TestUploadToDatastore_SkipsIdenticalCopy(t *testing.T) {
 	env, cleanup := newSimEnv(t)
 	defer cleanup()
 
 	_, _, datastore := createTestVM(t, env, "iso-upload")
 	manager := NewManager(env.ctx)
+	var events []UploadEvent
+	manager.SetUploadProgress(func(e UploadEvent) { events = append(events, e) })
 
-	tmp := t.TempDir()
-	bin := filepath.Join(tmp, "bin")
-	require.NoError(t, os.MkdirAll(bin, 0755))
-	writeFakeExecutable(t, bin, "govc", "#!/bin/sh\nexit 0\n")
-	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
-
-	local := filepath.Join(tmp, "test.iso")
+	local := filepath.Join(t.TempDir(), "test.iso")
 	require.NoError(t, os.WriteFile(local, []byte("data"), 0644))
 
-	err := manager.UploadToDatastore(datastore, local, "ISO/test.iso", "vc", "user", "pass", true)
+	require.NoError(t, manager.UploadToDatastore(datastore, local, "ISO/test.iso"))
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	require.True(t, last.Done)
+	require.Equal(t, int64(4), last.Bytes)
+	require.Equal(t, "ISO/test.iso"+partialExt, last.Path)
+
+	manifest, ok := manager.readManifest(datastore, "ISO/test.iso")
+	require.True(t, ok)
+	require.Equal(t, sha256Hex([]byte("data")), manifest.SHA256)
+	require.Equal(t, int64(4), manifest.Size)
+
+	exists, err := manager.CheckFileExists(datastore, "ISO/test.iso")
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = manager.CheckFileExists(datastore, "ISO/test.iso"+partialExt)
 	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Same content: another client (no local state) must not upload again.
+	events = nil
+	other := NewManager(env.ctx)
+	other.SetUploadProgress(func(e UploadEvent) { events = append(events, e) })
+	require.NoError(t, other.UploadToDatastore(datastore, local, "ISO/test.iso"))
+	require.Empty(t, events)
+}
 
-	if _, err := os.Stat(hashFile(local)); err != nil {
-		t.Fatalf("expected uploaded hash file: %v", err)
-	}
+func TestUploadToDatastore_ReuploadsChangedOrMissingCopy(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	_, _, datastore := createTestVM(t, env, "iso-reupload")
+	manager := NewManager(env.ctx)
+	uploads := 0
+	manager.SetUploadProgress(func(e UploadEvent) {
+		if e.Done {
+			uploads++
+		}
+	})
+
+	local := filepath.Join(t.TempDir(), "test.iso")
+	require.NoError(t, os.WriteFile(local, []byte("data"), 0644))
+	require.NoError(t, manager.UploadToDatastore(datastore, local, "ISO/test.iso"))
+	require.Equal(t, 1, uploads)
+
+	require.NoError(t, os.WriteFile(local, []byte("changed"), 0644))
+	require.NoError(t, manager.UploadToDatastore(datastore, local, "ISO/test.iso"))
+	require.Equal(t, 2, uploads)
+	manifest, ok := manager.readManifest(datastore, "ISO/test.iso")
+	require.True(t, ok)
+	require.Equal(t, sha256Hex([]byte("changed")), manifest.SHA256)
+	require.NoError(t, manager.verifyRemote(datastore, "ISO/test.iso", manifest.SHA256, 7, true))
+
+	// Manifest left behind but the ISO itself was deleted.
+	require.NoError(t, manager.DeleteFromDatastore(datastore, "ISO/test.iso"))
+	require.NoError(t, manager.UploadToDatastore(datastore, local, "ISO/test.iso"))
+	require.Equal(t, 3, uploads)
 }
 
-func TestUploadAlways_DoesNotSaveHash(t *testing.T) {
+func TestUploadToDatastore_AdoptsMatchingCopyWithoutManifest(t *testing.T) {
 	env, cleanup := newSimEnv(t)
 	defer cleanup()
 
-	_, _, datastore := createTestVM(t, env, "iso-upload-always")
+	_, _, datastore := createTestVM(t, env, "iso-adopt")
 	manager := NewManager(env.ctx)
 
-	tmp := t.TempDir()
-	bin := filepath.Join(tmp, "bin")
-	require.NoError(t, os.MkdirAll(bin, 0755))
-	writeFakeExecutable(t, bin, "govc", "#!/bin/sh\nexit 0\n")
-	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	local := filepath.Join(t.TempDir(), "test.iso")
+	require.NoError(t, os.WriteFile(local, []byte("data"), 0644))
+	require.NoError(t, manager.UploadAlways(datastore, local, "ISO/test.iso"))
+	_, ok := manager.readManifest(datastore, "ISO/test.iso")
+	require.False(t, ok, "UploadAlways must not write a manifest")
+
+	uploads := 0
+	manager.SetUploadProgress(func(e UploadEvent) {
+		if e.Done {
+			uploads++
+		}
+	})
+	require.NoError(t, manager.UploadToDatastore(datastore, local, "ISO/test.iso"))
+	require.Zero(t, uploads)
+	_, ok = manager.readManifest(datastore, "ISO/test.iso")
+	require.True(t, ok)
+}
+
+func TestVerifyRemote_DetectsMismatch(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	_, _, datastore := createTestVM(t, env, "iso-verify-remote")
+	manager := NewManager(env.ctx)
 
-	local := filepath.Join(tmp, "nocloud.iso")
+	local := filepath.Join(t.TempDir(), "nocloud.iso")
 	require.NoError(t, os.WriteFile(local, []byte("data"), 0644))
+	require.NoError(t, manager.UploadAlways(datastore, local, "ISO/nocloud/nocloud.iso"))
 
-	err := manager.UploadAlways(datastore, local, "ISO/nocloud.iso", "vc", "user", "pass", true)
-	require.NoError(t, err)
+	sum := sha256Hex([]byte("data"))
+	require.NoError(t, manager.verifyRemote(datastore, "ISO/nocloud/nocloud.iso", sum, 4, true))
 
-	if _, err := os.Stat(hashFile(local)); err == nil {
-		t.Fatal("did not expect uploaded hash file for UploadAlways")
-	}
+	err := manager.verifyRemote(datastore, "ISO/nocloud/nocloud.iso", sum, 5, false)
+	require.ErrorContains(t, err, "expected 5")
+
+	err = manager.verifyRemote(datastore, "ISO/nocloud/nocloud.iso", sha256Hex([]byte("atad")), 4, false)
+	require.NoError(t, err, "size-only verification ignores content")
+	err = manager.verifyRemote(datastore, "ISO/nocloud/nocloud.iso", sha256Hex([]byte("atad")), 4, true)
+	require.ErrorContains(t, err, "expected "+sha256Hex([]byte("atad")))
+
+	require.Error(t, manager.verifyRemote(datastore, "ISO/nocloud/missing.iso", sum, 4, false))
 }
 
 func TestUploadWithGovmomi_ErrorPath(t *testing.T) {
@@ -326,11 +400,31 @@ func TestUploadWithGovmomi_ErrorPath(t *testing.T) {
 	_, _, datastore := createTestVM(t, env, "iso-upload-govmomi")
 	manager := NewManager(env.ctx)
 
-	local := filepath.Join(t.TempDir(), "govmomi.iso")
+	missing := filepath.Join(t.TempDir(), "missing.iso")
+	err := manager.uploadWithGovmomi(datastore, missing, "ISO/govmomi.iso", 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open local file")
+}
+
+func TestDeleteFromDatastore(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	_, _, datastore := createTestVM(t, env, "iso-delete")
+	manager := NewManager(env.ctx)
+
+	local := filepath.Join(t.TempDir(), "nocloud.iso")
 	require.NoError(t, os.WriteFile(local, []byte("data"), 0644))
+	require.NoError(t, manager.UploadAlways(datastore, local, "ISO/nocloud/delete-me.iso"))
 
-	err := manager.uploadWithGovmomi(datastore, local, "ISO/govmomi.iso")
-	require.Error(t, err)
+	require.NoError(t, manager.DeleteFromDatastore(datastore, "ISO/nocloud/delete-me.iso"))
+
+	exists, err := manager.CheckFileExists(datastore, "ISO/nocloud/delete-me.iso")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Already gone - not an error.
+	require.NoError(t, manager.DeleteFromDatastore(datastore, "ISO/nocloud/delete-me.iso"))
 }
 
 func TestEnsureCDROMsConnectedAfterBoot_NoCdroms(t *testing.T) {
@@ -467,3 +561,56 @@ func TestCheckFileExists_ContextCanceled(t *testing.T) {
 	_, err := manager.CheckFileExists(datastore, "ISO/anything.iso")
 	require.Error(t, err)
 }
+
+func TestDatastoreGC_KeepsMountedAndRecent(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	_, vmObj, datastore := createTestVM(t, env, "iso-gc")
+	manager := NewManager(env.ctx)
+
+	local := filepath.Join(t.TempDir(), "seed.iso")
+	require.NoError(t, os.WriteFile(local, []byte("data"), 0644))
+	for _, p := range []string{
+		"ISO/ubuntu/ubuntu-24.04" + configs.Defaults.ISO.UbuntuModifiedSuffix + ".iso",
+		"ISO/nocloud/nocloud-iso-gc.iso",
+		"ISO/nocloud/nocloud-crashed.iso",
+		"ISO/talos/talos-1.12.4.iso",
+	} {
+		require.NoError(t, manager.UploadAlways(datastore, local, p))
+	}
+	require.NoError(t, manager.MountSingleISO(vmObj, "["+datastore.Name()+"] ISO/nocloud/nocloud-iso-gc.iso", "NoCloud"))
+
+	artifacts, err := manager.ListDatastoreArtifacts(datastore)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 4)
+	byPath := map[string]DatastoreArtifact{}
+	for _, a := range artifacts {
+		byPath[a.Path] = a
+	}
+	require.Equal(t, []string{"iso-gc"}, byPath["ISO/nocloud/nocloud-iso-gc.iso"].MountedBy)
+	require.Equal(t, KindAutoinstall, byPath["ISO/ubuntu/ubuntu-24.04"+configs.Defaults.ISO.UbuntuModifiedSuffix+".iso"].Kind)
+	require.Equal(t, KindTalos, byPath["ISO/talos/talos-1.12.4.iso"].Kind)
+	require.EqualValues(t, 4, byPath["ISO/nocloud/nocloud-crashed.iso"].Size)
+
+	// Fresh uploads are protected by the age threshold.
+	deleted, err := manager.DatastoreGC(datastore, DatastoreGCOptions{OlderThan: time.Hour})
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+
+	deleted, err = manager.DatastoreGC(datastore, DatastoreGCOptions{Kinds: []string{KindNoCloud}, DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, "ISO/nocloud/nocloud-crashed.iso", deleted[0].Path)
+	exists, err := manager.CheckFileExists(datastore, "ISO/nocloud/nocloud-crashed.iso")
+	require.NoError(t, err)
+	require.True(t, exists, "dry run must not delete")
+
+	deleted, err = manager.DatastoreGC(datastore, DatastoreGCOptions{})
+	require.NoError(t, err)
+	require.Len(t, deleted, 3)
+	artifacts, err = manager.ListDatastoreArtifacts(datastore)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	require.Equal(t, "ISO/nocloud/nocloud-iso-gc.iso", artifacts[0].Path)
+}
@@ -7,13 +7,6 @@ import (
 	"testing"
 )
 
-func TestHashFileSuffix(t *testing.T) {
-	path := "/tmp/example.iso"
-	if got := hashFile(path); got != "/tmp/example.iso.uploaded.sha256" {
-		t.Fatalf("hashFile() = %q", got)
-	}
-}
-
 func TestComputeSHA256(t *testing.T) {
 	dir := t.TempDir()
 	p := filepath.Join(dir, "file.txt")
@@ -31,43 +24,6 @@ func TestComputeSHA256(t *testing.T) {
 	}
 }
 
-func TestNeedsUpload_NoHashFile(t *testing.T) {
-	dir := t.TempDir()
-	p := filepath.Join(dir, "a.iso")
-	if err := os.WriteFile(p, []byte("data"), 0644); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-	if !needsUpload(p) {
-		t.Fatalf("expected needsUpload to be true when no hash file exists")
-	}
-}
-
-func TestNeedsUpload_MatchAndChange(t *testing.T) {
-	dir := t.TempDir()
-	p := filepath.Join(dir, "a.iso")
-	if err := os.WriteFile(p, []byte("data"), 0644); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	saveUploadedHash(p)
-	if needsUpload(p) {
-		t.Fatalf("expected needsUpload false when hash matches")
-	}
-
-	if err := os.WriteFile(p, []byte("changed"), 0644); err != nil {
-		t.Fatalf("rewrite: %v", err)
-	}
-	if !needsUpload(p) {
-		t.Fatalf("expected needsUpload true after content change")
-	}
-}
-
-func TestNeedsUpload_MissingFile(t *testing.T) {
-	if !needsUpload(filepath.Join(t.TempDir(), "missing.iso")) {
-		t.Fatalf("expected needsUpload true when file missing")
-	}
-}
-
 func TestVerifyChecksum_OK(t *testing.T) {
 	mgr := NewManager(context.Background())
 	dir := t.TempDir()
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 	"github.com/stretchr/testify/mock"
 	"github.com/vmware/govmomi/object"
 )
@@ -22,18 +23,23 @@ func (m *ManagerInterface) ModifyUbuntuISO(originalISOPath string) (string, bool
 	return args.String(0), args.Bool(1), args.Error(2)
 }
 
+func (m *ManagerInterface) ModifyUbuntuISOWithOptions(originalISOPath string, opts iso.RemasterOptions) (string, bool, error) {
+	args := m.Called(originalISOPath, opts)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
 func (m *ManagerInterface) CreateNoCloudISO(userData, metaData, networkConfig, vmName string) (string, error) {
 	args := m.Called(userData, metaData, networkConfig, vmName)
 	return args.String(0), args.Error(1)
 }
 
-func (m *ManagerInterface) UploadToDatastore(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	args := m.Called(ds, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass, insecure)
+func (m *ManagerInterface) UploadToDatastore(ds *object.Datastore, localPath, remotePath string) error {
+	args := m.Called(ds, localPath, remotePath)
 	return args.Error(0)
 }
 
-func (m *ManagerInterface) UploadAlways(ds *object.Datastore, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	args := m.Called(ds, localPath, remotePath, vcenterHost, vcenterUser, vcenterPass, insecure)
+func (m *ManagerInterface) UploadAlways(ds *object.Datastore, localPath, remotePath string) error {
+	args := m.Called(ds, localPath, remotePath)
 	return args.Error(0)
 }
 
@@ -57,7 +63,7 @@ func (m *ManagerInterface) EnsureCDROMsConnectedAfterBoot(vm *object.VirtualMach
 	return args.Error(0)
 }
 
-func (m *ManagerInterface) DeleteFromDatastore(datastoreName, remotePath, vcenterHost, vcenterUser, vcenterPass string, insecure bool) error {
-	args := m.Called(datastoreName, remotePath, vcenterHost, vcenterUser, vcenterPass, insecure)
+func (m *ManagerInterface) DeleteFromDatastore(ds *object.Datastore, remotePath string) error {
+	args := m.Called(ds, remotePath)
 	return args.Error(0)
 }
@@ -1,10 +1,11 @@
 package iso
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -13,31 +14,172 @@ import (
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 )
 
-const isoModifierVersion = "2026-02-24-20.04-append-v2"
+const isoModifierVersion = "2026-10-18-inplace-v3"
 
 type isoMeta struct {
-	Version       string `json:"version"`
-	SourcePath    string `json:"source_path"`
-	SourceSize    int64  `json:"source_size"`
-	SourceModTime int64  `json:"source_mod_time"`
+	Version       string   `json:"version"`
+	SourcePath    string   `json:"source_path"`
+	SourceSize    int64    `json:"source_size"`
+	SourceModTime int64    `json:"source_mod_time"`
+	SeedURL       string   `json:"seed_url,omitempty"`
+	KernelArgs    []string `json:"kernel_args,omitempty"`
+	MenuDefault   string   `json:"menu_default,omitempty"`
+	MenuTimeout   *int     `json:"menu_timeout,omitempty"`
 }
 
-// ModifyUbuntuISO modifies Ubuntu ISO to enable autoinstall mode.
+// options returns the remaster options recorded in the meta.
+func (m isoMeta) options() RemasterOptions {
+	return RemasterOptions{SeedURL: m.SeedURL, KernelArgs: m.KernelArgs, MenuDefault: m.MenuDefault, MenuTimeout: m.MenuTimeout}
+}
+
+// RemasterOptions selects a variant of the autoinstall remaster. The zero
+// value is the default remaster that reads its seed from a CIDATA ISO.
+type RemasterOptions struct {
+	// SeedURL makes the installer fetch its cloud-init seed over HTTP
+	// (ds=nocloud-net;s=SeedURL), see package seed.
+	SeedURL string
+	// KernelArgs are added to the installer kernel command line before "---"
+	// (e.g. "console=ttyS0,115200", "ip=dhcp", "debug").
+	KernelArgs []string
+	// MenuDefault is the default boot menu entry, as index or title (default "0").
+	MenuDefault string
+	// MenuTimeout is the boot menu timeout in seconds (nil = iso.grub_timeout_seconds).
+	MenuTimeout *int
+}
+
+// Validate checks that the options can be written into a boot config.
+func (o RemasterOptions) Validate() error {
+	for _, arg := range o.KernelArgs {
+		switch {
+		case arg == "":
+			return fmt.Errorf("empty installer kernel argument")
+		case arg == "---":
+			return fmt.Errorf("installer kernel argument %q: the separator is added by the remaster", arg)
+		case strings.ContainsAny(arg, " \t\r\n\"'`$\\"):
+			return fmt.Errorf("installer kernel argument %q: whitespace, quotes, $ and \\ are not allowed", arg)
+		}
+	}
+	if strings.ContainsAny(o.MenuDefault, "\r\n\"'`$\\") {
+		return fmt.Errorf("invalid installer menu default %q", o.MenuDefault)
+	}
+	if o.MenuTimeout != nil && *o.MenuTimeout < 0 {
+		return fmt.Errorf("installer menu timeout must not be negative (got %d)", *o.MenuTimeout)
+	}
+	return nil
+}
+
+// key is the canonical form of the options. Its hash tags non-default
+// remasters (see variant); a seed URL alone keys as itself.
+func (o RemasterOptions) key() string {
+	k := o.SeedURL
+	if len(o.KernelArgs) > 0 {
+		k += "\x00args=" + strings.Join(o.KernelArgs, " ")
+	}
+	if o.MenuDefault != "" {
+		k += "\x00default=" + o.MenuDefault
+	}
+	if o.MenuTimeout != nil {
+		k += fmt.Sprintf("\x00timeout=%d", *o.MenuTimeout)
+	}
+	return k
+}
+
+// variant returns a short tag distinguishing non-default remasters in file
+// names, so variants are cached and uploaded side by side.
+func (o RemasterOptions) variant() string {
+	k := o.key()
+	if k == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(k))
+	return "-" + hex.EncodeToString(sum[:4])
+}
+
+// datasource returns the cloud-init datasource kernel parameter. GRUB treats
+// ";" as a command separator, so it is escaped there but not for ISOLINUX.
+func (o RemasterOptions) datasource(grub bool) string {
+	if o.SeedURL == "" {
+		return "ds=nocloud"
+	}
+	sep := ";"
+	if grub {
+		sep = `\;`
+	}
+	return "ds=nocloud-net" + sep + "s=" + o.SeedURL
+}
+
+// kernelParams returns the parameters inserted before "---": autoinstall,
+// the datasource and KernelArgs, escaped for GRUB when grub is set.
+func (o RemasterOptions) kernelParams(grub bool) string {
+	params := []string{"autoinstall", o.datasource(grub)}
+	for _, arg := range o.KernelArgs {
+		if grub {
+			arg = grubSpecial.Replace(arg)
+		}
+		params = append(params, arg)
+	}
+	return strings.Join(params, " ")
+}
+
+// grubSpecial escapes the GRUB metacharacters allowed in kernel arguments.
+var grubSpecial = strings.NewReplacer(";", `\;`, "&", `\&`, "|", `\|`, "<", `\<`, ">", `\>`)
+
+// menuDefault returns the GRUB "set default=" value.
+func (o RemasterOptions) menuDefault() string {
+	switch {
+	case o.MenuDefault == "":
+		return "0"
+	case strings.ContainsAny(o.MenuDefault, " \t;&|<>{}"):
+		return `"` + o.MenuDefault + `"`
+	}
+	return o.MenuDefault
+}
+
+// menuTimeout returns the boot menu timeout in seconds.
+func (o RemasterOptions) menuTimeout() int {
+	if o.MenuTimeout != nil {
+		return *o.MenuTimeout
+	}
+	return configs.Defaults.ISO.GRUBTimeoutSeconds
+}
+
+// ModifyUbuntuISO remasters the Ubuntu ISO with the default options.
+func (m *Manager) ModifyUbuntuISO(originalISOPath string) (path string, wasCreated bool, err error) {
+	return m.ModifyUbuntuISOWithOptions(originalISOPath, RemasterOptions{})
+}
+
+// ModifyUbuntuISOWithOptions modifies Ubuntu ISO to enable autoinstall mode.
 // Returns path to modified ISO and whether it was newly created (vs cached).
 // wasCreated=true means datastore upload should be forced (overwrite stale version).
 //
+// The ISO is remastered in-process (no xorriso/genisoimage): only the boot
+// configs are rewritten in a copy of the image, see remasterISO.
+//
 // Modifications:
-// - GRUB timeout: 30s → 5s
-// - Kernel parameter: adds "autoinstall ds=nocloud"
-// - Default boot entry: set default=0
-func (m *Manager) ModifyUbuntuISO(originalISOPath string) (path string, wasCreated bool, err error) {
+// - GRUB timeout: 30s → 5s (or opts.MenuTimeout)
+// - Kernel parameter: adds "autoinstall ds=nocloud" (or the nocloud-net seed URL) and opts.KernelArgs
+// - Default boot entry: set default=0 (or opts.MenuDefault)
+//
+// Non-default options produce <name><suffix>-<variant>.iso next to the default
+// remaster; the variant is a hash of the options.
+func (m *Manager) ModifyUbuntuISOWithOptions(originalISOPath string, opts RemasterOptions) (path string, wasCreated bool, err error) {
+	if err := opts.Validate(); err != nil {
+		return "", false, err
+	}
+
 	// Create modified ISO filename
 	dir := filepath.Dir(originalISOPath)
 	base := filepath.Base(originalISOPath)
-	modifiedName := strings.Replace(base, ".iso", configs.Defaults.ISO.UbuntuModifiedSuffix+".iso", 1)
+	modifiedName := strings.Replace(base, ".iso", configs.Defaults.ISO.UbuntuModifiedSuffix+opts.variant()+".iso", 1)
 	modifiedPath := filepath.Join(dir, modifiedName)
 	metaPath := modifiedPath + ".meta.json"
 
+	unlock, err := lockArtifact(m.ctx, modifiedPath)
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
 	srcInfo, srcErr := os.Stat(originalISOPath)
 	if srcErr != nil {
 		return "", false, fmt.Errorf("stat source ISO: %w", srcErr)
@@ -47,6 +189,7 @@ func (m *Manager) ModifyUbuntuISO(originalISOPath string) (path string, wasCreat
 	if _, err := os.Stat(modifiedPath); err == nil {
 		if meta, err := readISOMeta(metaPath); err == nil {
 			if meta.Version == isoModifierVersion &&
+				meta.options().key() == opts.key() &&
 				meta.SourceSize == srcInfo.Size() &&
 				meta.SourceModTime == srcInfo.ModTime().Unix() {
 				fmt.Printf("✅ Modified Ubuntu ISO already exists: %s\n", modifiedPath)
@@ -59,54 +202,35 @@ func (m *Manager) ModifyUbuntuISO(originalISOPath string) (path string, wasCreat
 	}
 
 	fmt.Println("⚙️  Modifying Ubuntu ISO for autoinstall...")
-
-	// Step 1: Extract ISO
-	extractDir := filepath.Join(dir, configs.Defaults.ISO.ExtractDirName)
-
-	// Clean up previous extraction (files may be read-only)
-	if err := m.cleanupExtractDir(extractDir); err != nil {
-		return "", false, fmt.Errorf("failed to clean extract dir: %w", err)
-	}
-	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return "", false, fmt.Errorf("failed to create extract dir: %w", err)
+	started := time.Now()
+	tmpPath := modifiedPath + ".tmp"
+	if err := m.remasterISO(originalISOPath, tmpPath, opts); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", false, err
 	}
-	defer func() {
-		_ = os.RemoveAll(extractDir)
-	}() // Cleanup
-
-	fmt.Println("   Extracting ISO (this may take 1-2 minutes)...")
-	if err := m.extractISOWithProgress(originalISOPath, extractDir); err != nil {
-		return "", false, fmt.Errorf("failed to extract ISO: %w", err)
-	}
-
-	// Make all extracted files writable (xorriso extracts as read-only)
-	fmt.Println("   Setting file permissions...")
-	if err := m.makeExtractedFilesWritable(extractDir); err != nil {
-		return "", false, fmt.Errorf("failed to set permissions: %w", err)
-	}
-
-	// Step 2: Modify GRUB configs
-	fmt.Println("   Modifying GRUB configuration...")
-	if err := m.modifyGRUBConfigs(extractDir); err != nil {
-		return "", false, fmt.Errorf("failed to modify GRUB configs: %w", err)
-	}
-
-	// Step 3: Repack ISO
-	fmt.Println("   Repacking ISO...")
-	if err := m.repackISO(extractDir, modifiedPath); err != nil {
-		return "", false, fmt.Errorf("failed to repack ISO: %w", err)
+	if err := os.Rename(tmpPath, modifiedPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", false, fmt.Errorf("failed to store modified ISO: %w", err)
 	}
+	fmt.Printf("   Remastered in %d seconds\n", int(time.Since(started).Seconds()))
 
 	if err := writeISOMeta(metaPath, isoMeta{
 		Version:       isoModifierVersion,
 		SourcePath:    originalISOPath,
 		SourceSize:    srcInfo.Size(),
 		SourceModTime: srcInfo.ModTime().Unix(),
+		SeedURL:       opts.SeedURL,
+		KernelArgs:    opts.KernelArgs,
+		MenuDefault:   opts.MenuDefault,
+		MenuTimeout:   opts.MenuTimeout,
 	}); err != nil {
 		return "", false, fmt.Errorf("failed to write ISO metadata: %w", err)
 	}
 
 	fmt.Printf("✅ Ubuntu ISO modified successfully: %s\n", modifiedPath)
+	if err := NewCache(dir).EnforceLimit(modifiedPath, originalISOPath); err != nil {
+		fmt.Printf("⚠️  Cache size limit: %v\n", err)
+	}
 	return modifiedPath, true, nil
 }
 
@@ -130,145 +254,89 @@ func writeISOMeta(path string, meta isoMeta) error {
 	return os.WriteFile(path, data, 0644)
 }
 
-// extractISOWithProgress extracts ISO with progress indicator
-func (m *Manager) extractISOWithProgress(isoPath, extractDir string) error {
-	// Start extraction in background
-	cmd := exec.CommandContext(m.ctx, "xorriso",
-		"-osirrox", "on",
-		"-indev", isoPath,
-		"-extract", "/", extractDir,
-	)
-
-	// Start command
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start xorriso: %w", err)
-	}
-
-	// Progress ticker
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Wait()
-	}()
-
-	ticker := time.NewTicker(configs.Defaults.Timeouts.ExtractProgress())
-	defer ticker.Stop()
-
-	startTime := time.Now()
-	for {
-		select {
-		case err := <-done:
-			if err != nil {
-				return fmt.Errorf("xorriso extract failed: %w", err)
-			}
-			elapsed := time.Since(startTime)
-			fmt.Printf("\r   Extraction complete (took %d seconds)\n", int(elapsed.Seconds()))
-			return nil
-
-		case <-ticker.C:
-			elapsed := time.Since(startTime)
-			fmt.Printf("\r   Extracting... %ds elapsed", int(elapsed.Seconds()))
-		}
-	}
-}
-
-// makeExtractedFilesWritable makes all extracted files writable
-// xorriso extracts files as read-only by default
-func (m *Manager) makeExtractedFilesWritable(extractDir string) error {
-	return filepath.Walk(extractDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Make file/directory writable (add write permission for owner)
-		currentMode := info.Mode()
-		newMode := currentMode | 0200 // Add write permission for owner
-
-		if err := os.Chmod(path, newMode); err != nil {
-			// Ignore permission errors (some files may be protected)
-			return nil
-		}
-
-		return nil
-	})
+// bootConfigFiles are the boot menu configs patched for autoinstall.
+var bootConfigFiles = []string{
+	"boot/grub/grub.cfg",     // UEFI boot
+	"boot/grub/loopback.cfg", // Loopback boot
+	"isolinux/txt.cfg",       // BIOS boot (20.04)
 }
 
-// cleanupExtractDir removes extract directory, handling read-only files
-func (m *Manager) cleanupExtractDir(extractDir string) error {
-	// Check if directory exists
-	if _, err := os.Stat(extractDir); os.IsNotExist(err) {
-		// Directory doesn't exist - nothing to clean
-		return nil
+// remasterISO writes a copy of isoPath with autoinstall boot configs to outputPath.
+// Only the config files are rewritten (see isoImage); boot records, the EFI
+// partition and all other files are left byte-for-byte intact.
+func (m *Manager) remasterISO(isoPath, outputPath string, opts RemasterOptions) error {
+	fmt.Println("   Copying ISO...")
+	if err := copyFile(isoPath, outputPath); err != nil {
+		return fmt.Errorf("failed to copy ISO: %w", err)
 	}
-
-	// Make all files writable first (xorriso creates read-only files)
-	_ = m.makeExtractedFilesWritable(extractDir) // Ignore errors, try removal anyway
-
-	// Now remove directory
-	if err := os.RemoveAll(extractDir); err != nil {
+	if err := m.ctx.Err(); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-// modifyGRUBConfigs finds and modifies all GRUB configuration files
-func (m *Manager) modifyGRUBConfigs(extractDir string) error {
-	// List of GRUB config files to modify (from Python implementation)
-	configFiles := []string{
-		"boot/grub/grub.cfg",     // UEFI boot
-		"boot/grub/loopback.cfg", // Loopback boot
-		"isolinux/txt.cfg",       // BIOS boot
+	f, err := os.OpenFile(outputPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	img, err := openISOImage(f)
+	if err != nil {
+		return fmt.Errorf("failed to read ISO: %w", err)
 	}
 
+	fmt.Println("   Modifying GRUB configuration...")
 	modifiedCount := 0
-	for _, relPath := range configFiles {
-		fullPath := filepath.Join(extractDir, relPath)
-
-		// Skip if file doesn't exist (ISO structure may vary)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			continue
-		}
-
-		if err := m.modifyGRUBFile(fullPath); err != nil {
+	for _, relPath := range bootConfigFiles {
+		found, changed, err := img.patchFile(relPath, func(content []byte) []byte {
+			return modifyBootConfig(content, opts)
+		})
+		if err != nil {
 			// Don't fail entire process if one file fails (best-effort)
 			fmt.Printf("   ⚠️  Warning: Failed to modify %s: %v\n", relPath, err)
 			continue
 		}
-
+		if !found {
+			continue // ISO structure varies between releases
+		}
 		modifiedCount++
-		fmt.Printf("   ✅ Modified: %s\n", relPath)
+		if changed {
+			fmt.Printf("   ✅ Modified: %s\n", relPath)
+		}
 	}
-
 	if modifiedCount == 0 {
 		return fmt.Errorf("no GRUB config files found or modified")
 	}
 
-	return nil
+	if err := img.finish(); err != nil {
+		return err
+	}
+	return f.Sync()
 }
 
-// modifyGRUBFile modifies a single GRUB config file
-func (m *Manager) modifyGRUBFile(filePath string) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
+// modifyGRUBConfig returns a GRUB or ISOLINUX config set up for the default autoinstall.
+func modifyGRUBConfig(content []byte) []byte {
+	return modifyBootConfig(content, RemasterOptions{})
+}
 
-	original := string(content)
-	modified := original
+// modifyBootConfig returns a GRUB or ISOLINUX config set up for autoinstall with opts.
+func modifyBootConfig(content []byte, opts RemasterOptions) []byte {
+	modified := string(content)
 
 	// 1. Fix timeout value (30 → 5 seconds)
 	// Matches: "timeout 30", "set timeout=30", "timeout=30"
 	timeoutRegex := regexp.MustCompile(`(?m)(^|\s)(timeout|set timeout)(\s*=?\s*)(\d+)`)
-	modified = timeoutRegex.ReplaceAllString(modified, fmt.Sprintf("${1}${2}${3}%d", configs.Defaults.ISO.GRUBTimeoutSeconds))
-
-	// 2. Set default boot entry to 0 (first entry)
-	// Add or replace "set default=X" with "set default=0"
-	if strings.Contains(modified, "set default=") {
-		defaultRegex := regexp.MustCompile(`set default=\d+`)
-		modified = defaultRegex.ReplaceAllString(modified, "set default=0")
+	modified = timeoutRegex.ReplaceAllString(modified, fmt.Sprintf("${1}${2}${3}%d", opts.menuTimeout()))
+
+	// 2. Set default boot entry (first entry unless opts.MenuDefault)
+	// Add or replace "set default=X"
+	def := "set default=" + opts.menuDefault()
+	defaultRegex := regexp.MustCompile(`(?m)set default=("[^"\n]*"|\S+)`)
+	if defaultRegex.MatchString(modified) {
+		modified = defaultRegex.ReplaceAllLiteralString(modified, def)
 	} else {
-		// Add "set default=0" at beginning if not present
-		modified = "set default=0\n" + modified
+		// Add the default at beginning if not present
+		modified = def + "\n" + modified
 	}
 
 	// 3. Add kernel parameter "autoinstall ds=nocloud"
@@ -294,10 +362,11 @@ func (m *Manager) modifyGRUBFile(filePath string) error {
 		// CRITICAL: Use "autoinstall ds=nocloud" (WITHOUT path specification!)
 		// Do NOT add path like ds=nocloud;s=/cdrom - that's for single-ISO method
 		// We use dual-ISO: Ubuntu boot + NoCloud seed with CIDATA label (auto-detected)
+		kp := opts.kernelParams(true)
 		if strings.Contains(params, "---") {
-			params = strings.Replace(params, "---", "autoinstall ds=nocloud ---", 1)
+			params = strings.Replace(params, "---", kp+" ---", 1)
 		} else {
-			params = " autoinstall ds=nocloud" + params
+			params = " " + kp + params
 		}
 
 		return kernelCmd + params
@@ -315,104 +384,14 @@ func (m *Manager) modifyGRUBFile(filePath string) error {
 		}
 		prefix := parts[1]
 		params := parts[2]
+		kp := opts.kernelParams(false)
 		if strings.Contains(params, "---") {
-			params = strings.Replace(params, "---", "autoinstall ds=nocloud ---", 1)
+			params = strings.Replace(params, "---", kp+" ---", 1)
 		} else {
-			params = "autoinstall ds=nocloud " + params
+			params = kp + " " + params
 		}
 		return prefix + params
 	})
 
-	// Only write if content changed
-	if modified == original {
-		return nil
-	}
-
-	// Write modified content
-	if err := os.WriteFile(filePath, []byte(modified), 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
-}
-
-// repackISO repacks extracted directory to ISO using genisoimage
-// Matches Python implementation exactly - genisoimage handles large ISOs better than xorriso
-func (m *Manager) repackISO(extractDir, outputPath string) error {
-	// Find BIOS boot image (supports both grub and isolinux layouts)
-	type biosBoot struct {
-		path    string
-		catalog string
-	}
-	biosCandidates := []biosBoot{
-		{path: "boot/grub/i386-pc/eltorito.img", catalog: "boot.catalog"},
-		{path: "isolinux/isolinux.bin", catalog: "isolinux/boot.cat"},
-	}
-	var biosBootPath string
-	var biosCatalog string
-	for _, candidate := range biosCandidates {
-		fullPath := filepath.Join(extractDir, candidate.path)
-		if _, err := os.Stat(fullPath); err == nil {
-			biosBootPath = candidate.path
-			biosCatalog = candidate.catalog
-			break
-		}
-	}
-	if biosBootPath == "" {
-		return fmt.Errorf("BIOS boot image not found")
-	}
-
-	// Find UEFI boot image (try multiple locations like Python does)
-	uefiBootCandidates := []string{
-		"boot/grub/efi.img",
-		"EFI/ubuntu/grubx64.efi",
-		"EFI/boot/bootx64.efi",
-	}
-
-	var uefiBootPath string
-	for _, candidate := range uefiBootCandidates {
-		fullPath := filepath.Join(extractDir, candidate)
-		if _, err := os.Stat(fullPath); err == nil {
-			uefiBootPath = candidate
-			fmt.Printf("   Found EFI boot: %s\n", candidate)
-			break
-		}
-	}
-
-	// Build genisoimage command (matches Python exactly)
-	args := []string{
-		"-r",                                      // Rock Ridge
-		"-V", configs.Defaults.ISO.UbuntuVolumeID, // Volume ID (matches Python)
-		"-J", "-joliet-long", // Joliet
-		"-o", outputPath, // Output file
-		// BIOS boot (legacy)
-		"-b", biosBootPath,
-		"-c", biosCatalog,
-		"-no-emul-boot",
-		"-boot-load-size", "4",
-		"-boot-info-table",
-	}
-
-	// Add UEFI boot if found
-	if uefiBootPath != "" {
-		args = append(args,
-			"-eltorito-alt-boot",
-			"-e", uefiBootPath,
-			"-no-emul-boot",
-		)
-	} else {
-		fmt.Println("   ⚠️  EFI boot not found - creating BIOS-only ISO")
-	}
-
-	// Source directory
-	args = append(args, extractDir)
-
-	// Execute genisoimage
-	cmd := exec.CommandContext(m.ctx, "genisoimage", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("genisoimage failed: %w\nOutput: %s", err, string(output))
-	}
-
-	return nil
+	return []byte(modified)
 }
@@ -11,29 +11,13 @@ import (
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 )
 
-func TestModifyGRUBFile_AddsDefaultTimeoutAndAutoinstall(t *testing.T) {
-	m := NewManager(context.Background())
-	tmp := t.TempDir()
-	cfg := filepath.Join(tmp, "grub.cfg")
-
+func TestModifyGRUBConfig_AddsDefaultTimeoutAndAutoinstall(t *testing.T) {
 	original := `set timeout=30
 menuentry "Install" {
  linux /casper/vmlinuz --- 
 }
 `
-	if err := os.WriteFile(cfg, []byte(original), 0644); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	if err := m.modifyGRUBFile(cfg); err != nil {
-		t.Fatalf("modifyGRUBFile: %v", err)
-	}
-
-	updated, err := os.ReadFile(cfg)
-	if err != nil {
-		t.Fatalf("read: %v", err)
-	}
-	s := string(updated)
+	s := string(modifyGRUBConfig([]byte(original)))
 
 	if !strings.Contains(s, "set default=0") {
 		t.Errorf("expected set default=0 to be present")
@@ -46,30 +30,14 @@ menuentry "Install" {
 	}
 }
 
-func TestModifyGRUBFile_DoesNotDuplicateAutoinstall(t *testing.T) {
-	m := NewManager(context.Background())
-	tmp := t.TempDir()
-	cfg := filepath.Join(tmp, "grub.cfg")
-
+func TestModifyGRUBConfig_DoesNotDuplicateAutoinstall(t *testing.T) {
 	original := `set timeout=30
 set default=1
 menuentry "Install" {
  linux /casper/vmlinuz autoinstall ds=nocloud --- 
 }
 `
-	if err := os.WriteFile(cfg, []byte(original), 0644); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	if err := m.modifyGRUBFile(cfg); err != nil {
-		t.Fatalf("modifyGRUBFile: %v", err)
-	}
-
-	updated, err := os.ReadFile(cfg)
-	if err != nil {
-		t.Fatalf("read: %v", err)
-	}
-	s := string(updated)
+	s := string(modifyGRUBConfig([]byte(original)))
 
 	if strings.Count(s, "autoinstall") != 1 {
 		t.Errorf("expected autoinstall to appear once, got:\n%s", s)
@@ -79,119 +47,19 @@ menuentry "Install" {
 	}
 }
 
-func TestModifyGRUBFile_AddsAutoinstallToIsolinuxAppend(t *testing.T) {
-	m := NewManager(context.Background())
-	tmp := t.TempDir()
-	cfg := filepath.Join(tmp, "txt.cfg")
-
+func TestModifyGRUBConfig_AddsAutoinstallToIsolinuxAppend(t *testing.T) {
 	original := `default live
 label live
   menu label ^Install Ubuntu Server
   kernel /casper/vmlinuz
   append   initrd=/casper/initrd quiet  ---
 `
-	if err := os.WriteFile(cfg, []byte(original), 0644); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	if err := m.modifyGRUBFile(cfg); err != nil {
-		t.Fatalf("modifyGRUBFile: %v", err)
-	}
-
-	updated, err := os.ReadFile(cfg)
-	if err != nil {
-		t.Fatalf("read: %v", err)
-	}
-	s := string(updated)
+	s := string(modifyGRUBConfig([]byte(original)))
 	if !strings.Contains(s, "append") || !strings.Contains(s, "autoinstall ds=nocloud ---") {
 		t.Errorf("expected autoinstall on append line, got:\n%s", s)
 	}
 }
 
-func TestModifyGRUBConfigs_NoFiles(t *testing.T) {
-	m := NewManager(context.Background())
-	tmp := t.TempDir()
-
-	if err := m.modifyGRUBConfigs(tmp); err == nil {
-		t.Fatalf("expected error when no GRUB files exist")
-	}
-}
-
-func TestModifyGRUBConfigs_OneFile(t *testing.T) {
-	m := NewManager(context.Background())
-	tmp := t.TempDir()
-
-	cfg := filepath.Join(tmp, "boot/grub/grub.cfg")
-	if err := os.MkdirAll(filepath.Dir(cfg), 0755); err != nil {
-		t.Fatalf("mkdir: %v", err)
-	}
-	if err := os.WriteFile(cfg, []byte("timeout 30\nlinux /vmlinuz ---\n"), 0644); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	if err := m.modifyGRUBConfigs(tmp); err != nil {
-		t.Fatalf("modifyGRUBConfigs: %v", err)
-	}
-}
-
-func TestRepackISO_MissingBIOSBoot(t *testing.T) {
-	m := NewManager(context.Background())
-	tmp := t.TempDir()
-	out := filepath.Join(tmp, "out.iso")
-
-	if err := m.repackISO(tmp, out); err == nil {
-		t.Fatalf("expected error when BIOS boot image missing")
-	}
-}
-
-func TestModifyGRUBFile_MissingFile(t *testing.T) {
-	mgr := NewManager(context.Background())
-	err := mgr.modifyGRUBFile(filepath.Join(t.TempDir(), "missing.cfg"))
-	if err == nil {
-		t.Fatal("expected error for missing file")
-	}
-}
-
-func TestMakeExtractedFilesWritable(t *testing.T) {
-	mgr := NewManager(context.Background())
-	dir := t.TempDir()
-	file := filepath.Join(dir, "file.txt")
-	if err := os.WriteFile(file, []byte("data"), 0444); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	if err := mgr.makeExtractedFilesWritable(dir); err != nil {
-		t.Fatalf("makeExtractedFilesWritable: %v", err)
-	}
-
-	info, err := os.Stat(file)
-	if err != nil {
-		t.Fatalf("stat: %v", err)
-	}
-	if info.Mode()&0200 == 0 {
-		t.Fatalf("expected owner write bit to be set, mode=%v", info.Mode())
-	}
-}
-
-func TestCleanupExtractDir(t *testing.T) {
-	mgr := NewManager(context.Background())
-	dir := filepath.Join(t.TempDir(), "extract")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		t.Fatalf("mkdir: %v", err)
-	}
-	file := filepath.Join(dir, "file.txt")
-	if err := os.WriteFile(file, []byte("data"), 0444); err != nil {
-		t.Fatalf("write: %v", err)
-	}
-
-	if err := mgr.cleanupExtractDir(dir); err != nil {
-		t.Fatalf("cleanupExtractDir: %v", err)
-	}
-	if _, err := os.Stat(dir); !os.IsNotExist(err) {
-		t.Fatalf("expected dir to be removed, err=%v", err)
-	}
-}
-
 func TestModifyUbuntuISO_UsesCachedWhenMetaMatches(t *testing.T) {
 	m := NewManager(context.Background())
 	tmp := t.TempDir()
@@ -233,3 +101,102 @@ func TestModifyUbuntuISO_UsesCachedWhenMetaMatches(t *testing.T) {
 		t.Fatalf("expected %s, got %s", modified, got)
 	}
 }
+
+func TestModifyBootConfig_SeedURL(t *testing.T) {
+	opts := RemasterOptions{SeedURL: "http://10.0.0.5:8600/__dmi.system-uuid__/"}
+
+	grub := string(modifyBootConfig([]byte("menuentry \"Install\" {\n linux /casper/vmlinuz ---\n}\n"), opts))
+	if !strings.Contains(grub, `autoinstall ds=nocloud-net\;s=http://10.0.0.5:8600/__dmi.system-uuid__/ ---`) {
+		t.Errorf("expected escaped nocloud-net seed in GRUB config, got:\n%s", grub)
+	}
+
+	isolinux := string(modifyBootConfig([]byte("label live\n  append initrd=/casper/initrd ---\n"), opts))
+	if !strings.Contains(isolinux, "autoinstall ds=nocloud-net;s=http://10.0.0.5:8600/__dmi.system-uuid__/ ---") {
+		t.Errorf("expected nocloud-net seed in ISOLINUX config, got:\n%s", isolinux)
+	}
+}
+
+func TestRemasterOptions_Variant(t *testing.T) {
+	if v := (RemasterOptions{}).variant(); v != "" {
+		t.Errorf("default variant = %q, want empty", v)
+	}
+	a := RemasterOptions{SeedURL: "http://a/"}.variant()
+	b := RemasterOptions{SeedURL: "http://b/"}.variant()
+	if len(a) != 9 || a == b {
+		t.Errorf("variants %q and %q should be distinct 8-digit tags", a, b)
+	}
+}
+
+func TestModifyBootConfig_InstallerOptions(t *testing.T) {
+	timeout := 0
+	opts := RemasterOptions{
+		KernelArgs:  []string{"console=ttyS0,115200", "ip=dhcp", "proxy=http://proxy:3128/a;b"},
+		MenuDefault: "Try or Install Ubuntu Server",
+		MenuTimeout: &timeout,
+	}
+	grub := string(modifyBootConfig([]byte("set timeout=30\nset default=1\nmenuentry \"Install\" {\n linux /casper/vmlinuz quiet ---\n}\n"), opts))
+	for _, want := range []string{
+		`set timeout=0`,
+		`set default="Try or Install Ubuntu Server"`,
+		`linux /casper/vmlinuz quiet autoinstall ds=nocloud console=ttyS0,115200 ip=dhcp proxy=http://proxy:3128/a\;b ---`,
+	} {
+		if !strings.Contains(grub, want) {
+			t.Errorf("GRUB config lacks %q:\n%s", want, grub)
+		}
+	}
+
+	isolinux := string(modifyBootConfig([]byte("label live\n  append initrd=/casper/initrd ---\n"), opts))
+	if !strings.Contains(isolinux, "autoinstall ds=nocloud console=ttyS0,115200 ip=dhcp proxy=http://proxy:3128/a;b ---") {
+		t.Errorf("ISOLINUX config lacks kernel args:\n%s", isolinux)
+	}
+}
+
+func TestRemasterOptions_VariantCoversInstallerOptions(t *testing.T) {
+	one, two := 1, 2
+	variants := map[string]RemasterOptions{}
+	for _, o := range []RemasterOptions{
+		{KernelArgs: []string{"debug"}},
+		{KernelArgs: []string{"debug", "ip=dhcp"}},
+		{MenuDefault: "1"},
+		{MenuTimeout: &one},
+		{MenuTimeout: &two},
+		{SeedURL: "http://a/", KernelArgs: []string{"debug"}},
+	} {
+		v := o.variant()
+		if prev, ok := variants[v]; ok || !remasterVariant.MatchString(v) {
+			t.Fatalf("variant %q of %+v (clashes with %+v)", v, o, prev)
+		}
+		variants[v] = o
+	}
+	if a, b := (RemasterOptions{SeedURL: "http://a/"}).variant(), (RemasterOptions{SeedURL: "http://a/", KernelArgs: []string{}}).variant(); a != b {
+		t.Errorf("empty kernel args change the variant: %q != %q", a, b)
+	}
+	meta := isoMeta{SeedURL: "http://a/", KernelArgs: []string{"debug"}, MenuTimeout: &one}
+	if meta.options().key() != (RemasterOptions{SeedURL: "http://a/", KernelArgs: []string{"debug"}, MenuTimeout: &one}).key() {
+		t.Error("remaster meta does not round-trip the options")
+	}
+}
+
+func TestRemasterOptions_Validate(t *testing.T) {
+	negative := -1
+	for _, o := range []RemasterOptions{
+		{KernelArgs: []string{""}},
+		{KernelArgs: []string{"---"}},
+		{KernelArgs: []string{"a b"}},
+		{KernelArgs: []string{`quiet"`}},
+		{KernelArgs: []string{"x=$y"}},
+		{MenuDefault: "a\nb"},
+		{MenuTimeout: &negative},
+	} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", o)
+		}
+	}
+	if err := (RemasterOptions{KernelArgs: []string{"console=ttyS0,115200n8", "ip=dhcp"}, MenuDefault: "Install"}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	m := NewManager(context.Background())
+	if _, _, err := m.ModifyUbuntuISOWithOptions(filepath.Join(t.TempDir(), "x.iso"), RemasterOptions{KernelArgs: []string{"a b"}}); err == nil {
+		t.Error("ModifyUbuntuISOWithOptions accepted invalid options")
+	}
+}
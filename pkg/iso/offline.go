@@ -0,0 +1,195 @@
+package iso
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+)
+
+// Artifacts imported from an offline bundle (vmbootstrap bundle import) are
+// kept in <cache>/bundle, indexed by the URL they were downloaded from.
+// Download serves those URLs from disk, so sites without internet access
+// resolve ISOs, checksum files, signatures and signing keys from the bundle.
+const (
+	offlineDirName   = "bundle"
+	offlineIndexName = "index.json"
+)
+
+// ErrNotInBundle is returned for downloads missing from the offline bundle
+// when iso.offline is set.
+var ErrNotInBundle = errors.New("not in the offline bundle (import one with vmbootstrap bundle import)")
+
+// OfflineArtifact is one file of an imported bundle.
+type OfflineArtifact struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"` // relative to the index directory
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// OfflineIndex maps source URLs to imported bundle files.
+type OfflineIndex struct {
+	Dir       string                     `json:"-"`
+	Artifacts map[string]OfflineArtifact `json:"artifacts"` // normalized URL → artifact
+}
+
+// OfflineDir returns the directory imported bundles are kept in.
+func OfflineDir() string {
+	return NewCache("").OfflineDir()
+}
+
+// OfflineDir returns the directory bundles are imported into in this cache.
+func (c *Cache) OfflineDir() string {
+	return filepath.Join(c.Dir, offlineDirName)
+}
+
+// LoadOfflineIndex reads the index in dir ("" = OfflineDir()). A missing
+// index is empty.
+func LoadOfflineIndex(dir string) (*OfflineIndex, error) {
+	if dir == "" {
+		dir = OfflineDir()
+	}
+	idx := &OfflineIndex{Dir: dir, Artifacts: map[string]OfflineArtifact{}}
+	data, err := os.ReadFile(filepath.Join(dir, offlineIndexName))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("read offline index %s: %w", dir, err)
+	}
+	if idx.Artifacts == nil {
+		idx.Artifacts = map[string]OfflineArtifact{}
+	}
+	return idx, nil
+}
+
+// Add records a, replacing an earlier artifact for the same URL.
+func (x *OfflineIndex) Add(a OfflineArtifact) {
+	x.Artifacts[normalizeURL(a.URL)] = a
+}
+
+// Save writes the index.
+func (x *OfflineIndex) Save() error {
+	if err := os.MkdirAll(x.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(x.Dir, offlineIndexName+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(x.Dir, offlineIndexName))
+}
+
+// Lookup returns the artifact for rawURL and its local path, if the file is present.
+func (x *OfflineIndex) Lookup(rawURL string) (OfflineArtifact, string, bool) {
+	a, ok := x.Artifacts[normalizeURL(rawURL)]
+	if !ok {
+		return a, "", false
+	}
+	p := filepath.Join(x.Dir, filepath.FromSlash(a.Path))
+	if _, err := os.Stat(p); err != nil {
+		return a, "", false
+	}
+	return a, p, true
+}
+
+// LookupOffline returns the local path of rawURL in the bundles imported into
+// dir ("" = OfflineDir()).
+func LookupOffline(dir, rawURL string) (string, bool) {
+	idx, err := LoadOfflineIndex(dir)
+	if err != nil {
+		return "", false
+	}
+	_, p, ok := idx.Lookup(rawURL)
+	return p, ok
+}
+
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// offlineTransport answers GETs for bundled URLs from disk and passes the
+// rest to next, or fails them when strict (iso.offline).
+type offlineTransport struct {
+	index  *OfflineIndex
+	next   http.RoundTripper
+	strict bool
+}
+
+// newOfflineTransport returns the transport Download uses for spec: bundled
+// URLs are served from spec.OfflineDir, everything else goes to
+// http.DefaultTransport.
+func newOfflineTransport(spec DownloadSpec) http.RoundTripper {
+	strict := spec.Offline || configs.Defaults.ISO.Offline
+	idx, err := LoadOfflineIndex(spec.OfflineDir)
+	if err != nil {
+		fmt.Printf("⚠️  Ignoring offline bundle: %v\n", err)
+		idx = &OfflineIndex{Artifacts: map[string]OfflineArtifact{}}
+	}
+	if len(idx.Artifacts) == 0 && !strict {
+		return http.DefaultTransport
+	}
+	return &offlineTransport{index: idx, next: http.DefaultTransport, strict: strict}
+}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		if a, p, ok := t.index.Lookup(req.URL.String()); ok {
+			return serveOfflineFile(req, a, p)
+		}
+	}
+	if t.strict {
+		return nil, fmt.Errorf("%s: %w", req.URL, ErrNotInBundle)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// serveOfflineFile returns the whole file; Download restarts a resumed
+// transfer on a 200 response.
+func serveOfflineFile(req *http.Request, a OfflineArtifact, path string) (*http.Response, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	var body io.ReadCloser = f
+	if req.Method == http.MethodHead {
+		_ = f.Close()
+		body = http.NoBody
+	}
+	return &http.Response{
+		Status:        "200 OK (offline bundle)",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Length": {strconv.FormatInt(info.Size(), 10)}, "X-Offline-Bundle": {a.Path}},
+		Body:          body,
+		ContentLength: info.Size(),
+		Request:       req,
+	}, nil
+}
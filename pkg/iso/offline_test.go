@@ -0,0 +1,111 @@
+package iso
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+)
+
+// offlineCache points the cache at a temporary directory with an offline
+// index serving files (URL -> content), and enables iso.offline.
+func offlineCache(t *testing.T, files map[string]string) {
+	t.Helper()
+	old := configs.Defaults.ISO
+	configs.Defaults.ISO.CacheDir = t.TempDir()
+	configs.Defaults.ISO.Offline = true
+	t.Cleanup(func() { configs.Defaults.ISO = old })
+
+	idx, err := LoadOfflineIndex("")
+	if err != nil {
+		t.Fatalf("LoadOfflineIndex: %v", err)
+	}
+	i := 0
+	for u, content := range files {
+		rel := filepath.ToSlash(filepath.Join("files", string(rune('a'+i)), filepath.Base(u)))
+		p := filepath.Join(idx.Dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		idx.Add(OfflineArtifact{URL: u, Path: rel, Size: int64(len(content))})
+		i++
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func TestDownload_ResolvesFromOfflineBundle(t *testing.T) {
+	fastRetries(t)
+	payload := "talos-iso"
+	offlineCache(t, map[string]string{
+		"https://releases.invalid/v1/metal-amd64.iso": payload,
+		"https://releases.invalid/v1/sha256sum.txt":   sha256Hex([]byte(payload)) + "  metal-amd64.iso\n",
+	})
+
+	dest := filepath.Join(t.TempDir(), "talos.iso")
+	spec := DownloadSpec{
+		URLs:         []string{"https://releases.invalid/v1/metal-amd64.iso"},
+		Dest:         dest,
+		ChecksumFile: "sha256sum.txt",
+	}
+	if err := Download(context.Background(), spec); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != payload {
+		t.Fatalf("content = %q", got)
+	}
+	if meta, ok := readVerifiedMeta(dest); !ok || meta.SHA256 != sha256Hex([]byte(payload)) {
+		t.Fatalf("verified meta = %+v, %v", meta, ok)
+	}
+	if p, ok := LookupOffline("", spec.URLs[0]); !ok || !strings.HasPrefix(p, OfflineDir()) {
+		t.Fatalf("LookupOffline = %q, %v", p, ok)
+	}
+}
+
+func TestDownload_OfflineRejectsUnbundledURL(t *testing.T) {
+	fastRetries(t)
+	offlineCache(t, map[string]string{"https://releases.invalid/other.iso": "x"})
+
+	spec := DownloadSpec{
+		URLs: []string{"https://releases.invalid/missing.iso", "https://mirror.invalid/missing.iso"},
+		Dest: filepath.Join(t.TempDir(), "missing.iso"),
+	}
+	err := Download(context.Background(), spec)
+	if !errors.Is(err, ErrNotInBundle) {
+		t.Fatalf("expected ErrNotInBundle, got %v", err)
+	}
+}
+
+func TestSidecarURLs(t *testing.T) {
+	spec := DownloadSpec{
+		URLs:          []string{"https://cdimage.invalid/24.04/ubuntu.iso", "https://mirror.invalid/24.04/ubuntu.iso"},
+		ChecksumFile:  "SHA256SUMS",
+		SignatureFile: "SHA256SUMS.gpg",
+		SigningKeys:   []string{"aaaa bbbb"},
+		Keyserver:     "https://keys.invalid/",
+	}
+	want := []string{
+		"https://cdimage.invalid/24.04/SHA256SUMS",
+		"https://cdimage.invalid/24.04/SHA256SUMS.gpg",
+		"https://mirror.invalid/24.04/SHA256SUMS",
+		"https://mirror.invalid/24.04/SHA256SUMS.gpg",
+		"https://keys.invalid/pks/lookup?op=get&options=mr&search=0xAAAABBBB",
+	}
+	got := spec.SidecarURLs()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("SidecarURLs() =\n%s", strings.Join(got, "\n"))
+	}
+
+	spec.SHA256 = sha256Hex([]byte("pinned"))
+	if got := spec.SidecarURLs(); len(got) != 0 {
+		t.Fatalf("pinned checksum: SidecarURLs() = %v", got)
+	}
+}
@@ -0,0 +1,314 @@
+package iso
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// In-place ISO 9660 patching.
+//
+// Instead of extracting and repacking an image, isoImage rewrites single files
+// of a copy: directory records of the primary (Rock Ridge) and Joliet trees are
+// updated to the new length, and content that outgrows its sectors is appended
+// after the end of the image. Boot catalog, El Torito boot images, appended
+// partitions (EFI) and all other files keep their original sectors, so BIOS
+// and UEFI boot records stay valid.
+
+const (
+	isoSectorSize  = 2048
+	isoFirstVDLBA  = 16 // volume descriptors start at sector 16
+	isoMaxVDs      = 64 // bound for the descriptor scan of broken images
+	isoMaxDirDepth = 32
+
+	vdTypePrimary       = 1
+	vdTypeSupplementary = 2
+	vdTypeTerminator    = 255
+
+	dirFlagDirectory   = 0x02
+	dirFlagMultiExtent = 0x80
+)
+
+// isoTree is the root directory of one volume descriptor.
+type isoTree struct {
+	rootExtent uint32
+	rootSize   uint32
+	joliet     bool
+}
+
+// isoRecord locates one directory record of a file.
+type isoRecord struct {
+	offset int64 // absolute offset of the directory record in the image
+	extent uint32
+	size   uint32
+	flags  byte
+}
+
+// isoImage is an ISO 9660 image opened for in-place patching.
+type isoImage struct {
+	f       *os.File
+	trees   []isoTree
+	vds     []int64 // offsets of primary/supplementary descriptors
+	volSize uint32  // volume space size in sectors
+	end     int64   // where appended file content goes (sector aligned)
+}
+
+// openISOImage reads the volume descriptors of f.
+func openISOImage(f *os.File) (*isoImage, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	img := &isoImage{f: f}
+	buf := make([]byte, isoSectorSize)
+	for i := 0; i < isoMaxVDs; i++ {
+		off := int64(isoFirstVDLBA+i) * isoSectorSize
+		if _, err := f.ReadAt(buf, off); err != nil {
+			return nil, fmt.Errorf("read volume descriptor: %w", err)
+		}
+		if string(buf[1:6]) != "CD001" {
+			return nil, fmt.Errorf("not an ISO 9660 image (no volume descriptor at sector %d)", isoFirstVDLBA+i)
+		}
+		switch buf[0] {
+		case vdTypeTerminator:
+			if len(img.trees) == 0 {
+				return nil, fmt.Errorf("ISO 9660 image has no primary volume descriptor")
+			}
+			img.end = alignSector(max(info.Size(), int64(img.volSize)*isoSectorSize))
+			return img, nil
+		case vdTypePrimary, vdTypeSupplementary:
+			if bs := binary.LittleEndian.Uint16(buf[128:]); bs != isoSectorSize {
+				return nil, fmt.Errorf("unsupported logical block size %d", bs)
+			}
+			root := buf[156 : 156+34]
+			img.trees = append(img.trees, isoTree{
+				rootExtent: binary.LittleEndian.Uint32(root[2:]),
+				rootSize:   binary.LittleEndian.Uint32(root[10:]),
+				joliet:     buf[0] == vdTypeSupplementary && isJolietEscape(buf[88:91]),
+			})
+			img.vds = append(img.vds, off)
+			img.volSize = max(img.volSize, binary.LittleEndian.Uint32(buf[80:]))
+		}
+	}
+	return nil, fmt.Errorf("no volume descriptor terminator in the first %d sectors", isoMaxVDs)
+}
+
+func isJolietEscape(esc []byte) bool {
+	return esc[0] == '%' && esc[1] == '/' && (esc[2] == '@' || esc[2] == 'C' || esc[2] == 'E')
+}
+
+// lookup returns the directory records of the slash-separated path in every
+// tree that contains it. Names are matched case-insensitively, using Rock Ridge
+// or Joliet names where present and ignoring ";1" version suffixes.
+func (img *isoImage) lookup(path string) ([]isoRecord, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > isoMaxDirDepth {
+		return nil, fmt.Errorf("path %q too deep", path)
+	}
+	var found []isoRecord
+	for _, t := range img.trees {
+		extent, size := t.rootExtent, t.rootSize
+		for i, part := range parts {
+			rec, err := img.findInDir(extent, size, part, t.joliet)
+			if err != nil {
+				return nil, err
+			}
+			if rec == nil {
+				break
+			}
+			last := i == len(parts)-1
+			isDir := rec.flags&dirFlagDirectory != 0
+			if last && !isDir {
+				found = append(found, *rec)
+			}
+			if last || !isDir {
+				break
+			}
+			extent, size = rec.extent, rec.size
+		}
+	}
+	return found, nil
+}
+
+// findInDir scans one directory extent for name.
+func (img *isoImage) findInDir(extent, size uint32, name string, joliet bool) (*isoRecord, error) {
+	dir := make([]byte, size)
+	base := int64(extent) * isoSectorSize
+	if _, err := img.f.ReadAt(dir, base); err != nil {
+		return nil, fmt.Errorf("read directory at sector %d: %w", extent, err)
+	}
+	for pos := 0; pos < len(dir); {
+		recLen := int(dir[pos])
+		if recLen == 0 {
+			// Records never cross sector boundaries; the rest of the sector is padding.
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		if recLen < 34 || pos+recLen > len(dir) {
+			return nil, fmt.Errorf("corrupt directory record at sector %d", extent)
+		}
+		rec := dir[pos : pos+recLen]
+		if nameLen := int(rec[32]); 33+nameLen <= recLen && strings.EqualFold(recordName(rec, joliet), name) {
+			return &isoRecord{
+				offset: base + int64(pos),
+				extent: binary.LittleEndian.Uint32(rec[2:]),
+				size:   binary.LittleEndian.Uint32(rec[10:]),
+				flags:  rec[25],
+			}, nil
+		}
+		pos += recLen
+	}
+	return nil, nil
+}
+
+// recordName returns the file name of a directory record: the Rock Ridge NM
+// name when present, otherwise the ISO or Joliet identifier without version.
+func recordName(rec []byte, joliet bool) string {
+	nameLen := int(rec[32])
+	id := rec[33 : 33+nameLen]
+	if nameLen == 1 && (id[0] == 0 || id[0] == 1) {
+		return "" // "." and ".."
+	}
+	var name string
+	if joliet {
+		u := make([]uint16, len(id)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(id[2*i:])
+		}
+		name = string(utf16.Decode(u))
+	} else {
+		suStart := 33 + nameLen
+		if nameLen%2 == 0 {
+			suStart++ // padding byte
+		}
+		if nm := rockRidgeName(rec[min(suStart, len(rec)):]); nm != "" {
+			return nm
+		}
+		name = string(id)
+	}
+	name, _, _ = strings.Cut(name, ";")
+	return strings.TrimSuffix(name, ".")
+}
+
+// rockRidgeName extracts the NM (alternate name) entries of a System Use area.
+func rockRidgeName(su []byte) string {
+	var name []byte
+	for len(su) >= 4 {
+		entryLen := int(su[2])
+		if entryLen < 4 || entryLen > len(su) {
+			break
+		}
+		if su[0] == 'N' && su[1] == 'M' && entryLen >= 5 {
+			name = append(name, su[5:entryLen]...)
+		}
+		su = su[entryLen:]
+	}
+	return string(name)
+}
+
+// patchFile replaces the content of path with fn(old content).
+// It reports whether the file exists and whether it was changed.
+func (img *isoImage) patchFile(path string, fn func([]byte) []byte) (found, changed bool, err error) {
+	recs, err := img.lookup(path)
+	if err != nil || len(recs) == 0 {
+		return false, false, err
+	}
+	first := recs[0]
+	for _, r := range recs {
+		if r.flags&dirFlagMultiExtent != 0 {
+			return true, false, fmt.Errorf("%s: multi-extent files are not supported", path)
+		}
+		if r.extent != first.extent || r.size != first.size {
+			return true, false, fmt.Errorf("%s: volume trees disagree on file location", path)
+		}
+	}
+
+	old := make([]byte, first.size)
+	if _, err := img.f.ReadAt(old, int64(first.extent)*isoSectorSize); err != nil {
+		return true, false, fmt.Errorf("read %s: %w", path, err)
+	}
+	data := fn(old)
+	if bytes.Equal(data, old) {
+		return true, false, nil
+	}
+
+	// Rewrite in place while the content fits into the sectors the file
+	// already owns, otherwise move it behind the end of the image.
+	extent := first.extent
+	allocated := alignSector(int64(first.size))
+	padded := make([]byte, alignSector(int64(len(data))))
+	copy(padded, data)
+	if int64(len(padded)) <= allocated {
+		padded = append(padded, make([]byte, allocated-int64(len(padded)))...)
+	} else {
+		extent = uint32(img.end / isoSectorSize)
+		img.end += int64(len(padded))
+	}
+	if _, err := img.f.WriteAt(padded, int64(extent)*isoSectorSize); err != nil {
+		return true, false, fmt.Errorf("write %s: %w", path, err)
+	}
+
+	var field [16]byte
+	putBothEndian(field[0:8], extent)
+	putBothEndian(field[8:16], uint32(len(data)))
+	for _, r := range recs {
+		// Extent location (offset 2) and data length (offset 10) are adjacent.
+		if _, err := img.f.WriteAt(field[:], r.offset+2); err != nil {
+			return true, false, fmt.Errorf("update directory record of %s: %w", path, err)
+		}
+	}
+	return true, true, nil
+}
+
+// finish records appended content in the volume space size of every descriptor.
+func (img *isoImage) finish() error {
+	sectors := uint32(img.end / isoSectorSize)
+	if sectors <= img.volSize {
+		return nil
+	}
+	var field [8]byte
+	putBothEndian(field[:], sectors)
+	for _, off := range img.vds {
+		if _, err := img.f.WriteAt(field[:], off+80); err != nil {
+			return fmt.Errorf("update volume space size: %w", err)
+		}
+	}
+	img.volSize = sectors
+	return nil
+}
+
+// putBothEndian writes v as ISO 9660 both-byte-order uint32 (8 bytes).
+func putBothEndian(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b[0:4], v)
+	binary.BigEndian.PutUint32(b[4:8], v)
+}
+
+func alignSector(n int64) int64 {
+	return (n + isoSectorSize - 1) / isoSectorSize * isoSectorSize
+}
+
+// copyFile copies src to dst (copy_file_range/reflink where the OS supports it).
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return nil
+}
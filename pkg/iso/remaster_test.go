@@ -0,0 +1,270 @@
+package iso
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kdomanski/iso9660"
+)
+
+const testGRUBConfig = "set timeout=30\nmenuentry \"Install\" {\n linux /casper/vmlinuz ---\n}\n"
+
+// writeTestISO builds an ISO 9660 image with the given files (path -> content).
+func writeTestISO(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	w, err := iso9660.NewWriter()
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	defer func() { _ = w.Cleanup() }()
+	for name, content := range files {
+		if err := w.AddFile(strings.NewReader(content), name); err != nil {
+			t.Fatalf("AddFile %s: %v", name, err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := w.WriteTo(f, "UBUNTU"); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+}
+
+// readTestISOFile reads path from an image with the independent iso9660 reader.
+func readTestISOFile(t *testing.T, isoPath, path string) string {
+	t.Helper()
+	f, err := os.Open(isoPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	img, err := iso9660.OpenImage(f)
+	if err != nil {
+		t.Fatalf("OpenImage: %v", err)
+	}
+	dir, err := img.RootDir()
+	if err != nil {
+		t.Fatalf("RootDir: %v", err)
+	}
+next:
+	for _, part := range strings.Split(path, "/") {
+		children, err := dir.GetChildren()
+		if err != nil {
+			t.Fatalf("GetChildren: %v", err)
+		}
+		for _, c := range children {
+			if strings.EqualFold(c.Name(), part) {
+				dir = c
+				continue next
+			}
+		}
+		t.Fatalf("%s not found in %s", path, isoPath)
+	}
+	data, err := io.ReadAll(dir.Reader())
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func lookupExtent(t *testing.T, isoPath, path string) uint32 {
+	t.Helper()
+	f, err := os.Open(isoPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	img, err := openISOImage(f)
+	if err != nil {
+		t.Fatalf("openISOImage: %v", err)
+	}
+	recs, err := img.lookup(path)
+	if err != nil || len(recs) == 0 {
+		t.Fatalf("lookup %s: %v (%d records)", path, err, len(recs))
+	}
+	return recs[0].extent
+}
+
+func TestRemasterISO_PatchesInPlaceAndAppends(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "ubuntu.iso")
+	// loopback.cfg fills its sector, so the patched version must be appended.
+	loopback := testGRUBConfig + "#" + strings.Repeat("x", isoSectorSize-len(testGRUBConfig)-2) + "\n"
+	bootImage := strings.Repeat("\xeb\x3c\x90BOOT", 300)
+	writeTestISO(t, src, map[string]string{
+		"boot/grub/grub.cfg":     testGRUBConfig,
+		"boot/grub/loopback.cfg": loopback,
+		"isolinux/isolinux.bin":  bootImage,
+		"casper/vmlinuz":         "kernel",
+	})
+	srcInfo, _ := os.Stat(src)
+
+	dst := filepath.Join(tmp, "ubuntu-autoinstall.iso")
+	m := NewManager(context.Background())
+	if err := m.remasterISO(src, dst, RemasterOptions{}); err != nil {
+		t.Fatalf("remasterISO: %v", err)
+	}
+
+	if got := readTestISOFile(t, dst, "boot/grub/grub.cfg"); got != string(modifyGRUBConfig([]byte(testGRUBConfig))) {
+		t.Errorf("grub.cfg =\n%s", got)
+	}
+	if got := readTestISOFile(t, dst, "boot/grub/loopback.cfg"); got != string(modifyGRUBConfig([]byte(loopback))) {
+		t.Errorf("loopback.cfg not patched (len %d)", len(got))
+	}
+	if got := readTestISOFile(t, dst, "isolinux/isolinux.bin"); got != bootImage {
+		t.Error("boot image content changed")
+	}
+
+	// Untouched files keep their sectors; grown files move behind the image.
+	if a, b := lookupExtent(t, src, "isolinux/isolinux.bin"), lookupExtent(t, dst, "isolinux/isolinux.bin"); a != b {
+		t.Errorf("boot image moved from sector %d to %d", a, b)
+	}
+	if a, b := lookupExtent(t, src, "boot/grub/grub.cfg"), lookupExtent(t, dst, "boot/grub/grub.cfg"); a != b {
+		t.Errorf("grub.cfg moved from sector %d to %d although it fits", a, b)
+	}
+	if ext := lookupExtent(t, dst, "boot/grub/loopback.cfg"); int64(ext)*isoSectorSize < srcInfo.Size() {
+		t.Errorf("loopback.cfg at sector %d, want appended after the original image", ext)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	pvd := data[isoFirstVDLBA*isoSectorSize:]
+	if vol := binary.LittleEndian.Uint32(pvd[80:]); int64(vol)*isoSectorSize != int64(len(data)) {
+		t.Errorf("volume space size %d sectors, image is %d bytes", vol, len(data))
+	}
+	if binary.LittleEndian.Uint32(pvd[80:]) != binary.BigEndian.Uint32(pvd[84:]) {
+		t.Error("volume space size byte orders disagree")
+	}
+	orig, _ := os.ReadFile(src)
+	if !bytes.Equal(data[:isoFirstVDLBA*isoSectorSize], orig[:isoFirstVDLBA*isoSectorSize]) {
+		t.Error("system area (MBR/GPT) changed")
+	}
+}
+
+func TestRemasterISO_Errors(t *testing.T) {
+	tmp := t.TempDir()
+	m := NewManager(context.Background())
+
+	notISO := filepath.Join(tmp, "not.iso")
+	if err := os.WriteFile(notISO, make([]byte, 20*isoSectorSize), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := m.remasterISO(notISO, filepath.Join(tmp, "out.iso"), RemasterOptions{}); err == nil || !strings.Contains(err.Error(), "not an ISO 9660 image") {
+		t.Errorf("expected not-an-ISO error, got %v", err)
+	}
+
+	noConfig := filepath.Join(tmp, "plain.iso")
+	writeTestISO(t, noConfig, map[string]string{"casper/vmlinuz": "kernel"})
+	if err := m.remasterISO(noConfig, filepath.Join(tmp, "out2.iso"), RemasterOptions{}); err == nil || !strings.Contains(err.Error(), "no GRUB config files") {
+		t.Errorf("expected missing config error, got %v", err)
+	}
+}
+
+func TestModifyUbuntuISO_SuccessAndCache(t *testing.T) {
+	tmp := t.TempDir()
+	isoPath := filepath.Join(tmp, "ubuntu.iso")
+	writeTestISO(t, isoPath, map[string]string{
+		"boot/grub/grub.cfg": testGRUBConfig,
+		"isolinux/txt.cfg":   "label live\n  append initrd=/casper/initrd ---\n",
+	})
+
+	mgr := NewManager(context.Background())
+	modified, wasCreated, err := mgr.ModifyUbuntuISO(isoPath)
+	if err != nil {
+		t.Fatalf("ModifyUbuntuISO: %v", err)
+	}
+	if !wasCreated {
+		t.Fatal("expected wasCreated true on first run")
+	}
+	if got := readTestISOFile(t, modified, "isolinux/txt.cfg"); !strings.Contains(got, "autoinstall ds=nocloud ---") {
+		t.Errorf("txt.cfg not patched:\n%s", got)
+	}
+	if _, err := os.Stat(modified + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	modified2, wasCreated2, err := mgr.ModifyUbuntuISO(isoPath)
+	if err != nil {
+		t.Fatalf("ModifyUbuntuISO second run: %v", err)
+	}
+	if wasCreated2 || modified2 != modified {
+		t.Fatalf("expected cached %s, got %s (created=%v)", modified, modified2, wasCreated2)
+	}
+}
+
+func TestModifyUbuntuISOWithOptions_SeedVariant(t *testing.T) {
+	tmp := t.TempDir()
+	isoPath := filepath.Join(tmp, "ubuntu.iso")
+	writeTestISO(t, isoPath, map[string]string{
+		"boot/grub/grub.cfg": testGRUBConfig,
+	})
+
+	mgr := NewManager(context.Background())
+	plain, _, err := mgr.ModifyUbuntuISO(isoPath)
+	if err != nil {
+		t.Fatalf("ModifyUbuntuISO: %v", err)
+	}
+	opts := RemasterOptions{SeedURL: "http://10.0.0.5:8600/__dmi.system-uuid__/"}
+	seeded, wasCreated, err := mgr.ModifyUbuntuISOWithOptions(isoPath, opts)
+	if err != nil {
+		t.Fatalf("ModifyUbuntuISOWithOptions: %v", err)
+	}
+	if !wasCreated || seeded == plain {
+		t.Fatalf("expected a separate remaster, got %s (created=%v)", seeded, wasCreated)
+	}
+	if got := readTestISOFile(t, seeded, "boot/grub/grub.cfg"); !strings.Contains(got, `ds=nocloud-net\;s=`+opts.SeedURL) {
+		t.Errorf("grub.cfg not patched for nocloud-net:\n%s", got)
+	}
+	if got := readTestISOFile(t, plain, "boot/grub/grub.cfg"); strings.Contains(got, "nocloud-net") {
+		t.Errorf("default remaster changed:\n%s", got)
+	}
+
+	entries, err := NewCache(tmp).List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, e := range entries {
+		if e.Path == seeded && e.Kind != KindAutoinstall {
+			t.Errorf("seed variant classified as %q, want %q", e.Kind, KindAutoinstall)
+		}
+	}
+}
+
+func TestRecordName(t *testing.T) {
+	record := func(id []byte, su []byte) []byte {
+		rec := make([]byte, 33, 64)
+		rec[32] = byte(len(id))
+		rec = append(rec, id...)
+		if len(id)%2 == 0 {
+			rec = append(rec, 0)
+		}
+		rec = append(rec, su...)
+		rec[0] = byte(len(rec))
+		return rec
+	}
+
+	if got := recordName(record([]byte("GRUB.CFG;1"), nil), false); got != "GRUB.CFG" {
+		t.Errorf("plain name = %q", got)
+	}
+	if got := recordName(record([]byte("README.;1"), nil), false); got != "README" {
+		t.Errorf("name without extension = %q", got)
+	}
+	nm := append([]byte{'N', 'M', 13, 1, 0}, "loopback"...)
+	if got := recordName(record([]byte("LOOPBACK.CFG;1"), nm), false); got != "loopback" {
+		t.Errorf("Rock Ridge name = %q", got)
+	}
+	joliet := []byte{0, 'g', 0, 'r', 0, 'u', 0, 'b', 0, '.', 0, 'c', 0, 'f', 0, 'g', 0, ';', 0, '1'}
+	if got := recordName(record(joliet, nil), true); got != "grub.cfg" {
+		t.Errorf("Joliet name = %q", got)
+	}
+}
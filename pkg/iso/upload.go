@@ -0,0 +1,305 @@
+package iso
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// manifestExt names the sidecar written beside an uploaded ISO on the
+// datastore. It records what the remote file contains, so every client
+// (not only the one that uploaded it) can tell whether the copy is current.
+const manifestExt = ".manifest.json"
+
+// UploadVerifyChecksum is the iso.upload_verify value that reads the remote
+// copy back and compares its SHA256; any other value only compares sizes.
+const UploadVerifyChecksum = "checksum"
+
+// uploadManifest is the content of a remote manifest sidecar.
+type uploadManifest struct {
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	Uploaded time.Time `json:"uploaded"`
+}
+
+// UploadEvent reports the progress of one datastore upload attempt.
+type UploadEvent struct {
+	Path    string // remote path being written
+	Bytes   int64  // bytes sent so far
+	Total   int64  // file size
+	Attempt int    // 1-based attempt number
+	Done    bool   // last event of the attempt
+}
+
+// SetUploadProgress sets the callback receiving upload progress events
+// (nil = print a progress line every timeouts.upload_progress_seconds).
+func (m *Manager) SetUploadProgress(fn func(UploadEvent)) {
+	m.uploadProgress = fn
+}
+
+// UploadToDatastore uploads an ISO to the datastore unless an identical copy
+// is already there. The remote manifest must record the local SHA256 and the
+// remote file must have the local size; otherwise the ISO is uploaded again.
+func (m *Manager) UploadToDatastore(ds *object.Datastore, localPath, remotePath string) error {
+	sum, size, err := m.localDigest(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", localPath, err)
+	}
+
+	manifest, haveManifest := m.readManifest(ds, remotePath)
+	switch {
+	case haveManifest && manifest.SHA256 == sum && manifest.Size == size:
+		if err := m.verifyRemote(ds, remotePath, sum, size, false); err == nil {
+			fmt.Printf("✅ ISO already exists on datastore (sha256 %s): %s\n", sum[:12], filepath.Base(localPath))
+			return nil
+		}
+		fmt.Printf("🔄 Re-uploading (datastore copy missing or truncated): %s\n", filepath.Base(localPath))
+	case haveManifest:
+		fmt.Printf("🔄 Re-uploading (ISO changed since last upload): %s\n", filepath.Base(localPath))
+	default:
+		// A copy without manifest (interrupted run, older vmbootstrap) is
+		// adopted only when its content matches; its size alone is not enough.
+		if err := m.verifyRemote(ds, remotePath, sum, size, true); err == nil {
+			fmt.Printf("✅ ISO already exists on datastore (verified): %s\n", filepath.Base(localPath))
+			m.writeManifest(ds, remotePath, sum, size)
+			return nil
+		}
+	}
+
+	if err := m.doUpload(ds, localPath, remotePath, sum, size); err != nil {
+		return err
+	}
+	m.writeManifest(ds, remotePath, sum, size)
+	return nil
+}
+
+// UploadAlways uploads an ISO without checking the datastore first.
+// Use for small, VM-specific ISOs like NoCloud that must always be fresh.
+// The upload is still verified and retried like UploadToDatastore.
+func (m *Manager) UploadAlways(ds *object.Datastore, localPath, remotePath string) error {
+	sum, size, err := m.localDigest(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", localPath, err)
+	}
+	return m.doUpload(ds, localPath, remotePath, sum, size)
+}
+
+// doUpload writes the ISO to remotePath.part, verifies the remote copy and
+// moves it into place, retrying up to timeouts.upload_retries times. The
+// datastore HTTP API cannot append to a file, so a failed attempt restarts
+// from the beginning; the atomic move guarantees that remotePath is never a
+// partial file, even while VMs mount the previous version.
+func (m *Manager) doUpload(ds *object.Datastore, localPath, remotePath, sum string, size int64) error {
+	fmt.Printf("📤 Uploading %s (%.1f MB) to datastore...\n", filepath.Base(localPath), float64(size)/(1024*1024))
+
+	if err := m.makeDatastoreDir(ds, path.Dir(remotePath)); err != nil {
+		return err
+	}
+	dc, err := datastoreDatacenter(m.ctx, ds)
+	if err != nil {
+		return err
+	}
+	fm := ds.NewFileManager(dc, true)
+
+	part := remotePath + partialExt
+	checksum := configs.Defaults.ISO.UploadVerify == UploadVerifyChecksum
+	attempts := max(configs.Defaults.Timeouts.UploadRetries, 1)
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			fmt.Printf("   ↻ Upload attempt %d/%d (%v)\n", attempt, attempts, lastErr)
+			select {
+			case <-m.ctx.Done():
+				return m.ctx.Err()
+			case <-time.After(configs.Defaults.Timeouts.UploadRetryDelay()):
+			}
+		}
+		if lastErr = m.uploadWithGovmomi(ds, localPath, part, attempt); lastErr != nil {
+			continue
+		}
+		if lastErr = m.verifyRemote(ds, part, sum, size, checksum); lastErr != nil {
+			continue
+		}
+		if lastErr = fm.Move(m.ctx, part, remotePath); lastErr != nil {
+			lastErr = fmt.Errorf("failed to move %s into place: %w", ds.Path(part), lastErr)
+			continue
+		}
+		fmt.Printf("✅ Upload complete: %s\n", filepath.Base(localPath))
+		return nil
+	}
+
+	_ = m.DeleteFromDatastore(ds, part)
+	return fmt.Errorf("failed to upload %s after %d attempts: %w", filepath.Base(localPath), attempts, lastErr)
+}
+
+// uploadWithGovmomi streams the file to the datastore over the datastore HTTP API,
+// reporting progress through the manager's upload callback.
+func (m *Manager) uploadWithGovmomi(ds *object.Datastore, localPath, remotePath string, attempt int) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	report := m.uploadProgress
+	if report == nil {
+		report = printUploadProgress(time.Now())
+	}
+	r := &uploadReader{
+		r:      file,
+		report: report,
+		event:  UploadEvent{Path: remotePath, Total: fileInfo.Size(), Attempt: attempt},
+	}
+
+	p := soap.DefaultUpload
+	p.ContentLength = fileInfo.Size()
+	err = ds.Upload(m.ctx, r, remotePath, &p)
+	r.event.Done = true
+	report(r.event)
+	if err != nil {
+		return fmt.Errorf("failed to upload to datastore: %w", err)
+	}
+
+	return nil
+}
+
+// verifyRemote checks that remotePath has the expected size and, with
+// checksum, the expected SHA256 (which reads the whole file back).
+func (m *Manager) verifyRemote(ds *object.Datastore, remotePath, sum string, size int64, checksum bool) error {
+	info, err := ds.Stat(m.ctx, remotePath)
+	if err != nil {
+		return err
+	}
+	if got := info.GetFileInfo().FileSize; got != size {
+		return fmt.Errorf("%s is %d bytes, expected %d", ds.Path(remotePath), got, size)
+	}
+	if !checksum {
+		return nil
+	}
+
+	rc, _, err := ds.Download(m.ctx, remotePath, &soap.DefaultDownload)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", ds.Path(remotePath), err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return fmt.Errorf("failed to read back %s: %w", ds.Path(remotePath), err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("%s has sha256 %s, expected %s", ds.Path(remotePath), got, sum)
+	}
+	return nil
+}
+
+// localDigest returns the SHA256 and size of localPath. Files in the cache
+// directory reuse (and record) their .verified.json so large ISOs are only
+// hashed again after they change.
+func (m *Manager) localDigest(localPath string) (string, int64, error) {
+	if meta, ok := readVerifiedMeta(localPath); ok {
+		return meta.SHA256, meta.Size, nil
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", 0, err
+	}
+	sum, err := computeSHA256(localPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if filepath.Dir(localPath) == filepath.Clean(m.cacheDir) {
+		_ = writeVerifiedMeta(localPath, sum)
+	}
+	return sum, info.Size(), nil
+}
+
+func (m *Manager) readManifest(ds *object.Datastore, remotePath string) (uploadManifest, bool) {
+	var manifest uploadManifest
+	rc, _, err := ds.Download(m.ctx, remotePath+manifestExt, &soap.DefaultDownload)
+	if err != nil {
+		return manifest, false
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	if err := json.NewDecoder(rc).Decode(&manifest); err != nil || manifest.SHA256 == "" {
+		return manifest, false
+	}
+	return manifest, true
+}
+
+// writeManifest records the uploaded content beside remotePath. A missing
+// manifest only costs a re-upload next time, so failures are reported but
+// do not fail the upload.
+func (m *Manager) writeManifest(ds *object.Datastore, remotePath, sum string, size int64) {
+	data, err := json.Marshal(uploadManifest{SHA256: sum, Size: size, Uploaded: time.Now().UTC()})
+	if err == nil {
+		p := soap.DefaultUpload
+		p.ContentLength = int64(len(data))
+		err = ds.Upload(m.ctx, bytes.NewReader(data), remotePath+manifestExt, &p)
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Failed to write upload manifest for %s: %v\n", ds.Path(remotePath), err)
+	}
+}
+
+// uploadReader counts the bytes read by an upload and reports them at most
+// every timeouts.upload_progress_seconds.
+type uploadReader struct {
+	r         io.Reader
+	report    func(UploadEvent)
+	event     UploadEvent
+	lastEvent time.Time
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	u.event.Bytes += int64(n)
+	if now := time.Now(); now.Sub(u.lastEvent) >= configs.Defaults.Timeouts.UploadProgress() && !errors.Is(err, io.EOF) {
+		u.lastEvent = now
+		u.report(u.event)
+	}
+	return n, err
+}
+
+// printUploadProgress returns the default progress callback.
+func printUploadProgress(start time.Time) func(UploadEvent) {
+	return func(e UploadEvent) {
+		elapsed := time.Since(start).Seconds()
+		speed := float64(e.Bytes) / max(elapsed, 0.001) / (1024 * 1024)
+		percent := 100.0
+		if e.Total > 0 {
+			percent = float64(e.Bytes) / float64(e.Total) * 100
+		}
+		fmt.Printf("\r   Uploading: %.1f MB / %.1f MB (%.1f%%) - %.1f MB/s",
+			float64(e.Bytes)/(1024*1024),
+			float64(e.Total)/(1024*1024),
+			percent,
+			speed)
+		if e.Done {
+			fmt.Println()
+		}
+	}
+}
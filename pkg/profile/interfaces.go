@@ -3,8 +3,13 @@ package profile
 import (
 	"context"
 	"log/slog"
+	"maps"
+	"time"
 
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/cloudinit"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/seed"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/vm"
 	"github.com/vmware/govmomi/object"
 )
@@ -26,8 +31,17 @@ type Input struct {
 	DNS               []string
 	DataDiskMountPath string
 	SwapSizeGB        *int
+	Firmware          string
 	OSVersion         string
 	OSSchematicID     string
+	DownloadTimeout   time.Duration
+	CacheDir          string // local ISO cache ("" = iso.DefaultCacheDir())
+	Offline           bool   // resolve downloads from imported bundles only
+	// Installer selects the Ubuntu installer remaster (kernel args, boot menu);
+	// the provisioner sets its SeedURL.
+	Installer iso.RemasterOptions
+	// CloudInit is merged into the generated user-data (Ubuntu only).
+	CloudInit cloudinit.Extensions
 
 	VCenterHost     string
 	VCenterUsername string
@@ -43,16 +57,40 @@ type Runtime struct {
 	ISODatastore     *object.Datastore
 	ISODatastoreName string
 	Logger           *slog.Logger
+
+	// SeedServer serves the cloud-init seed over HTTP (nocloud-net) instead
+	// of a per-VM NoCloud ISO (optional, Ubuntu only).
+	SeedServer *seed.Server
 }
 
 // Result carries profile-specific artifacts needed by bootstrap core.
 type Result struct {
 	NoCloudUploadPath string
+	SeedUUID          string // BIOS UUID the seed server serves this VM's seed under
+}
+
+// HardwareDefaults are VM hardware settings a profile recommends for its guest OS.
+// Per-VM settings in bootstrap.VMConfig take precedence.
+type HardwareDefaults struct {
+	GuestID         string            // vSphere guest OS identifier (e.g., "other5xLinux64Guest")
+	HardwareVersion string            // Virtual hardware version (e.g., "vmx-19"); empty = vCenter default
+	ExtraConfig     map[string]string // VMX advanced settings (e.g., "disk.EnableUUID": "TRUE")
 }
 
 // Provisioner is the OS-profile contract used by bootstrap flow.
 type Provisioner interface {
 	Name() string
+	HardwareDefaults() HardwareDefaults
 	ProvisionAndBoot(ctx context.Context, in Input, rt Runtime) (Result, error)
 	PostInstall(ctx context.Context, in Input, rt Runtime, res Result) error
 }
+
+// HardwareDefaultsFor returns the hardware defaults for profile name from configs/defaults.yaml.
+func HardwareDefaultsFor(name string) HardwareDefaults {
+	d := configs.Defaults.VM.Profiles[name]
+	return HardwareDefaults{
+		GuestID:         d.GuestOS,
+		HardwareVersion: d.HardwareVersion,
+		ExtraConfig:     maps.Clone(d.ExtraConfig),
+	}
+}
@@ -3,13 +3,13 @@ package talos
 import (
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
 )
 
@@ -19,21 +19,29 @@ func New() *Provisioner { return &Provisioner{} }
 
 func (p *Provisioner) Name() string { return "talos" }
 
+// HardwareDefaults returns the talos guest OS ID, hardware version and extraConfig defaults.
+func (p *Provisioner) HardwareDefaults() profile.HardwareDefaults {
+	return profile.HardwareDefaultsFor(p.Name())
+}
+
 func (p *Provisioner) ProvisionAndBoot(ctx context.Context, in profile.Input, rt profile.Runtime) (profile.Result, error) {
 	version := normalizeTalosVersion(in.OSVersion)
 	if version == "" {
 		return profile.Result{}, fmt.Errorf("talos version is required")
 	}
 
-	isoPath, err := downloadTalosISO(ctx, version, in.OSSchematicID)
+	isoPath, err := downloadTalosISO(ctx, version, in.OSSchematicID, ISOOptions{
+		CacheDir: in.CacheDir,
+		Timeout:  in.DownloadTimeout,
+		Offline:  in.Offline,
+	})
 	if err != nil {
 		return profile.Result{}, err
 	}
 	rt.Logger.Info("Talos ISO ready", "path", isoPath)
 
-	uploadPath := fmt.Sprintf("ISO/talos/%s", filepath.Base(isoPath))
-	if err := rt.ISOManager.UploadToDatastore(rt.ISODatastore, isoPath, uploadPath,
-		in.VCenterHost, in.VCenterUsername, in.VCenterPassword, in.VCenterInsecure); err != nil {
+	uploadPath := path.Join(iso.DatastoreTalosDir, filepath.Base(isoPath))
+	if err := rt.ISOManager.UploadToDatastore(rt.ISODatastore, isoPath, uploadPath); err != nil {
 		return profile.Result{}, fmt.Errorf("failed to upload Talos ISO: %w", err)
 	}
 
@@ -92,53 +100,50 @@ func talosCacheFilename(version, schematicID string) string {
 	return base + ".iso"
 }
 
-func downloadTalosISO(ctx context.Context, version, schematicID string) (string, error) {
-	cacheDir := filepath.Join(configs.Defaults.ISO.CacheDir, "talos")
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return "", fmt.Errorf("failed to create Talos cache directory: %w", err)
-	}
-
-	localPath := filepath.Join(cacheDir, talosCacheFilename(version, schematicID))
-	if _, err := os.Stat(localPath); err == nil {
-		return localPath, nil
-	}
-
-	url := talosISOURL(version, schematicID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create Talos ISO request: %w", err)
-	}
+// ISOOptions are the local cache settings of a Talos ISO download.
+type ISOOptions struct {
+	CacheDir string        // local ISO cache ("" = iso.DefaultCacheDir())
+	Timeout  time.Duration // per HTTP request (0 = configured default)
+	Offline  bool          // resolve from imported bundles only
+}
 
-	client := &http.Client{Timeout: configs.Defaults.Timeouts.Download()}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to download Talos ISO: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
+// ISODownloadSpec returns the download spec of a Talos ISO in the local cache.
+// GitHub release images are checked against the release's sha256sum.txt. Image
+// Factory publishes no checksums, so factory images are verified against the
+// SHA256 recorded when they were first downloaded.
+func ISODownloadSpec(version, schematicID string, opts ISOOptions) iso.DownloadSpec {
+	version = normalizeTalosVersion(version)
+	cache := iso.NewCache(opts.CacheDir)
+	spec := iso.DownloadSpec{
+		URLs:       []string{talosISOURL(version, schematicID)},
+		Dest:       filepath.Join(cache.Dir, "talos", talosCacheFilename(version, schematicID)),
+		Timeout:    opts.Timeout,
+		OfflineDir: cache.OfflineDir(),
+		Offline:    opts.Offline,
+	}
+	if strings.TrimSpace(schematicID) == "" {
+		spec.ChecksumFile = "sha256sum.txt"
+	}
+	return spec
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download Talos ISO: unexpected status %d from %s", resp.StatusCode, url)
-	}
+// DownloadISO fetches a Talos ISO into the local cache (or resolves it from an
+// imported offline bundle).
+func DownloadISO(ctx context.Context, version, schematicID string, opts ISOOptions) (string, error) {
+	return downloadTalosISO(ctx, normalizeTalosVersion(version), schematicID, opts)
+}
 
-	tmpPath := localPath + ".part"
-	out, err := os.Create(tmpPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create Talos ISO temp file: %w", err)
+// downloadTalosISO fetches the ISO into the local cache.
+func downloadTalosISO(ctx context.Context, version, schematicID string, opts ISOOptions) (string, error) {
+	spec := ISODownloadSpec(version, schematicID, opts)
+	if err := os.MkdirAll(filepath.Dir(spec.Dest), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create Talos cache directory: %w", err)
 	}
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		_ = out.Close()
-		_ = os.Remove(tmpPath)
-		return "", fmt.Errorf("failed to write Talos ISO: %w", err)
+	if err := iso.Download(ctx, spec); err != nil {
+		return "", fmt.Errorf("failed to download Talos ISO: %w", err)
 	}
-	if err := out.Close(); err != nil {
-		_ = os.Remove(tmpPath)
-		return "", fmt.Errorf("failed to close Talos ISO temp file: %w", err)
+	if err := iso.NewCache(opts.CacheDir).EnforceLimit(spec.Dest); err != nil {
+		fmt.Printf("⚠️  Cache size limit: %v\n", err)
 	}
-
-	if err := os.Rename(tmpPath, localPath); err != nil {
-		_ = os.Remove(tmpPath)
-		return "", fmt.Errorf("failed to finalize Talos ISO cache file: %w", err)
-	}
-
-	return localPath, nil
+	return spec.Dest, nil
 }
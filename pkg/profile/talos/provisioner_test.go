@@ -2,6 +2,8 @@ package talos
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"log/slog"
@@ -69,7 +71,7 @@ func TestDownloadTalosISO_UsesCache(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got, err := downloadTalosISO(context.Background(), version, schematic)
+	got, err := downloadTalosISO(context.Background(), version, schematic, ISOOptions{})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -103,7 +105,7 @@ func TestProvisionAndBoot_SuccessFromCache(t *testing.T) {
 
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
-	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath), "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath)).Return(nil).Once()
 	isoMgr.On("MountSingleISO", mock.Anything, "[ds] ISO/talos/"+filepath.Base(cachePath), "Talos").Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(nil).Once()
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", mock.Anything).Return(nil).Once()
@@ -153,7 +155,7 @@ func TestDownloadTalosISO_CreateCacheDirError(t *testing.T) {
 	}
 	configs.Defaults.ISO.CacheDir = filePath
 
-	_, err := downloadTalosISO(context.Background(), "v1.2.3", "abc123")
+	_, err := downloadTalosISO(context.Background(), "v1.2.3", "abc123", ISOOptions{})
 	if err == nil {
 		t.Fatal("expected cache-dir creation error")
 	}
@@ -176,7 +178,7 @@ func TestDownloadTalosISO_HTTPStatusError(t *testing.T) {
 		}, nil
 	})
 
-	_, err := downloadTalosISO(context.Background(), "v1.2.3", "")
+	_, err := downloadTalosISO(context.Background(), "v1.2.3", "", ISOOptions{})
 	if err == nil {
 		t.Fatal("expected http status error")
 	}
@@ -190,11 +192,12 @@ func TestDownloadTalosISO_RequestError(t *testing.T) {
 		http.DefaultTransport = oldTransport
 	})
 	configs.Defaults.ISO.CacheDir = t.TempDir()
+	noRetryDelay(t)
 	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
 		return nil, errors.New("network down")
 	})
 
-	_, err := downloadTalosISO(context.Background(), "v1.2.3", "abc123")
+	_, err := downloadTalosISO(context.Background(), "v1.2.3", "abc123", ISOOptions{})
 	if err == nil {
 		t.Fatal("expected request error")
 	}
@@ -217,7 +220,7 @@ func TestDownloadTalosISO_DownloadSuccess(t *testing.T) {
 		}, nil
 	})
 
-	got, err := downloadTalosISO(context.Background(), "v1.2.3", "abc123")
+	got, err := downloadTalosISO(context.Background(), "v1.2.3", "abc123", ISOOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -230,6 +233,50 @@ func TestDownloadTalosISO_DownloadSuccess(t *testing.T) {
 	}
 }
 
+func TestDownloadTalosISO_VerifiesReleaseChecksum(t *testing.T) {
+	oldCache := configs.Defaults.ISO.CacheDir
+	oldTransport := http.DefaultTransport
+	t.Cleanup(func() {
+		configs.Defaults.ISO.CacheDir = oldCache
+		http.DefaultTransport = oldTransport
+	})
+	configs.Defaults.ISO.CacheDir = t.TempDir()
+	noRetryDelay(t)
+	sum := sha256.Sum256([]byte("iso-content"))
+	sums := hex.EncodeToString(sum[:]) + "  metal-amd64.iso\n"
+	content := "iso-content"
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := content
+		if strings.HasSuffix(req.URL.Path, "/sha256sum.txt") {
+			body = sums
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Header:     make(http.Header),
+			Request:    req,
+		}, nil
+	})
+
+	if _, err := downloadTalosISO(context.Background(), "v1.2.3", "", ISOOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content = "tampered"
+	configs.Defaults.ISO.CacheDir = t.TempDir()
+	if _, err := downloadTalosISO(context.Background(), "v1.2.3", "", ISOOptions{}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+// noRetryDelay makes failing downloads return without waiting between attempts.
+func noRetryDelay(t *testing.T) {
+	t.Helper()
+	old := configs.Defaults.Timeouts
+	configs.Defaults.Timeouts.DownloadRetryDelaySeconds = 0
+	t.Cleanup(func() { configs.Defaults.Timeouts = old })
+}
+
 func talosRuntimeForErrors(t *testing.T, cachePath string) (profile.Input, profile.Runtime, *isomocks.ManagerInterface, *vmmocks.CreatorInterface) {
 	t.Helper()
 	isoMgr := &isomocks.ManagerInterface{}
@@ -249,7 +296,7 @@ func talosRuntimeForErrors(t *testing.T, cachePath string) (profile.Input, profi
 		ISODatastoreName: "ds",
 		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
-	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath), "vc", "user", "pass", true).Return(nil)
+	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath)).Return(nil)
 	isoMgr.On("MountSingleISO", mock.Anything, "[ds] ISO/talos/"+filepath.Base(cachePath), "Talos").Return(nil)
 	creator.On("PowerOn", mock.Anything).Return(nil)
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", mock.Anything).Return(nil)
@@ -271,7 +318,7 @@ func TestProvisionAndBoot_UploadFails(t *testing.T) {
 
 	in, rt, isoMgr, _ := talosRuntimeForErrors(t, cachePath)
 	isoMgr.ExpectedCalls = nil
-	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath), "vc", "user", "pass", true).
+	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath)).
 		Return(errors.New("upload failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), in, rt)
@@ -295,7 +342,7 @@ func TestProvisionAndBoot_MountFails(t *testing.T) {
 
 	in, rt, isoMgr, _ := talosRuntimeForErrors(t, cachePath)
 	isoMgr.ExpectedCalls = nil
-	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath), "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath)).Return(nil).Once()
 	isoMgr.On("MountSingleISO", mock.Anything, "[ds] ISO/talos/"+filepath.Base(cachePath), "Talos").Return(errors.New("mount failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), in, rt)
@@ -320,7 +367,7 @@ func TestProvisionAndBoot_PowerOnFails(t *testing.T) {
 	in, rt, isoMgr, creator := talosRuntimeForErrors(t, cachePath)
 	isoMgr.ExpectedCalls = nil
 	creator.ExpectedCalls = nil
-	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath), "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath)).Return(nil).Once()
 	isoMgr.On("MountSingleISO", mock.Anything, "[ds] ISO/talos/"+filepath.Base(cachePath), "Talos").Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(errors.New("power on failed")).Once()
 
@@ -346,7 +393,7 @@ func TestProvisionAndBoot_EnsureCDROMWarningDoesNotFail(t *testing.T) {
 	in, rt, isoMgr, creator := talosRuntimeForErrors(t, cachePath)
 	isoMgr.ExpectedCalls = nil
 	creator.ExpectedCalls = nil
-	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath), "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, cachePath, "ISO/talos/"+filepath.Base(cachePath)).Return(nil).Once()
 	isoMgr.On("MountSingleISO", mock.Anything, "[ds] ISO/talos/"+filepath.Base(cachePath), "Talos").Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(nil).Once()
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", mock.Anything).Return(errors.New("warn")).Once()
@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 
+	"github.com/google/uuid"
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/infrakit-io/vmware-vm-bootstrap/internal/utils"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/cloudinit"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
-	"github.com/google/uuid"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/seed"
+	"github.com/vmware/govmomi/object"
 )
 
 type Provisioner struct{}
@@ -19,12 +23,115 @@ func New() *Provisioner { return &Provisioner{} }
 
 func (p *Provisioner) Name() string { return "ubuntu" }
 
+// HardwareDefaults returns the ubuntu guest OS ID, hardware version and extraConfig defaults.
+func (p *Provisioner) HardwareDefaults() profile.HardwareDefaults {
+	return profile.HardwareDefaultsFor(p.Name())
+}
+
 func (p *Provisioner) ProvisionAndBoot(ctx context.Context, in profile.Input, rt profile.Runtime) (profile.Result, error) {
 	generator, err := cloudinit.NewGenerator()
 	if err != nil {
 		return profile.Result{}, fmt.Errorf("failed to create cloud-init generator: %w", err)
 	}
 
+	userData, err := generateUserData(generator, in)
+	if err != nil {
+		return profile.Result{}, err
+	}
+	cidr, _ := utils.NetmaskToCIDR(in.Netmask) // validated by generateUserData
+
+	metaData, err := generator.GenerateMetaData(&cloudinit.MetaDataInput{
+		InstanceID: uuid.New().String(),
+		Hostname:   in.VMName,
+	})
+	if err != nil {
+		return profile.Result{}, fmt.Errorf("failed to generate meta-data: %w", err)
+	}
+
+	networkConfig, err := generator.GenerateNetworkConfig(&cloudinit.NetworkConfigInput{
+		InterfaceName: in.NetworkInterface,
+		IPAddress:     in.IPAddress,
+		CIDR:          cidr,
+		Gateway:       in.Gateway,
+		DNS:           in.DNS,
+	})
+	if err != nil {
+		return profile.Result{}, fmt.Errorf("failed to generate network-config: %w", err)
+	}
+
+	rt.Logger.Info("Cloud-init configs generated")
+
+	ubuntuISOPath, err := rt.ISOManager.DownloadUbuntu(in.OSVersion)
+	if err != nil {
+		return profile.Result{}, fmt.Errorf("failed to download Ubuntu ISO: %w", err)
+	}
+	rt.Logger.Info("Ubuntu ISO ready", "path", ubuntuISOPath)
+
+	if rt.SeedServer != nil {
+		return bootWithSeedServer(ctx, in, rt, ubuntuISOPath, seed.Seed{
+			VMName:        in.VMName,
+			UserData:      userData,
+			MetaData:      metaData,
+			NetworkConfig: networkConfig,
+		})
+	}
+
+	ubuntuISOPath, _, err = rt.ISOManager.ModifyUbuntuISOWithOptions(ubuntuISOPath, in.Installer)
+	if err != nil {
+		return profile.Result{}, fmt.Errorf("failed to modify Ubuntu ISO: %w", err)
+	}
+	rt.Logger.Info("Ubuntu ISO modified for autoinstall", "path", ubuntuISOPath)
+
+	nocloudISOPath, err := rt.ISOManager.CreateNoCloudISO(userData, metaData, networkConfig, in.VMName)
+	if err != nil {
+		return profile.Result{}, fmt.Errorf("failed to create NoCloud ISO: %w", err)
+	}
+	rt.Logger.Info("NoCloud ISO created", "path", nocloudISOPath)
+
+	ubuntuUploadPath := path.Join(iso.DatastoreUbuntuDir, filepath.Base(ubuntuISOPath))
+	nocloudUploadPath := path.Join(iso.DatastoreNoCloudDir, filepath.Base(nocloudISOPath))
+
+	if err := rt.ISOManager.UploadToDatastore(rt.ISODatastore, ubuntuISOPath, ubuntuUploadPath); err != nil {
+		return profile.Result{}, fmt.Errorf("failed to upload Ubuntu ISO: %w", err)
+	}
+	if err := rt.ISOManager.UploadAlways(rt.ISODatastore, nocloudISOPath, nocloudUploadPath); err != nil {
+		return profile.Result{}, fmt.Errorf("failed to upload NoCloud ISO: %w", err)
+	}
+
+	_ = os.Remove(nocloudISOPath)
+	rt.Logger.Info("NoCloud ISO uploaded and cleaned up")
+	rt.Logger.Info("ISOs uploaded to datastore")
+
+	ubuntuMountPath := fmt.Sprintf("[%s] %s", rt.ISODatastoreName, ubuntuUploadPath)
+	nocloudMountPath := fmt.Sprintf("[%s] %s", rt.ISODatastoreName, nocloudUploadPath)
+	if err := rt.ISOManager.MountISOs(rt.CreatedVM, ubuntuMountPath, nocloudMountPath); err != nil {
+		return profile.Result{}, fmt.Errorf("failed to mount ISOs: %w", err)
+	}
+	rt.Logger.Info("ISOs mounted to VM")
+
+	if err := rt.Creator.PowerOn(rt.CreatedVM); err != nil {
+		return profile.Result{}, fmt.Errorf("failed to power on VM: %w", err)
+	}
+	rt.Logger.Info("VM powered on - waiting for installation...")
+
+	if err := rt.ISOManager.EnsureCDROMsConnectedAfterBoot(rt.CreatedVM); err != nil {
+		rt.Logger.Warn("CD-ROM post-boot check failed (continuing)", "error", err)
+	}
+
+	return profile.Result{NoCloudUploadPath: nocloudUploadPath}, nil
+}
+
+// UserData returns the autoinstall user-data ProvisionAndBoot generates for
+// in, with in.CloudInit merged (e.g. for a dry run).
+func UserData(in profile.Input) (string, error) {
+	generator, err := cloudinit.NewGenerator()
+	if err != nil {
+		return "", fmt.Errorf("failed to create cloud-init generator: %w", err)
+	}
+	return generateUserData(generator, in)
+}
+
+func generateUserData(generator *cloudinit.Generator, in profile.Input) (string, error) {
 	var passwordHash string
 	switch {
 	case in.PasswordHash != "":
@@ -32,7 +139,7 @@ func (p *Provisioner) ProvisionAndBoot(ctx context.Context, in profile.Input, rt
 	case in.Password != "":
 		hashed, hashErr := utils.HashPasswordBcrypt(in.Password)
 		if hashErr != nil {
-			return profile.Result{}, fmt.Errorf("failed to hash password: %w", hashErr)
+			return "", fmt.Errorf("failed to hash password: %w", hashErr)
 		}
 		passwordHash = hashed
 	default:
@@ -41,7 +148,7 @@ func (p *Provisioner) ProvisionAndBoot(ctx context.Context, in profile.Input, rt
 
 	cidr, err := utils.NetmaskToCIDR(in.Netmask)
 	if err != nil {
-		return profile.Result{}, fmt.Errorf("invalid netmask: %w", err)
+		return "", fmt.Errorf("invalid netmask: %w", err)
 	}
 
 	swapSizeGB := configs.Defaults.CloudInit.SwapSizeGB
@@ -65,93 +172,82 @@ func (p *Provisioner) ProvisionAndBoot(ctx context.Context, in profile.Input, rt
 		UserGroups:        configs.Defaults.CloudInit.UserGroups,
 		UserShell:         configs.Defaults.CloudInit.UserShell,
 		InterfaceName:     in.NetworkInterface,
+		Firmware:          in.Firmware,
 		DataDiskMountPath: in.DataDiskMountPath,
 		IPAddress:         in.IPAddress,
 		CIDR:              cidr,
 		Gateway:           in.Gateway,
 		DNS:               in.DNS,
+		Extensions:        in.CloudInit,
 	})
 	if err != nil {
-		return profile.Result{}, fmt.Errorf("failed to generate user-data: %w", err)
-	}
-
-	metaData, err := generator.GenerateMetaData(&cloudinit.MetaDataInput{
-		InstanceID: uuid.New().String(),
-		Hostname:   in.VMName,
-	})
-	if err != nil {
-		return profile.Result{}, fmt.Errorf("failed to generate meta-data: %w", err)
-	}
-
-	networkConfig, err := generator.GenerateNetworkConfig(&cloudinit.NetworkConfigInput{
-		InterfaceName: in.NetworkInterface,
-		IPAddress:     in.IPAddress,
-		CIDR:          cidr,
-		Gateway:       in.Gateway,
-		DNS:           in.DNS,
-	})
-	if err != nil {
-		return profile.Result{}, fmt.Errorf("failed to generate network-config: %w", err)
+		return "", fmt.Errorf("failed to generate user-data: %w", err)
 	}
+	return userData, nil
 
-	rt.Logger.Info("Cloud-init configs generated")
+}
 
-	ubuntuISOPath, err := rt.ISOManager.DownloadUbuntu(in.OSVersion)
-	if err != nil {
-		return profile.Result{}, fmt.Errorf("failed to download Ubuntu ISO: %w", err)
-	}
-	rt.Logger.Info("Ubuntu ISO ready", "path", ubuntuISOPath)
+// biosUUID returns the VM's BIOS UUID, which the guest sees as its SMBIOS
+// system UUID (a variable so tests can run without vCenter).
+var biosUUID = func(ctx context.Context, vm *object.VirtualMachine) string {
+	return vm.UUID(ctx)
+}
 
-	ubuntuISOPath, _, err = rt.ISOManager.ModifyUbuntuISO(ubuntuISOPath)
+// bootWithSeedServer boots the shared nocloud-net installer ISO and serves the
+// VM's seed from rt.SeedServer under its BIOS UUID. No per-VM ISO is built.
+func bootWithSeedServer(ctx context.Context, in profile.Input, rt profile.Runtime, ubuntuISOPath string, s seed.Seed) (profile.Result, error) {
+	seedURL := rt.SeedServer.SeedURL()
+	opts := in.Installer
+	opts.SeedURL = seedURL
+	ubuntuISOPath, _, err := rt.ISOManager.ModifyUbuntuISOWithOptions(ubuntuISOPath, opts)
 	if err != nil {
 		return profile.Result{}, fmt.Errorf("failed to modify Ubuntu ISO: %w", err)
 	}
-	rt.Logger.Info("Ubuntu ISO modified for autoinstall", "path", ubuntuISOPath)
+	rt.Logger.Info("Ubuntu ISO modified for network autoinstall", "path", ubuntuISOPath, "seed_url", seedURL)
 
-	nocloudISOPath, err := rt.ISOManager.CreateNoCloudISO(userData, metaData, networkConfig, in.VMName)
-	if err != nil {
-		return profile.Result{}, fmt.Errorf("failed to create NoCloud ISO: %w", err)
+	ubuntuUploadPath := path.Join(iso.DatastoreUbuntuDir, filepath.Base(ubuntuISOPath))
+	if err := rt.ISOManager.UploadToDatastore(rt.ISODatastore, ubuntuISOPath, ubuntuUploadPath); err != nil {
+		return profile.Result{}, fmt.Errorf("failed to upload Ubuntu ISO: %w", err)
 	}
-	rt.Logger.Info("NoCloud ISO created", "path", nocloudISOPath)
-
-	ubuntuUploadPath := fmt.Sprintf("ISO/ubuntu/%s", filepath.Base(ubuntuISOPath))
-	nocloudUploadPath := fmt.Sprintf("ISO/nocloud/%s", filepath.Base(nocloudISOPath))
+	rt.Logger.Info("ISO uploaded to datastore")
 
-	if err := rt.ISOManager.UploadToDatastore(rt.ISODatastore, ubuntuISOPath, ubuntuUploadPath,
-		in.VCenterHost, in.VCenterUsername, in.VCenterPassword, in.VCenterInsecure); err != nil {
-		return profile.Result{}, fmt.Errorf("failed to upload Ubuntu ISO: %w", err)
+	uuid := biosUUID(ctx, rt.CreatedVM)
+	s.OnFetch = func(f seed.Fetch) {
+		if f.File == "user-data" {
+			rt.Logger.Info("Installer fetched autoinstall config - installation starting", "remote", f.RemoteAddr)
+		}
 	}
-	if err := rt.ISOManager.UploadAlways(rt.ISODatastore, nocloudISOPath, nocloudUploadPath,
-		in.VCenterHost, in.VCenterUsername, in.VCenterPassword, in.VCenterInsecure); err != nil {
-		return profile.Result{}, fmt.Errorf("failed to upload NoCloud ISO: %w", err)
+	if err := rt.SeedServer.Register(uuid, s); err != nil {
+		return profile.Result{}, fmt.Errorf("failed to register seed: %w", err)
 	}
-
-	_ = os.Remove(nocloudISOPath)
-	rt.Logger.Info("NoCloud ISO uploaded and cleaned up")
-	rt.Logger.Info("ISOs uploaded to datastore")
+	rt.Logger.Info("Seed registered", "uuid", uuid, "url", rt.SeedServer.URL())
 
 	ubuntuMountPath := fmt.Sprintf("[%s] %s", rt.ISODatastoreName, ubuntuUploadPath)
-	nocloudMountPath := fmt.Sprintf("[%s] %s", rt.ISODatastoreName, nocloudUploadPath)
-	if err := rt.ISOManager.MountISOs(rt.CreatedVM, ubuntuMountPath, nocloudMountPath); err != nil {
-		return profile.Result{}, fmt.Errorf("failed to mount ISOs: %w", err)
+	if err := rt.ISOManager.MountSingleISO(rt.CreatedVM, ubuntuMountPath, "Ubuntu"); err != nil {
+		rt.SeedServer.Unregister(uuid)
+		return profile.Result{}, fmt.Errorf("failed to mount ISO: %w", err)
 	}
-	rt.Logger.Info("ISOs mounted to VM")
+	rt.Logger.Info("ISO mounted to VM")
 
 	if err := rt.Creator.PowerOn(rt.CreatedVM); err != nil {
+		rt.SeedServer.Unregister(uuid)
 		return profile.Result{}, fmt.Errorf("failed to power on VM: %w", err)
 	}
-	rt.Logger.Info("VM powered on - waiting for installation...")
+	rt.Logger.Info("VM powered on - waiting for installer to fetch its seed...")
 
 	if err := rt.ISOManager.EnsureCDROMsConnectedAfterBoot(rt.CreatedVM); err != nil {
 		rt.Logger.Warn("CD-ROM post-boot check failed (continuing)", "error", err)
 	}
 
-	return profile.Result{NoCloudUploadPath: nocloudUploadPath}, nil
+	return profile.Result{SeedUUID: uuid}, nil
 }
 
 func (p *Provisioner) PostInstall(ctx context.Context, in profile.Input, rt profile.Runtime, res profile.Result) error {
-	rt.Logger.Info("Powering off VM to release CD-ROM file locks...")
-	if err := rt.Creator.PowerOff(rt.CreatedVM); err != nil {
+	if res.SeedUUID != "" && rt.SeedServer != nil {
+		rt.SeedServer.Unregister(res.SeedUUID)
+	}
+	rt.Logger.Info("Shutting down guest to release CD-ROM file locks...")
+	if err := rt.Creator.ShutdownGuest(rt.CreatedVM); err != nil {
 		rt.Logger.Warn("Failed to power off VM for cleanup (continuing)", "error", err)
 		return nil
 	}
@@ -159,8 +255,7 @@ func (p *Provisioner) PostInstall(ctx context.Context, in profile.Input, rt prof
 		rt.Logger.Warn("Failed to remove CD-ROMs (continuing)", "error", err)
 	}
 	if res.NoCloudUploadPath != "" {
-		if err := rt.ISOManager.DeleteFromDatastore(rt.ISODatastoreName, res.NoCloudUploadPath,
-			in.VCenterHost, in.VCenterUsername, in.VCenterPassword, in.VCenterInsecure); err != nil {
+		if err := rt.ISOManager.DeleteFromDatastore(rt.ISODatastore, res.NoCloudUploadPath); err != nil {
 			rt.Logger.Warn("Failed to delete NoCloud ISO from datastore (non-critical)", "error", err)
 		} else {
 			rt.Logger.Info("NoCloud ISO deleted from datastore", "path", res.NoCloudUploadPath)
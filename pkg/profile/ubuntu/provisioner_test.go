@@ -5,13 +5,18 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso"
 	isomocks "github.com/infrakit-io/vmware-vm-bootstrap/pkg/iso/mocks"
 	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/profile"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/seed"
 	vmmocks "github.com/infrakit-io/vmware-vm-bootstrap/pkg/vm/mocks"
 	"github.com/stretchr/testify/mock"
+	"github.com/vmware/govmomi/object"
 )
 
 func TestProvisionAndBoot_InvalidNetmask(t *testing.T) {
@@ -44,10 +49,10 @@ func TestProvisionAndBoot_Success(t *testing.T) {
 	ubuntuUploadPath := "ISO/ubuntu/" + filepath.Base(modISO)
 
 	isoMgr.On("DownloadUbuntu", "24.04").Return(ubuntuISO, nil).Once()
-	isoMgr.On("ModifyUbuntuISO", ubuntuISO).Return(modISO, true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", ubuntuISO, iso.RemasterOptions{}).Return(modISO, true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return(nocloudISO, nil).Once()
-	isoMgr.On("UploadToDatastore", mock.Anything, modISO, ubuntuUploadPath, "vc", "user", "pass", true).Return(nil).Once()
-	isoMgr.On("UploadAlways", mock.Anything, nocloudISO, nocloudUploadPath, "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, modISO, ubuntuUploadPath).Return(nil).Once()
+	isoMgr.On("UploadAlways", mock.Anything, nocloudISO, nocloudUploadPath).Return(nil).Once()
 	isoMgr.On("MountISOs", mock.Anything, "[ds] "+ubuntuUploadPath, "[ds] "+nocloudUploadPath).Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(nil).Once()
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", mock.Anything).Return(nil).Once()
@@ -88,9 +93,9 @@ func TestProvisionAndBoot_Success(t *testing.T) {
 func TestPostInstall_Success(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
-	creator.On("PowerOff", mock.Anything).Return(nil).Once()
+	creator.On("ShutdownGuest", mock.Anything).Return(nil).Once()
 	isoMgr.On("RemoveAllCDROMs", mock.Anything).Return(nil).Once()
-	isoMgr.On("DeleteFromDatastore", "ds", "ISO/nocloud/vm1.iso", "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("DeleteFromDatastore", mock.Anything, "ISO/nocloud/vm1.iso").Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(nil).Once()
 
 	p := New()
@@ -114,10 +119,10 @@ func TestPostInstall_Success(t *testing.T) {
 	creator.AssertExpectations(t)
 }
 
-func TestPostInstall_PowerOffErrorIsNonFatal(t *testing.T) {
+func TestPostInstall_ShutdownErrorIsNonFatal(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
-	creator.On("PowerOff", mock.Anything).Return(assertErr("poweroff failed")).Once()
+	creator.On("ShutdownGuest", mock.Anything).Return(assertErr("shutdown failed")).Once()
 
 	p := New()
 	err := p.PostInstall(context.Background(), profile.Input{}, profile.Runtime{
@@ -134,7 +139,7 @@ func TestPostInstall_PowerOffErrorIsNonFatal(t *testing.T) {
 func TestPostInstall_PowerOnError(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
-	creator.On("PowerOff", mock.Anything).Return(nil).Once()
+	creator.On("ShutdownGuest", mock.Anything).Return(nil).Once()
 	isoMgr.On("RemoveAllCDROMs", mock.Anything).Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(assertErr("poweron failed")).Once()
 
@@ -204,7 +209,7 @@ func TestProvisionAndBoot_ModifyUbuntuFails(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("", false, errors.New("modify failed")).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("", false, errors.New("modify failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), baseUbuntuInput(), baseUbuntuRuntime(isoMgr, creator))
 	if err == nil {
@@ -216,7 +221,7 @@ func TestProvisionAndBoot_CreateNoCloudFails(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-mod.iso", true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-mod.iso", true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return("", errors.New("nocloud failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), baseUbuntuInput(), baseUbuntuRuntime(isoMgr, creator))
@@ -229,9 +234,9 @@ func TestProvisionAndBoot_UploadUbuntuFails(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-mod.iso", true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-mod.iso", true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return("/tmp/nocloud.iso", nil).Once()
-	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso", "vc", "user", "pass", true).Return(errors.New("upload failed")).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso").Return(errors.New("upload failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), baseUbuntuInput(), baseUbuntuRuntime(isoMgr, creator))
 	if err == nil {
@@ -243,10 +248,10 @@ func TestProvisionAndBoot_UploadNoCloudFails(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-mod.iso", true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-mod.iso", true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return("/tmp/nocloud.iso", nil).Once()
-	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso", "vc", "user", "pass", true).Return(nil).Once()
-	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso", "vc", "user", "pass", true).Return(errors.New("upload nocloud failed")).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso").Return(nil).Once()
+	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso").Return(errors.New("upload nocloud failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), baseUbuntuInput(), baseUbuntuRuntime(isoMgr, creator))
 	if err == nil {
@@ -258,10 +263,10 @@ func TestProvisionAndBoot_MountFails(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-mod.iso", true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-mod.iso", true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return("/tmp/nocloud.iso", nil).Once()
-	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso", "vc", "user", "pass", true).Return(nil).Once()
-	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso", "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso").Return(nil).Once()
+	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso").Return(nil).Once()
 	isoMgr.On("MountISOs", mock.Anything, "[ds] ISO/ubuntu/ubuntu-mod.iso", "[ds] ISO/nocloud/nocloud.iso").Return(errors.New("mount failed")).Once()
 
 	_, err := New().ProvisionAndBoot(context.Background(), baseUbuntuInput(), baseUbuntuRuntime(isoMgr, creator))
@@ -274,10 +279,10 @@ func TestProvisionAndBoot_PowerOnFails(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-mod.iso", true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-mod.iso", true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return("/tmp/nocloud.iso", nil).Once()
-	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso", "vc", "user", "pass", true).Return(nil).Once()
-	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso", "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso").Return(nil).Once()
+	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso").Return(nil).Once()
 	isoMgr.On("MountISOs", mock.Anything, "[ds] ISO/ubuntu/ubuntu-mod.iso", "[ds] ISO/nocloud/nocloud.iso").Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(errors.New("power on failed")).Once()
 
@@ -291,10 +296,10 @@ func TestProvisionAndBoot_CDRomWarningNonFatal(t *testing.T) {
 	isoMgr := &isomocks.ManagerInterface{}
 	creator := &vmmocks.CreatorInterface{}
 	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
-	isoMgr.On("ModifyUbuntuISO", "/tmp/ubuntu.iso").Return("/tmp/ubuntu-mod.iso", true, nil).Once()
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{}).Return("/tmp/ubuntu-mod.iso", true, nil).Once()
 	isoMgr.On("CreateNoCloudISO", mock.Anything, mock.Anything, mock.Anything, "vm1").Return("/tmp/nocloud.iso", nil).Once()
-	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso", "vc", "user", "pass", true).Return(nil).Once()
-	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso", "vc", "user", "pass", true).Return(nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-mod.iso", "ISO/ubuntu/ubuntu-mod.iso").Return(nil).Once()
+	isoMgr.On("UploadAlways", mock.Anything, "/tmp/nocloud.iso", "ISO/nocloud/nocloud.iso").Return(nil).Once()
 	isoMgr.On("MountISOs", mock.Anything, "[ds] ISO/ubuntu/ubuntu-mod.iso", "[ds] ISO/nocloud/nocloud.iso").Return(nil).Once()
 	creator.On("PowerOn", mock.Anything).Return(nil).Once()
 	isoMgr.On("EnsureCDROMsConnectedAfterBoot", mock.Anything).Return(errors.New("warn")).Once()
@@ -307,3 +312,67 @@ func TestProvisionAndBoot_CDRomWarningNonFatal(t *testing.T) {
 		t.Fatalf("unexpected nocloud upload path: %s", res.NoCloudUploadPath)
 	}
 }
+
+func TestProvisionAndBoot_SeedServer(t *testing.T) {
+	const uuid = "4231c8f2-1b2a-3c4d-5e6f-0123456789ab"
+	orig := biosUUID
+	biosUUID = func(context.Context, *object.VirtualMachine) string { return uuid }
+	t.Cleanup(func() { biosUUID = orig })
+
+	srv := seed.NewServer(seed.Config{Listen: "127.0.0.1:0", Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("start seed server: %v", err)
+	}
+	defer func() { _ = srv.Close() }()
+
+	isoMgr := &isomocks.ManagerInterface{}
+	creator := &vmmocks.CreatorInterface{}
+	isoMgr.On("DownloadUbuntu", "24.04").Return("/tmp/ubuntu.iso", nil).Once()
+	installer := iso.RemasterOptions{KernelArgs: []string{"console=ttyS0,115200"}}
+	isoMgr.On("ModifyUbuntuISOWithOptions", "/tmp/ubuntu.iso", iso.RemasterOptions{SeedURL: srv.SeedURL(), KernelArgs: installer.KernelArgs}).
+		Return("/tmp/ubuntu-autoinstall-1a2b3c4d.iso", true, nil).Once()
+	isoMgr.On("UploadToDatastore", mock.Anything, "/tmp/ubuntu-autoinstall-1a2b3c4d.iso", "ISO/ubuntu/ubuntu-autoinstall-1a2b3c4d.iso").Return(nil).Once()
+	isoMgr.On("MountSingleISO", mock.Anything, "[ds] ISO/ubuntu/ubuntu-autoinstall-1a2b3c4d.iso", "Ubuntu").Return(nil).Once()
+	creator.On("PowerOn", mock.Anything).Return(nil).Once()
+	isoMgr.On("EnsureCDROMsConnectedAfterBoot", mock.Anything).Return(nil).Once()
+
+	rt := baseUbuntuRuntime(isoMgr, creator)
+	rt.SeedServer = srv
+	in := baseUbuntuInput()
+	in.Installer = installer
+	res, err := New().ProvisionAndBoot(context.Background(), in, rt)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.SeedUUID != uuid || res.NoCloudUploadPath != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	isoMgr.AssertExpectations(t)
+	creator.AssertExpectations(t)
+
+	userData := srv.URL() + uuid + "/user-data"
+	resp, err := http.Get(userData)
+	if err != nil {
+		t.Fatalf("GET user-data: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "autoinstall") {
+		t.Fatalf("user-data: %d %q", resp.StatusCode, body)
+	}
+
+	creator.On("ShutdownGuest", mock.Anything).Return(nil).Once()
+	isoMgr.On("RemoveAllCDROMs", mock.Anything).Return(nil).Once()
+	creator.On("PowerOn", mock.Anything).Return(nil).Once()
+	if err := New().PostInstall(context.Background(), baseUbuntuInput(), rt, res); err != nil {
+		t.Fatalf("PostInstall: %v", err)
+	}
+	resp, err = http.Get(userData)
+	if err != nil {
+		t.Fatalf("GET user-data: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("seed still served after PostInstall: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,246 @@
+// Package seed serves cloud-init NoCloud seeds over HTTP (nocloud-net), so
+// Ubuntu VMs can install from one shared autoinstall ISO instead of each
+// getting its own seed ISO built, uploaded, mounted and deleted.
+//
+// The installer ISO points cloud-init at SeedURL, which contains the
+// __dmi.system-uuid__ placeholder. cloud-init replaces it with the VM's
+// SMBIOS system UUID (the vSphere BIOS UUID), so the server can tell VMs
+// apart while they all boot the same ISO.
+package seed
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UUIDPlaceholder is substituted by cloud-init with the SMBIOS system UUID.
+const UUIDPlaceholder = "__dmi.system-uuid__"
+
+// Seed is the cloud-init data served to one VM.
+type Seed struct {
+	VMName        string
+	UserData      string
+	MetaData      string
+	NetworkConfig string
+
+	// OnFetch is called after each file served to this VM (optional).
+	OnFetch func(Fetch)
+}
+
+// Fetch describes one served seed file.
+type Fetch struct {
+	VMName     string
+	UUID       string
+	File       string
+	RemoteAddr string
+	Time       time.Time
+}
+
+// Config configures a Server.
+type Config struct {
+	Listen string // listen address, e.g. ":8600" (":0" picks a free port)
+	// URL is the base URL VMs use to reach the server, e.g.
+	// "http://10.0.0.5:8600/". Required when Listen has no host part,
+	// unless AdvertiseHost is set.
+	URL string
+	// AdvertiseHost is the host VMs reach a server listening on all
+	// interfaces at; the URL gets the port actually listened on.
+	AdvertiseHost string
+	Logger        *slog.Logger // request log (nil = slog.Default())
+}
+
+// Server is an HTTP server for per-VM NoCloud seeds.
+type Server struct {
+	cfg     Config
+	logger  *slog.Logger
+	baseURL string
+
+	mu    sync.Mutex
+	seeds map[string]*Seed // normalized UUID → seed
+
+	ln  net.Listener
+	srv *http.Server
+}
+
+// NewServer returns a server for cfg; call Start to begin serving.
+func NewServer(cfg Config) *Server {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Server{cfg: cfg, logger: logger, seeds: make(map[string]*Seed)}
+}
+
+// Start listens on cfg.Listen and serves seeds in the background.
+func (s *Server) Start() error {
+	if s.ln != nil {
+		return errors.New("seed server already started")
+	}
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("seed server: %w", err)
+	}
+	base, err := baseURL(s.cfg.URL, s.cfg.AdvertiseHost, ln.Addr())
+	if err != nil {
+		_ = ln.Close()
+		return err
+	}
+	s.ln, s.baseURL = ln, base
+	s.srv = &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Seed server stopped", "error", err)
+		}
+	}()
+	s.logger.Info("Seed server listening", "addr", ln.Addr().String(), "url", s.baseURL)
+	return nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	if s.srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// URL returns the base URL VMs use to reach the server.
+func (s *Server) URL() string {
+	return s.baseURL
+}
+
+// SeedURL returns the nocloud-net seed URL for the installer kernel
+// command line. It is the same for every VM.
+func (s *Server) SeedURL() string {
+	return s.baseURL + UUIDPlaceholder + "/"
+}
+
+// Register serves seed to the VM with the given BIOS UUID until Unregister.
+func (s *Server) Register(uuid string, seed Seed) error {
+	key, ok := normalizeUUID(uuid)
+	if !ok {
+		return fmt.Errorf("invalid VM UUID %q", uuid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seeds[key] = &seed
+	// Depending on SMBIOS version and firmware the guest reports the first
+	// three UUID fields byte-swapped; accept both forms.
+	s.seeds[swapUUID(key)] = &seed
+	return nil
+}
+
+// Unregister stops serving the seed of the VM with the given BIOS UUID.
+func (s *Server) Unregister(uuid string) {
+	key, ok := normalizeUUID(uuid)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seeds, key)
+	delete(s.seeds, swapUUID(key))
+}
+
+// ServeHTTP serves /<uuid>/<file>.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, file, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	key, ok := normalizeUUID(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	seed := s.seeds[key]
+	s.mu.Unlock()
+	if seed == nil {
+		s.logger.Warn("Seed requested for unknown VM", "uuid", id, "file", file, "remote", r.RemoteAddr)
+		http.NotFound(w, r)
+		return
+	}
+
+	var body string
+	switch file {
+	case "user-data":
+		body = seed.UserData
+	case "meta-data":
+		body = seed.MetaData
+	case "network-config":
+		if seed.NetworkConfig == "" {
+			http.NotFound(w, r)
+			return
+		}
+		body = seed.NetworkConfig
+	case "vendor-data":
+		// Served empty so cloud-init does not log a failed fetch.
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(body))
+
+	f := Fetch{VMName: seed.VMName, UUID: id, File: file, RemoteAddr: r.RemoteAddr, Time: time.Now()}
+	s.logger.Info("Seed fetched", "vm", f.VMName, "file", f.File, "remote", f.RemoteAddr)
+	if seed.OnFetch != nil {
+		seed.OnFetch(f)
+	}
+}
+
+// baseURL returns the configured URL with a trailing slash, or one derived
+// from the listener when it is bound to a specific host.
+func baseURL(configured, advertiseHost string, addr net.Addr) (string, error) {
+	if configured != "" {
+		u, err := url.Parse(configured)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("seed server: invalid URL %q", configured)
+		}
+		return strings.TrimSuffix(configured, "/") + "/", nil
+	}
+	tcp, ok := addr.(*net.TCPAddr)
+	if ok && tcp.IP.IsUnspecified() && advertiseHost != "" {
+		return "http://" + net.JoinHostPort(advertiseHost, strconv.Itoa(tcp.Port)) + "/", nil
+	}
+	if !ok || tcp.IP.IsUnspecified() {
+		return "", fmt.Errorf("seed server listens on all interfaces (%s): set the URL VMs reach it at", addr)
+	}
+	return "http://" + tcp.String() + "/", nil
+}
+
+// normalizeUUID returns uuid as 32 lowercase hex digits.
+func normalizeUUID(uuid string) (string, bool) {
+	s := strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+	if len(s) != 32 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// swapUUID byte-swaps the first three fields of a normalized UUID.
+func swapUUID(s string) string {
+	b, _ := hex.DecodeString(s)
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,120 @@
+package seed
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func startTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer(Config{Listen: "127.0.0.1:0", Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_ServesRegisteredSeed(t *testing.T) {
+	s := startTestServer(t)
+
+	var fetched []Fetch
+	const uuid = "4231C8F2-1B2A-3C4D-5E6F-0123456789AB"
+	if err := s.Register(uuid, Seed{
+		VMName:   "vm1",
+		UserData: "#cloud-config\nautoinstall: {}\n",
+		MetaData: "instance-id: i-1\n",
+		OnFetch:  func(f Fetch) { fetched = append(fetched, f) },
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	seedURL := strings.Replace(s.SeedURL(), UUIDPlaceholder, strings.ToLower(uuid), 1)
+	if code, body := get(t, seedURL+"user-data"); code != 200 || !strings.Contains(body, "autoinstall") {
+		t.Fatalf("user-data: %d %q", code, body)
+	}
+	if code, body := get(t, seedURL+"meta-data"); code != 200 || body != "instance-id: i-1\n" {
+		t.Fatalf("meta-data: %d %q", code, body)
+	}
+	if code, _ := get(t, seedURL+"vendor-data"); code != 200 {
+		t.Fatalf("vendor-data: %d", code)
+	}
+	if code, _ := get(t, seedURL+"network-config"); code != 404 {
+		t.Fatalf("network-config without content: %d, want 404", code)
+	}
+
+	if len(fetched) != 3 || fetched[0].File != "user-data" || fetched[0].VMName != "vm1" {
+		t.Fatalf("unexpected fetch events: %+v", fetched)
+	}
+}
+
+func TestServer_MatchesByteSwappedUUID(t *testing.T) {
+	s := startTestServer(t)
+	if err := s.Register("4231c8f2-1b2a-3c4d-5e6f-0123456789ab", Seed{VMName: "vm1", MetaData: "m"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if code, body := get(t, s.URL()+"f2c83142-2a1b-4d3c-5e6f-0123456789ab/meta-data"); code != 200 || body != "m" {
+		t.Fatalf("swapped UUID: %d %q", code, body)
+	}
+
+	s.Unregister("4231C8F2-1B2A-3C4D-5E6F-0123456789AB")
+	if code, _ := get(t, s.URL()+"4231c8f2-1b2a-3c4d-5e6f-0123456789ab/meta-data"); code != 404 {
+		t.Fatalf("after Unregister: %d, want 404", code)
+	}
+	if code, _ := get(t, s.URL()+"f2c83142-2a1b-4d3c-5e6f-0123456789ab/meta-data"); code != 404 {
+		t.Fatalf("swapped after Unregister: %d, want 404", code)
+	}
+}
+
+func TestServer_RejectsUnknownRequests(t *testing.T) {
+	s := startTestServer(t)
+	for _, p := range []string{"", "not-a-uuid/user-data", "4231c8f2-1b2a-3c4d-5e6f-0123456789ab/user-data"} {
+		if code, _ := get(t, s.URL()+p); code != 404 {
+			t.Errorf("GET /%s: %d, want 404", p, code)
+		}
+	}
+	if err := s.Register("bad", Seed{}); err == nil {
+		t.Error("expected error for invalid UUID")
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	s := NewServer(Config{Listen: "127.0.0.1:0", URL: "http://seed.example:8600"})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if got := s.SeedURL(); got != "http://seed.example:8600/"+UUIDPlaceholder+"/" {
+		t.Fatalf("SeedURL() = %q", got)
+	}
+
+	if err := NewServer(Config{Listen: ":0"}).Start(); err == nil || !strings.Contains(err.Error(), "all interfaces") {
+		t.Fatalf("expected URL required error, got %v", err)
+	}
+	adv := NewServer(Config{Listen: ":0", AdvertiseHost: "10.0.0.5"})
+	if err := adv.Start(); err != nil {
+		t.Fatalf("Start with AdvertiseHost: %v", err)
+	}
+	defer func() { _ = adv.Close() }()
+	if got := adv.URL(); !strings.HasPrefix(got, "http://10.0.0.5:") || strings.HasSuffix(got, ":0/") {
+		t.Fatalf("URL() = %q, want the advertised host with the listened port", got)
+	}
+
+	if err := NewServer(Config{Listen: "127.0.0.1:0", URL: "seed.example"}).Start(); err == nil {
+		t.Fatal("expected invalid URL error")
+	}
+}
@@ -0,0 +1,52 @@
+package vcenter
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// inventoryCache holds listing results shared by every Client in the process, so
+// wizard steps that reconnect to the same vCenter don't repeat inventory queries.
+// Entries are keyed by vCenter host, user, datacenter and listing kind.
+var inventoryCache = struct {
+	sync.Mutex
+	entries map[string]inventoryEntry
+}{entries: map[string]inventoryEntry{}}
+
+type inventoryEntry struct {
+	value   any
+	expires time.Time
+}
+
+// ResetInventoryCache drops all cached inventory listings.
+func ResetInventoryCache() {
+	inventoryCache.Lock()
+	defer inventoryCache.Unlock()
+	clear(inventoryCache.entries)
+}
+
+// cachedList returns the cached result for kind/datacenter if the client has a
+// cache TTL and the entry is fresh; otherwise it calls load and caches a successful result.
+func cachedList[T any](c *Client, kind, datacenter string, load func() ([]T, error)) ([]T, error) {
+	if c.cacheTTL <= 0 {
+		return load()
+	}
+	key := c.cacheKey + "|" + datacenter + "|" + kind
+
+	inventoryCache.Lock()
+	entry, ok := inventoryCache.entries[key]
+	inventoryCache.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return slices.Clone(entry.value.([]T)), nil
+	}
+
+	result, err := load()
+	if err != nil {
+		return nil, err
+	}
+	inventoryCache.Lock()
+	inventoryCache.entries[key] = inventoryEntry{value: slices.Clone(result), expires: time.Now().Add(c.cacheTTL)}
+	inventoryCache.Unlock()
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package vcenter
+
+import (
+	"fmt"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// HostCapabilities describes the largest VM the hosts behind a resource pool can run.
+// Values are the maximum across all hosts of the owning cluster/compute resource.
+type HostCapabilities struct {
+	MaxVCPUs   int32 // Max vCPUs per VM (host capability, falls back to CPU threads)
+	CPUThreads int32 // Logical CPU threads of the largest host
+	CPUMHz     int64 // Total CPU capacity of the largest host in MHz (cores * core MHz)
+	MemoryMB   int64 // Physical memory of the largest host in MB
+	Hosts      int   // Number of hosts considered
+}
+
+// HostCapabilities returns CPU/memory limits of the hosts backing the given resource pool.
+func (c *Client) HostCapabilities(pool *object.ResourcePool) (*HostCapabilities, error) {
+	owner, err := pool.Owner(c.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource pool owner: %w", err)
+	}
+
+	pc := property.DefaultCollector(c.conn.Client)
+
+	var cr mo.ComputeResource
+	if err := pc.RetrieveOne(c.ctx, owner.Reference(), []string{"host"}, &cr); err != nil {
+		return nil, fmt.Errorf("failed to get compute resource hosts: %w", err)
+	}
+	if len(cr.Host) == 0 {
+		return nil, fmt.Errorf("no hosts found for resource pool %q", pool.InventoryPath)
+	}
+
+	var hosts []mo.HostSystem
+	if err := pc.Retrieve(c.ctx, cr.Host, []string{"summary.hardware", "capability"}, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to get host hardware: %w", err)
+	}
+
+	caps := &HostCapabilities{Hosts: len(hosts)}
+	for _, h := range hosts {
+		hw := h.Summary.Hardware
+		if hw == nil {
+			continue
+		}
+		caps.CPUThreads = max(caps.CPUThreads, int32(hw.NumCpuThreads))
+		caps.CPUMHz = max(caps.CPUMHz, int64(hw.NumCpuCores)*int64(hw.CpuMhz))
+		caps.MemoryMB = max(caps.MemoryMB, hw.MemorySize/(1024*1024))
+		if h.Capability != nil {
+			caps.MaxVCPUs = max(caps.MaxVCPUs, h.Capability.MaxSupportedVcpus)
+		}
+	}
+	if caps.MaxVCPUs == 0 {
+		caps.MaxVCPUs = caps.CPUThreads
+	}
+	return caps, nil
+}
+
+// Validate checks requested VM resources against the host capabilities.
+// Zero values are treated as "not requested" and skipped.
+func (h *HostCapabilities) Validate(cpus int32, memoryMB, cpuReservationMHz, memoryReservationMB int64) error {
+	if h.MaxVCPUs > 0 && cpus > h.MaxVCPUs {
+		return fmt.Errorf("requested %d vCPUs exceeds host maximum of %d", cpus, h.MaxVCPUs)
+	}
+	if h.MemoryMB > 0 && memoryMB > h.MemoryMB {
+		return fmt.Errorf("requested %d MB memory exceeds host memory of %d MB", memoryMB, h.MemoryMB)
+	}
+	if h.CPUMHz > 0 && cpuReservationMHz > h.CPUMHz {
+		return fmt.Errorf("CPU reservation %d MHz exceeds host capacity of %d MHz", cpuReservationMHz, h.CPUMHz)
+	}
+	if h.MemoryMB > 0 && memoryReservationMB > h.MemoryMB {
+		return fmt.Errorf("memory reservation %d MB exceeds host memory of %d MB", memoryReservationMB, h.MemoryMB)
+	}
+	return nil
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vim25/soap"
 )
 
@@ -19,6 +23,19 @@ type Client struct {
 	conn   *govmomi.Client
 	finder *find.Finder
 	ctx    context.Context
+	rest   *rest.Client // vAPI session, created on first use (see restClient)
+
+	// Credentials for the vAPI login; the password is resolved again at that
+	// point rather than kept for the client's lifetime.
+	username         string
+	password         string
+	passwordProvider credentials.Provider
+
+	cacheTTL time.Duration // Inventory listing cache lifetime (0 = disabled)
+	cacheKey string        // Identifies this vCenter/user in the shared inventory cache
+
+	keepAlive   *keepalive.HandlerSOAP // nil when keep-alive is disabled
+	keepSession bool                   // Session is cached on disk; Disconnect must not log out
 }
 
 // Config holds vCenter connection parameters.
@@ -28,6 +45,35 @@ type Config struct {
 	Password string // vCenter password
 	Port     int    // vCenter port (default: 443)
 	Insecure bool   // Skip TLS verification (not recommended for production)
+
+	// PasswordProvider resolves the password at connect time when Password is empty.
+	PasswordProvider credentials.Provider
+
+	// Thumbprint pins the vCenter server certificate by its SHA-1 or SHA-256
+	// fingerprint (hex, colons optional). A matching certificate is accepted even
+	// if it is self-signed; any other certificate is rejected.
+	Thumbprint string
+
+	// CACertFile is a PEM bundle (or list of bundles separated by the OS path list
+	// separator) used instead of the system roots to verify vCenter.
+	CACertFile string
+
+	// CACertPEM is an inline PEM bundle, added to the roots from CACertFile.
+	CACertPEM string
+
+	// CacheTTL enables the in-process inventory cache for List* calls.
+	// Results are shared by all clients connected to the same vCenter as the same user.
+	// Zero disables caching.
+	CacheTTL time.Duration
+
+	// SessionCacheDir enables reuse of the vCenter session across processes.
+	// Sessions are stored encrypted per host+user; Disconnect keeps the session alive.
+	// Empty uses configs.Defaults.VCenter.SessionCacheDir (empty there = disabled).
+	SessionCacheDir string
+
+	// KeepAlive is the idle interval between keep-alive requests while connected.
+	// Zero uses configs.Defaults.VCenter.KeepAliveSeconds (0 there = disabled).
+	KeepAlive time.Duration
 }
 
 // NewClient creates a new vCenter client and connects to the vCenter server.
@@ -37,7 +83,61 @@ func NewClient(ctx context.Context, cfg *Config) (*Client, error) {
 		cfg.Port = configs.Defaults.VCenter.Port
 	}
 
-	var vcURL *url.URL
+	vcURL, err := cfg.sdkURL()
+	if err != nil {
+		return nil, err
+	}
+	password, err := credentials.Resolve(ctx, cfg.Password, cfg.PasswordProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve vCenter password: %w", err)
+	}
+	vcURL.User = url.UserPassword(cfg.Username, password)
+
+	sessionDir := cfg.SessionCacheDir
+	if sessionDir == "" {
+		sessionDir = configs.Defaults.VCenter.SessionCacheDir
+	}
+	keepAlive := cfg.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = configs.Defaults.VCenter.KeepAlive()
+	}
+	var cache *sessionCache
+	if sessionDir != "" {
+		cache = &sessionCache{dir: sessionDir, host: vcURL.Host, user: cfg.Username}
+	}
+
+	// Connect to vCenter (reusing a cached session when possible)
+	client, keepAliveHandler, err := connect(ctx, vcURL, cfg, cache, keepAlive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to vCenter: %w", err)
+	}
+
+	// Create finder for object lookups
+	finder := find.NewFinder(client.Client, true)
+
+	return &Client{
+		conn:   client,
+		finder: finder,
+		ctx:    ctx,
+
+		username:         cfg.Username,
+		password:         cfg.Password,
+		passwordProvider: cfg.PasswordProvider,
+
+		cacheTTL: cfg.CacheTTL,
+		cacheKey: vcURL.Host + "|" + cfg.Username,
+
+		keepAlive:   keepAliveHandler,
+		keepSession: cache != nil,
+	}, nil
+}
+
+// sdkURL returns the vCenter SDK endpoint for cfg.Host and cfg.Port (without credentials).
+func (cfg *Config) sdkURL() (*url.URL, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = configs.Defaults.VCenter.Port
+	}
 	if strings.Contains(cfg.Host, "://") {
 		parsed, err := url.Parse(cfg.Host)
 		if err != nil {
@@ -55,39 +155,34 @@ func NewClient(ctx context.Context, cfg *Config) (*Client, error) {
 		if parsed.Host == "" {
 			return nil, fmt.Errorf("invalid vCenter URL (missing host): %q", cfg.Host)
 		}
-		if parsed.Port() == "" && cfg.Port != 0 {
-			parsed.Host = fmt.Sprintf("%s:%d", parsed.Hostname(), cfg.Port)
-		}
-		vcURL = parsed
-	} else {
-		// Build vCenter URL from host + port
-		vcURL = &url.URL{
-			Scheme: "https",
-			Host:   fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-			Path:   "/sdk",
+		if parsed.Port() == "" && port != 0 {
+			parsed.Host = fmt.Sprintf("%s:%d", parsed.Hostname(), port)
 		}
+		return parsed, nil
 	}
-	vcURL.User = url.UserPassword(cfg.Username, cfg.Password)
-
-	// Connect to vCenter
-	client, err := govmomi.NewClient(ctx, vcURL, cfg.Insecure)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to vCenter: %w", err)
-	}
-
-	// Create finder for object lookups
-	finder := find.NewFinder(client.Client, true)
-
-	return &Client{
-		conn:   client,
-		finder: finder,
-		ctx:    ctx,
+	// Build vCenter URL from host + port
+	return &url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%s:%d", cfg.Host, port),
+		Path:   "/sdk",
 	}, nil
 }
 
 // Disconnect closes the vCenter connection.
+// A session held in the session cache is left logged in for the next process.
 func (c *Client) Disconnect() error {
+	if c.keepAlive != nil {
+		c.keepAlive.Stop()
+	}
+	if c.rest != nil {
+		_ = c.rest.Logout(c.ctx)
+		c.rest = nil
+	}
 	if c.conn != nil {
+		if c.keepSession {
+			c.conn.CloseIdleConnections()
+			return nil
+		}
 		return c.conn.Logout(c.ctx)
 	}
 	return nil
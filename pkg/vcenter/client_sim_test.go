@@ -3,11 +3,21 @@ package vcenter
 import (
 	"context"
 	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
 	"github.com/stretchr/testify/require"
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vim25/soap"
 )
 
 func newSimClient(t *testing.T) (*Client, context.Context, func()) {
@@ -161,3 +171,279 @@ func TestClient_FindErrors(t *testing.T) {
 	_, err = client.FindResourcePool(dcName, "missing-pool")
 	require.Error(t, err)
 }
+
+func TestClient_HostCapabilities(t *testing.T) {
+	client, _, cleanup := newSimClient(t)
+	defer cleanup()
+
+	dcName := getDatacenterName(t, client)
+	pools, err := client.ListResourcePools(dcName)
+	require.NoError(t, err)
+	require.NotEmpty(t, pools)
+	pool, err := client.FindResourcePool(dcName, pools[0].Name)
+	require.NoError(t, err)
+
+	caps, err := client.HostCapabilities(pool)
+	require.NoError(t, err)
+	require.Positive(t, caps.Hosts)
+	require.Positive(t, caps.MaxVCPUs)
+	require.Positive(t, caps.MemoryMB)
+	require.Positive(t, caps.CPUMHz)
+
+	require.NoError(t, caps.Validate(1, 1024, 0, 0))
+	require.Error(t, caps.Validate(caps.MaxVCPUs+1, 1024, 0, 0))
+	require.Error(t, caps.Validate(1, caps.MemoryMB+1, 0, 0))
+	require.Error(t, caps.Validate(1, 1024, caps.CPUMHz+1, 0))
+	require.Error(t, caps.Validate(1, 1024, 0, caps.MemoryMB+1))
+}
+
+func markAsTemplate(t *testing.T, ctx context.Context, vm *object.VirtualMachine) {
+	t.Helper()
+
+	task, err := vm.PowerOff(ctx)
+	require.NoError(t, err)
+	require.NoError(t, task.Wait(ctx))
+	require.NoError(t, vm.MarkAsTemplate(ctx))
+}
+
+func TestClient_InventoryListings(t *testing.T) {
+	client, ctx, cleanup := newSimClient(t)
+	defer cleanup()
+
+	dcName := getDatacenterName(t, client)
+
+	hosts, err := client.ListHosts(dcName)
+	require.NoError(t, err)
+	require.NotEmpty(t, hosts)
+	require.NotEmpty(t, hosts[0].Name)
+	require.Positive(t, hosts[0].MemoryMB)
+
+	clusters, err := client.ListClusters(dcName)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	require.Positive(t, clusters[0].Hosts)
+
+	vms, err := client.ListVMs(dcName)
+	require.NoError(t, err)
+	require.NotEmpty(t, vms)
+
+	// Templates are excluded.
+	vm, err := client.FindVM(dcName, vms[0].Name)
+	require.NoError(t, err)
+	markAsTemplate(t, ctx, vm)
+	after, err := client.ListVMs(dcName)
+	require.NoError(t, err)
+	require.Len(t, after, len(vms)-1)
+
+	_, err = client.ListVMGuestIPs(dcName)
+	require.NoError(t, err)
+
+	_, err = client.ListHosts("missing-dc")
+	require.Error(t, err)
+}
+
+func TestClient_InventoryCache(t *testing.T) {
+	client, ctx, cleanup := newSimClient(t)
+	defer cleanup()
+	t.Cleanup(ResetInventoryCache)
+
+	dcName := getDatacenterName(t, client)
+	client.cacheTTL = time.Minute
+
+	before, err := client.ListVMs(dcName)
+	require.NoError(t, err)
+
+	vm, err := client.FindVM(dcName, before[0].Name)
+	require.NoError(t, err)
+	markAsTemplate(t, ctx, vm)
+
+	// Served from cache: the template change is not visible yet.
+	cached, err := client.ListVMs(dcName)
+	require.NoError(t, err)
+	require.Len(t, cached, len(before))
+
+	ResetInventoryCache()
+	fresh, err := client.ListVMs(dcName)
+	require.NoError(t, err)
+	require.Len(t, fresh, len(before)-1)
+}
+
+func TestSessionCache_ReuseAcrossClients(t *testing.T) {
+	model := simulator.VPX()
+	require.NoError(t, model.Create())
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	defer func() {
+		s.Close()
+		model.Remove()
+	}()
+
+	password, _ := simulator.DefaultLogin.Password()
+	cfg := &Config{
+		Host:            s.URL.String(),
+		Username:        simulator.DefaultLogin.Username(),
+		Password:        password,
+		Insecure:        true,
+		SessionCacheDir: t.TempDir(),
+		KeepAlive:       time.Minute,
+	}
+	ctx := context.Background()
+
+	first, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	firstSession, err := first.conn.SessionManager.UserSession(ctx)
+	require.NoError(t, err)
+	require.NoError(t, first.Disconnect())
+
+	// Disconnect keeps the cached session logged in; the next client reuses it.
+	second, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	secondSession, err := second.conn.SessionManager.UserSession(ctx)
+	require.NoError(t, err)
+	require.Equal(t, firstSession.Key, secondSession.Key)
+
+	// An expired session falls back to a fresh login.
+	require.NoError(t, second.conn.Logout(ctx))
+	third, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	defer func() { _ = third.Disconnect() }()
+	thirdSession, err := third.conn.SessionManager.UserSession(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, firstSession.Key, thirdSession.Key)
+}
+
+func TestSessionCache_OtherKeyMiss(t *testing.T) {
+	dir := t.TempDir()
+	cache := &sessionCache{dir: dir, host: "vc:443", user: "u"}
+	cookies := []*http.Cookie{{Name: "vmware_soap_session", Value: "abc"}}
+	require.NoError(t, cache.save(cookies))
+
+	got, err := cache.load()
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "abc", got[0].Value)
+
+	raw, err := os.ReadFile(cache.path())
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "abc")
+
+	info, err := os.Stat(filepath.Join(dir, sessionKeyName))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// The same session file under a different cache directory's key is a miss.
+	other := &sessionCache{dir: t.TempDir(), host: "vc:443", user: "u"}
+	_, err = other.key(true)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(other.path(), raw, 0o600))
+	got, err = other.load()
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	// Without a key file nothing can be read.
+	require.NoError(t, os.Remove(filepath.Join(dir, sessionKeyName)))
+	got, err = cache.load()
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestNewClient_CertificateTrust(t *testing.T) {
+	model := simulator.VPX()
+	require.NoError(t, model.Create())
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	defer func() {
+		s.Close()
+		model.Remove()
+	}()
+
+	password, _ := simulator.DefaultLogin.Password()
+	base := Config{
+		Host:     s.URL.String(),
+		Username: simulator.DefaultLogin.Username(),
+		Password: password,
+	}
+	ctx := context.Background()
+	connect := func(cfg Config) error {
+		c, err := NewClient(ctx, &cfg)
+		if err == nil {
+			_ = c.Disconnect()
+		}
+		return err
+	}
+
+	// Self-signed simulator certificate is rejected by default.
+	err := connect(base)
+	require.ErrorIs(t, err, ErrCertificateUntrusted)
+
+	cert, err := ProbeCertificate(ctx, &base)
+	require.NoError(t, err)
+	require.False(t, cert.Trusted)
+	require.Equal(t, soap.ThumbprintSHA256(s.Certificate()), cert.SHA256)
+
+	pinned := base
+	pinned.Thumbprint = strings.ToLower(strings.ReplaceAll(cert.SHA256, ":", ""))
+	require.NoError(t, connect(pinned))
+
+	pinned.Thumbprint = soap.ThumbprintSHA1(s.Certificate())
+	require.NoError(t, connect(pinned))
+
+	wrong := base
+	wrong.Thumbprint = strings.Repeat("00:", 31) + "00"
+	require.Error(t, connect(wrong))
+
+	withCA := base
+	withCA.CACertPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+	require.NoError(t, connect(withCA))
+	cert, err = ProbeCertificate(ctx, &withCA)
+	require.NoError(t, err)
+	require.True(t, cert.Trusted)
+
+	caFile, err := s.CertificateFile()
+	require.NoError(t, err)
+	withCAFile := base
+	withCAFile.CACertFile = caFile
+	require.NoError(t, connect(withCAFile))
+
+	// A trusted CA does not bypass a pinned thumbprint.
+	withCA.Thumbprint = wrong.Thumbprint
+	require.ErrorIs(t, connect(withCA), ErrThumbprintMismatch)
+
+	conflicting := pinned
+	conflicting.Insecure = true
+	require.Error(t, connect(conflicting))
+}
+
+func TestNewClient_PasswordProvider(t *testing.T) {
+	model := simulator.VPX()
+	require.NoError(t, model.Create())
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	s := model.Service.NewServer()
+	defer func() {
+		s.Close()
+		model.Remove()
+	}()
+
+	password, _ := simulator.DefaultLogin.Password()
+	t.Setenv("VMBOOTSTRAP_TEST_VC_PASSWORD", password)
+	cfg := &Config{
+		Host:             s.URL.String(),
+		Username:         simulator.DefaultLogin.Username(),
+		PasswordProvider: credentials.Env{Name: "VMBOOTSTRAP_TEST_VC_PASSWORD"},
+		Insecure:         true,
+	}
+	client, err := NewClient(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Empty(t, cfg.Password)
+	require.Empty(t, client.password)
+
+	// The vAPI login resolves the password again instead of keeping it.
+	_, err = client.ListLibraries()
+	require.NoError(t, err)
+	require.NoError(t, client.Disconnect())
+
+	cfg.PasswordProvider = credentials.Env{Name: "VMBOOTSTRAP_TEST_UNSET"}
+	_, err = NewClient(context.Background(), cfg)
+	require.ErrorIs(t, err, credentials.ErrNotFound)
+}
@@ -1,7 +1,15 @@
 package vcenter
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vapi/rest"
 )
 
 func TestNewClient(t *testing.T) {
@@ -99,3 +107,73 @@ func TestConfigValidation(t *testing.T) {
 func BenchmarkClientConnect(b *testing.B) {
 	b.Skip("Benchmark - requires vCenter")
 }
+
+func TestNormalizeThumbprint(t *testing.T) {
+	sha1 := "AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01"
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: sha1, want: sha1},
+		{in: "abcdef0123456789abcdef0123456789abcdef01", want: sha1},
+		{in: " ab:cd:ef:01:23:45:67:89:ab:cd:ef:01:23:45:67:89:ab:cd:ef:01 ", want: sha1},
+		{in: "zz", wantErr: true},
+		{in: "abcd", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeThumbprint(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("NormalizeThumbprint(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeThumbprint(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsInvalidLibraryItem(t *testing.T) {
+	invalid := &vapiError{Status: "400 Bad Request", Type: "com.vmware.vapi.std.errors.invalid_argument"}
+	invalid.Value.Messages = []rest.LocalizableMessage{{ID: ovfInvalidLibraryItemID}}
+	other := &vapiError{Status: "400 Bad Request", Type: "com.vmware.vapi.std.errors.invalid_argument"}
+	other.Value.Messages = []rest.LocalizableMessage{{ID: "com.vmware.vcenter.ovf.target_invalid", DefaultMessage: "not an OVF invalid_library_item"}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"ovf message id", invalid, true},
+		{"wrapped", fmt.Errorf("filter: %w", invalid), true},
+		{"other message id", other, false},
+		{"plain text", errors.New("400 Bad Request: invalid_library_item: not an OVF"), false},
+	}
+	for _, tt := range tests {
+		if got := isInvalidLibraryItem(tt.err); got != tt.want {
+			t.Errorf("%s: isInvalidLibraryItem = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpenSource_StalledServer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	old := sourceHeaderTimeout
+	sourceHeaderTimeout = 50 * time.Millisecond
+	defer func() { sourceHeaderTimeout = old }()
+
+	c := &Client{ctx: context.Background()}
+	start := time.Now()
+	if _, _, err := c.openSource(srv.URL + "/talos.ova"); err == nil {
+		t.Fatal("expected a response header timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("openSource took %s, want it to fail after the header timeout", elapsed)
+	}
+}
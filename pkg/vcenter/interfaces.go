@@ -13,11 +13,15 @@ type ClientInterface interface {
 	FindFolder(datacenter, path string) (*object.Folder, error)
 	FindResourcePool(datacenter, path string) (*object.ResourcePool, error)
 	FindVM(datacenter, name string) (*object.VirtualMachine, error)
+	HostCapabilities(pool *object.ResourcePool) (*HostCapabilities, error)
 	ListDatastores(datacenter string) ([]DatastoreInfo, error)
 	ListNetworks(datacenter string) ([]NetworkInfo, error)
 	ListFolders(datacenter string) ([]FolderInfo, error)
 	ListResourcePools(datacenter string) ([]ResourcePoolInfo, error)
 	ListVMGuestIPs(datacenter string) ([]VMGuestIPInfo, error)
+	ListHosts(datacenter string) ([]HostInfo, error)
+	ListClusters(datacenter string) ([]ClusterInfo, error)
+	ListVMs(datacenter string) ([]VMInfo, error)
 	Disconnect() error
 }
 
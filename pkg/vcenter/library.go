@@ -0,0 +1,487 @@
+package vcenter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/pkg/credentials"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/rest"
+	vapivcenter "github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// ErrInvalidLibraryItem is returned by DeployLibraryItem when vCenter cannot parse
+// the library item as an OVF template (e.g. a partially failed import).
+var ErrInvalidLibraryItem = errors.New("invalid library item")
+
+// LibraryInfo describes a content library.
+type LibraryInfo struct {
+	ID   string
+	Name string
+	Type string // LOCAL or SUBSCRIBED
+}
+
+// LibraryDeployOptions controls deployment of an OVF library item.
+// Empty Folder/ResourcePool use the datacenter defaults.
+type LibraryDeployOptions struct {
+	ItemID       string
+	VMName       string
+	Datacenter   string
+	Datastore    string
+	Folder       string
+	ResourcePool string
+	Network      string // Mapped to every network declared in the OVF
+}
+
+// libraryItemLocks serializes check+import per library item so concurrent
+// node creations in one process don't import the same OVA twice.
+var libraryItemLocks sync.Map
+
+// restClient returns a logged-in vAPI REST client sharing the SOAP connection's transport.
+func (c *Client) restClient() (*rest.Client, error) {
+	if c.rest != nil {
+		return c.rest, nil
+	}
+	password, err := credentials.Resolve(c.ctx, c.password, c.passwordProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve vCenter password: %w", err)
+	}
+	rc := rest.NewClient(c.conn.Client)
+	if err := rc.Login(c.ctx, url.UserPassword(c.username, password)); err != nil {
+		return nil, fmt.Errorf("vAPI login failed: %w", err)
+	}
+	c.rest = rc
+	return rc, nil
+}
+
+func (c *Client) libraryManager() (*library.Manager, error) {
+	rc, err := c.restClient()
+	if err != nil {
+		return nil, err
+	}
+	return library.NewManager(rc), nil
+}
+
+// ListLibraries returns all content libraries visible to the session.
+func (c *Client) ListLibraries() ([]LibraryInfo, error) {
+	m, err := c.libraryManager()
+	if err != nil {
+		return nil, err
+	}
+	libs, err := m.GetLibraries(c.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list content libraries: %w", err)
+	}
+	result := make([]LibraryInfo, 0, len(libs))
+	for _, l := range libs {
+		result = append(result, LibraryInfo{ID: l.ID, Name: l.Name, Type: l.Type})
+	}
+	return result, nil
+}
+
+// FindLibrary locates a content library by ID or name.
+// Returns nil if no library matches (no error).
+func (c *Client) FindLibrary(nameOrID string) (*LibraryInfo, error) {
+	nameOrID = strings.TrimSpace(nameOrID)
+	if nameOrID == "" {
+		return nil, fmt.Errorf("library name is required")
+	}
+	libs, err := c.ListLibraries()
+	if err != nil {
+		return nil, err
+	}
+	var match *LibraryInfo
+	for i := range libs {
+		if libs[i].ID == nameOrID {
+			return &libs[i], nil
+		}
+		if libs[i].Name == nameOrID {
+			if match != nil {
+				return nil, fmt.Errorf("library name %q is ambiguous (use the library ID)", nameOrID)
+			}
+			match = &libs[i]
+		}
+	}
+	return match, nil
+}
+
+// EnsureLibrary returns the library with the given name or ID, creating a local
+// library backed by datastore if none exists.
+func (c *Client) EnsureLibrary(nameOrID, datacenter, datastore string) (*LibraryInfo, error) {
+	lib, err := c.FindLibrary(nameOrID)
+	if err != nil || lib != nil {
+		return lib, err
+	}
+
+	ds, err := c.FindDatastore(datacenter, datastore)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.libraryManager()
+	if err != nil {
+		return nil, err
+	}
+	id, err := m.CreateLibrary(c.ctx, library.Library{
+		Name: nameOrID,
+		Type: "LOCAL",
+		Storage: []library.StorageBacking{{
+			DatastoreID: ds.Reference().Value,
+			Type:        "DATASTORE",
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content library %q: %w", nameOrID, err)
+	}
+	return &LibraryInfo{ID: id, Name: nameOrID, Type: "LOCAL"}, nil
+}
+
+// FindLibraryItem returns the ID of the named item in a library, or "" if it does not exist.
+func (c *Client) FindLibraryItem(libraryID, name string) (string, error) {
+	m, err := c.libraryManager()
+	if err != nil {
+		return "", err
+	}
+	ids, err := m.FindLibraryItems(c.ctx, library.FindItem{LibraryID: libraryID, Name: name})
+	if err != nil {
+		return "", fmt.Errorf("failed to find library item %q: %w", name, err)
+	}
+	switch len(ids) {
+	case 0:
+		return "", nil
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("library item name %q matches %d items", name, len(ids))
+	}
+}
+
+// EnsureLibraryItemFromURL returns the ID of the named library item, importing it
+// from sourceURL first if it does not exist.
+func (c *Client) EnsureLibraryItemFromURL(libraryID, name, sourceURL string) (string, error) {
+	lockAny, _ := libraryItemLocks.LoadOrStore(libraryID+"/"+name, &sync.Mutex{})
+	lock := lockAny.(*sync.Mutex)
+	lock.Lock()
+	defer lock.Unlock()
+
+	id, err := c.FindLibraryItem(libraryID, name)
+	if err != nil || id != "" {
+		return id, err
+	}
+	return c.ImportLibraryItemFromURL(libraryID, name, sourceURL)
+}
+
+// ImportLibraryItemFromURL creates an OVF library item from an OVA/OVF URL.
+// vCenter pulls the file itself; if that fails (e.g. vCenter has no route to the
+// source), the file is streamed through this client instead. file:// URLs
+// (e.g. an OVA from an offline bundle) are always streamed.
+func (c *Client) ImportLibraryItemFromURL(libraryID, name, sourceURL string) (string, error) {
+	m, err := c.libraryManager()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid source URL %q: %w", sourceURL, err)
+	}
+	fileName := path.Base(u.Path)
+
+	if u.Scheme == "file" {
+		err := c.importLibraryItem(m, libraryID, name, func(sessionID string) error {
+			return c.pushLibraryItemFile(m, sessionID, fileName, sourceURL)
+		})
+		if err != nil {
+			return "", fmt.Errorf("library import of %q failed: %w", sourceURL, err)
+		}
+		return c.FindLibraryItem(libraryID, name)
+	}
+
+	pullErr := c.importLibraryItem(m, libraryID, name, func(sessionID string) error {
+		_, err := m.AddLibraryItemFileFromURI(c.ctx, sessionID, fileName, sourceURL)
+		return err
+	})
+	if pullErr == nil {
+		return c.FindLibraryItem(libraryID, name)
+	}
+	if c.ctx.Err() != nil {
+		return "", c.ctx.Err()
+	}
+
+	pushErr := c.importLibraryItem(m, libraryID, name, func(sessionID string) error {
+		return c.pushLibraryItemFile(m, sessionID, fileName, sourceURL)
+	})
+	if pushErr != nil {
+		return "", fmt.Errorf("library import of %q failed (pull: %v): %w", sourceURL, pullErr, pushErr)
+	}
+	return c.FindLibraryItem(libraryID, name)
+}
+
+// importLibraryItem creates an item and runs addFiles in an update session.
+// On failure the half-created item is removed so a retry starts clean.
+func (c *Client) importLibraryItem(m *library.Manager, libraryID, name string, addFiles func(sessionID string) error) error {
+	itemID, err := m.CreateLibraryItem(c.ctx, library.Item{Name: name, Type: library.ItemTypeOVF, LibraryID: libraryID})
+	if err != nil {
+		return fmt.Errorf("failed to create library item %q: %w", name, err)
+	}
+	sessionID, err := m.CreateLibraryItemUpdateSession(c.ctx, library.Session{LibraryItemID: itemID})
+	if err != nil {
+		_ = m.DeleteLibraryItem(c.ctx, &library.Item{ID: itemID})
+		return fmt.Errorf("failed to create update session: %w", err)
+	}
+
+	// The session stays ACTIVE until completed; completing it commits the files
+	// once any vCenter-side (PULL) transfers finish.
+	err = addFiles(sessionID)
+	if err == nil {
+		err = m.CompleteLibraryItemUpdateSession(c.ctx, sessionID)
+	}
+	if err == nil {
+		err = m.WaitOnLibraryItemUpdateSession(c.ctx, sessionID, 2*time.Second, nil)
+	}
+	if err != nil {
+		_ = m.FailLibraryItemUpdateSession(c.ctx, sessionID)
+		_ = m.DeleteLibraryItem(c.ctx, &library.Item{ID: itemID})
+		return err
+	}
+	return nil
+}
+
+func (c *Client) pushLibraryItemFile(m *library.Manager, sessionID, fileName, sourceURL string) error {
+	body, size, err := c.openSource(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	info, err := m.AddLibraryItemFile(c.ctx, sessionID, library.UpdateFile{
+		Name:       fileName,
+		SourceType: "PUSH",
+		Size:       size,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add library item file: %w", err)
+	}
+	if info.UploadEndpoint == nil {
+		return fmt.Errorf("no upload endpoint returned for %s", fileName)
+	}
+	uploadURL, err := url.Parse(info.UploadEndpoint.URI)
+	if err != nil {
+		return fmt.Errorf("invalid upload endpoint: %w", err)
+	}
+	p := soap.DefaultUpload
+	p.ContentLength = size
+	if err := m.Upload(c.ctx, body, uploadURL, &p); err != nil {
+		return fmt.Errorf("upload %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// sourceHeaderTimeout bounds the wait for the response headers of an OVA
+// source; the body itself may stream for as long as the caller's context allows.
+var sourceHeaderTimeout = time.Minute
+
+// newSourceClient returns the HTTP client the push fallback downloads OVAs with:
+// environment proxies and a response-header timeout, so a stalled server fails
+// without waiting for the caller's deadline.
+func newSourceClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = sourceHeaderTimeout
+	return &http.Client{Transport: t}
+}
+
+// openSource opens a file:// or HTTP(S) source and returns its content length.
+func (c *Client) openSource(sourceURL string) (io.ReadCloser, int64, error) {
+	if u, err := url.Parse(sourceURL); err == nil && u.Scheme == "file" {
+		f, err := os.Open(filepath.FromSlash(u.Path))
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := newSourceClient().Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("download %s: %w", sourceURL, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, 0, fmt.Errorf("download %s: %s", sourceURL, res.Status)
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+// RemoveLibraryItem deletes a library item by ID.
+func (c *Client) RemoveLibraryItem(itemID string) error {
+	m, err := c.libraryManager()
+	if err != nil {
+		return err
+	}
+	if err := m.DeleteLibraryItem(c.ctx, &library.Item{ID: itemID}); err != nil {
+		return fmt.Errorf("failed to remove library item %q: %w", itemID, err)
+	}
+	return nil
+}
+
+// ovfInvalidLibraryItemID is the vAPI message ID of an item vCenter cannot parse as OVF.
+const ovfInvalidLibraryItemID = "com.vmware.ovfs.ovfs-main.ovfs.invalid_library_item"
+
+// vapiError is a structured vAPI error response of the /rest endpoint.
+type vapiError struct {
+	Status string `json:"-"`
+	Type   string `json:"type"`
+	Value  struct {
+		Messages []rest.LocalizableMessage `json:"messages"`
+	} `json:"value"`
+}
+
+func (e *vapiError) Error() string {
+	msgs := make([]string, 0, len(e.Value.Messages))
+	for _, m := range e.Value.Messages {
+		if m.DefaultMessage != "" {
+			msgs = append(msgs, m.DefaultMessage)
+		} else {
+			msgs = append(msgs, m.ID)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Status, e.Type, strings.Join(msgs, "; "))
+}
+
+// isInvalidLibraryItem reports whether a vAPI error means vCenter cannot read
+// the item as OVF. Session, permission and transport errors do not qualify:
+// callers remove and re-import items that do.
+func isInvalidLibraryItem(err error) bool {
+	var vErr *vapiError
+	if !errors.As(err, &vErr) {
+		return false
+	}
+	for _, m := range vErr.Value.Messages {
+		if m.ID == ovfInvalidLibraryItemID {
+			return true
+		}
+	}
+	return false
+}
+
+// filterLibraryItem is vapivcenter.Manager.FilterLibraryItem, except that a
+// 400 response is returned as a *vapiError instead of flattened into text.
+func (c *Client) filterLibraryItem(rc *rest.Client, itemID string, filter vapivcenter.FilterRequest) (vapivcenter.FilterResponse, error) {
+	var res vapivcenter.FilterResponse
+	req := rc.Resource("/com/vmware/vcenter/ovf/library-item").WithID(itemID).WithAction("filter").Request(http.MethodPost, filter)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("vmware-api-session-id", rc.SessionID())
+	err := rc.Client.Do(c.ctx, req, func(r *http.Response) error {
+		switch r.StatusCode {
+		case http.StatusOK:
+			return json.NewDecoder(r.Body).Decode(&struct {
+				Value *vapivcenter.FilterResponse `json:"value"`
+			}{&res})
+		case http.StatusBadRequest:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return err
+			}
+			vErr := &vapiError{Status: r.Status}
+			if json.Unmarshal(body, vErr) != nil || vErr.Type == "" {
+				return fmt.Errorf("%s: %s", r.Status, bytes.TrimSpace(body))
+			}
+			return vErr
+		default:
+			return fmt.Errorf("%s %s: %s", req.Method, req.URL, r.Status)
+		}
+	})
+	return res, err
+}
+
+// DeployLibraryItem deploys an OVF library item as a new (powered off) VM.
+// Returns an error wrapping ErrInvalidLibraryItem if vCenter cannot read the item as OVF.
+func (c *Client) DeployLibraryItem(opts LibraryDeployOptions) (*object.VirtualMachine, error) {
+	if strings.TrimSpace(opts.ItemID) == "" || strings.TrimSpace(opts.VMName) == "" {
+		return nil, fmt.Errorf("library item ID and VM name are required")
+	}
+
+	ds, err := c.FindDatastore(opts.Datacenter, opts.Datastore)
+	if err != nil {
+		return nil, err
+	}
+	var pool *object.ResourcePool
+	if opts.ResourcePool != "" {
+		pool, err = c.FindResourcePool(opts.Datacenter, opts.ResourcePool)
+	} else {
+		pool, err = c.finder.DefaultResourcePool(c.ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find resource pool: %w", err)
+	}
+	var folder *object.Folder
+	if opts.Folder != "" {
+		folder, err = c.FindFolder(opts.Datacenter, opts.Folder)
+	} else {
+		folder, err = c.finder.DefaultFolder(c.ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find folder: %w", err)
+	}
+
+	rc, err := c.restClient()
+	if err != nil {
+		return nil, err
+	}
+	m := vapivcenter.NewManager(rc)
+	target := vapivcenter.Target{
+		ResourcePoolID: pool.Reference().Value,
+		FolderID:       folder.Reference().Value,
+	}
+
+	filter, err := c.filterLibraryItem(rc, opts.ItemID, vapivcenter.FilterRequest{Target: target})
+	if isInvalidLibraryItem(err) {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidLibraryItem, opts.ItemID, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("library item filter failed: %w", err)
+	}
+
+	spec := vapivcenter.DeploymentSpec{
+		Name:               opts.VMName,
+		DefaultDatastoreID: ds.Reference().Value,
+		AcceptAllEULA:      true,
+	}
+	if opts.Network != "" && len(filter.Networks) > 0 {
+		net, err := c.FindNetwork(opts.Datacenter, opts.Network)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range filter.Networks {
+			spec.NetworkMappings = append(spec.NetworkMappings, vapivcenter.NetworkMapping{
+				Key:   name,
+				Value: net.Reference().Value,
+			})
+		}
+	}
+
+	ref, err := m.DeployLibraryItem(c.ctx, opts.ItemID, vapivcenter.Deploy{DeploymentSpec: spec, Target: target})
+	if err != nil {
+		return nil, fmt.Errorf("library deploy failed: %w", err)
+	}
+	return object.NewVirtualMachine(c.conn.Client, *ref), nil
+}
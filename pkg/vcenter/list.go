@@ -1,10 +1,14 @@
 package vcenter
 
 import (
+	"fmt"
 	"net"
 	"strings"
 
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 // DatastoreInfo holds information about a vCenter datastore.
@@ -23,37 +27,34 @@ type NetworkInfo struct {
 
 // ListDatastores returns all datastores in a datacenter.
 func (c *Client) ListDatastores(datacenter string) ([]DatastoreInfo, error) {
-	dc, err := c.FindDatacenter(datacenter)
-	if err != nil {
-		return nil, err
-	}
-	c.finder.SetDatacenter(dc)
-
-	dsList, err := c.finder.DatastoreList(c.ctx, "*")
-	if err != nil {
-		return nil, err
-	}
-
-	var result []DatastoreInfo
-	for _, ds := range dsList {
-		var moDS mo.Datastore
-		if err := ds.Properties(c.ctx, ds.Reference(), []string{"summary"}, &moDS); err != nil {
-			continue
+	return cachedList(c, "datastores", datacenter, func() ([]DatastoreInfo, error) {
+		var dss []mo.Datastore
+		if err := c.retrieveView(datacenter, "Datastore", []string{"summary"}, &dss, nil); err != nil {
+			return nil, err
 		}
-		s := moDS.Summary
-		result = append(result, DatastoreInfo{
-			Name:        s.Name,
-			CapacityGB:  float64(s.Capacity) / (1024 * 1024 * 1024),
-			FreeSpaceGB: float64(s.FreeSpace) / (1024 * 1024 * 1024),
-			Accessible:  s.Accessible,
-			Type:        inferStorageType(s.Name),
-		})
-	}
-	return result, nil
+		result := make([]DatastoreInfo, 0, len(dss))
+		for _, ds := range dss {
+			s := ds.Summary
+			result = append(result, DatastoreInfo{
+				Name:        s.Name,
+				CapacityGB:  float64(s.Capacity) / (1024 * 1024 * 1024),
+				FreeSpaceGB: float64(s.FreeSpace) / (1024 * 1024 * 1024),
+				Accessible:  s.Accessible,
+				Type:        inferStorageType(s.Name),
+			})
+		}
+		return result, nil
+	})
 }
 
 // ListNetworks returns all networks/port groups in a datacenter.
 func (c *Client) ListNetworks(datacenter string) ([]NetworkInfo, error) {
+	return cachedList(c, "networks", datacenter, func() ([]NetworkInfo, error) {
+		return c.listNetworks(datacenter)
+	})
+}
+
+func (c *Client) listNetworks(datacenter string) ([]NetworkInfo, error) {
 	dc, err := c.FindDatacenter(datacenter)
 	if err != nil {
 		return nil, err
@@ -79,6 +80,12 @@ type FolderInfo struct {
 
 // ListFolders returns all VM folders in a datacenter (excludes the datacenter root folder itself).
 func (c *Client) ListFolders(datacenter string) ([]FolderInfo, error) {
+	return cachedList(c, "folders", datacenter, func() ([]FolderInfo, error) {
+		return c.listFolders(datacenter)
+	})
+}
+
+func (c *Client) listFolders(datacenter string) ([]FolderInfo, error) {
 	dc, err := c.FindDatacenter(datacenter)
 	if err != nil {
 		return nil, err
@@ -113,6 +120,12 @@ type ResourcePoolInfo struct {
 
 // ListResourcePools returns all resource pools in a datacenter.
 func (c *Client) ListResourcePools(datacenter string) ([]ResourcePoolInfo, error) {
+	return cachedList(c, "resource-pools", datacenter, func() ([]ResourcePoolInfo, error) {
+		return c.listResourcePools(datacenter)
+	})
+}
+
+func (c *Client) listResourcePools(datacenter string) ([]ResourcePoolInfo, error) {
 	dc, err := c.FindDatacenter(datacenter)
 	if err != nil {
 		return nil, err
@@ -145,40 +158,155 @@ type VMGuestIPInfo struct {
 // ListVMGuestIPs returns VM name -> reported guest IPv4 address for a datacenter.
 // This is best-effort information sourced from guest tools.
 func (c *Client) ListVMGuestIPs(datacenter string) ([]VMGuestIPInfo, error) {
+	return cachedList(c, "vm-guest-ips", datacenter, func() ([]VMGuestIPInfo, error) {
+		var vms []mo.VirtualMachine
+		if err := c.retrieveView(datacenter, "VirtualMachine", []string{"name", "guest.ipAddress"}, &vms, nil); err != nil {
+			return nil, err
+		}
+		out := make([]VMGuestIPInfo, 0, len(vms))
+		for _, vm := range vms {
+			if vm.Guest == nil {
+				continue
+			}
+			ip := strings.TrimSpace(vm.Guest.IpAddress)
+			if ip == "" {
+				continue
+			}
+			parsed := net.ParseIP(ip)
+			if parsed == nil || parsed.To4() == nil {
+				continue
+			}
+			out = append(out, VMGuestIPInfo{
+				Name: vm.Name,
+				IP:   parsed.String(),
+			})
+		}
+		return out, nil
+	})
+}
+
+// HostInfo holds summary information about an ESXi host.
+type HostInfo struct {
+	Name            string
+	ConnectionState string // connected, disconnected, notResponding
+	InMaintenance   bool
+	CPUCores        int16
+	CPUMHz          int32 // Per-core clock speed
+	MemoryMB        int64
+}
+
+// ListHosts returns all ESXi hosts in a datacenter.
+func (c *Client) ListHosts(datacenter string) ([]HostInfo, error) {
+	return cachedList(c, "hosts", datacenter, func() ([]HostInfo, error) {
+		var hosts []mo.HostSystem
+		if err := c.retrieveView(datacenter, "HostSystem", []string{"name", "runtime", "summary.hardware"}, &hosts, nil); err != nil {
+			return nil, err
+		}
+		out := make([]HostInfo, 0, len(hosts))
+		for _, h := range hosts {
+			info := HostInfo{
+				Name:            h.Name,
+				ConnectionState: string(h.Runtime.ConnectionState),
+				InMaintenance:   h.Runtime.InMaintenanceMode,
+			}
+			if hw := h.Summary.Hardware; hw != nil {
+				info.CPUCores = hw.NumCpuCores
+				info.CPUMHz = hw.CpuMhz
+				info.MemoryMB = hw.MemorySize / (1024 * 1024)
+			}
+			out = append(out, info)
+		}
+		return out, nil
+	})
+}
+
+// ClusterInfo holds summary information about a compute cluster.
+type ClusterInfo struct {
+	Name        string
+	Hosts       int   // Total hosts in the cluster
+	EffectiveMB int64 // Memory available to VMs across effective hosts
+	DRSEnabled  bool
+}
+
+// ListClusters returns all compute clusters in a datacenter.
+func (c *Client) ListClusters(datacenter string) ([]ClusterInfo, error) {
+	return cachedList(c, "clusters", datacenter, func() ([]ClusterInfo, error) {
+		var clusters []mo.ClusterComputeResource
+		if err := c.retrieveView(datacenter, "ClusterComputeResource", []string{"name", "summary", "configurationEx"}, &clusters, nil); err != nil {
+			return nil, err
+		}
+		out := make([]ClusterInfo, 0, len(clusters))
+		for _, cl := range clusters {
+			info := ClusterInfo{Name: cl.Name}
+			if s := cl.Summary; s != nil {
+				cs := s.GetComputeResourceSummary()
+				info.Hosts = int(cs.NumHosts)
+				info.EffectiveMB = cs.EffectiveMemory
+			}
+			if cfg, ok := cl.ConfigurationEx.(*types.ClusterConfigInfoEx); ok && cfg.DrsConfig.Enabled != nil {
+				info.DRSEnabled = *cfg.DrsConfig.Enabled
+			}
+			out = append(out, info)
+		}
+		return out, nil
+	})
+}
+
+// VMInfo holds summary information about a virtual machine (templates excluded).
+type VMInfo struct {
+	Name       string
+	PowerState string // poweredOn, poweredOff, suspended
+	CPUs       int32
+	MemoryMB   int32
+	GuestID    string
+	IP         string // Best-effort guest IP from VMware Tools
+}
+
+// ListVMs returns all virtual machines in a datacenter, excluding templates.
+func (c *Client) ListVMs(datacenter string) ([]VMInfo, error) {
+	return cachedList(c, "vms", datacenter, func() ([]VMInfo, error) {
+		var vms []mo.VirtualMachine
+		props := []string{"name", "summary.runtime.powerState", "summary.config", "summary.guest.ipAddress"}
+		if err := c.retrieveView(datacenter, "VirtualMachine", props, &vms, property.Match{"summary.config.template": false}); err != nil {
+			return nil, err
+		}
+		out := make([]VMInfo, 0, len(vms))
+		for _, vm := range vms {
+			info := VMInfo{
+				Name:       vm.Name,
+				PowerState: string(vm.Summary.Runtime.PowerState),
+				CPUs:       vm.Summary.Config.NumCpu,
+				MemoryMB:   vm.Summary.Config.MemorySizeMB,
+				GuestID:    vm.Summary.Config.GuestId,
+			}
+			if g := vm.Summary.Guest; g != nil {
+				info.IP = g.IpAddress
+			}
+			out = append(out, info)
+		}
+		return out, nil
+	})
+}
+
+// retrieveView fetches props of every object of kind below the datacenter through a
+// single container view instead of one Properties call per object.
+// A non-empty filter restricts the result to matching objects.
+func (c *Client) retrieveView(datacenter, kind string, props []string, dst any, filter property.Match) error {
 	dc, err := c.FindDatacenter(datacenter)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	c.finder.SetDatacenter(dc)
 
-	vms, err := c.finder.VirtualMachineList(c.ctx, "*")
+	v, err := view.NewManager(c.conn.Client).CreateContainerView(c.ctx, dc.Reference(), []string{kind}, true)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to create %s view: %w", kind, err)
 	}
+	defer func() { _ = v.Destroy(c.ctx) }()
 
-	out := make([]VMGuestIPInfo, 0, len(vms))
-	for _, vm := range vms {
-		var moVM mo.VirtualMachine
-		if err := vm.Properties(c.ctx, vm.Reference(), []string{"name", "guest.ipAddress"}, &moVM); err != nil {
-			continue
-		}
-		if moVM.Guest == nil {
-			continue
-		}
-		ip := strings.TrimSpace(moVM.Guest.IpAddress)
-		if ip == "" {
-			continue
-		}
-		parsed := net.ParseIP(ip)
-		if parsed == nil || parsed.To4() == nil {
-			continue
-		}
-		out = append(out, VMGuestIPInfo{
-			Name: moVM.Name,
-			IP:   parsed.String(),
-		})
+	if err := v.RetrieveWithFilter(c.ctx, []string{kind}, props, dst, filter); err != nil {
+		return fmt.Errorf("failed to list %s objects: %w", kind, err)
 	}
-	return out, nil
+	return nil
 }
 
 // inferStorageType infers SSD vs HDD from the datastore name (matches Python heuristic).
@@ -61,6 +61,14 @@ func (m *ClientInterface) FindVM(datacenter, name string) (*object.VirtualMachin
 	return args.Get(0).(*object.VirtualMachine), args.Error(1)
 }
 
+func (m *ClientInterface) HostCapabilities(pool *object.ResourcePool) (*vcenter.HostCapabilities, error) {
+	args := m.Called(pool)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*vcenter.HostCapabilities), args.Error(1)
+}
+
 func (m *ClientInterface) ListDatastores(datacenter string) ([]vcenter.DatastoreInfo, error) {
 	args := m.Called(datacenter)
 	if args.Get(0) == nil {
@@ -101,6 +109,30 @@ func (m *ClientInterface) ListVMGuestIPs(datacenter string) ([]vcenter.VMGuestIP
 	return args.Get(0).([]vcenter.VMGuestIPInfo), args.Error(1)
 }
 
+func (m *ClientInterface) ListHosts(datacenter string) ([]vcenter.HostInfo, error) {
+	args := m.Called(datacenter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]vcenter.HostInfo), args.Error(1)
+}
+
+func (m *ClientInterface) ListClusters(datacenter string) ([]vcenter.ClusterInfo, error) {
+	args := m.Called(datacenter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]vcenter.ClusterInfo), args.Error(1)
+}
+
+func (m *ClientInterface) ListVMs(datacenter string) ([]vcenter.VMInfo, error) {
+	args := m.Called(datacenter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]vcenter.VMInfo), args.Error(1)
+}
+
 func (m *ClientInterface) Disconnect() error {
 	args := m.Called()
 	return args.Error(0)
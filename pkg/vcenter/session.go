@@ -0,0 +1,214 @@
+package vcenter
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// sessionFile is the decrypted content of a cached session.
+type sessionFile struct {
+	Cookies []*http.Cookie `json:"cookies"`
+}
+
+// sessionKeyName is the random AES-256 key of a session cache directory.
+const sessionKeyName = "session.key"
+
+// sessionCache stores vCenter session cookies on disk, one file per host+user.
+// Files are AES-GCM encrypted with a random key kept in the same directory
+// (0600), so the cache holds nothing that could be used to test password guesses.
+type sessionCache struct {
+	dir  string
+	host string
+	user string
+}
+
+func (s *sessionCache) path() string {
+	sum := sha256.Sum256([]byte(s.host + "\x00" + s.user))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".session")
+}
+
+// key reads the key of the cache directory. With create set a missing key is
+// generated; otherwise it is reported as nil.
+func (s *sessionCache) key(create bool) ([]byte, error) {
+	path := filepath.Join(s.dir, sessionKeyName)
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		key, err = createSessionKey(s.dir, path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid session key %s: want 32 bytes, got %d", path, len(key))
+	}
+	return key, nil
+}
+
+// createSessionKey writes a new random key to path. If another process created
+// the key first, that key is returned instead.
+func createSessionKey(dir, path string) ([]byte, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
+
+func newSessionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// load returns the cached cookies, or nil if there is no usable cache entry.
+func (s *sessionCache) load() ([]*http.Cookie, error) {
+	data, err := os.ReadFile(s.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.key(false)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	aead, err := newSessionAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, nil
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		// Written under another key (e.g. the key file was replaced) - treat as a miss.
+		return nil, nil
+	}
+	var f sessionFile
+	if err := json.Unmarshal(plain, &f); err != nil {
+		return nil, nil
+	}
+	return f.Cookies, nil
+}
+
+func (s *sessionCache) save(cookies []*http.Cookie) error {
+	plain, err := json.Marshal(sessionFile{Cookies: cookies})
+	if err != nil {
+		return err
+	}
+	key, err := s.key(true)
+	if err != nil {
+		return err
+	}
+	aead, err := newSessionAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	tmp := s.path() + ".tmp"
+	if err := os.WriteFile(tmp, aead.Seal(nonce, nonce, plain, nil), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path())
+}
+
+func (s *sessionCache) remove() {
+	_ = os.Remove(s.path())
+}
+
+// connect creates a govmomi client for vcURL, reusing a cached session when it is
+// still valid and logging in (and caching the new session) otherwise.
+// A non-zero keepAlive wraps the SOAP transport in a keep-alive handler.
+// Returns the keep-alive handler (nil if disabled) so Disconnect can stop it.
+func connect(ctx context.Context, vcURL *url.URL, cfg *Config, cache *sessionCache, keepAlive time.Duration) (*govmomi.Client, *keepalive.HandlerSOAP, error) {
+	soapClient := soap.NewClient(vcURL, cfg.Insecure)
+	if err := configureTLS(soapClient, vcURL.Host, cfg); err != nil {
+		return nil, nil, err
+	}
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		if soap.IsCertificateUntrusted(err) {
+			return nil, nil, fmt.Errorf("%w: %v (set a thumbprint or CA certificate)", ErrCertificateUntrusted, err)
+		}
+		return nil, nil, err
+	}
+
+	var ka *keepalive.HandlerSOAP
+	if keepAlive > 0 {
+		ka = keepalive.NewHandlerSOAP(vimClient.RoundTripper, keepAlive, nil)
+		vimClient.RoundTripper = ka
+	}
+
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+
+	if cache != nil {
+		if cookies, err := cache.load(); err == nil && len(cookies) > 0 {
+			soapClient.Jar.SetCookies(vcURL, cookies)
+			if us, err := client.SessionManager.UserSession(ctx); err == nil && us != nil {
+				if ka != nil {
+					ka.Start()
+				}
+				return client, ka, nil
+			}
+			cache.remove()
+		}
+	}
+
+	if err := client.Login(ctx, vcURL.User); err != nil {
+		return nil, nil, err
+	}
+	if cache != nil {
+		// A failed cache write only costs a login next time.
+		_ = cache.save(soapClient.Jar.Cookies(vcURL))
+	}
+	return client, ka, nil
+}
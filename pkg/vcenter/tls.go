@@ -0,0 +1,170 @@
+package vcenter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// ErrCertificateUntrusted is returned by NewClient when the vCenter certificate is
+// not trusted by the configured roots and no matching thumbprint is pinned.
+var ErrCertificateUntrusted = errors.New("vCenter certificate is not trusted")
+
+// ErrThumbprintMismatch is returned when the vCenter certificate does not match Config.Thumbprint.
+var ErrThumbprintMismatch = errors.New("vCenter certificate does not match pinned thumbprint")
+
+// NormalizeThumbprint converts a SHA-1 or SHA-256 certificate fingerprint in any
+// common notation (upper/lower case, with or without colons or spaces) to the
+// uppercase colon-separated form used by govmomi.
+func NormalizeThumbprint(thumbprint string) (string, error) {
+	clean := strings.NewReplacer(":", "", " ", "", "-", "").Replace(strings.TrimSpace(thumbprint))
+	raw, err := hex.DecodeString(clean)
+	if err != nil {
+		return "", fmt.Errorf("invalid thumbprint %q: not hex", thumbprint)
+	}
+	if len(raw) != 20 && len(raw) != 32 {
+		return "", fmt.Errorf("invalid thumbprint %q: expected SHA-1 or SHA-256 (got %d bytes)", thumbprint, len(raw))
+	}
+	parts := make([]string, len(raw))
+	for i, b := range raw {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// thumbprintMatches reports whether cert has the given normalized SHA-1 or SHA-256 thumbprint.
+func thumbprintMatches(thumbprint string, cert *x509.Certificate) bool {
+	return thumbprint == soap.ThumbprintSHA256(cert) || thumbprint == soap.ThumbprintSHA1(cert)
+}
+
+// rootCAs returns the pool built from CACertFile and CACertPEM, or nil to use the system roots.
+func (cfg *Config) rootCAs() (*x509.CertPool, error) {
+	if cfg.CACertFile == "" && strings.TrimSpace(cfg.CACertPEM) == "" {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, name := range filepath.SplitList(cfg.CACertFile) {
+		data, err := os.ReadFile(filepath.Clean(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", name)
+		}
+	}
+	if strings.TrimSpace(cfg.CACertPEM) != "" && !pool.AppendCertsFromPEM([]byte(cfg.CACertPEM)) {
+		return nil, fmt.Errorf("no certificates found in inline CA PEM")
+	}
+	return pool, nil
+}
+
+// configureTLS applies the CA and thumbprint settings of cfg to sc.
+// The transport is shared by every client derived from sc (vAPI, datastore and
+// content library transfers), so they all verify vCenter the same way.
+func configureTLS(sc *soap.Client, host string, cfg *Config) error {
+	if cfg.Insecure && (cfg.Thumbprint != "" || cfg.CACertFile != "" || cfg.CACertPEM != "") {
+		return fmt.Errorf("insecure cannot be combined with thumbprint or CA certificate options")
+	}
+	tlsCfg := sc.DefaultTransport().TLSClientConfig
+
+	pool, err := cfg.rootCAs()
+	if err != nil {
+		return err
+	}
+	if pool != nil {
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.Thumbprint == "" {
+		return nil
+	}
+	thumbprint, err := NormalizeThumbprint(cfg.Thumbprint)
+	if err != nil {
+		return err
+	}
+	// govmomi only consults the thumbprint when normal verification fails;
+	// VerifyConnection also enforces the pin for CA-signed certificates.
+	sc.SetThumbprint(host, thumbprint)
+	tlsCfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 || !thumbprintMatches(thumbprint, cs.PeerCertificates[0]) {
+			return fmt.Errorf("%w (%s)", ErrThumbprintMismatch, thumbprint)
+		}
+		return nil
+	}
+	return nil
+}
+
+// CertificateInfo describes the certificate presented by a vCenter server.
+type CertificateInfo struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+
+	SHA1   string // colon-separated hex
+	SHA256 string // colon-separated hex
+
+	// Trusted is true when the certificate verifies against the configured roots
+	// (system roots unless CACertFile/CACertPEM are set) for the vCenter host name.
+	Trusted bool
+
+	// Pinned is true when Config.Thumbprint is set and matches the certificate.
+	Pinned bool
+}
+
+// ProbeCertificate connects to the vCenter in cfg without verifying it and reports
+// the certificate it presents, so callers can offer trust-on-first-use.
+// No credentials are sent.
+func ProbeCertificate(ctx context.Context, cfg *Config) (*CertificateInfo, error) {
+	vcURL, err := cfg.sdkURL()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}} // #nosec G402 -- certificate is inspected, not trusted
+	conn, err := dialer.DialContext(ctx, "tcp", vcURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", vcURL.Host, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	chain := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s presented no certificate", vcURL.Host)
+	}
+	leaf := chain[0]
+	info := &CertificateInfo{
+		Subject:  leaf.Subject.String(),
+		Issuer:   leaf.Issuer.String(),
+		NotAfter: leaf.NotAfter,
+		SHA1:     soap.ThumbprintSHA1(leaf),
+		SHA256:   soap.ThumbprintSHA256(leaf),
+	}
+
+	pool, err := cfg.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	host, _, _ := net.SplitHostPort(vcURL.Host)
+	_, verr := leaf.Verify(x509.VerifyOptions{Roots: pool, Intermediates: intermediates, DNSName: host})
+	info.Trusted = verr == nil
+
+	if cfg.Thumbprint != "" {
+		if tp, err := NormalizeThumbprint(cfg.Thumbprint); err == nil {
+			info.Pinned = thumbprintMatches(tp, leaf)
+		}
+	}
+	return info, nil
+}
@@ -4,7 +4,10 @@ package vm
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
 	"github.com/vmware/govmomi/object"
@@ -13,7 +16,8 @@ import (
 
 // Creator handles VM creation and hardware configuration.
 type Creator struct {
-	ctx context.Context
+	ctx             context.Context
+	shutdownTimeout time.Duration // 0 = configs.Defaults.Timeouts.GuestShutdown()
 }
 
 // NewCreator creates a new VM creator instance.
@@ -21,29 +25,68 @@ func NewCreator(ctx context.Context) *Creator {
 	return &Creator{ctx: ctx}
 }
 
+// SetShutdownTimeout sets how long ShutdownGuest waits for the guest before a
+// hard power-off (0 = configured default).
+func (c *Creator) SetShutdownTimeout(d time.Duration) {
+	c.shutdownTimeout = d
+}
+
 // Config holds VM hardware configuration.
 type Config struct {
-	Name           string // VM name
-	CPUs           int32  // Number of CPUs
-	MemoryMB       int64  // Memory in MB
-	GuestOS        string // Guest OS identifier (e.g., "ubuntu64Guest")
-	Firmware       string // Firmware type: "bios" or "efi" (default: bios)
-	DiskSizeGB     int64  // OS disk size in GB
-	DataDiskSizeGB *int64 // Optional data disk size in GB
-	NetworkName    string // Network name
-	Datacenter     string // Datacenter name
-	Folder         string // VM folder path
-	ResourcePool   string // Resource pool path
-	Datastore      string // Datastore name
+	Name            string            // VM name
+	CPUs            int32             // Number of CPUs
+	MemoryMB        int64             // Memory in MB
+	GuestOS         string            // Guest OS identifier (e.g., "ubuntu64Guest")
+	HardwareVersion string            // Virtual hardware version (e.g., "vmx-19"); empty = vCenter default
+	ExtraConfig     map[string]string // VMX advanced settings (e.g., "disk.EnableUUID": "TRUE")
+	Firmware        string            // Firmware type: "bios" or "efi" (default: bios)
+	DiskSizeGB      int64             // OS disk size in GB
+	DataDiskSizeGB  *int64            // Optional data disk size in GB
+	NetworkName     string            // Network name
+	Datacenter      string            // Datacenter name
+	Folder          string            // VM folder path
+	ResourcePool    string            // Resource pool path
+	Datastore       string            // Datastore name
+
+	// Advanced CPU/memory options (zero values keep vCenter defaults)
+	CoresPerSocket      int32  // Cores per virtual socket (must divide CPUs)
+	CPUHotAdd           bool   // Allow adding vCPUs while powered on
+	MemoryHotAdd        bool   // Allow adding memory while powered on
+	CPUReservationMHz   int64  // Guaranteed CPU in MHz
+	CPULimitMHz         int64  // CPU limit in MHz (0 = unlimited)
+	CPUShares           string // "low", "normal", "high" or custom number
+	MemoryReservationMB int64  // Guaranteed memory in MB
+	MemoryLimitMB       int64  // Memory limit in MB (0 = unlimited)
+	MemoryShares        string // "low", "normal", "high" or custom number
+	LatencySensitivity  string // "low", "normal", "medium" or "high" (high reserves all memory)
+
+	// Security options
+	SecureBoot       bool   // Enable UEFI Secure Boot (implies efi firmware)
+	VTPM             bool   // Add a virtual TPM 2.0 device (implies efi firmware)
+	EncryptionPolicy string // Optional storage policy ID applied to VM home and disks (e.g., VM Encryption Policy)
+}
+
+// effectiveFirmware returns the firmware to use for cfg.
+// Secure Boot and vTPM both require EFI, so they override a BIOS default.
+func (cfg *Config) effectiveFirmware() string {
+	if cfg.SecureBoot || cfg.VTPM {
+		return "efi"
+	}
+	if cfg.Firmware == "" {
+		return configs.Defaults.VM.Firmware
+	}
+	return cfg.Firmware
+}
+
+// HasSecurityOptions reports whether any of SecureBoot, VTPM or EncryptionPolicy is set.
+func (cfg *Config) HasSecurityOptions() bool {
+	return cfg.SecureBoot || cfg.VTPM || cfg.EncryptionPolicy != ""
 }
 
 // CreateSpec builds a VirtualMachineConfigSpec from the given configuration.
 func (c *Creator) CreateSpec(cfg *Config) *types.VirtualMachineConfigSpec {
 	// Default to BIOS firmware (from configs/defaults.yaml)
-	firmware := cfg.Firmware
-	if firmware == "" {
-		firmware = configs.Defaults.VM.Firmware
-	}
+	firmware := cfg.effectiveFirmware()
 
 	// Default guest OS (from configs/defaults.yaml)
 	guestOS := cfg.GuestOS
@@ -56,9 +99,11 @@ func (c *Creator) CreateSpec(cfg *Config) *types.VirtualMachineConfigSpec {
 		NumCPUs:  cfg.CPUs,
 		MemoryMB: cfg.MemoryMB,
 		GuestId:  guestOS,
+		Version:  cfg.HardwareVersion,
 		Files: &types.VirtualMachineFileInfo{
 			VmPathName: fmt.Sprintf("[%s]", cfg.Datastore),
 		},
+		ExtraConfig: extraConfigOptions(cfg.ExtraConfig),
 	}
 
 	// Set firmware
@@ -66,9 +111,177 @@ func (c *Creator) CreateSpec(cfg *Config) *types.VirtualMachineConfigSpec {
 		spec.Firmware = string(types.GuestOsDescriptorFirmwareTypeEfi)
 	}
 
+	applyResourceOptions(spec, cfg)
+
+	if cfg.SecureBoot {
+		spec.BootOptions = &types.VirtualMachineBootOptions{
+			EfiSecureBootEnabled: types.NewBool(true),
+		}
+	}
+
+	if cfg.VTPM {
+		spec.DeviceChange = append(spec.DeviceChange, newVTPMDeviceChange())
+	}
+
+	if cfg.EncryptionPolicy != "" {
+		spec.VmProfile = storageProfileSpec(cfg.EncryptionPolicy)
+	}
+
+	return spec
+}
+
+// ConfigureSecurity applies SecureBoot, VTPM and EncryptionPolicy to an existing VM.
+// Used for VMs that were not created from CreateSpec (e.g. OVA deployments) and to
+// propagate the encryption policy to disks attached after creation.
+// The VM must be powered off. Does nothing when no security option is set.
+func (c *Creator) ConfigureSecurity(vm *object.VirtualMachine, cfg *Config) error {
+	if !cfg.HasSecurityOptions() {
+		return nil
+	}
+
+	devices, err := vm.Device(c.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get VM devices: %w", err)
+	}
+
+	spec := types.VirtualMachineConfigSpec{}
+	if cfg.SecureBoot || cfg.VTPM {
+		spec.Firmware = string(types.GuestOsDescriptorFirmwareTypeEfi)
+	}
+	if cfg.SecureBoot {
+		spec.BootOptions = &types.VirtualMachineBootOptions{
+			EfiSecureBootEnabled: types.NewBool(true),
+		}
+	}
+	if cfg.VTPM && len(devices.SelectByType((*types.VirtualTPM)(nil))) == 0 {
+		spec.DeviceChange = append(spec.DeviceChange, newVTPMDeviceChange())
+	}
+	if cfg.EncryptionPolicy != "" {
+		spec.VmProfile = storageProfileSpec(cfg.EncryptionPolicy)
+		for _, disk := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+			spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationEdit,
+				Device:    disk,
+				Profile:   storageProfileSpec(cfg.EncryptionPolicy),
+			})
+		}
+	}
+
+	task, err := vm.Reconfigure(c.ctx, spec)
+	if err != nil {
+		return fmt.Errorf("failed to reconfigure VM security: %w", err)
+	}
+	if err := task.Wait(c.ctx); err != nil {
+		return fmt.Errorf("failed to apply VM security options: %w", err)
+	}
+	return nil
+}
+
+// ReconfigureHardware applies CPU/memory sizing and tuning, guest OS ID and
+// extraConfig from cfg to an existing VM, upgrading the virtual hardware version
+// first when cfg.HardwareVersion is set. Used for VMs deployed from an OVA, which
+// ship with the template's hardware. Zero-valued fields are left unchanged.
+// The VM must be powered off.
+func (c *Creator) ReconfigureHardware(vm *object.VirtualMachine, cfg *Config) error {
+	if cfg.HardwareVersion != "" {
+		task, err := vm.UpgradeVM(c.ctx, cfg.HardwareVersion)
+		if err != nil {
+			return fmt.Errorf("failed to upgrade VM hardware: %w", err)
+		}
+		if err := task.Wait(c.ctx); err != nil {
+			return fmt.Errorf("failed to upgrade VM hardware to %s: %w", cfg.HardwareVersion, err)
+		}
+	}
+
+	task, err := vm.Reconfigure(c.ctx, *hardwareSpec(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to reconfigure VM hardware: %w", err)
+	}
+	if err := task.Wait(c.ctx); err != nil {
+		return fmt.Errorf("failed to apply VM hardware: %w", err)
+	}
+	return nil
+}
+
+// hardwareSpec builds the reconfigure spec used by ReconfigureHardware.
+func hardwareSpec(cfg *Config) *types.VirtualMachineConfigSpec {
+	spec := &types.VirtualMachineConfigSpec{
+		NumCPUs:     cfg.CPUs,
+		MemoryMB:    cfg.MemoryMB,
+		GuestId:     cfg.GuestOS,
+		ExtraConfig: extraConfigOptions(cfg.ExtraConfig),
+	}
+	applyResourceOptions(spec, cfg)
 	return spec
 }
 
+// ResizeDisk grows the VM's first virtual disk to sizeGB.
+// Disks cannot shrink, so a disk that is already as large or larger is left unchanged.
+func (c *Creator) ResizeDisk(vm *object.VirtualMachine, sizeGB int64) error {
+	devices, err := vm.Device(c.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get VM devices: %w", err)
+	}
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return fmt.Errorf("no virtual disk found")
+	}
+	disk := disks[0].(*types.VirtualDisk)
+
+	capacityKB := sizeGB * 1024 * 1024
+	if disk.CapacityInKB >= capacityKB {
+		return nil
+	}
+	disk.CapacityInKB = capacityKB
+	disk.CapacityInBytes = capacityKB * 1024
+
+	spec := types.VirtualMachineConfigSpec{
+		DeviceChange: []types.BaseVirtualDeviceConfigSpec{
+			&types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationEdit,
+				Device:    disk,
+			},
+		},
+	}
+	task, err := vm.Reconfigure(c.ctx, spec)
+	if err != nil {
+		return fmt.Errorf("failed to reconfigure VM: %w", err)
+	}
+	if err := task.Wait(c.ctx); err != nil {
+		return fmt.Errorf("failed to resize disk: %w", err)
+	}
+	return nil
+}
+
+// extraConfigOptions converts a key/value map into sorted OptionValues (nil when empty).
+func extraConfigOptions(extra map[string]string) []types.BaseOptionValue {
+	if len(extra) == 0 {
+		return nil
+	}
+	opts := make([]types.BaseOptionValue, 0, len(extra))
+	for _, k := range slices.Sorted(maps.Keys(extra)) {
+		opts = append(opts, &types.OptionValue{Key: k, Value: extra[k]})
+	}
+	return opts
+}
+
+// newVTPMDeviceChange returns a device change that adds a virtual TPM.
+func newVTPMDeviceChange() types.BaseVirtualDeviceConfigSpec {
+	return &types.VirtualDeviceConfigSpec{
+		Operation: types.VirtualDeviceConfigSpecOperationAdd,
+		Device: &types.VirtualTPM{
+			VirtualDevice: types.VirtualDevice{Key: -1},
+		},
+	}
+}
+
+// storageProfileSpec builds a storage policy profile spec for the given policy ID.
+func storageProfileSpec(policyID string) []types.BaseVirtualMachineProfileSpec {
+	return []types.BaseVirtualMachineProfileSpec{
+		&types.VirtualMachineDefinedProfileSpec{ProfileId: policyID},
+	}
+}
+
 // Create creates a VM in vCenter with the given specification.
 func (c *Creator) Create(
 	folder *object.Folder,
@@ -230,14 +443,23 @@ func (c *Creator) PowerOn(vm *object.VirtualMachine) error {
 	return task.Wait(c.ctx)
 }
 
-// PowerOff powers off the VM gracefully.
+// PowerOff hard powers off the VM (no guest shutdown). Prefer ShutdownGuest.
 func (c *Creator) PowerOff(vm *object.VirtualMachine) error {
-	task, err := vm.PowerOff(c.ctx)
-	if err != nil {
-		return fmt.Errorf("failed to power off VM: %w", err)
-	}
+	return hardPowerOff(c.ctx, vm)
+}
 
-	return task.Wait(c.ctx)
+// ShutdownGuest shuts the guest down via VMware Tools, falling back to a hard
+// power-off after the shutdown timeout.
+func (c *Creator) ShutdownGuest(vm *object.VirtualMachine) error {
+	_, err := ShutdownGuest(c.ctx, vm, c.shutdownTimeout)
+	return err
+}
+
+// RebootGuest reboots the guest via VMware Tools, falling back to a hard reset
+// after the configured guest shutdown timeout.
+func (c *Creator) RebootGuest(vm *object.VirtualMachine) error {
+	_, err := RebootGuest(c.ctx, vm, c.shutdownTimeout)
+	return err
 }
 
 // Delete removes the VM from vCenter.
@@ -249,7 +471,7 @@ func (c *Creator) Delete(vm *object.VirtualMachine) error {
 	}
 
 	if powerState == types.VirtualMachinePowerStatePoweredOn {
-		if err := c.PowerOff(vm); err != nil {
+		if err := c.ShutdownGuest(vm); err != nil {
 			return fmt.Errorf("failed to power off before delete: %w", err)
 		}
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
@@ -17,6 +18,7 @@ type simEnv struct {
 	ctx    context.Context
 	client *govmomi.Client
 	finder *find.Finder
+	model  *simulator.Model
 }
 
 func newSimEnv(t *testing.T) (*simEnv, func()) {
@@ -48,7 +50,7 @@ func newSimEnv(t *testing.T) (*simEnv, func()) {
 		model.Remove()
 	}
 
-	return &simEnv{ctx: ctx, client: c, finder: f}, cleanup
+	return &simEnv{ctx: ctx, client: c, finder: f, model: model}, cleanup
 }
 
 func (e *simEnv) defaultObjects(t *testing.T) (*object.Folder, *object.ResourcePool, *object.Datastore, object.NetworkReference) {
@@ -204,3 +206,38 @@ func TestPowerOnPowerOffDelete(t *testing.T) {
 
 	require.NoError(t, creator.Delete(vm))
 }
+
+func TestConfigureSecurity(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-security")
+	cfg := &Config{Name: "vm-security", SecureBoot: true, VTPM: true}
+
+	require.NoError(t, creator.ConfigureSecurity(vm, cfg))
+	// Second call must not add a second vTPM.
+	require.NoError(t, creator.ConfigureSecurity(vm, cfg))
+
+	devices, err := vm.Device(env.ctx)
+	require.NoError(t, err)
+	require.Len(t, devices.SelectByType((*types.VirtualTPM)(nil)), 1)
+
+	var props mo.VirtualMachine
+	require.NoError(t, vm.Properties(env.ctx, vm.Reference(), []string{"config.firmware", "config.bootOptions"}, &props))
+	require.Equal(t, string(types.GuestOsDescriptorFirmwareTypeEfi), props.Config.Firmware)
+	require.NotNil(t, props.Config.BootOptions)
+	require.True(t, *props.Config.BootOptions.EfiSecureBootEnabled)
+}
+
+func TestConfigureSecurity_NoOptions(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-security-noop")
+
+	require.NoError(t, creator.ConfigureSecurity(vm, &Config{Name: "vm-security-noop"}))
+
+	devices, err := vm.Device(env.ctx)
+	require.NoError(t, err)
+	require.Empty(t, devices.SelectByType((*types.VirtualTPM)(nil)))
+}
@@ -114,3 +114,85 @@ func TestCreateSpec_defaultFirmwareFromConfig(t *testing.T) {
 		t.Errorf("Default BIOS firmware should not set spec.Firmware, got %q", spec.Firmware)
 	}
 }
+
+func TestCreateSpec_secureBootAndVTPM(t *testing.T) {
+	creator := NewCreator(context.Background())
+	cfg := &Config{
+		Name:       "vm",
+		Firmware:   "", // secure boot implies efi
+		Datastore:  "ds",
+		SecureBoot: true,
+		VTPM:       true,
+	}
+
+	spec := creator.CreateSpec(cfg)
+
+	if want := string(types.GuestOsDescriptorFirmwareTypeEfi); spec.Firmware != want {
+		t.Errorf("spec.Firmware = %q, want %q", spec.Firmware, want)
+	}
+	if spec.BootOptions == nil || spec.BootOptions.EfiSecureBootEnabled == nil || !*spec.BootOptions.EfiSecureBootEnabled {
+		t.Error("expected EfiSecureBootEnabled=true")
+	}
+	if len(spec.DeviceChange) != 1 {
+		t.Fatalf("DeviceChange len = %d, want 1", len(spec.DeviceChange))
+	}
+	change := spec.DeviceChange[0].GetVirtualDeviceConfigSpec()
+	if change.Operation != types.VirtualDeviceConfigSpecOperationAdd {
+		t.Errorf("Operation = %q, want add", change.Operation)
+	}
+	if _, ok := change.Device.(*types.VirtualTPM); !ok {
+		t.Errorf("Device = %T, want *types.VirtualTPM", change.Device)
+	}
+	if spec.VmProfile != nil {
+		t.Error("VmProfile should be empty without EncryptionPolicy")
+	}
+}
+
+func TestCreateSpec_encryptionPolicy(t *testing.T) {
+	creator := NewCreator(context.Background())
+	cfg := &Config{
+		Name:             "vm",
+		Datastore:        "ds",
+		EncryptionPolicy: "4d5f673c-536f-11e6-beb8-9e71128cae77",
+	}
+
+	spec := creator.CreateSpec(cfg)
+
+	if len(spec.VmProfile) != 1 {
+		t.Fatalf("VmProfile len = %d, want 1", len(spec.VmProfile))
+	}
+	profile, ok := spec.VmProfile[0].(*types.VirtualMachineDefinedProfileSpec)
+	if !ok || profile.ProfileId != cfg.EncryptionPolicy {
+		t.Errorf("VmProfile = %#v, want policy %q", spec.VmProfile[0], cfg.EncryptionPolicy)
+	}
+	if spec.BootOptions != nil {
+		t.Error("BootOptions should not be set without SecureBoot")
+	}
+}
+
+func TestCreateSpec_hardwareVersionAndExtraConfig(t *testing.T) {
+	creator := NewCreator(context.Background())
+	cfg := &Config{
+		Name:            "vm",
+		Datastore:       "ds",
+		GuestOS:         "other5xLinux64Guest",
+		HardwareVersion: "vmx-19",
+		ExtraConfig:     map[string]string{"guestinfo.role": "db", "disk.EnableUUID": "TRUE"},
+	}
+
+	spec := creator.CreateSpec(cfg)
+
+	if spec.GuestId != "other5xLinux64Guest" {
+		t.Errorf("GuestId = %q, want other5xLinux64Guest", spec.GuestId)
+	}
+	if spec.Version != "vmx-19" {
+		t.Errorf("Version = %q, want vmx-19", spec.Version)
+	}
+	if len(spec.ExtraConfig) != 2 {
+		t.Fatalf("ExtraConfig len = %d, want 2", len(spec.ExtraConfig))
+	}
+	first := spec.ExtraConfig[0].GetOptionValue()
+	if first.Key != "disk.EnableUUID" || first.Value != "TRUE" {
+		t.Errorf("ExtraConfig[0] = %s=%v, want sorted disk.EnableUUID=TRUE", first.Key, first.Value)
+	}
+}
@@ -16,9 +16,14 @@ type CreatorInterface interface {
 	EnsureSCSIController(vm *object.VirtualMachine) (int32, error)
 	AddDisk(vm *object.VirtualMachine, datastore *object.Datastore, sizeGB int64, scsiKey int32) error
 	AddNetworkAdapter(vm *object.VirtualMachine, network object.NetworkReference) error
+	ConfigureSecurity(vm *object.VirtualMachine, cfg *Config) error      // Apply Secure Boot / vTPM / encryption policy
+	ReconfigureHardware(vm *object.VirtualMachine, cfg *Config) error    // Apply sizing/tuning to an existing (OVA) VM
+	ResizeDisk(vm *object.VirtualMachine, sizeGB int64) error            // Grow the first disk; never shrinks
 	SetMACAddress(vm *object.VirtualMachine, mac string) (string, error) // Apply static MAC; returns normalized MAC
-	GetMACAddress(vm *object.VirtualMachine) (string, error)            // Read assigned MAC from last NIC
+	GetMACAddress(vm *object.VirtualMachine) (string, error)             // Read assigned MAC from last NIC
 	PowerOn(vm *object.VirtualMachine) error
-	PowerOff(vm *object.VirtualMachine) error
+	PowerOff(vm *object.VirtualMachine) error      // Hard power-off
+	ShutdownGuest(vm *object.VirtualMachine) error // Tools shutdown, hard power-off on timeout
+	RebootGuest(vm *object.VirtualMachine) error   // Tools reboot, hard reset on timeout
 	Delete(vm *object.VirtualMachine) error
 }
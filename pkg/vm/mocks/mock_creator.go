@@ -46,6 +46,21 @@ func (m *CreatorInterface) AddNetworkAdapter(v *object.VirtualMachine, network o
 	return args.Error(0)
 }
 
+func (m *CreatorInterface) ConfigureSecurity(v *object.VirtualMachine, cfg *vm.Config) error {
+	args := m.Called(v, cfg)
+	return args.Error(0)
+}
+
+func (m *CreatorInterface) ReconfigureHardware(v *object.VirtualMachine, cfg *vm.Config) error {
+	args := m.Called(v, cfg)
+	return args.Error(0)
+}
+
+func (m *CreatorInterface) ResizeDisk(v *object.VirtualMachine, sizeGB int64) error {
+	args := m.Called(v, sizeGB)
+	return args.Error(0)
+}
+
 func (m *CreatorInterface) SetMACAddress(v *object.VirtualMachine, mac string) (string, error) {
 	args := m.Called(v, mac)
 	return args.String(0), args.Error(1)
@@ -66,6 +81,16 @@ func (m *CreatorInterface) PowerOff(v *object.VirtualMachine) error {
 	return args.Error(0)
 }
 
+func (m *CreatorInterface) ShutdownGuest(v *object.VirtualMachine) error {
+	args := m.Called(v)
+	return args.Error(0)
+}
+
+func (m *CreatorInterface) RebootGuest(v *object.VirtualMachine) error {
+	args := m.Called(v)
+	return args.Error(0)
+}
+
 func (m *CreatorInterface) Delete(v *object.VirtualMachine) error {
 	args := m.Called(v)
 	return args.Error(0)
@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/infrakit-io/vmware-vm-bootstrap/configs"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ShutdownGuest asks VMware Tools to shut down the guest OS and waits up to timeout
+// for the VM to reach poweredOff. If Tools is not running, the request fails, or the
+// timeout expires, the VM is hard powered off. timeout <= 0 uses the configured default.
+// Returns true if the guest shut down gracefully.
+func ShutdownGuest(ctx context.Context, vm *object.VirtualMachine, timeout time.Duration) (bool, error) {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get power state: %w", err)
+	}
+	if state != types.VirtualMachinePowerStatePoweredOn {
+		return true, nil
+	}
+	if timeout <= 0 {
+		timeout = configs.Defaults.Timeouts.GuestShutdown()
+	}
+
+	if toolsRunning, err := vm.IsToolsRunning(ctx); err == nil && toolsRunning {
+		if err := vm.ShutdownGuest(ctx); err == nil {
+			waitCtx, cancel := context.WithTimeout(ctx, timeout)
+			err = vm.WaitForPowerState(waitCtx, types.VirtualMachinePowerStatePoweredOff)
+			cancel()
+			if err == nil {
+				return true, nil
+			}
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+		}
+	}
+
+	// Guest did not stop in time (or Tools unavailable) - hard power-off.
+	state, err = vm.PowerState(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get power state: %w", err)
+	}
+	if state == types.VirtualMachinePowerStatePoweredOff {
+		return true, nil
+	}
+	if err := hardPowerOff(ctx, vm); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// RebootGuest asks VMware Tools to reboot the guest OS and waits up to timeout for the
+// guest to go down (Tools stops reporting running). If Tools is not running, the request
+// fails, or the timeout expires, the VM is hard reset. timeout <= 0 uses the configured
+// default. Returns true if the guest rebooted gracefully.
+func RebootGuest(ctx context.Context, vm *object.VirtualMachine, timeout time.Duration) (bool, error) {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get power state: %w", err)
+	}
+	if state != types.VirtualMachinePowerStatePoweredOn {
+		return false, fmt.Errorf("cannot reboot VM in power state %s", state)
+	}
+	if timeout <= 0 {
+		timeout = configs.Defaults.Timeouts.GuestShutdown()
+	}
+
+	if toolsRunning, err := vm.IsToolsRunning(ctx); err == nil && toolsRunning {
+		if err := vm.RebootGuest(ctx); err == nil {
+			waitCtx, cancel := context.WithTimeout(ctx, timeout)
+			err = waitForToolsStopped(waitCtx, vm)
+			cancel()
+			if err == nil {
+				return true, nil
+			}
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+		}
+	}
+
+	// Guest did not go down in time (or Tools unavailable) - hard reset.
+	task, err := vm.Reset(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset VM: %w", err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return false, fmt.Errorf("failed to reset VM: %w", err)
+	}
+	return false, nil
+}
+
+// waitForToolsStopped blocks until the guest's Tools running status leaves "guestToolsRunning".
+func waitForToolsStopped(ctx context.Context, vm *object.VirtualMachine) error {
+	pc := property.DefaultCollector(vm.Client())
+	return property.Wait(ctx, pc, vm.Reference(), []string{"guest.toolsRunningStatus"}, func(changes []types.PropertyChange) bool {
+		for _, c := range changes {
+			if s, ok := c.Val.(string); ok && s != string(types.VirtualMachineToolsRunningStatusGuestToolsRunning) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func hardPowerOff(ctx context.Context, vm *object.VirtualMachine) error {
+	task, err := vm.PowerOff(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to power off VM: %w", err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		// Guest may have finished shutting down between the check and the request.
+		if state, _ := vm.PowerState(ctx); state == types.VirtualMachinePowerStatePoweredOff {
+			return nil
+		}
+		return fmt.Errorf("failed to power off VM: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func setToolsStatus(env *simEnv, vm *object.VirtualMachine, status types.VirtualMachineToolsRunningStatus) {
+	simVM := env.model.Service.Context.Map.Get(vm.Reference()).(*simulator.VirtualMachine)
+	simVM.Guest.ToolsRunningStatus = string(status)
+}
+
+func TestShutdownGuest_Graceful(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-shutdown")
+	require.NoError(t, creator.PowerOn(vm))
+	setToolsStatus(env, vm, types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+
+	graceful, err := ShutdownGuest(env.ctx, vm, 10*time.Second)
+	require.NoError(t, err)
+	require.True(t, graceful)
+
+	state, err := vm.PowerState(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, types.VirtualMachinePowerStatePoweredOff, state)
+}
+
+func TestShutdownGuest_FallsBackWithoutTools(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-shutdown-hard")
+	require.NoError(t, creator.PowerOn(vm))
+	setToolsStatus(env, vm, types.VirtualMachineToolsRunningStatusGuestToolsNotRunning)
+
+	graceful, err := ShutdownGuest(env.ctx, vm, time.Second)
+	require.NoError(t, err)
+	require.False(t, graceful)
+
+	state, err := vm.PowerState(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, types.VirtualMachinePowerStatePoweredOff, state)
+}
+
+func TestShutdownGuest_AlreadyOff(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-shutdown-off")
+
+	require.NoError(t, creator.ShutdownGuest(vm))
+	require.NoError(t, creator.Delete(vm))
+}
+
+func TestRebootGuest(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-reboot")
+
+	_, err := RebootGuest(env.ctx, vm, time.Second)
+	require.Error(t, err)
+
+	require.NoError(t, creator.PowerOn(vm))
+	setToolsStatus(env, vm, types.VirtualMachineToolsRunningStatusGuestToolsNotRunning)
+
+	graceful, err := RebootGuest(env.ctx, vm, time.Second)
+	require.NoError(t, err)
+	require.False(t, graceful)
+	require.NoError(t, creator.RebootGuest(vm))
+
+	state, err := vm.PowerState(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, types.VirtualMachinePowerStatePoweredOn, state)
+}
+
+func TestRebootGuest_ContextCanceled(t *testing.T) {
+	env, cleanup := newSimEnv(t)
+	defer cleanup()
+
+	creator, vm, _ := createTestVM(t, env, "vm-reboot-cancel")
+	require.NoError(t, creator.PowerOn(vm))
+	setToolsStatus(env, vm, types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+
+	ctx, cancel := context.WithCancel(env.ctx)
+	cancel()
+	_, err := RebootGuest(ctx, vm, time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// LatencySensitivityLevels lists accepted values for Config.LatencySensitivity.
+var LatencySensitivityLevels = []string{"low", "normal", "medium", "high"}
+
+// ParseShares converts a shares setting into a SharesInfo.
+// Accepts "low", "normal", "high" or a positive custom share count (e.g., "2000").
+// An empty string returns nil (leave vCenter default).
+func ParseShares(s string) (*types.SharesInfo, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "":
+		return nil, nil
+	case string(types.SharesLevelLow), string(types.SharesLevelNormal), string(types.SharesLevelHigh):
+		return &types.SharesInfo{Level: types.SharesLevel(s)}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid shares %q (expected low, normal, high or a positive number)", s)
+	}
+	return &types.SharesInfo{Level: types.SharesLevelCustom, Shares: int32(n)}, nil
+}
+
+// ValidateLatencySensitivity returns an error if level is not empty and not a known level.
+func ValidateLatencySensitivity(level string) error {
+	if level == "" {
+		return nil
+	}
+	for _, l := range LatencySensitivityLevels {
+		if strings.EqualFold(level, l) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid latency sensitivity %q (expected %s)", level, strings.Join(LatencySensitivityLevels, "|"))
+}
+
+// applyResourceOptions sets cores per socket, hot-add, allocation and latency
+// sensitivity on spec. Invalid shares/latency values are ignored here; callers
+// validate them up front (see bootstrap.VMConfig.Validate).
+func applyResourceOptions(spec *types.VirtualMachineConfigSpec, cfg *Config) {
+	if cfg.CoresPerSocket > 0 {
+		spec.NumCoresPerSocket = types.NewInt32(cfg.CoresPerSocket)
+	}
+	if cfg.CPUHotAdd {
+		spec.CpuHotAddEnabled = types.NewBool(true)
+	}
+	if cfg.MemoryHotAdd {
+		spec.MemoryHotAddEnabled = types.NewBool(true)
+	}
+
+	cpuShares, _ := ParseShares(cfg.CPUShares)
+	spec.CpuAllocation = allocation(cfg.CPUReservationMHz, cfg.CPULimitMHz, cpuShares)
+	memShares, _ := ParseShares(cfg.MemoryShares)
+	spec.MemoryAllocation = allocation(cfg.MemoryReservationMB, cfg.MemoryLimitMB, memShares)
+
+	if cfg.LatencySensitivity != "" && ValidateLatencySensitivity(cfg.LatencySensitivity) == nil {
+		level := strings.ToLower(cfg.LatencySensitivity)
+		spec.LatencySensitivity = &types.LatencySensitivity{
+			Level: types.LatencySensitivitySensitivityLevel(level),
+		}
+		// High latency sensitivity requires a full memory reservation.
+		if level == "high" {
+			spec.MemoryReservationLockedToMax = types.NewBool(true)
+		}
+	}
+}
+
+// allocation builds a ResourceAllocationInfo, or nil when nothing is set.
+func allocation(reservation, limit int64, shares *types.SharesInfo) *types.ResourceAllocationInfo {
+	if reservation <= 0 && limit <= 0 && shares == nil {
+		return nil
+	}
+	info := &types.ResourceAllocationInfo{Shares: shares}
+	if reservation > 0 {
+		info.Reservation = types.NewInt64(reservation)
+	}
+	if limit > 0 {
+		info.Limit = types.NewInt64(limit)
+	}
+	return info
+}
@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestParseShares(t *testing.T) {
+	tests := []struct {
+		in      string
+		level   types.SharesLevel
+		shares  int32
+		wantNil bool
+		wantErr bool
+	}{
+		{in: "", wantNil: true},
+		{in: "high", level: types.SharesLevelHigh},
+		{in: "Normal", level: types.SharesLevelNormal},
+		{in: "2000", level: types.SharesLevelCustom, shares: 2000},
+		{in: "0", wantErr: true},
+		{in: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseShares(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseShares(%q) expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseShares(%q) unexpected error: %v", tt.in, err)
+			continue
+		}
+		if tt.wantNil {
+			if got != nil {
+				t.Errorf("ParseShares(%q) = %#v, want nil", tt.in, got)
+			}
+			continue
+		}
+		if got.Level != tt.level || got.Shares != tt.shares {
+			t.Errorf("ParseShares(%q) = %s/%d, want %s/%d", tt.in, got.Level, got.Shares, tt.level, tt.shares)
+		}
+	}
+}
+
+func TestValidateLatencySensitivity(t *testing.T) {
+	for _, ok := range []string{"", "low", "normal", "medium", "HIGH"} {
+		if err := ValidateLatencySensitivity(ok); err != nil {
+			t.Errorf("ValidateLatencySensitivity(%q) unexpected error: %v", ok, err)
+		}
+	}
+	if err := ValidateLatencySensitivity("extreme"); err == nil {
+		t.Error("expected error for unknown latency level")
+	}
+}
+
+func TestCreateSpec_resourceOptions(t *testing.T) {
+	creator := NewCreator(context.Background())
+	cfg := &Config{
+		Name:                "etcd-01",
+		CPUs:                4,
+		MemoryMB:            8192,
+		Datastore:           "ds",
+		CoresPerSocket:      2,
+		CPUHotAdd:           true,
+		MemoryHotAdd:        true,
+		CPUReservationMHz:   2000,
+		CPUShares:           "high",
+		MemoryReservationMB: 8192,
+		MemoryLimitMB:       8192,
+		MemoryShares:        "4000",
+		LatencySensitivity:  "high",
+	}
+
+	spec := creator.CreateSpec(cfg)
+
+	if spec.NumCoresPerSocket == nil || *spec.NumCoresPerSocket != 2 {
+		t.Errorf("NumCoresPerSocket = %v, want 2", spec.NumCoresPerSocket)
+	}
+	if spec.CpuHotAddEnabled == nil || !*spec.CpuHotAddEnabled {
+		t.Error("expected CpuHotAddEnabled=true")
+	}
+	if spec.MemoryHotAddEnabled == nil || !*spec.MemoryHotAddEnabled {
+		t.Error("expected MemoryHotAddEnabled=true")
+	}
+	if spec.CpuAllocation == nil || *spec.CpuAllocation.Reservation != 2000 || spec.CpuAllocation.Limit != nil {
+		t.Errorf("CpuAllocation = %#v, want reservation 2000 and no limit", spec.CpuAllocation)
+	}
+	if spec.CpuAllocation.Shares == nil || spec.CpuAllocation.Shares.Level != types.SharesLevelHigh {
+		t.Errorf("CPU shares = %#v, want high", spec.CpuAllocation.Shares)
+	}
+	if spec.MemoryAllocation == nil || *spec.MemoryAllocation.Reservation != 8192 || *spec.MemoryAllocation.Limit != 8192 {
+		t.Errorf("MemoryAllocation = %#v, want reservation/limit 8192", spec.MemoryAllocation)
+	}
+	if spec.MemoryAllocation.Shares.Level != types.SharesLevelCustom || spec.MemoryAllocation.Shares.Shares != 4000 {
+		t.Errorf("memory shares = %#v, want custom 4000", spec.MemoryAllocation.Shares)
+	}
+	if spec.LatencySensitivity == nil || spec.LatencySensitivity.Level != types.LatencySensitivitySensitivityLevelHigh {
+		t.Errorf("LatencySensitivity = %#v, want high", spec.LatencySensitivity)
+	}
+	if spec.MemoryReservationLockedToMax == nil || !*spec.MemoryReservationLockedToMax {
+		t.Error("expected MemoryReservationLockedToMax=true for high latency sensitivity")
+	}
+}
+
+func TestCreateSpec_noResourceOptions(t *testing.T) {
+	creator := NewCreator(context.Background())
+	spec := creator.CreateSpec(&Config{Name: "vm", CPUs: 2, MemoryMB: 2048, Datastore: "ds"})
+
+	if spec.NumCoresPerSocket != nil || spec.CpuAllocation != nil || spec.MemoryAllocation != nil ||
+		spec.LatencySensitivity != nil || spec.CpuHotAddEnabled != nil || spec.MemoryHotAddEnabled != nil {
+		t.Errorf("expected no resource options in spec, got %#v", spec)
+	}
+}
@@ -15,7 +15,6 @@ RESET="\033[0m"
 # --- Versions ---
 GO_VERSION="1.26.1"
 GOLANGCI_LINT_VERSION="latest"
-GOVC_VERSION="latest"
 SOPS_VERSION="latest"
 
 # --- Helpers ---
@@ -133,39 +132,9 @@ if ! command -v golangci-lint >/dev/null 2>&1; then
 fi
 
 # ─────────────────────────────────────────────────────────
-# 3. govc (VMware CLI) — binary download (go install doesn't work: replace directives)
+# 3. govulncheck
 # ─────────────────────────────────────────────────────────
-header "3. govc (VMware CLI)"
-
-if command -v govc >/dev/null 2>&1; then
-    success "govc already installed ($(govc version 2>&1))"
-else
-    info "Installing govc binary from GitHub releases..."
-
-    GOVC_URL="https://github.com/vmware/govmomi/releases/latest/download/govc_Linux_x86_64.tar.gz"
-    if [[ "$OS" == "macos" ]]; then
-        if [[ "$ARCH" == "arm64" ]]; then
-            GOVC_URL="https://github.com/vmware/govmomi/releases/latest/download/govc_Darwin_arm64.tar.gz"
-        else
-            GOVC_URL="https://github.com/vmware/govmomi/releases/latest/download/govc_Darwin_x86_64.tar.gz"
-        fi
-    elif [[ "$ARCH" == "arm64" ]]; then
-        GOVC_URL="https://github.com/vmware/govmomi/releases/latest/download/govc_Linux_arm64.tar.gz"
-    fi
-
-    TMPDIR_GOVC=$(mktemp -d)
-    curl -sfL "$GOVC_URL" | tar -xz -C "$TMPDIR_GOVC"
-    $SUDO mv "$TMPDIR_GOVC/govc" /usr/local/bin/govc
-    $SUDO chmod +x /usr/local/bin/govc
-    rm -rf "$TMPDIR_GOVC"
-
-    success "govc installed → /usr/local/bin/govc"
-fi
-
-# ─────────────────────────────────────────────────────────
-# 4. govulncheck
-# ─────────────────────────────────────────────────────────
-header "4. govulncheck"
+header "3. govulncheck"
 
 if command -v govulncheck >/dev/null 2>&1; then
     success "govulncheck already installed ($(govulncheck -version 2>&1 | head -1))"
@@ -192,9 +161,9 @@ if ! command -v govulncheck >/dev/null 2>&1; then
 fi
 
 # ─────────────────────────────────────────────────────────
-# 5. sops
+# 4. sops
 # ─────────────────────────────────────────────────────────
-header "5. sops"
+header "4. sops"
 
 if command -v sops >/dev/null 2>&1; then
     success "sops already installed ($(sops --version 2>&1 | head -1))"
@@ -224,41 +193,6 @@ else
     success "sops installed → /usr/local/bin/sops"
 fi
 
-# ─────────────────────────────────────────────────────────
-# 6. xorriso + genisoimage (system packages)
-# ─────────────────────────────────────────────────────────
-header "6. xorriso + genisoimage"
-
-install_pkg() {
-    local pkg=$1
-    if command -v "$pkg" >/dev/null 2>&1; then
-        success "$pkg already installed"
-        return
-    fi
-
-    info "Installing $pkg..."
-    case "$OS" in
-        debian)
-            $SUDO apt-get install -y -q "$pkg" >/dev/null 2>&1 && success "$pkg installed" || error "$pkg install failed"
-            ;;
-        rhel)
-            $SUDO yum install -y "$pkg" >/dev/null 2>&1 && success "$pkg installed" || error "$pkg install failed"
-            ;;
-        fedora)
-            $SUDO dnf install -y "$pkg" >/dev/null 2>&1 && success "$pkg installed" || error "$pkg install failed"
-            ;;
-        macos)
-            brew install "$pkg" >/dev/null 2>&1 && success "$pkg installed" || error "$pkg install failed (try: brew install $pkg)"
-            ;;
-        *)
-            warn "$pkg: unknown OS — install manually"
-            ;;
-    esac
-}
-
-install_pkg xorriso
-install_pkg genisoimage
-
 # ─────────────────────────────────────────────────────────
 # Done
 # ─────────────────────────────────────────────────────────